@@ -0,0 +1,98 @@
+// Command grantrole grants a role assignment to an existing user. Signing up
+// always creates a plain user, so this is how the first administrator is
+// made; after that, administrators grant roles through the API.
+//
+//	go run ./cmd/grantrole -email admin@example.org -role ADMIN
+//	go run ./cmd/grantrole -email steward@example.org -role DISTRICT_STEWARD -scope-level district -scope-number <number>
+//
+// It reads the same DB_* settings as the server, from the environment or a
+// .env file in the working directory.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+func main() {
+	email := flag.String("email", "", "email address of the user to grant the role to")
+	role := flag.String("role", "", "role to grant, such as ADMIN or DATA_EDITOR")
+	scopeLevel := flag.String("scope-level", "", "limit the role to a region or district")
+	scopeNumber := flag.String("scope-number", "", "number of the unit the role is limited to")
+	flag.Parse()
+
+	if *email == "" || *role == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// A missing .env is fine; the settings may come from the environment.
+	_ = godotenv.Load()
+
+	db, err := database.NewDatabase(&database.Config{
+		Host:     os.Getenv("DB_HOST"),
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+		DBName:   os.Getenv("DB_NAME"),
+		Port:     os.Getenv("DB_PORT"),
+		SSLMode:  os.Getenv("DB_SSLMODE"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	var user models.User
+	if err := db.DB.Where("email = ?", strings.ToLower(strings.TrimSpace(*email))).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Fatalf("No user with email %s; sign up first", *email)
+		}
+		log.Fatalf("Failed to find user: %v", err)
+	}
+
+	input := &models.RoleAssignmentInput{
+		UserNumber:  user.Number,
+		Role:        models.UserRole(*role),
+		ScopeLevel:  *scopeLevel,
+		ScopeNumber: *scopeNumber,
+	}
+	input.Prepare()
+	if err := input.Validate(); err != nil {
+		log.Fatalf("Invalid role assignment: %v", err)
+	}
+
+	roles := controllers.NewRoleController(db)
+	if input.ScopeNumber != "" {
+		exists, err := roles.UnitExists(input.ScopeLevel, input.ScopeNumber)
+		if err != nil {
+			log.Fatalf("Failed to check scope: %v", err)
+		}
+		if !exists {
+			log.Fatalf("No %s with number %s", input.ScopeLevel, input.ScopeNumber)
+		}
+	}
+
+	exists, err := roles.AssignmentExists(input)
+	if err != nil {
+		log.Fatalf("Failed to check existing assignments: %v", err)
+	}
+	if exists {
+		fmt.Printf("%s already has %s\n", user.Email, input.Role)
+		return
+	}
+
+	assignment, err := roles.CreateAssignment(input, "")
+	if err != nil {
+		log.Fatalf("Failed to grant role: %v", err)
+	}
+	fmt.Printf("Granted %s to %s (assignment %s)\n", assignment.Role, user.Email, assignment.Number)
+}
@@ -0,0 +1,109 @@
+// Command validate checks the administrative hierarchy against the data
+// quality rules and prints the report.
+//
+//	go run ./cmd/validate -level village,parish -format text -fail-on error
+//
+// It reads the same DB_* settings as the server, from the environment or a
+// .env file in the working directory. The exit status is 1 when the report
+// has issues at the -fail-on severity, so it can gate imports in CI.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"opendataug.org/database"
+	"opendataug.org/services"
+)
+
+func main() {
+	levels := flag.String("level", "", "comma-separated levels to check (default all)")
+	rules := flag.String("rule", "", "comma-separated rules to run (default all)")
+	format := flag.String("format", "json", "output format: json or text")
+	failOn := flag.String("fail-on", services.SeverityError, "exit with status 1 on issues at this severity: error, warning or none")
+	listRules := flag.Bool("list-rules", false, "print the available rules and exit")
+	flag.Parse()
+
+	if *listRules {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, rule := range services.QualityRules() {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", rule.Name, rule.Severity, rule.Description)
+		}
+		w.Flush()
+		return
+	}
+
+	if *format != "json" && *format != "text" {
+		log.Fatalf("Invalid -format %q", *format)
+	}
+	if *failOn != services.SeverityError && *failOn != services.SeverityWarning && *failOn != "none" {
+		log.Fatalf("Invalid -fail-on %q", *failOn)
+	}
+
+	// A missing .env is fine; the settings may come from the environment.
+	_ = godotenv.Load()
+
+	db, err := database.NewDatabase(&database.Config{
+		Host:     os.Getenv("DB_HOST"),
+		User:     os.Getenv("DB_USER"),
+		Password: os.Getenv("DB_PASSWORD"),
+		DBName:   os.Getenv("DB_NAME"),
+		Port:     os.Getenv("DB_PORT"),
+		SSLMode:  os.Getenv("DB_SSLMODE"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	report, err := services.NewQualityValidator(db.DB).Validate(services.QualityOptions{
+		Levels: splitList(*levels),
+		Rules:  splitList(*rules),
+	})
+	if err != nil {
+		log.Fatalf("Validation failed: %v", err)
+	}
+
+	if *format == "text" {
+		printText(report)
+	} else {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	}
+
+	if *failOn != "none" && report.HasIssues(*failOn) {
+		os.Exit(1)
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func printText(report *services.QualityReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%q\t%s\n", issue.Severity, issue.Rule, issue.Level, issue.Number, issue.Name, issue.Message)
+	}
+	w.Flush()
+
+	fmt.Println()
+	for _, level := range report.Levels {
+		fmt.Printf("scanned %d %s units\n", report.Scanned[level], level)
+	}
+	fmt.Printf("%d errors, %d warnings\n", report.Summary.Errors, report.Summary.Warnings)
+}
@@ -1,10 +1,7 @@
 package commons
 
 import (
-	"encoding/base64"
 	"fmt"
-	"log"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -70,24 +67,12 @@ func (s *AuthService) InvalidateSession(refreshToken string) error {
 }
 
 func ValidateToken(tokenString string, tokenType string) (jwt.MapClaims, error) {
-	publicKey := os.Getenv("ACCESS_TOKEN_PUBLIC_KEY")
-	decodedPublicKey, err := base64.StdEncoding.DecodeString(publicKey)
-	if err != nil {
-		return nil, fmt.Errorf("could not decode token public key: %w", err)
-	}
-
-	rsaPublicKey, err := jwt.ParseRSAPublicKeyFromPEM(decodedPublicKey)
-	if err != nil {
-		return nil, fmt.Errorf("validate: parse token public key: %w", err)
+	keys := DefaultKeyManager()
+	if keys == nil {
+		return nil, fmt.Errorf("signing keys are not loaded")
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return rsaPublicKey, nil
-	})
-
+	token, err := jwt.Parse(tokenString, keys.Keyfunc)
 	if err != nil {
 		return nil, err
 	}
@@ -121,17 +106,15 @@ func RefreshToken(refreshToken string) (*TokenDetails, error) {
 func CreateToken(userNumber string) (*TokenDetails, error) {
 	td := &TokenDetails{}
 	now := time.Now()
-	privateKey := os.Getenv("ACCESS_TOKEN_PRIVATE_KEY")
 
-	decodedPrivateKey, err := base64.StdEncoding.DecodeString(privateKey)
-	if err != nil {
-		log.Println(privateKey)
-		return nil, fmt.Errorf("invalid token")
+	keys := DefaultKeyManager()
+	if keys == nil {
+		return nil, fmt.Errorf("signing keys are not loaded")
 	}
 
-	rsaPrivateKey, err := jwt.ParseRSAPrivateKeyFromPEM(decodedPrivateKey)
+	kid, rsaPrivateKey, err := keys.SigningKey()
 	if err != nil {
-		return nil, fmt.Errorf("create: parse token private key: %w", err)
+		return nil, err
 	}
 
 	// Access token
@@ -141,6 +124,7 @@ func CreateToken(userNumber string) (*TokenDetails, error) {
 		"exp":         now.Add(time.Hour * 1).Unix(),
 	}
 	accessToken := jwt.NewWithClaims(jwt.SigningMethodRS256, accessClaims)
+	accessToken.Header["kid"] = kid
 	signedAccessToken, err := accessToken.SignedString(rsaPrivateKey)
 	if err != nil {
 		return nil, err
@@ -153,6 +137,7 @@ func CreateToken(userNumber string) (*TokenDetails, error) {
 		"exp":         now.Add(time.Hour * 24 * 7).Unix(),
 	}
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodRS256, refreshClaims)
+	refreshToken.Header["kid"] = kid
 	signedRefreshToken, err := refreshToken.SignedString(rsaPrivateKey)
 	if err != nil {
 		return nil, err
@@ -1,8 +1,11 @@
 package constants
 
 const (
-	RoleAdmin        = "ADMIN"
-	RoleUser         = "USER"
-	ENVIRONMENT_DEV  = "dev"
-	ENVIRONMENT_PROD = "prod"
+	RoleAdmin           = "ADMIN"
+	RoleUser            = "USER"
+	RoleDataEditor      = "DATA_EDITOR"
+	RoleReviewer        = "REVIEWER"
+	RoleDistrictSteward = "DISTRICT_STEWARD"
+	ENVIRONMENT_DEV     = "dev"
+	ENVIRONMENT_PROD    = "prod"
 )
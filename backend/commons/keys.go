@@ -0,0 +1,467 @@
+package commons
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+	"opendataug.org/models"
+)
+
+const (
+	signingAlgorithm  = "RS256"
+	signingKeyBits    = 2048
+	keyReloadInterval = time.Minute
+	// minKeyReloadGap stops tokens with made-up kids from forcing a database
+	// read on every request.
+	minKeyReloadGap = 10 * time.Second
+
+	// rotationLockKey serializes instances rotating the signing key.
+	rotationLockKey int64 = 7_310_411_301
+)
+
+var defaultKeyManager *KeyManager
+
+type KeyManagerConfig struct {
+	// PrivateKey and PublicKey are the base64 encoded PEM keys from the
+	// environment. They remain valid for verification so tokens issued before
+	// rotation was enabled keep working.
+	PrivateKey string
+	PublicKey  string
+	// Secret encrypts generated private keys at rest. Rotation is disabled
+	// when it is empty.
+	Secret string
+	// RotationInterval is how long a generated key signs new tokens before a
+	// successor replaces it. Zero disables rotation.
+	RotationInterval time.Duration
+	// VerificationGrace is how long a retired key still verifies tokens. It
+	// should cover the longest lived token we issue.
+	VerificationGrace time.Duration
+}
+
+type signingKey struct {
+	id         string
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+	createdAt  time.Time
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyManager holds the RSA keys used to sign and verify tokens. Keys are
+// parsed once at startup; generated keys are shared between instances through
+// the signing_keys table.
+type KeyManager struct {
+	db     *gorm.DB
+	config KeyManagerConfig
+	cipher cipher.AEAD
+
+	mu         sync.RWMutex
+	staticKey  *signingKey
+	active     *signingKey
+	keys       map[string]*signingKey
+	lastReload time.Time
+}
+
+// LoadKeyManager builds the key manager, rotates if a new key is due and
+// makes it the default used by the token helpers.
+func LoadKeyManager(db *gorm.DB, config KeyManagerConfig) (*KeyManager, error) {
+	m := &KeyManager{
+		db:     db,
+		config: config,
+		keys:   make(map[string]*signingKey),
+	}
+
+	if config.PrivateKey != "" && config.PublicKey != "" {
+		key, err := parseStaticKey(config.PrivateKey, config.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		m.staticKey = key
+	}
+
+	if m.rotationEnabled() {
+		aead, err := newKeyCipher(config.Secret)
+		if err != nil {
+			return nil, err
+		}
+		m.cipher = aead
+	}
+
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+
+	if err := m.RotateIfDue(); err != nil {
+		return nil, err
+	}
+
+	if m.active == nil {
+		return nil, errors.New("no signing key configured: set ACCESS_TOKEN_PRIVATE_KEY or enable key rotation")
+	}
+
+	defaultKeyManager = m
+	return m, nil
+}
+
+func DefaultKeyManager() *KeyManager {
+	return defaultKeyManager
+}
+
+func (m *KeyManager) rotationEnabled() bool {
+	return m.config.RotationInterval > 0 && m.config.Secret != ""
+}
+
+// Run periodically picks up keys created by other instances and rotates the
+// active key once it is older than the rotation interval, until stop is
+// closed. Keys already published stay valid, so stopping between rotations
+// loses nothing.
+func (m *KeyManager) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(keyReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Reload(); err != nil {
+				log.Printf("key manager: reload failed: %v", err)
+				continue
+			}
+			if err := m.RotateIfDue(); err != nil {
+				log.Printf("key manager: rotation failed: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (m *KeyManager) Reload() error {
+	keys := make(map[string]*signingKey)
+	if m.staticKey != nil {
+		keys[m.staticKey.id] = m.staticKey
+	}
+
+	active := m.staticKey
+
+	if m.cipher != nil {
+		var stored []models.SigningKey
+		if err := m.db.Where("expires_at > ?", time.Now()).Order("created_at ASC").Find(&stored).Error; err != nil {
+			return fmt.Errorf("load signing keys: %w", err)
+		}
+
+		for _, record := range stored {
+			key, err := m.decodeStoredKey(record)
+			if err != nil {
+				log.Printf("key manager: skipping key %s: %v", record.Number, err)
+				continue
+			}
+			keys[key.id] = key
+			active = key
+		}
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.active = active
+	m.lastReload = time.Now()
+	m.mu.Unlock()
+
+	return nil
+}
+
+// RotateIfDue generates a new signing key once the active one is older than
+// the rotation interval. Instances running side by side take turns, and
+// whichever goes second finds the fresh key and stores nothing.
+func (m *KeyManager) RotateIfDue() error {
+	if !m.rotationEnabled() {
+		return nil
+	}
+
+	m.mu.RLock()
+	active := m.active
+	m.mu.RUnlock()
+
+	if active != nil && active != m.staticKey && time.Since(active.createdAt) < m.config.RotationInterval {
+		return nil
+	}
+
+	return m.rotate(true)
+}
+
+// Rotate generates a new signing key. The previous key keeps verifying tokens
+// for the configured grace period.
+func (m *KeyManager) Rotate() error {
+	return m.rotate(false)
+}
+
+func (m *KeyManager) rotate(onlyIfDue bool) error {
+	if m.cipher == nil {
+		return errors.New("key rotation is not enabled")
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		return fmt.Errorf("generate signing key: %w", err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return fmt.Errorf("encode public key: %w", err)
+	}
+
+	encrypted, err := m.encrypt(x509.MarshalPKCS1PrivateKey(privateKey))
+	if err != nil {
+		return err
+	}
+
+	record := models.SigningKey{
+		Number:              keyThumbprint(&privateKey.PublicKey),
+		Algorithm:           signingAlgorithm,
+		PublicKey:           string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		EncryptedPrivateKey: encrypted,
+	}
+
+	rotated := false
+	err = m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", rotationLockKey).Error; err != nil {
+			return err
+		}
+
+		// Another instance may have rotated while this one was waiting.
+		now := time.Now().UTC()
+		if onlyIfDue {
+			var newest []models.SigningKey
+			if err := tx.Where("expires_at > ?", now).
+				Order("created_at DESC").
+				Limit(1).
+				Find(&newest).Error; err != nil {
+				return err
+			}
+			if len(newest) > 0 && now.Sub(newest[0].CreatedAt) < m.config.RotationInterval {
+				return nil
+			}
+		}
+
+		record.ExpiresAt = now.Add(m.config.RotationInterval + m.config.VerificationGrace)
+		if err := tx.Model(&models.SigningKey{}).
+			Where("expires_at > ?", now.Add(m.config.VerificationGrace)).
+			Update("expires_at", now.Add(m.config.VerificationGrace)).Error; err != nil {
+			return err
+		}
+		rotated = true
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return fmt.Errorf("store signing key: %w", err)
+	}
+
+	if rotated {
+		log.Printf("key manager: rotated signing key, new kid %s", record.Number)
+	}
+
+	return m.Reload()
+}
+
+// SigningKey returns the key new tokens should be signed with.
+func (m *KeyManager) SigningKey() (string, *rsa.PrivateKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.active == nil {
+		return "", nil, errors.New("no active signing key")
+	}
+	return m.active.id, m.active.privateKey, nil
+}
+
+// VerificationKey returns the public key for a kid. Tokens issued before keys
+// carried a kid are checked against the environment key.
+func (m *KeyManager) VerificationKey(kid string) (*rsa.PublicKey, error) {
+	if kid == "" {
+		if m.staticKey == nil {
+			return nil, errors.New("token is missing a key id")
+		}
+		return m.staticKey.publicKey, nil
+	}
+
+	m.mu.RLock()
+	key, ok := m.keys[kid]
+	stale := time.Since(m.lastReload) > minKeyReloadGap
+	m.mu.RUnlock()
+	if ok {
+		return key.publicKey, nil
+	}
+	if !stale || m.cipher == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	// Another instance may have rotated since our last reload.
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if key, ok := m.keys[kid]; ok {
+		return key.publicKey, nil
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// Keyfunc resolves the verification key for a parsed token.
+func (m *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	return m.VerificationKey(kid)
+}
+
+func (m *KeyManager) JWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(m.keys))}
+	for _, key := range m.keys {
+		set.Keys = append(set.Keys, toJWK(key.id, key.publicKey))
+	}
+
+	return set
+}
+
+func (m *KeyManager) decodeStoredKey(record models.SigningKey) (*signingKey, error) {
+	der, err := m.decrypt(record.EncryptedPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	return &signingKey{
+		id:         record.Number,
+		privateKey: privateKey,
+		publicKey:  &privateKey.PublicKey,
+		createdAt:  record.CreatedAt,
+	}, nil
+}
+
+func (m *KeyManager) encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, m.cipher.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+
+	sealed := m.cipher.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (m *KeyManager) decrypt(encoded string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode private key: %w", err)
+	}
+
+	nonceSize := m.cipher.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, errors.New("encrypted private key is too short")
+	}
+
+	plaintext, err := m.cipher.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt private key: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func newKeyCipher(secret string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func parseStaticKey(privateKey, publicKey string) (*signingKey, error) {
+	decodedPrivateKey, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode token private key: %w", err)
+	}
+
+	rsaPrivateKey, err := jwt.ParseRSAPrivateKeyFromPEM(decodedPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse token private key: %w", err)
+	}
+
+	decodedPublicKey, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode token public key: %w", err)
+	}
+
+	rsaPublicKey, err := jwt.ParseRSAPublicKeyFromPEM(decodedPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse token public key: %w", err)
+	}
+
+	return &signingKey{
+		id:         keyThumbprint(rsaPublicKey),
+		privateKey: rsaPrivateKey,
+		publicKey:  rsaPublicKey,
+	}, nil
+}
+
+// keyThumbprint derives a kid from the RFC 7638 JWK thumbprint.
+func keyThumbprint(key *rsa.PublicKey) string {
+	jwk := toJWK("", key)
+	canonical, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{E: jwk.E, Kty: jwk.Kty, N: jwk.N})
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func toJWK(kid string, key *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: signingAlgorithm,
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
@@ -0,0 +1,76 @@
+package commons
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"opendataug.org/database/dbtest"
+	"opendataug.org/models"
+)
+
+func TestRotateIfDueOncePerInterval(t *testing.T) {
+	db := dbtest.Open(t)
+	config := KeyManagerConfig{Secret: "test secret", RotationInterval: time.Hour, VerificationGrace: time.Hour}
+
+	count := func() int64 {
+		t.Helper()
+		var keys int64
+		if err := db.DB.Model(&models.SigningKey{}).Count(&keys).Error; err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+
+	// The second instance to start finds the key the first one made.
+	var managers [3]*KeyManager
+	for i := range managers {
+		manager, err := LoadKeyManager(db.DB, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		managers[i] = manager
+	}
+	if got := count(); got != 1 {
+		t.Fatalf("%d keys after starting, want 1", got)
+	}
+
+	// Once the key is due, instances rotating at the same time store one
+	// successor between them.
+	if err := db.DB.Model(&models.SigningKey{}).Where("1 = 1").Update("created_at", time.Now().Add(-2*time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, manager := range managers {
+		if err := manager.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(managers))
+	for i, manager := range managers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = manager.RotateIfDue()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := count(); got != 2 {
+		t.Errorf("%d keys after rotating, want 2", got)
+	}
+
+	kid, _, err := managers[0].SigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, manager := range managers[1:] {
+		if other, _, _ := manager.SigningKey(); other != kid {
+			t.Errorf("instances sign with %s and %s, want the same key", kid, other)
+		}
+	}
+}
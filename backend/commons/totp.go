@@ -0,0 +1,189 @@
+package commons
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew is how many steps either side of the current one are
+	// accepted, to tolerate clock drift on the user's device.
+	TOTPSkew       = 1
+	totpSecretSize = 20
+
+	RecoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random 160-bit secret in unpadded base32, the
+// format authenticator apps expect.
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return "", fmt.Errorf("generate totp secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPProvisioningURI builds the otpauth:// URI that authenticator apps
+// import, usually by scanning it as a QR code.
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPStep returns the RFC 6238 time step containing t.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+// TOTPCode computes the code for a time step as described in RFC 4226.
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", fmt.Errorf("decode totp secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulus := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulus), nil
+}
+
+// ValidateTOTP checks code against the steps around now. Steps at or before
+// lastStep are rejected so a code cannot be replayed. It returns the
+// matching step, which the caller stores as the new lastStep.
+func ValidateTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(now)
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns n single-use codes formatted as xxxxx-xxxxx.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		raw := make([]byte, 10)
+		if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+			return nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		for j := range raw {
+			raw[j] = alphabet[int(raw[j])%len(alphabet)]
+		}
+		codes = append(codes, string(raw[:5])+"-"+string(raw[5:]))
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode makes codes typed with different case or spacing
+// compare equal.
+func NormalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// mfaSecretKey encrypts TOTP secrets at rest. MFA_SECRET_KEY lets it be
+// rotated independently of JWT_SECRET.
+func mfaSecretKey() (string, error) {
+	if key := os.Getenv("MFA_SECRET_KEY"); key != "" {
+		return key, nil
+	}
+	if key := os.Getenv("JWT_SECRET"); key != "" {
+		return key, nil
+	}
+	return "", errors.New("MFA_SECRET_KEY or JWT_SECRET must be set to store two-factor secrets")
+}
+
+// EncryptTOTPSecret seals a TOTP secret with AES-GCM for storage.
+func EncryptTOTPSecret(secret string) (string, error) {
+	key, err := mfaSecretKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newKeyCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	return totpEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// DecryptTOTPSecret reverses EncryptTOTPSecret.
+func DecryptTOTPSecret(encoded string) (string, error) {
+	key, err := mfaSecretKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newKeyCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := totpEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decode totp secret: %w", err)
+	}
+	nonceSize := aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("encrypted totp secret is too short")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt totp secret: %w", err)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,83 @@
+package commons
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890" in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted an invalid secret")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := TOTPStep(now)
+	code := func(step int64) string {
+		c, err := TOTPCode(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfcSecret, code(step), 0, step, true},
+		{"previous step within skew", rfcSecret, code(step - 1), 0, step - 1, true},
+		{"next step within skew", rfcSecret, code(step + 1), 0, step + 1, true},
+		{"two steps behind", rfcSecret, code(step - 2), 0, 0, false},
+		{"two steps ahead", rfcSecret, code(step + 2), 0, 0, false},
+		{"spaces are ignored", rfcSecret, " " + code(step)[:3] + " " + code(step)[3:] + " ", 0, step, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code(step), 0, step, true},
+		{"wrong code", rfcSecret, "000000", 0, 0, false},
+		{"too short", rfcSecret, code(step)[:5], 0, 0, false},
+		{"too long", rfcSecret, code(step) + "0", 0, 0, false},
+		{"empty", rfcSecret, "", 0, 0, false},
+		{"replayed step", rfcSecret, code(step), step, 0, false},
+		{"earlier step after use", rfcSecret, code(step - 1), step - 1, 0, false},
+		{"later step after use", rfcSecret, code(step + 1), step, step + 1, true},
+		{"invalid secret", "not base32!", code(step), 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTP(tt.secret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("ValidateTOTP = (%d, %v), want (%d, %v)", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
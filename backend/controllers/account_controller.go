@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+// accountPurgeInterval is how often due deletions are carried out.
+const accountPurgeInterval = 15 * time.Minute
+
+var (
+	ErrOwnsOrganizations      = errors.New("transfer ownership of your organizations before deleting your account")
+	ErrDeletionTokenInvalid   = errors.New("deletion cancel link is invalid or has already been used")
+	ErrDeletionAlreadyPending = errors.New("account deletion is already scheduled")
+)
+
+// AccountController handles the owner's own account lifecycle: data export
+// and scheduled deletion.
+type AccountController struct {
+	db            *database.Database
+	apiKeys       *APIKeyController
+	organizations *OrganizationController
+	sessions      *SessionController
+}
+
+func NewAccountController(db *database.Database) *AccountController {
+	return &AccountController{
+		db:            db,
+		apiKeys:       NewAPIKeyController(db),
+		organizations: NewOrganizationController(db),
+		sessions:      NewSessionController(db),
+	}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *AccountController) WithContext(ctx context.Context) *AccountController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
+// Export collects the user's profile, API key metadata, sessions, usage
+// history, memberships and role assignments.
+func (c *AccountController) Export(user *models.User) (*models.AccountExport, error) {
+	export := &models.AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: models.AccountExportProfile{
+			Number:       user.Number,
+			Email:        user.Email,
+			FirstName:    user.FirstName,
+			LastName:     user.LastName,
+			Role:         user.Role,
+			Status:       user.Status,
+			Provider:     user.Provider,
+			MFAEnabledAt: user.MFAEnabledAt,
+			CreatedAt:    user.CreatedAt,
+			UpdatedAt:    user.UpdatedAt,
+		},
+		APIKeys:         []models.AccountExportAPIKey{},
+		Sessions:        []models.AccountExportSession{},
+		Usage:           []models.AccountExportUsage{},
+		Memberships:     []models.AccountExportMembership{},
+		RoleAssignments: []models.AccountExportRole{},
+	}
+
+	var keys []models.APIKey
+	if err := c.db.DB.Where("user_number = ?", user.Number).Order("created_at").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		export.APIKeys = append(export.APIKeys, models.AccountExportAPIKey{
+			Number:             key.Number,
+			Name:               key.Name,
+			OrganizationNumber: key.OrganizationNumber,
+			IsActive:           key.IsActive,
+			UsageCount:         key.UsageCount,
+			LastUsedAt:         key.LastUsedAt,
+			ExpiresAt:          key.ExpiresAt,
+			CreatedAt:          key.CreatedAt,
+		})
+	}
+
+	var sessions []models.Session
+	if err := c.db.DB.Where("user_number = ?", user.Number).Order("created_at").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, models.AccountExportSession{
+			Number:     session.Number,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			RevokedAt:  session.RevokedAt,
+		})
+	}
+
+	var usage []models.APIKeyUsage
+	if err := c.db.DB.Where("user_number = ?", user.Number).Order("requested_at").Find(&usage).Error; err != nil {
+		return nil, err
+	}
+	for _, entry := range usage {
+		export.Usage = append(export.Usage, models.AccountExportUsage{
+			APIKeyNumber: entry.APIKeyNumber,
+			Method:       entry.Method,
+			Endpoint:     entry.Endpoint,
+			StatusCode:   entry.StatusCode,
+			LatencyMs:    entry.LatencyMs,
+			BytesOut:     entry.BytesOut,
+			RequestedAt:  entry.RequestedAt,
+		})
+	}
+
+	var memberships []models.Membership
+	if err := c.db.DB.Where("user_number = ?", user.Number).Order("created_at").Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	for _, membership := range memberships {
+		export.Memberships = append(export.Memberships, models.AccountExportMembership{
+			OrganizationNumber: membership.OrganizationNumber,
+			Role:               membership.Role,
+			CreatedAt:          membership.CreatedAt,
+		})
+	}
+
+	var assignments []models.RoleAssignment
+	if err := c.db.DB.Where("user_number = ?", user.Number).Order("created_at").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	for _, assignment := range assignments {
+		export.RoleAssignments = append(export.RoleAssignments, models.AccountExportRole{
+			Role:        assignment.Role,
+			ScopeLevel:  assignment.ScopeLevel,
+			ScopeNumber: assignment.ScopeNumber,
+			CreatedAt:   assignment.CreatedAt,
+		})
+	}
+
+	return export, nil
+}
+
+func hashDeletionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ScheduleDeletion disables the account and schedules its removal after
+// grace. It returns the request and the plain cancel token to email; only
+// the token hash is stored.
+func (c *AccountController) ScheduleDeletion(user *models.User, grace time.Duration) (*models.AccountDeletion, string, error) {
+	if user.IsPendingDeletion() {
+		return nil, "", ErrDeletionAlreadyPending
+	}
+
+	owned, err := c.organizations.CountOwned(user.Number)
+	if err != nil {
+		return nil, "", err
+	}
+	if owned > 0 {
+		return nil, "", ErrOwnsOrganizations
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	deletion := &models.AccountDeletion{
+		Number:         commons.UUIDGenerator(),
+		UserNumber:     user.Number,
+		TokenHash:      hashDeletionToken(token),
+		Status:         models.AccountDeletionPending,
+		PreviousStatus: user.Status,
+		ScheduledFor:   time.Now().Add(grace),
+	}
+
+	err = c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(deletion).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("number = ?", user.Number).
+			Update("status", models.UserStatusPendingDeletion).Error; err != nil {
+			return err
+		}
+		return c.sessions.RevokeAllSessions(tx, user.Number)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	user.Status = models.UserStatusPendingDeletion
+	return deletion, token, nil
+}
+
+// CancelDeletion restores the account behind a cancel token.
+func (c *AccountController) CancelDeletion(token string) (*models.User, error) {
+	var user models.User
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		var deletion models.AccountDeletion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND status = ?", hashDeletionToken(token), models.AccountDeletionPending).
+			First(&deletion).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDeletionTokenInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&deletion).Updates(map[string]interface{}{
+			"status":      models.AccountDeletionCanceled,
+			"canceled_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("number = ?", deletion.UserNumber).First(&user).Error; err != nil {
+			return err
+		}
+		// An admin may have suspended the account meanwhile; leave that be.
+		if !user.IsPendingDeletion() {
+			return nil
+		}
+		user.Status = deletion.PreviousStatus
+		return tx.Model(&user).Update("status", deletion.PreviousStatus).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteAccount removes the user and their personal data in tx.
+// Organization keys the user created stay with the organization. The users
+// row itself is kept, since other records still point at it, but it is
+// stripped of anything personal before being soft deleted, and the
+// snapshots of it in the audit log are redacted.
+func (c *AccountController) DeleteAccount(tx *gorm.DB, user *models.User) error {
+	if err := tx.Where("user_number = ?", user.Number).Delete(&models.PasswordReset{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_number = ?", user.Number).Delete(&models.UserPassword{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_number = ?", user.Number).Delete(&models.EmailChange{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_number = ?", user.Number).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_number = ?", user.Number).Delete(&models.UserMFA{}).Error; err != nil {
+		return err
+	}
+	if err := c.apiKeys.DeletePersonalAPIKeys(tx, user.Number); err != nil {
+		return err
+	}
+	if err := c.organizations.RemoveUserMemberships(tx, user.Number); err != nil {
+		return err
+	}
+	if err := c.sessions.RevokeAllSessions(tx, user.Number); err != nil {
+		return err
+	}
+	if err := c.deleteUsageHistory(tx, user.Number); err != nil {
+		return err
+	}
+	if err := tx.Model(&models.User{}).Where("number = ?", user.Number).Updates(anonymizedUser(user.Number)).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("number = ?", user.Number).Delete(&models.User{}).Error; err != nil {
+		return err
+	}
+	return services.RedactAuditSnapshots(tx, "users", user.Number)
+}
+
+// deleteUsageHistory removes the request log of the user's personal keys.
+// Requests made with organization keys still count toward the
+// organization's quota, so those rows are kept but detached from the user.
+func (c *AccountController) deleteUsageHistory(tx *gorm.DB, userNumber string) error {
+	if err := tx.Where("user_number = ? AND organization_number = ''", userNumber).
+		Delete(&models.APIKeyUsage{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.APIKeyUsage{}).Where("user_number = ?", userNumber).
+		Update("user_number", "").Error
+}
+
+// anonymizedUser blanks every personal column of a deleted account. The
+// placeholder email is unique per account and frees the real address for a
+// new sign-up.
+func anonymizedUser(number string) map[string]interface{} {
+	return map[string]interface{}{
+		"email":             "deleted-" + number + "@deleted.invalid",
+		"name":              "Deleted user",
+		"first_name":        "Deleted",
+		"last_name":         "",
+		"suspension_reason": "",
+		"mfa_enabled_at":    nil,
+		"provider":          "",
+		"auth_number":       "",
+	}
+}
+
+// PurgeDue carries out every pending deletion whose grace period has
+// ended and returns how many accounts were removed. Accounts that became
+// organization owners meanwhile are skipped until ownership is handed over.
+func (c *AccountController) PurgeDue(now time.Time) (int, error) {
+	var due []models.AccountDeletion
+	if err := c.db.DB.Where("status = ? AND scheduled_for <= ?", models.AccountDeletionPending, now).
+		Order("scheduled_for").
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, deletion := range due {
+		owned, err := c.organizations.CountOwned(deletion.UserNumber)
+		if err != nil {
+			return purged, err
+		}
+		if owned > 0 {
+			log.Printf("account deletion %s postponed: user %s owns %d organizations", deletion.Number, deletion.UserNumber, owned)
+			continue
+		}
+
+		err = c.db.DB.Transaction(func(tx *gorm.DB) error {
+			var user models.User
+			if err := tx.Where("number = ?", deletion.UserNumber).First(&user).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			} else if err == nil {
+				if err := c.DeleteAccount(tx, &user); err != nil {
+					return err
+				}
+			}
+			return tx.Model(&deletion).Updates(map[string]interface{}{
+				"status":       models.AccountDeletionCompleted,
+				"completed_at": time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// RunDeletionPurger carries out due deletions every accountPurgeInterval
+// until stop is closed. Each account is erased in its own transaction, so
+// deletions left over when the process stops are still due on the next
+// start.
+func (c *AccountController) RunDeletionPurger(stop <-chan struct{}) {
+	ticker := time.NewTicker(accountPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := c.PurgeDue(time.Now())
+			if err != nil {
+				log.Printf("account purger: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("account purger: deleted %d accounts", purged)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
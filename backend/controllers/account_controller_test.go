@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	db := openTestDB(t)
+	accounts := NewAccountController(db)
+
+	user := &models.User{Number: commons.UUIDGenerator(), Email: "leaving@example.org", Name: "Jane Doe", FirstName: "Jane", LastName: "Doe", Role: models.RoleUser, Status: models.UserStatusActive, Provider: "google", AuthNumber: "google-subject"}
+	if err := db.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.Model(&models.User{}).Where("number = ?", user.Number).Update("last_name", "Doe-Okello").Error; err != nil {
+		t.Fatal(err)
+	}
+	usage := func(organization string) models.APIKeyUsage {
+		return models.APIKeyUsage{APIKeyNumber: commons.UUIDGenerator(), UserNumber: user.Number, OrganizationNumber: organization, Method: "GET", Endpoint: "/v1/regions", StatusCode: 200, RequestedAt: time.Now()}
+	}
+	organization := commons.UUIDGenerator()
+	usages := []models.APIKeyUsage{usage(""), usage(""), usage(organization)}
+	if err := db.DB.Create(&usages).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tx := db.DB.Begin()
+	if err := accounts.DeleteAccount(tx, user); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var stored models.User
+	if err := db.DB.Unscoped().Where("number = ?", user.Number).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !stored.DeletedAt.Valid {
+		t.Error("account is not deleted")
+	}
+	for column, value := range map[string]string{
+		"email":       stored.Email,
+		"name":        stored.Name,
+		"first_name":  stored.FirstName,
+		"last_name":   stored.LastName,
+		"auth_number": stored.AuthNumber,
+	} {
+		if strings.Contains(value, "leaving") || strings.Contains(value, "Jane") || strings.Contains(value, "Doe") || strings.Contains(value, "google") {
+			t.Errorf("%s still holds %q", column, value)
+		}
+	}
+
+	// The address is free for a new account.
+	again := &models.User{Number: commons.UUIDGenerator(), Email: "leaving@example.org", FirstName: "Jane", Role: models.RoleUser, Status: models.UserStatusActive}
+	if err := db.DB.Create(again).Error; err != nil {
+		t.Errorf("re-registering the email: %v", err)
+	}
+
+	var remaining []models.APIKeyUsage
+	if err := db.DB.Where("id IN ?", []uint64{usages[0].ID, usages[1].ID, usages[2].ID}).Find(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0].OrganizationNumber != organization || remaining[0].UserNumber != "" {
+		t.Errorf("usage left behind: %+v", remaining)
+	}
+
+	var entries []models.AuditLog
+	if err := db.DB.Where("entity_type = ? AND entity_number = ?", "users", user.Number).Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) < 3 {
+		t.Fatalf("%d audit entries for the user, want create, update and delete", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.RedactedAt == nil || entry.Before != nil || entry.After != nil {
+			t.Errorf("audit entry %d (%s) is not redacted", entry.Sequence, entry.Action)
+		}
+	}
+
+	verification, err := NewAuditController(db).VerifyChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verification.Valid {
+		t.Errorf("audit chain broken at %d after redaction", *verification.BrokenSequence)
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,6 +10,10 @@ import (
 	"opendataug.org/models"
 )
 
+// personalKeys restricts a query to keys owned by an individual rather than
+// an organization.
+const personalKeys = "(organization_number IS NULL OR organization_number = '')"
+
 type APIKeyController struct {
 	db *database.Database
 }
@@ -17,12 +22,19 @@ func NewAPIKeyController(db *database.Database) *APIKeyController {
 	return &APIKeyController{db: db}
 }
 
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *APIKeyController) WithContext(ctx context.Context) *APIKeyController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
 func (c *APIKeyController) CreateAPIKey(apiKey *models.APIKey) error {
 	return c.db.DB.Create(apiKey).Error
 }
 
 func (c *APIKeyController) DeleteAPIKey(userID string, keyID string) error {
-	result := c.db.DB.Where("user_number = ? AND number = ?", userID, keyID).Delete(&models.APIKey{})
+	result := c.db.DB.Where("user_number = ? AND number = ?", userID, keyID).Where(personalKeys).Delete(&models.APIKey{})
 	if result.RowsAffected == 0 {
 		return gorm.ErrRecordNotFound
 	}
@@ -31,10 +43,16 @@ func (c *APIKeyController) DeleteAPIKey(userID string, keyID string) error {
 
 func (c *APIKeyController) GetAPIKeys(userID string) ([]models.APIKey, error) {
 	var keys []models.APIKey
-	err := c.db.DB.Where("user_number = ?", userID).Order("created_at DESC").Find(&keys).Error
+	err := c.db.DB.Where("user_number = ?", userID).Where(personalKeys).Order("created_at DESC").Find(&keys).Error
 	return keys, err
 }
 
+// DeletePersonalAPIKeys removes the keys owned by userNumber. Keys the user
+// created on behalf of an organization are left in place.
+func (c *APIKeyController) DeletePersonalAPIKeys(tx *gorm.DB, userNumber string) error {
+	return tx.Where("user_number = ?", userNumber).Where(personalKeys).Delete(&models.APIKey{}).Error
+}
+
 func (c *APIKeyController) GetAPIKeyByNumber(key string) (*models.APIKey, error) {
 	var apiKey models.APIKey
 	err := c.db.DB.Where("number = ?", key).First(&apiKey).Error
@@ -62,7 +80,7 @@ func (c *APIKeyController) UpdateAPIKeyUsage(apiKeyID string) error {
 
 func (c *APIKeyController) APIKeyNameExists(userNumber string, name string) (bool, error) {
 	var count int64
-	result := c.db.DB.Model(&models.APIKey{}).Where("user_number = ? AND name = ?", userNumber, name).Count(&count)
+	result := c.db.DB.Model(&models.APIKey{}).Where("user_number = ? AND name = ?", userNumber, name).Where(personalKeys).Count(&count)
 
 	if result.Error != nil {
 		return false, result.Error
@@ -70,3 +88,14 @@ func (c *APIKeyController) APIKeyNameExists(userNumber string, name string) (boo
 
 	return count > 0, nil
 }
+
+func (c *APIKeyController) GetAPIKeyUsage(keyNumber string, from, to time.Time, granularity string) ([]models.APIKeyUsagePoint, error) {
+	points := make([]models.APIKeyUsagePoint, 0)
+	err := c.db.DB.Model(&models.APIKeyUsage{}).
+		Select("date_trunc(?, requested_at) AS bucket, endpoint, status_code, COUNT(*) AS count", granularity).
+		Where("api_key_number = ? AND requested_at >= ? AND requested_at < ?", keyNumber, from, to).
+		Group("bucket, endpoint, status_code").
+		Order("bucket ASC, endpoint ASC, status_code ASC").
+		Scan(&points).Error
+	return points, err
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"time"
+
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+const auditVerifyBatchSize = 1000
+
+type AuditController struct {
+	db *database.Database
+}
+
+func NewAuditController(db *database.Database) *AuditController {
+	return &AuditController{db: db}
+}
+
+type AuditFilter struct {
+	ActorNumber  string
+	Action       string
+	EntityType   string
+	EntityNumber string
+	RequestID    string
+	From         *time.Time
+	To           *time.Time
+}
+
+func (c *AuditController) ListAuditLogs(filter AuditFilter, pagination commons.PaginationParams) ([]models.AuditLog, int64, error) {
+	query := c.db.DB.Model(&models.AuditLog{})
+
+	if filter.ActorNumber != "" {
+		query = query.Where("actor_number = ?", filter.ActorNumber)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityNumber != "" {
+		query = query.Where("entity_number = ?", filter.EntityNumber)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.AuditLog
+	err := query.Order("sequence DESC").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&entries).Error
+	return entries, total, err
+}
+
+// VerifyChain walks the log in sequence order and reports the first entry
+// whose hash, predecessor link, sequence number or snapshots do not line
+// up.
+func (c *AuditController) VerifyChain() (*models.AuditVerification, error) {
+	result := &models.AuditVerification{Valid: true}
+
+	var (
+		prevHash string
+		expected int64 = 1
+	)
+
+	for {
+		var entries []models.AuditLog
+		if err := c.db.DB.Where("sequence >= ?", expected).
+			Order("sequence ASC").
+			Limit(auditVerifyBatchSize).
+			Find(&entries).Error; err != nil {
+			return nil, err
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			if entry.Sequence != expected || entry.PrevHash != prevHash || entry.ComputeHash() != entry.Hash || !entry.SnapshotsIntact() {
+				broken := expected
+				result.Valid = false
+				result.BrokenSequence = &broken
+				return result, nil
+			}
+			prevHash = entry.Hash
+			expected++
+			result.Checked++
+		}
+
+		if len(entries) < auditVerifyBatchSize {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"sort"
+	"time"
+
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+const (
+	DefaultChangeFeedLimit = 100
+	MaxChangeFeedLimit     = 1000
+)
+
+// ChangeFeedController serves the unit change feed. Positions in the feed
+// are audit log sequence numbers, which only ever increase and are assigned
+// in commit order, so a client that resumes from the last sequence it saw
+// never misses a change.
+type ChangeFeedController struct {
+	db *database.Database
+}
+
+func NewChangeFeedController(db *database.Database) *ChangeFeedController {
+	return &ChangeFeedController{db: db}
+}
+
+// levelsByTable maps unit table names back to their levels.
+func levelsByTable() map[string]string {
+	tables := make(map[string]string, len(models.Levels))
+	for level, meta := range models.Levels {
+		tables[meta.Table] = level
+	}
+	return tables
+}
+
+// SequenceAt returns the feed position just before at, so reading from it
+// returns every change made at or after that time.
+func (c *ChangeFeedController) SequenceAt(at time.Time) (int64, error) {
+	var sequence int64
+	err := c.db.DB.Model(&models.AuditLog{}).
+		Select("COALESCE(MAX(sequence), 0)").
+		Where("created_at < ?", at).
+		Scan(&sequence).Error
+	return sequence, err
+}
+
+// Latest returns the newest position in the feed.
+func (c *ChangeFeedController) Latest() (int64, error) {
+	var sequence int64
+	err := c.db.DB.Model(&models.AuditLog{}).Select("COALESCE(MAX(sequence), 0)").Scan(&sequence).Error
+	return sequence, err
+}
+
+// Changes returns units changed after since, at most limit audit entries at
+// a time. A unit changed several times within a page appears once, at its
+// last sequence, with its current state.
+func (c *ChangeFeedController) Changes(since int64, limit int) (*models.ChangeFeedResponse, error) {
+	// Fix the high-water mark before reading the page. Sequences commit in
+	// order, so every entry up to it is already visible, and one committed
+	// after it is left for the next call rather than skipped by Next.
+	latest, err := c.Latest()
+	if err != nil {
+		return nil, err
+	}
+	return c.changesUpTo(since, latest, limit)
+}
+
+// changesUpTo is Changes bounded to entries at or below latest.
+func (c *ChangeFeedController) changesUpTo(since, latest int64, limit int) (*models.ChangeFeedResponse, error) {
+	tables := levelsByTable()
+	names := make([]string, 0, len(tables))
+	for table := range tables {
+		names = append(names, table)
+	}
+
+	var entries []models.AuditLog
+	if err := c.db.DB.Select("sequence", "entity_type", "entity_number", "created_at").
+		Where("sequence > ? AND sequence <= ? AND entity_type IN ?", since, latest, names).
+		Order("sequence").
+		Limit(limit + 1).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	response := &models.ChangeFeedResponse{
+		Since:   since,
+		Next:    since,
+		Changes: []models.ChangeFeedEntry{},
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		response.HasMore = true
+	}
+	if len(entries) == 0 {
+		// Skip past entries for other tables so idle polls stay cheap.
+		response.Next = max(since, latest)
+		return response, nil
+	}
+	response.Next = entries[len(entries)-1].Sequence
+
+	type key struct{ table, number string }
+	last := map[key]models.AuditLog{}
+	numbers := map[string][]string{}
+	for _, entry := range entries {
+		k := key{entry.EntityType, entry.EntityNumber}
+		if _, seen := last[k]; !seen {
+			numbers[entry.EntityType] = append(numbers[entry.EntityType], entry.EntityNumber)
+		}
+		last[k] = entry
+	}
+
+	for table, tableNumbers := range numbers {
+		level := tables[table]
+		meta := models.Levels[level]
+
+		columns := "number, name, deleted_at"
+		if meta.ParentColumn != "" {
+			columns += ", " + meta.ParentColumn + " AS parent_number"
+		}
+		if level == models.LevelDistrict {
+			columns += ", town_status"
+		}
+
+		var rows []struct {
+			Number       string
+			Name         string
+			ParentNumber string
+			TownStatus   bool
+			DeletedAt    *time.Time
+		}
+		if err := c.db.DB.Table(table).Select(columns).Where("number IN ?", tableNumbers).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		current := make(map[string]int, len(rows))
+		for i, row := range rows {
+			current[row.Number] = i
+		}
+
+		for _, number := range tableNumbers {
+			entry := last[key{table, number}]
+			change := models.ChangeFeedEntry{
+				Sequence:  entry.Sequence,
+				Op:        models.ChangeOpTombstone,
+				Level:     level,
+				Number:    number,
+				ChangedAt: entry.CreatedAt,
+			}
+			if i, ok := current[number]; ok {
+				change.ParentNumber = rows[i].ParentNumber
+				if rows[i].DeletedAt == nil {
+					change.Op = models.ChangeOpUpsert
+					change.Name = rows[i].Name
+					change.TownStatus = rows[i].TownStatus
+				}
+			}
+			response.Changes = append(response.Changes, change)
+		}
+	}
+
+	sort.Slice(response.Changes, func(i, j int) bool {
+		return response.Changes[i].Sequence < response.Changes[j].Sequence
+	})
+	return response, nil
+}
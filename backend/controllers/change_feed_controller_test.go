@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+func TestChanges(t *testing.T) {
+	db := openTestDB(t)
+	feed := NewChangeFeedController(db)
+	units := NewUnitController()
+
+	start, err := feed.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	region := createUnit(t, db, models.LevelRegion, "Northern", "")
+	district := createUnit(t, db, models.LevelDistrict, "Gulu", region)
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return units.UpdateUnit(tx, models.LevelDistrict, district, UnitInput{Name: "Gulu City"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	doomed := createUnit(t, db, models.LevelDistrict, "Omoro", region)
+	deleteUnit(t, db, models.LevelDistrict, doomed, time.Now())
+
+	// Writes to other audited tables are skipped over.
+	user := models.User{Number: commons.UUIDGenerator(), Email: "feed@example.org", FirstName: "Feed", Role: models.RoleUser, Status: models.UserStatusActive}
+	if err := db.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	latest, err := feed.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := feed.Changes(start, MaxChangeFeedLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.HasMore {
+		t.Error("single page reports more")
+	}
+	want := []struct{ number, op, name string }{
+		{region, models.ChangeOpUpsert, "Northern"},
+		{district, models.ChangeOpUpsert, "Gulu City"},
+		{doomed, models.ChangeOpTombstone, ""},
+	}
+	if len(page.Changes) != len(want) {
+		t.Fatalf("changes = %+v, want %d", page.Changes, len(want))
+	}
+	for i, w := range want {
+		got := page.Changes[i]
+		if got.Number != w.number || got.Op != w.op || got.Name != w.name {
+			t.Errorf("change %d = %+v, want %s %s %q", i, got, w.number, w.op, w.name)
+		}
+		if i > 0 && got.Sequence <= page.Changes[i-1].Sequence {
+			t.Errorf("change %d out of order", i)
+		}
+	}
+	if page.Changes[1].ParentNumber != region {
+		t.Errorf("district parent = %q, want %q", page.Changes[1].ParentNumber, region)
+	}
+
+	// Paging one audit entry at a time visits every unit and ends up at
+	// the same place.
+	seen := map[string]bool{}
+	since := start
+	for {
+		page, err := feed.Changes(since, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, change := range page.Changes {
+			seen[change.Number] = true
+		}
+		if page.Next < since {
+			t.Fatalf("cursor went back from %d to %d", since, page.Next)
+		}
+		since = page.Next
+		if !page.HasMore {
+			break
+		}
+	}
+	for _, w := range want {
+		if !seen[w.number] {
+			t.Errorf("paging missed %s", w.number)
+		}
+	}
+
+	idle, err := feed.Changes(since, DefaultChangeFeedLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idle.Changes) != 0 || idle.Next != latest {
+		t.Errorf("idle poll = %d changes, next %d, want none and %d", len(idle.Changes), idle.Next, latest)
+	}
+}
+
+// A change that commits after the high-water mark is read must not be
+// skipped by the cursor.
+func TestChangesDoesNotSkipLateCommits(t *testing.T) {
+	db := openTestDB(t)
+	feed := NewChangeFeedController(db)
+
+	createUnit(t, db, models.LevelRegion, "Northern", "")
+	since, err := feed.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	late := createUnit(t, db, models.LevelRegion, "Western", "")
+
+	// The page is bounded by the mark read before the write.
+	page, err := feed.changesUpTo(since, since, DefaultChangeFeedLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Changes) != 0 || page.Next != since {
+		t.Fatalf("page = %d changes, next %d; want none and the cursor left at %d", len(page.Changes), page.Next, since)
+	}
+
+	page, err = feed.Changes(page.Next, DefaultChangeFeedLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Changes) != 1 || page.Changes[0].Number != late {
+		t.Errorf("next page = %+v, want the late region", page.Changes)
+	}
+}
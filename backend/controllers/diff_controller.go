@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+// DiffLive names the live hierarchy as a side of a diff.
+const DiffLive = "live"
+
+var ErrDiffPointInvalid = errors.New(`must be a release name, a date or "live"`)
+
+type DiffController struct {
+	db       *database.Database
+	releases *ReleaseController
+}
+
+func NewDiffController(db *database.Database) *DiffController {
+	return &DiffController{
+		db:       db,
+		releases: NewReleaseController(db),
+	}
+}
+
+// diffUnit is the part of a unit a diff compares.
+type diffUnit struct {
+	Name         string
+	ParentNumber string
+}
+
+type diffSource struct {
+	point         models.DiffPoint
+	releaseNumber string
+	live          bool
+}
+
+// resolvePoint reads value as a release name first, then as a date, so a
+// release called "2026-10-01" wins over the date.
+func (c *DiffController) resolvePoint(value string) (*diffSource, error) {
+	if value == "" || value == DiffLive {
+		return &diffSource{point: models.DiffPoint{At: time.Now().UTC()}, live: true}, nil
+	}
+
+	release, err := c.releases.Find(value)
+	if err == nil {
+		return &diffSource{
+			point:         models.DiffPoint{Release: release.Name, At: release.CreatedAt.UTC()},
+			releaseNumber: release.Number,
+		}, nil
+	}
+	if !errors.Is(err, ErrReleaseNotFound) {
+		return nil, err
+	}
+
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return &diffSource{point: models.DiffPoint{At: at.UTC()}}, nil
+	}
+	if at, err := time.Parse("2006-01-02", value); err == nil {
+		return &diffSource{point: models.DiffPoint{At: at}}, nil
+	}
+	return nil, ErrDiffPointInvalid
+}
+
+func (c *DiffController) state(source *diffSource, level string) (map[string]diffUnit, error) {
+	if source.releaseNumber != "" {
+		return c.releaseState(source.releaseNumber, level)
+	}
+	return c.stateAt(source.point.At, level, source.live)
+}
+
+func (c *DiffController) releaseState(releaseNumber, level string) (map[string]diffUnit, error) {
+	var rows []models.ReleaseUnit
+	if err := c.db.DB.Where("release_number = ? AND level = ?", releaseNumber, level).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	state := make(map[string]diffUnit, len(rows))
+	for _, row := range rows {
+		state[row.UnitNumber] = diffUnit{Name: row.Name, ParentNumber: row.ParentNumber}
+	}
+	return state, nil
+}
+
+// stateAt rebuilds level as it stood at at. Units count if they were
+// created by then and not yet deleted; names, parents and deletions changed
+// since are rolled back using the audit trail.
+func (c *DiffController) stateAt(at time.Time, level string, live bool) (map[string]diffUnit, error) {
+	meta := models.Levels[level]
+
+	columns := "number, name, created_at, deleted_at"
+	if meta.ParentColumn != "" {
+		columns += ", " + meta.ParentColumn + " AS parent_number"
+	}
+
+	var rows []struct {
+		Number       string
+		Name         string
+		ParentNumber string
+		CreatedAt    time.Time
+		DeletedAt    *time.Time
+	}
+	query := c.db.DB.Table(meta.Table).Select(columns)
+	if live {
+		query = query.Where("deleted_at IS NULL")
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byNumber := make(map[string]int, len(rows))
+	for i, row := range rows {
+		byNumber[row.Number] = i
+	}
+
+	if !live {
+		var entries []models.AuditLog
+		if err := c.db.DB.Select("entity_number", "before").
+			Where("entity_type = ? AND action = ? AND created_at > ?", meta.Table, models.AuditActionUpdate, at).
+			Order("sequence DESC").
+			Find(&entries).Error; err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			i, ok := byNumber[entry.EntityNumber]
+			if !ok || entry.Before == nil {
+				continue
+			}
+			var before map[string]interface{}
+			if err := json.Unmarshal([]byte(*entry.Before), &before); err != nil {
+				continue
+			}
+
+			if name, ok := before["name"].(string); ok {
+				rows[i].Name = name
+			}
+			if meta.ParentColumn != "" {
+				if parent, ok := before[meta.ParentColumn].(string); ok {
+					rows[i].ParentNumber = parent
+				}
+			}
+			if deletedAt, ok := before["deleted_at"]; ok {
+				rows[i].DeletedAt = nil
+				if value, ok := deletedAt.(string); ok {
+					if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+						rows[i].DeletedAt = &parsed
+					}
+				}
+			}
+		}
+	}
+
+	state := make(map[string]diffUnit, len(rows))
+	for _, row := range rows {
+		if !live && (row.CreatedAt.After(at) || (row.DeletedAt != nil && !row.DeletedAt.After(at))) {
+			continue
+		}
+		state[row.Number] = diffUnit{Name: row.Name, ParentNumber: row.ParentNumber}
+	}
+	return state, nil
+}
+
+// auditWarning notes when a date is older than the audit trail, since
+// renames and moves made before the trail began cannot be rolled back.
+func (c *DiffController) auditWarning(sources ...*diffSource) ([]string, error) {
+	var earliest *time.Time
+	if err := c.db.DB.Model(&models.AuditLog{}).Select("MIN(created_at)").Scan(&earliest).Error; err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for _, source := range sources {
+		if source.releaseNumber != "" || source.live {
+			continue
+		}
+		if earliest == nil || source.point.At.Before(*earliest) {
+			warnings = append(warnings, "history before "+source.point.At.Format(time.RFC3339)+
+				" predates the audit trail; renames and moves made before the trail began are not reflected")
+		}
+	}
+	return warnings, nil
+}
+
+// Diff compares the hierarchy at from and to, each a release name, a date
+// or "live", over levels.
+func (c *DiffController) Diff(from, to string, levels []string) (*models.DiffResponse, error) {
+	fromSource, err := c.resolvePoint(from)
+	if err != nil {
+		return nil, err
+	}
+	toSource, err := c.resolvePoint(to)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := c.auditWarning(fromSource, toSource)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.DiffResponse{
+		From:       fromSource.point,
+		To:         toSource.point,
+		Levels:     levels,
+		Added:      []models.DiffChange{},
+		Removed:    []models.DiffChange{},
+		Renamed:    []models.DiffChange{},
+		Reparented: []models.DiffChange{},
+		Warnings:   warnings,
+	}
+
+	for _, level := range levels {
+		before, err := c.state(fromSource, level)
+		if err != nil {
+			return nil, err
+		}
+		after, err := c.state(toSource, level)
+		if err != nil {
+			return nil, err
+		}
+		compareStates(response, level, before, after)
+	}
+
+	response.Summary = models.DiffSummary{
+		Added:      len(response.Added),
+		Removed:    len(response.Removed),
+		Renamed:    len(response.Renamed),
+		Reparented: len(response.Reparented),
+	}
+	return response, nil
+}
+
+func compareStates(response *models.DiffResponse, level string, before, after map[string]diffUnit) {
+	numbers := make([]string, 0, len(after))
+	for number := range after {
+		numbers = append(numbers, number)
+	}
+	for number := range before {
+		if _, ok := after[number]; !ok {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Strings(numbers)
+
+	for _, number := range numbers {
+		old, existed := before[number]
+		current, exists := after[number]
+
+		switch {
+		case !existed:
+			response.Added = append(response.Added, models.DiffChange{
+				Change: models.DiffAdded, Level: level, Number: number,
+				Name: current.Name, ParentNumber: current.ParentNumber,
+			})
+		case !exists:
+			response.Removed = append(response.Removed, models.DiffChange{
+				Change: models.DiffRemoved, Level: level, Number: number,
+				Name: old.Name, ParentNumber: old.ParentNumber,
+			})
+		default:
+			if old.Name != current.Name {
+				response.Renamed = append(response.Renamed, models.DiffChange{
+					Change: models.DiffRenamed, Level: level, Number: number,
+					Name: current.Name, OldName: old.Name, ParentNumber: current.ParentNumber,
+				})
+			}
+			if old.ParentNumber != current.ParentNumber {
+				response.Reparented = append(response.Reparented, models.DiffChange{
+					Change: models.DiffReparented, Level: level, Number: number,
+					Name: current.Name, ParentNumber: current.ParentNumber, OldParentNumber: old.ParentNumber,
+				})
+			}
+		}
+	}
+}
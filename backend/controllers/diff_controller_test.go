@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"opendataug.org/models"
+)
+
+func TestCompareStates(t *testing.T) {
+	const level = models.LevelDistrict
+
+	tests := []struct {
+		name   string
+		before map[string]diffUnit
+		after  map[string]diffUnit
+		want   models.DiffResponse
+	}{
+		{
+			name:   "unchanged",
+			before: map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r1"}},
+			after:  map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r1"}},
+		},
+		{
+			name:   "both empty",
+			before: map[string]diffUnit{},
+			after:  map[string]diffUnit{},
+		},
+		{
+			name:   "added",
+			before: map[string]diffUnit{},
+			after:  map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r1"}},
+			want: models.DiffResponse{Added: []models.DiffChange{
+				{Change: models.DiffAdded, Level: level, Number: "d1", Name: "Gulu", ParentNumber: "r1"},
+			}},
+		},
+		{
+			name:   "removed",
+			before: map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r1"}},
+			after:  map[string]diffUnit{},
+			want: models.DiffResponse{Removed: []models.DiffChange{
+				{Change: models.DiffRemoved, Level: level, Number: "d1", Name: "Gulu", ParentNumber: "r1"},
+			}},
+		},
+		{
+			name:   "renamed",
+			before: map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r1"}},
+			after:  map[string]diffUnit{"d1": {Name: "Gulu City", ParentNumber: "r1"}},
+			want: models.DiffResponse{Renamed: []models.DiffChange{
+				{Change: models.DiffRenamed, Level: level, Number: "d1", Name: "Gulu City", OldName: "Gulu", ParentNumber: "r1"},
+			}},
+		},
+		{
+			name:   "reparented",
+			before: map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r1"}},
+			after:  map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r2"}},
+			want: models.DiffResponse{Reparented: []models.DiffChange{
+				{Change: models.DiffReparented, Level: level, Number: "d1", Name: "Gulu", ParentNumber: "r2", OldParentNumber: "r1"},
+			}},
+		},
+		{
+			name:   "renamed and reparented",
+			before: map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r1"}},
+			after:  map[string]diffUnit{"d1": {Name: "Gulu City", ParentNumber: "r2"}},
+			want: models.DiffResponse{
+				Renamed: []models.DiffChange{
+					{Change: models.DiffRenamed, Level: level, Number: "d1", Name: "Gulu City", OldName: "Gulu", ParentNumber: "r2"},
+				},
+				Reparented: []models.DiffChange{
+					{Change: models.DiffReparented, Level: level, Number: "d1", Name: "Gulu City", ParentNumber: "r2", OldParentNumber: "r1"},
+				},
+			},
+		},
+		{
+			// A unit whose number changes is a removal plus an addition,
+			// even under the same name.
+			name:   "renumbered",
+			before: map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r1"}},
+			after:  map[string]diffUnit{"d2": {Name: "Gulu", ParentNumber: "r1"}},
+			want: models.DiffResponse{
+				Added: []models.DiffChange{
+					{Change: models.DiffAdded, Level: level, Number: "d2", Name: "Gulu", ParentNumber: "r1"},
+				},
+				Removed: []models.DiffChange{
+					{Change: models.DiffRemoved, Level: level, Number: "d1", Name: "Gulu", ParentNumber: "r1"},
+				},
+			},
+		},
+		{
+			name: "ordered by number",
+			before: map[string]diffUnit{
+				"d3": {Name: "Lira"},
+				"d1": {Name: "Arua"},
+			},
+			after: map[string]diffUnit{
+				"d4": {Name: "Mbale"},
+				"d2": {Name: "Gulu"},
+			},
+			want: models.DiffResponse{
+				Added: []models.DiffChange{
+					{Change: models.DiffAdded, Level: level, Number: "d2", Name: "Gulu"},
+					{Change: models.DiffAdded, Level: level, Number: "d4", Name: "Mbale"},
+				},
+				Removed: []models.DiffChange{
+					{Change: models.DiffRemoved, Level: level, Number: "d1", Name: "Arua"},
+					{Change: models.DiffRemoved, Level: level, Number: "d3", Name: "Lira"},
+				},
+			},
+		},
+		{
+			name:   "top level has no parent",
+			before: map[string]diffUnit{"d1": {Name: "Gulu"}},
+			after:  map[string]diffUnit{"d1": {Name: "Gulu"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.DiffResponse
+			compareStates(&got, level, tt.before, tt.after)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareStates =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareStatesAppendsAcrossLevels(t *testing.T) {
+	var response models.DiffResponse
+	compareStates(&response, models.LevelRegion, nil, map[string]diffUnit{"r1": {Name: "Northern"}})
+	compareStates(&response, models.LevelDistrict, nil, map[string]diffUnit{"d1": {Name: "Gulu", ParentNumber: "r1"}})
+
+	if len(response.Added) != 2 || response.Added[0].Level != models.LevelRegion || response.Added[1].Level != models.LevelDistrict {
+		t.Errorf("added = %+v, want the region then the district", response.Added)
+	}
+}
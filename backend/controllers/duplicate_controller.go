@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/database"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+type DuplicateController struct {
+	db     *database.Database
+	units  *UnitController
+	finder *services.DuplicateFinder
+}
+
+func NewDuplicateController(db *database.Database) *DuplicateController {
+	return &DuplicateController{
+		db:     db,
+		units:  NewUnitController(),
+		finder: services.NewDuplicateFinder(db.DB),
+	}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *DuplicateController) WithContext(ctx context.Context) *DuplicateController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	scoped.finder = services.NewDuplicateFinder(scoped.db.DB)
+	return &scoped
+}
+
+// Candidates lists likely duplicates at level, leaving out pairs a reviewer
+// has already dismissed. Each side carries its number of children to help
+// pick which record to keep.
+func (c *DuplicateController) Candidates(level, parentNumber string, minScore float64) ([]services.DuplicateCandidate, error) {
+	candidates, err := c.finder.Find(level, parentNumber, minScore)
+	if err != nil || len(candidates) == 0 {
+		return candidates, err
+	}
+
+	var dismissals []models.DuplicateDismissal
+	if err := c.db.DB.Where("level = ?", level).Find(&dismissals).Error; err != nil {
+		return nil, err
+	}
+	dismissed := make(map[[2]string]bool, len(dismissals))
+	for _, d := range dismissals {
+		dismissed[[2]string{d.NumberA, d.NumberB}] = true
+	}
+
+	kept := candidates[:0]
+	var numbers []string
+	for _, candidate := range candidates {
+		pair := [2]string{candidate.A.Number, candidate.B.Number}
+		if pair[1] < pair[0] {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		if dismissed[pair] {
+			continue
+		}
+		kept = append(kept, candidate)
+		numbers = append(numbers, candidate.A.Number, candidate.B.Number)
+	}
+
+	children, err := c.units.CountChildren(c.db.DB, level, numbers)
+	if err != nil {
+		return nil, err
+	}
+	for i := range kept {
+		kept[i].A.Children = children[kept[i].A.Number]
+		kept[i].B.Children = children[kept[i].B.Number]
+	}
+	return kept, nil
+}
+
+// Merge folds one unit into another in a single transaction.
+func (c *DuplicateController) Merge(input *models.MergeUnitsInput, mergedBy string) (*models.MergeResult, error) {
+	var result *models.MergeResult
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = c.units.MergeUnit(tx, input.Level, input.KeepNumber, input.MergeNumber, mergedBy)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Dismiss records that a candidate pair is not a duplicate. Dismissing the
+// same pair again is a no-op.
+func (c *DuplicateController) Dismiss(input *models.DismissDuplicateInput, dismissedBy string) error {
+	return c.db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.DuplicateDismissal{
+		Level:       input.Level,
+		NumberA:     input.NumberA,
+		NumberB:     input.NumberB,
+		DismissedBy: dismissedBy,
+	}).Error
+}
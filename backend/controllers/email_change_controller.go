@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+// EmailChangeReauthWindow is how recently an account without a password
+// must have signed in to change its email.
+const EmailChangeReauthWindow = 10 * time.Minute
+
+var (
+	ErrEmailUnchanged          = errors.New("new email is the same as the current one")
+	ErrEmailTaken              = errors.New("email address is already in use")
+	ErrEmailChangePassword     = errors.New("current password is incorrect")
+	ErrEmailChangeReauth       = errors.New("sign in again to change your email")
+	ErrEmailChangeTokenInvalid = errors.New("email confirmation link is invalid or has expired")
+)
+
+type EmailChangeController struct {
+	db         *database.Database
+	jwtService *services.JWTService
+	sessions   *SessionController
+}
+
+func NewEmailChangeController(db *database.Database, jwtService *services.JWTService) *EmailChangeController {
+	return &EmailChangeController{
+		db:         db,
+		jwtService: jwtService,
+		sessions:   NewSessionController(db),
+	}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *EmailChangeController) WithContext(ctx context.Context) *EmailChangeController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
+func (c *EmailChangeController) emailInUse(tx *gorm.DB, email string) (bool, error) {
+	var count int64
+	err := tx.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error
+	return count > 0, err
+}
+
+// RequestChange records a pending change of user's email to newEmail and
+// returns it with the signed confirmation token. Earlier pending requests
+// are superseded so only the latest link works.
+//
+// The request is re-authenticated with the current password. Accounts that
+// only sign in through a provider have none, so session, the one the
+// request was made with, must come from a sign-in, MFA included, within
+// EmailChangeReauthWindow instead.
+func (c *EmailChangeController) RequestChange(user *models.User, session *models.Session, newEmail, password string) (*models.EmailChange, string, error) {
+	if newEmail == user.Email {
+		return nil, "", ErrEmailUnchanged
+	}
+
+	var current models.UserPassword
+	err := c.db.DB.Where("user_number = ?", user.Number).Order("created_at DESC").First(&current).Error
+	switch {
+	case err == nil:
+		if _, err := commons.ComparePassword(current.UserPassword, password); err != nil {
+			return nil, "", ErrEmailChangePassword
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if session == nil {
+			return nil, "", ErrEmailChangeReauth
+		}
+		signedIn, err := c.sessions.SignedInAt(session)
+		if err != nil {
+			return nil, "", err
+		}
+		if time.Since(signedIn) > EmailChangeReauthWindow {
+			return nil, "", ErrEmailChangeReauth
+		}
+	default:
+		return nil, "", err
+	}
+
+	taken, err := c.emailInUse(c.db.DB, newEmail)
+	if err != nil {
+		return nil, "", err
+	}
+	if taken {
+		return nil, "", ErrEmailTaken
+	}
+
+	change := &models.EmailChange{
+		Number:     commons.UUIDGenerator(),
+		UserNumber: user.Number,
+		OldEmail:   user.Email,
+		NewEmail:   newEmail,
+		Status:     models.EmailChangePending,
+		ExpiresAt:  time.Now().Add(services.EmailChangeDuration),
+	}
+
+	token, err := c.jwtService.CreateEmailChangeToken(user.Number, string(user.Role), change.Number, newEmail)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EmailChange{}).
+			Where("user_number = ? AND status = ?", user.Number, models.EmailChangePending).
+			Update("status", models.EmailChangeSuperseded).Error; err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return change, token, nil
+}
+
+// ConfirmChange swaps the email on the account behind token and signs the
+// user out everywhere.
+func (c *EmailChangeController) ConfirmChange(token string) error {
+	claims, err := c.jwtService.ValidateToken(token)
+	if err != nil {
+		return ErrEmailChangeTokenInvalid
+	}
+	tokenType, _ := claims["type"].(string)
+	changeNumber, _ := claims["token_uuid"].(string)
+	userNumber, _ := claims["user_number"].(string)
+	newEmail, _ := claims["new_email"].(string)
+	if tokenType != "email_change" || changeNumber == "" || newEmail == "" {
+		return ErrEmailChangeTokenInvalid
+	}
+
+	return c.db.DB.Transaction(func(tx *gorm.DB) error {
+		var change models.EmailChange
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("number = ? AND user_number = ? AND new_email = ? AND status = ?", changeNumber, userNumber, newEmail, models.EmailChangePending).
+			First(&change).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEmailChangeTokenInvalid
+			}
+			return err
+		}
+		if time.Now().After(change.ExpiresAt) {
+			return ErrEmailChangeTokenInvalid
+		}
+
+		var user models.User
+		if err := tx.Where("number = ?", userNumber).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEmailChangeTokenInvalid
+			}
+			return err
+		}
+		if user.Email != change.OldEmail {
+			return ErrEmailChangeTokenInvalid
+		}
+
+		// The address may have been registered since the request was made.
+		taken, err := c.emailInUse(tx, change.NewEmail)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrEmailTaken
+		}
+
+		now := time.Now()
+		if err := tx.Model(&change).Updates(map[string]interface{}{
+			"status":       models.EmailChangeConfirmed,
+			"confirmed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("email", change.NewEmail).Error; err != nil {
+			return err
+		}
+		return c.sessions.RevokeAllSessions(tx, user.Number)
+	})
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+func TestRequestChangeReauthenticates(t *testing.T) {
+	db := openTestDB(t)
+	changes := NewEmailChangeController(db, nil)
+
+	user := models.User{Number: commons.UUIDGenerator(), Email: "oidc@example.org", FirstName: "Test", Role: models.RoleUser, Status: models.UserStatusActive}
+	if err := db.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// A login long ago, refreshed a moment ago.
+	family := commons.UUIDGenerator()
+	signedIn := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var sessions []*models.Session
+	for _, created := range []time.Time{signedIn, time.Now()} {
+		session := &models.Session{
+			Number:           commons.UUIDGenerator(),
+			UserNumber:       user.Number,
+			FamilyNumber:     family,
+			AccessTokenUUID:  commons.UUIDGenerator(),
+			RefreshTokenUUID: commons.UUIDGenerator(),
+			LastSeenAt:       created,
+			ExpiresAt:        time.Now().Add(time.Hour),
+		}
+		session.CreatedAt = created
+		if err := db.DB.Create(session).Error; err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+	}
+	refreshed := sessions[1]
+
+	got, err := changes.sessions.SignedInAt(refreshed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(signedIn) {
+		t.Errorf("SignedInAt = %s, want the start of the family at %s", got, signedIn)
+	}
+
+	// Without a password, refreshing tokens does not stand in for signing in.
+	for name, session := range map[string]*models.Session{"no session": nil, "stale sign-in": refreshed} {
+		if _, _, err := changes.RequestChange(&user, session, "new@example.org", ""); !errors.Is(err, ErrEmailChangeReauth) {
+			t.Errorf("%s: got %v, want ErrEmailChangeReauth", name, err)
+		}
+	}
+
+	hash, err := commons.HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.Create(&models.UserPassword{Number: commons.UUIDGenerator(), UserNumber: user.Number, UserPassword: hash}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := changes.RequestChange(&user, refreshed, "new@example.org", "wrong"); !errors.Is(err, ErrEmailChangePassword) {
+		t.Errorf("wrong password: got %v, want ErrEmailChangePassword", err)
+	}
+}
@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+const mfaIssuer = "Open Data Uganda"
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolling   = errors.New("start enrollment before confirming it")
+	ErrMFAInvalidCode    = errors.New("invalid two-factor code")
+)
+
+type MFAController struct {
+	db       *database.Database
+	sessions *SessionController
+}
+
+func NewMFAController(db *database.Database) *MFAController {
+	return &MFAController{
+		db:       db,
+		sessions: NewSessionController(db),
+	}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *MFAController) WithContext(ctx context.Context) *MFAController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
+func (c *MFAController) findEnrollment(tx *gorm.DB, userNumber string) (*models.UserMFA, error) {
+	var enrollment models.UserMFA
+	if err := tx.Where("user_number = ?", userNumber).First(&enrollment).Error; err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// BeginEnrollment generates a new secret for the user, replacing any
+// unconfirmed one. The secret is returned in the clear only here.
+func (c *MFAController) BeginEnrollment(user *models.User) (*models.MFAEnrollmentResponse, error) {
+	if user.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := commons.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := commons.EncryptTOTPSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_number = ?", user.Number).Delete(&models.UserMFA{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserMFA{
+			Number:     commons.UUIDGenerator(),
+			UserNumber: user.Number,
+			Secret:     sealed,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = mfaIssuer
+	}
+
+	return &models.MFAEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: commons.TOTPProvisioningURI(issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables two-factor sign-in once the user proves their
+// authenticator produces valid codes, and issues the first recovery codes.
+func (c *MFAController) ConfirmEnrollment(user *models.User, code string) ([]string, error) {
+	if user.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	var codes []string
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		enrollment, err := c.findEnrollment(tx.Clauses(clause.Locking{Strength: "UPDATE"}), user.Number)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMFANotEnrolling
+			}
+			return err
+		}
+
+		if err := c.checkCode(tx, enrollment, code); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(enrollment).Update("confirmed_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("number = ?", user.Number).Update("mfa_enabled_at", now).Error; err != nil {
+			return err
+		}
+		user.MFAEnabledAt = &now
+
+		codes, err = c.replaceRecoveryCodes(tx, user.Number)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify accepts either a current TOTP code or an unused recovery code.
+// Recovery codes are consumed on success.
+func (c *MFAController) Verify(user *models.User, code, recoveryCode string) error {
+	if !user.MFAEnabled() {
+		return ErrMFANotEnabled
+	}
+
+	return c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if recoveryCode != "" {
+			return c.useRecoveryCode(tx, user.Number, recoveryCode)
+		}
+
+		enrollment, err := c.findEnrollment(tx.Clauses(clause.Locking{Strength: "UPDATE"}), user.Number)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMFANotEnabled
+			}
+			return err
+		}
+		return c.checkCode(tx, enrollment, code)
+	})
+}
+
+// checkCode validates a TOTP code and records its step so it cannot be
+// replayed.
+func (c *MFAController) checkCode(tx *gorm.DB, enrollment *models.UserMFA, code string) error {
+	secret, err := commons.DecryptTOTPSecret(enrollment.Secret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := commons.ValidateTOTP(secret, code, time.Now(), enrollment.LastUsedStep)
+	if !ok {
+		return ErrMFAInvalidCode
+	}
+
+	enrollment.LastUsedStep = step
+	return tx.Model(enrollment).Update("last_used_step", step).Error
+}
+
+func (c *MFAController) useRecoveryCode(tx *gorm.DB, userNumber, recoveryCode string) error {
+	var codes []models.MFARecoveryCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_number = ? AND used_at IS NULL", userNumber).
+		Find(&codes).Error; err != nil {
+		return err
+	}
+
+	normalized := commons.NormalizeRecoveryCode(recoveryCode)
+	for _, candidate := range codes {
+		if match, _ := commons.ComparePassword(candidate.CodeHash, normalized); match {
+			return tx.Model(&candidate).Update("used_at", time.Now()).Error
+		}
+	}
+	return ErrMFAInvalidCode
+}
+
+func (c *MFAController) replaceRecoveryCodes(tx *gorm.DB, userNumber string) ([]string, error) {
+	if err := tx.Unscoped().Where("user_number = ?", userNumber).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes, err := commons.GenerateRecoveryCodes(commons.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]models.MFARecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		hash, err := commons.HashPassword(code)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, models.MFARecoveryCode{
+			Number:     commons.UUIDGenerator(),
+			UserNumber: userNumber,
+			CodeHash:   hash,
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes invalidates every existing recovery code and
+// returns a fresh set.
+func (c *MFAController) RegenerateRecoveryCodes(user *models.User) ([]string, error) {
+	if !user.MFAEnabled() {
+		return nil, ErrMFANotEnabled
+	}
+
+	var codes []string
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = c.replaceRecoveryCodes(tx, user.Number)
+		return err
+	})
+	return codes, err
+}
+
+func (c *MFAController) RemainingRecoveryCodes(userNumber string) (int64, error) {
+	var count int64
+	err := c.db.DB.Model(&models.MFARecoveryCode{}).
+		Where("user_number = ? AND used_at IS NULL", userNumber).
+		Count(&count).Error
+	return count, err
+}
+
+// Disable removes the user's enrollment and recovery codes. Callers check
+// the user's password and a second factor first.
+func (c *MFAController) Disable(user *models.User) error {
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		return c.clear(tx, user.Number)
+	})
+	if err != nil {
+		return err
+	}
+	user.MFAEnabledAt = nil
+	return nil
+}
+
+// Reset is the administrator's recovery path for a user who lost both their
+// authenticator and recovery codes. It also signs the user out everywhere.
+func (c *MFAController) Reset(user *models.User) error {
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := c.clear(tx, user.Number); err != nil {
+			return err
+		}
+		return c.sessions.RevokeAllSessions(tx, user.Number)
+	})
+	if err != nil {
+		return fmt.Errorf("reset two-factor authentication: %w", err)
+	}
+	user.MFAEnabledAt = nil
+	return nil
+}
+
+func (c *MFAController) clear(tx *gorm.DB, userNumber string) error {
+	if err := tx.Unscoped().Where("user_number = ?", userNumber).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_number = ?", userNumber).Delete(&models.UserMFA{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.User{}).Where("number = ?", userNumber).Update("mfa_enabled_at", nil).Error
+}
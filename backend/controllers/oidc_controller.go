@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+// OIDCStateTTL is how long a user has to finish signing in with the
+// provider.
+const OIDCStateTTL = 10 * time.Minute
+
+var ErrOIDCStateInvalid = errors.New("sign-in request is invalid or has expired")
+
+type OIDCController struct {
+	db    *database.Database
+	users *UserController
+}
+
+func NewOIDCController(db *database.Database, users *UserController) *OIDCController {
+	return &OIDCController{db: db, users: users}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *OIDCController) WithContext(ctx context.Context) *OIDCController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	scoped.users = c.users.WithContext(ctx)
+	return &scoped
+}
+
+func randomURLToken(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// CreateState starts a sign-in with provider. Expired states are swept at
+// the same time so the table stays small.
+func (c *OIDCController) CreateState(provider, redirectTo string) (*models.OIDCLoginState, error) {
+	state, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomURLToken(48)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.OIDCLoginState{
+		Number:       commons.UUIDGenerator(),
+		State:        state,
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectTo:   redirectTo,
+		ExpiresAt:    time.Now().Add(OIDCStateTTL),
+	}
+
+	err = c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ConsumeState looks up and deletes the state for a callback, so each one
+// can only be used once.
+func (c *OIDCController) ConsumeState(provider, state string) (*models.OIDCLoginState, error) {
+	var record models.OIDCLoginState
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("state = ? AND provider = ?", state, provider).
+			First(&record).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&record).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOIDCStateInvalid
+		}
+		return nil, err
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrOIDCStateInvalid
+	}
+	return &record, nil
+}
+
+// ResolveUser finds the account for an external identity. Accounts already
+// linked to the identity are returned directly. Otherwise the provider must
+// vouch for the email address: a matching account is linked to the
+// identity, and a new USER account is created when there is none.
+func (c *OIDCController) ResolveUser(identity *services.OIDCIdentity) (*models.User, bool, error) {
+	if user, err := c.users.FindByAuthID(identity.Provider, identity.Subject); err == nil {
+		return user, false, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+	if email == "" || !identity.EmailVerified {
+		return nil, false, services.ErrOIDCEmailUnverified
+	}
+
+	user, err := c.users.FindByEmail(email)
+	if err == nil {
+		return user, false, c.link(user, identity)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	user = newOIDCUser(email, identity)
+	if err := c.db.DB.Create(user).Error; err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+// link records the identity on an existing account. An account keeps the
+// first provider it was linked to; later providers can still sign in with
+// the same verified email. Inactive accounts are activated, since the
+// provider has just proven the user owns the address.
+func (c *OIDCController) link(user *models.User, identity *services.OIDCIdentity) error {
+	updates := map[string]interface{}{}
+	if user.Provider == "" {
+		updates["provider"] = identity.Provider
+		updates["auth_number"] = identity.Subject
+	}
+	if user.Status == models.UserStatusInactive {
+		updates["status"] = models.UserStatusActive
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if err := c.db.DB.Model(&models.User{}).Where("number = ?", user.Number).Updates(updates).Error; err != nil {
+		return err
+	}
+	if user.Provider == "" {
+		user.Provider, user.AuthNumber = identity.Provider, identity.Subject
+	}
+	if user.Status == models.UserStatusInactive {
+		user.Status = models.UserStatusActive
+	}
+	return nil
+}
+
+func newOIDCUser(email string, identity *services.OIDCIdentity) *models.User {
+	firstName, lastName := identity.GivenName, identity.FamilyName
+	if firstName == "" {
+		parts := strings.Fields(identity.Name)
+		if len(parts) > 0 {
+			firstName = parts[0]
+			lastName = strings.Join(parts[1:], " ")
+		}
+	}
+	if firstName == "" {
+		firstName = strings.SplitN(email, "@", 2)[0]
+	}
+
+	return &models.User{
+		Number:     commons.UUIDGenerator(),
+		Email:      email,
+		Name:       strings.TrimSpace(firstName + " " + lastName),
+		FirstName:  firstName,
+		LastName:   lastName,
+		Role:       models.RoleUser,
+		Status:     models.UserStatusActive,
+		Provider:   identity.Provider,
+		AuthNumber: identity.Subject,
+	}
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"opendataug.org/commons"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+func TestResolveUser(t *testing.T) {
+	db := openTestDB(t)
+	logins := NewOIDCController(db, NewUserController(db, nil))
+
+	existing := func(email, status, provider string) *models.User {
+		user := &models.User{Number: commons.UUIDGenerator(), Email: email, FirstName: "Test", Role: models.RoleUser, Status: status, Provider: provider}
+		if provider != "" {
+			user.AuthNumber = "first-" + email
+		}
+		if err := db.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+	password := existing("password@example.org", models.UserStatusActive, "")
+	inactive := existing("inactive@example.org", models.UserStatusInactive, "")
+	linked := existing("linked@example.org", models.UserStatusActive, services.ProviderGitHub)
+
+	identity := func(subject, email string, verified bool) *services.OIDCIdentity {
+		return &services.OIDCIdentity{
+			Provider:      services.ProviderGoogle,
+			Subject:       subject,
+			Email:         email,
+			EmailVerified: verified,
+			GivenName:     "Jane",
+			FamilyName:    "Doe",
+		}
+	}
+
+	tests := []struct {
+		name         string
+		identity     *services.OIDCIdentity
+		wantErr      error
+		wantUser     string
+		wantCreated  bool
+		wantProvider string
+		wantStatus   string
+	}{
+		{
+			name:         "provisions a new account",
+			identity:     identity("new-subject", "New@Example.org", true),
+			wantCreated:  true,
+			wantProvider: services.ProviderGoogle,
+			wantStatus:   models.UserStatusActive,
+		},
+		{
+			name:         "returns the account linked to the subject",
+			identity:     identity("new-subject", "changed@example.org", false),
+			wantProvider: services.ProviderGoogle,
+			wantStatus:   models.UserStatusActive,
+		},
+		{
+			name:         "links a password account by verified email",
+			identity:     identity("password-subject", "PASSWORD@example.org", true),
+			wantUser:     password.Number,
+			wantProvider: services.ProviderGoogle,
+			wantStatus:   models.UserStatusActive,
+		},
+		{
+			name:         "activates an inactive account it links",
+			identity:     identity("inactive-subject", "inactive@example.org", true),
+			wantUser:     inactive.Number,
+			wantProvider: services.ProviderGoogle,
+			wantStatus:   models.UserStatusActive,
+		},
+		{
+			name:         "keeps the first provider of a linked account",
+			identity:     identity("linked-subject", "linked@example.org", true),
+			wantUser:     linked.Number,
+			wantProvider: services.ProviderGitHub,
+			wantStatus:   models.UserStatusActive,
+		},
+		{
+			name:     "refuses an unverified email",
+			identity: identity("unverified-subject", "password@example.org", false),
+			wantErr:  services.ErrOIDCEmailUnverified,
+		},
+		{
+			name:     "refuses a missing email",
+			identity: identity("anonymous-subject", "", true),
+			wantErr:  services.ErrOIDCEmailUnverified,
+		},
+	}
+
+	var provisioned string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, created, err := logins.ResolveUser(tt.identity)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ResolveUser = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created != tt.wantCreated {
+				t.Errorf("created = %v, want %v", created, tt.wantCreated)
+			}
+			if created {
+				provisioned = user.Number
+				if user.Email != "new@example.org" || user.Role != models.RoleUser || user.FirstName != "Jane" {
+					t.Errorf("provisioned %+v", user)
+				}
+			}
+
+			wantUser := tt.wantUser
+			if wantUser == "" {
+				wantUser = provisioned
+			}
+			var stored models.User
+			if err := db.DB.Where("number = ?", wantUser).First(&stored).Error; err != nil {
+				t.Fatal(err)
+			}
+			if user.Number != stored.Number {
+				t.Errorf("resolved %s, want %s", user.Number, stored.Number)
+			}
+			if stored.Provider != tt.wantProvider || user.Provider != tt.wantProvider {
+				t.Errorf("provider = %q (stored %q), want %q", user.Provider, stored.Provider, tt.wantProvider)
+			}
+			if stored.Status != tt.wantStatus || user.Status != tt.wantStatus {
+				t.Errorf("status = %q (stored %q), want %q", user.Status, stored.Status, tt.wantStatus)
+			}
+		})
+	}
+
+	var count int64
+	if err := db.DB.Model(&models.User{}).Where("email = ?", "password@example.org").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d accounts for password@example.org, want linking rather than a duplicate", count)
+	}
+}
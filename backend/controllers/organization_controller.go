@@ -0,0 +1,349 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+const InvitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvitationInvalid  = errors.New("invitation is invalid or has expired")
+	ErrInvitationEmail    = errors.New("invitation was sent to a different email address")
+	ErrAlreadyMember      = errors.New("user is already a member of this organization")
+	ErrNotMember          = errors.New("user is not a member of this organization")
+	ErrOwnerCannotLeave   = errors.New("the owner must transfer ownership before leaving the organization")
+	ErrOwnerRoleImmutable = errors.New("ownership can only be changed by transferring it")
+)
+
+type OrganizationController struct {
+	db *database.Database
+}
+
+func NewOrganizationController(db *database.Database) *OrganizationController {
+	return &OrganizationController{db: db}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *OrganizationController) WithContext(ctx context.Context) *OrganizationController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
+// CreateOrganization creates the organization with owner as its first member.
+func (c *OrganizationController) CreateOrganization(name, ownerNumber string) (*models.Organization, error) {
+	organization := &models.Organization{
+		Number: commons.UUIDGenerator(),
+		Name:   name,
+	}
+
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Membership{
+			Number:             commons.UUIDGenerator(),
+			OrganizationNumber: organization.Number,
+			UserNumber:         ownerNumber,
+			Role:               models.OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+func (c *OrganizationController) FindOrganization(number string) (*models.Organization, error) {
+	var organization models.Organization
+	if err := c.db.DB.Where("number = ?", number).First(&organization).Error; err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (c *OrganizationController) FindMembership(organizationNumber, userNumber string) (*models.Membership, error) {
+	var membership models.Membership
+	err := c.db.DB.Where("organization_number = ? AND user_number = ?", organizationNumber, userNumber).
+		First(&membership).Error
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// ListForUser returns the organizations userNumber belongs to along with the
+// role held in each.
+func (c *OrganizationController) ListForUser(userNumber string) ([]models.OrganizationResponse, error) {
+	organizations := make([]models.OrganizationResponse, 0)
+	err := c.db.DB.Model(&models.Organization{}).
+		Select("organizations.number AS id, organizations.name, memberships.role, organizations.monthly_request_quota, organizations.created_at").
+		Joins("JOIN memberships ON memberships.organization_number = organizations.number AND memberships.deleted_at IS NULL").
+		Where("memberships.user_number = ?", userNumber).
+		Order("organizations.name ASC").
+		Scan(&organizations).Error
+	return organizations, err
+}
+
+func (c *OrganizationController) RenameOrganization(number, name string) error {
+	return c.db.DB.Model(&models.Organization{}).Where("number = ?", number).Update("name", name).Error
+}
+
+func (c *OrganizationController) SetQuota(number string, quota int64) error {
+	result := c.db.DB.Model(&models.Organization{}).Where("number = ?", number).Update("monthly_request_quota", quota)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteOrganization removes the organization together with its keys,
+// memberships and outstanding invitations.
+func (c *OrganizationController) DeleteOrganization(number string) error {
+	return c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_number = ?", number).Delete(&models.APIKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_number = ?", number).Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_number = ?", number).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("number = ?", number).Delete(&models.Organization{}).Error
+	})
+}
+
+func (c *OrganizationController) ListMembers(organizationNumber string) ([]models.MembershipResponse, error) {
+	members := make([]models.MembershipResponse, 0)
+	err := c.db.DB.Model(&models.Membership{}).
+		Select("memberships.user_number, users.email, users.name, memberships.role, memberships.created_at AS joined_at").
+		Joins("JOIN users ON users.number = memberships.user_number AND users.deleted_at IS NULL").
+		Where("memberships.organization_number = ?", organizationNumber).
+		Order("memberships.created_at ASC").
+		Scan(&members).Error
+	return members, err
+}
+
+func (c *OrganizationController) UpdateMemberRole(organizationNumber, userNumber string, role models.OrganizationRole) error {
+	membership, err := c.FindMembership(organizationNumber, userNumber)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotMember
+		}
+		return err
+	}
+	if membership.Role == models.OrgRoleOwner || role == models.OrgRoleOwner {
+		return ErrOwnerRoleImmutable
+	}
+	return c.db.DB.Model(membership).Update("role", role).Error
+}
+
+func (c *OrganizationController) RemoveMember(organizationNumber, userNumber string) error {
+	membership, err := c.FindMembership(organizationNumber, userNumber)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotMember
+		}
+		return err
+	}
+	if membership.Role == models.OrgRoleOwner {
+		return ErrOwnerCannotLeave
+	}
+	return c.db.DB.Delete(membership).Error
+}
+
+// RemoveUserMemberships drops every non-owner membership held by userNumber.
+func (c *OrganizationController) RemoveUserMemberships(tx *gorm.DB, userNumber string) error {
+	return tx.Where("user_number = ? AND role <> ?", userNumber, models.OrgRoleOwner).Delete(&models.Membership{}).Error
+}
+
+// CountOwned returns how many organizations userNumber currently owns.
+func (c *OrganizationController) CountOwned(userNumber string) (int64, error) {
+	var count int64
+	err := c.db.DB.Model(&models.Membership{}).
+		Where("user_number = ? AND role = ?", userNumber, models.OrgRoleOwner).
+		Count(&count).Error
+	return count, err
+}
+
+// TransferOwnership makes newOwner the owner and demotes the current owner
+// to admin. The new owner must already be a member.
+func (c *OrganizationController) TransferOwnership(organizationNumber, currentOwner, newOwner string) error {
+	return c.db.DB.Transaction(func(tx *gorm.DB) error {
+		var target models.Membership
+		if err := tx.Where("organization_number = ? AND user_number = ?", organizationNumber, newOwner).
+			First(&target).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotMember
+			}
+			return err
+		}
+
+		if err := tx.Model(&models.Membership{}).
+			Where("organization_number = ? AND user_number = ? AND role = ?", organizationNumber, currentOwner, models.OrgRoleOwner).
+			Update("role", models.OrgRoleAdmin).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&target).Update("role", models.OrgRoleOwner).Error
+	})
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateInvitation stores a pending invitation and returns it together with
+// the plain token to send to the invitee. Only the token hash is persisted.
+func (c *OrganizationController) CreateInvitation(organizationNumber string, input *models.InvitationInput, invitedBy string) (*models.OrganizationInvitation, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	invitation := &models.OrganizationInvitation{
+		Number:             commons.UUIDGenerator(),
+		OrganizationNumber: organizationNumber,
+		Email:              input.Email,
+		Role:               input.Role,
+		Token:              hashInvitationToken(token),
+		Status:             models.InvitationStatusPending,
+		InvitedBy:          invitedBy,
+		ExpiresAt:          time.Now().Add(InvitationTTL),
+	}
+
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		// A fresh invitation supersedes any earlier one for the same address.
+		if err := tx.Model(&models.OrganizationInvitation{}).
+			Where("organization_number = ? AND email = ? AND status = ?", organizationNumber, input.Email, models.InvitationStatusPending).
+			Update("status", models.InvitationStatusRevoked).Error; err != nil {
+			return err
+		}
+		return tx.Create(invitation).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return invitation, token, nil
+}
+
+func (c *OrganizationController) ListInvitations(organizationNumber string) ([]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+	err := c.db.DB.Where("organization_number = ? AND status = ? AND expires_at > ?",
+		organizationNumber, models.InvitationStatusPending, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (c *OrganizationController) RevokeInvitation(organizationNumber, number string) error {
+	result := c.db.DB.Model(&models.OrganizationInvitation{}).
+		Where("organization_number = ? AND number = ? AND status = ?", organizationNumber, number, models.InvitationStatusPending).
+		Update("status", models.InvitationStatusRevoked)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation adds user to the invited organization. The invitation can
+// only be redeemed by the account it was addressed to.
+func (c *OrganizationController) AcceptInvitation(token string, user *models.User) (*models.Organization, error) {
+	var organization models.Organization
+
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		var invitation models.OrganizationInvitation
+		if err := tx.Where("token = ? AND status = ? AND expires_at > ?",
+			hashInvitationToken(token), models.InvitationStatusPending, time.Now()).
+			First(&invitation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationInvalid
+			}
+			return err
+		}
+
+		if !strings.EqualFold(invitation.Email, user.Email) {
+			return ErrInvitationEmail
+		}
+
+		if err := tx.Where("number = ?", invitation.OrganizationNumber).First(&organization).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationInvalid
+			}
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Membership{}).
+			Where("organization_number = ? AND user_number = ?", organization.Number, user.Number).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyMember
+		}
+
+		if err := tx.Create(&models.Membership{
+			Number:             commons.UUIDGenerator(),
+			OrganizationNumber: organization.Number,
+			UserNumber:         user.Number,
+			Role:               invitation.Role,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&invitation).Update("status", models.InvitationStatusAccepted).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &organization, nil
+}
+
+func (c *OrganizationController) ListAPIKeys(organizationNumber string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := c.db.DB.Where("organization_number = ?", organizationNumber).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (c *OrganizationController) APIKeyNameExists(organizationNumber, name string) (bool, error) {
+	var count int64
+	err := c.db.DB.Model(&models.APIKey{}).
+		Where("organization_number = ? AND name = ?", organizationNumber, name).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (c *OrganizationController) DeleteAPIKey(organizationNumber, keyNumber string) error {
+	result := c.db.DB.Where("organization_number = ? AND number = ?", organizationNumber, keyNumber).Delete(&models.APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
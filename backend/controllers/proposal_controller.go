@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+var (
+	ErrProposalNotFound   = errors.New("proposal not found")
+	ErrProposalNotPending = errors.New("proposal has already been reviewed or withdrawn")
+	ErrProposalSelfReview = errors.New("you cannot review your own proposal")
+	ErrProposalNotOwner   = errors.New("only the author can withdraw a proposal")
+)
+
+type ProposalController struct {
+	db    *database.Database
+	units *UnitController
+}
+
+func NewProposalController(db *database.Database) *ProposalController {
+	return &ProposalController{
+		db:    db,
+		units: NewUnitController(),
+	}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *ProposalController) WithContext(ctx context.Context) *ProposalController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
+// Submit records a proposal from proposedBy, made through apiKeyNumber when
+// it came in with an API key. The target unit and parent must exist now;
+// they are checked again on approval.
+func (c *ProposalController) Submit(input *models.ChangeProposalInput, proposedBy, apiKeyNumber string) (*models.ChangeProposal, error) {
+	proposal := &models.ChangeProposal{
+		Number:       commons.UUIDGenerator(),
+		Action:       input.Action,
+		Level:        input.Level,
+		Name:         input.Name,
+		ParentNumber: input.ParentNumber,
+		Evidence:     input.Evidence,
+		Status:       models.ProposalStatusPending,
+		ProposedBy:   proposedBy,
+		APIKeyNumber: apiKeyNumber,
+	}
+
+	meta := models.Levels[input.Level]
+	if input.Action == models.ProposalActionCreate {
+		proposal.TownStatus = input.TownStatus && input.Level == models.LevelDistrict
+	} else {
+		current, err := c.units.FindUnit(c.db.DB, input.Level, input.UnitNumber)
+		if err != nil {
+			return nil, err
+		}
+		proposal.UnitNumber = input.UnitNumber
+		proposal.CurrentName = current.Name
+		proposal.CurrentParentNumber = current.ParentNumber
+	}
+
+	if input.ParentNumber != "" && (input.Action == models.ProposalActionCreate || input.Action == models.ProposalActionReparent) {
+		if _, err := c.units.FindUnit(c.db.DB, meta.ParentLevel, input.ParentNumber); err != nil {
+			if errors.Is(err, ErrUnitNotFound) {
+				return nil, ErrUnitParentNotFound
+			}
+			return nil, err
+		}
+	} else {
+		proposal.ParentNumber = ""
+	}
+	if input.Action == models.ProposalActionReparent || input.Action == models.ProposalActionDelete {
+		proposal.Name = ""
+	}
+
+	if err := c.db.DB.Create(proposal).Error; err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+func (c *ProposalController) Find(number string) (*models.ChangeProposal, error) {
+	var proposal models.ChangeProposal
+	if err := c.db.DB.Where("number = ?", number).First(&proposal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProposalNotFound
+		}
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// ProposalFilter narrows the proposal listing. ProposedBy limits it to one
+// author's proposals.
+type ProposalFilter struct {
+	Status     string
+	Level      string
+	ProposedBy string
+}
+
+func (c *ProposalController) List(filter ProposalFilter, pagination commons.PaginationParams) ([]models.ChangeProposal, int64, error) {
+	query := c.db.DB.Model(&models.ChangeProposal{})
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Level != "" {
+		query = query.Where("level = ?", filter.Level)
+	}
+	if filter.ProposedBy != "" {
+		query = query.Where("proposed_by = ?", filter.ProposedBy)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var proposals []models.ChangeProposal
+	err := query.Order("created_at DESC").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&proposals).Error
+	return proposals, total, err
+}
+
+// lockPending loads a pending proposal for update inside tx.
+func lockPending(tx *gorm.DB, number string) (*models.ChangeProposal, error) {
+	var proposal models.ChangeProposal
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("number = ?", number).
+		First(&proposal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProposalNotFound
+		}
+		return nil, err
+	}
+	if proposal.Status != models.ProposalStatusPending {
+		return nil, ErrProposalNotPending
+	}
+	return &proposal, nil
+}
+
+// Withdraw lets the author take back a proposal that has not been reviewed.
+func (c *ProposalController) Withdraw(number, userNumber string) (*models.ChangeProposal, error) {
+	var proposal *models.ChangeProposal
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if proposal, err = lockPending(tx, number); err != nil {
+			return err
+		}
+		if proposal.ProposedBy != userNumber {
+			return ErrProposalNotOwner
+		}
+		proposal.Status = models.ProposalStatusWithdrawn
+		return tx.Model(proposal).Update("status", proposal.Status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// apply carries out an approved proposal with the same UnitController
+// methods the admin handlers use.
+func (c *ProposalController) apply(tx *gorm.DB, proposal *models.ChangeProposal) error {
+	switch proposal.Action {
+	case models.ProposalActionCreate:
+		number, err := c.units.CreateUnit(tx, proposal.Level, UnitInput{
+			Name:         proposal.Name,
+			ParentNumber: proposal.ParentNumber,
+			TownStatus:   proposal.TownStatus,
+		})
+		if err != nil {
+			return err
+		}
+		proposal.UnitNumber = number
+		return nil
+	case models.ProposalActionRename:
+		return c.units.UpdateUnit(tx, proposal.Level, proposal.UnitNumber, UnitInput{Name: proposal.Name})
+	case models.ProposalActionReparent:
+		return c.units.UpdateUnit(tx, proposal.Level, proposal.UnitNumber, UnitInput{ParentNumber: proposal.ParentNumber})
+	case models.ProposalActionDelete:
+		return c.units.DeleteUnit(tx, proposal.Level, proposal.UnitNumber)
+	}
+	return errors.New("unknown proposal action")
+}
+
+// review closes a pending proposal as status. Approval applies the change
+// in the same transaction, so a change that no longer fits the hierarchy
+// leaves the proposal pending and nothing written.
+func (c *ProposalController) review(number string, reviewer *models.User, status, comment string) (*models.ChangeProposal, error) {
+	var proposal *models.ChangeProposal
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if proposal, err = lockPending(tx, number); err != nil {
+			return err
+		}
+		if proposal.ProposedBy == reviewer.Number {
+			return ErrProposalSelfReview
+		}
+
+		if status == models.ProposalStatusApproved {
+			if err := c.apply(tx, proposal); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		proposal.Status = status
+		proposal.ReviewedBy = reviewer.Number
+		proposal.ReviewComment = comment
+		proposal.ReviewedAt = &now
+		return tx.Model(proposal).Updates(map[string]interface{}{
+			"status":         proposal.Status,
+			"unit_number":    proposal.UnitNumber,
+			"reviewed_by":    proposal.ReviewedBy,
+			"review_comment": proposal.ReviewComment,
+			"reviewed_at":    proposal.ReviewedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+func (c *ProposalController) Approve(number string, reviewer *models.User, comment string) (*models.ChangeProposal, error) {
+	return c.review(number, reviewer, models.ProposalStatusApproved, comment)
+}
+
+func (c *ProposalController) Reject(number string, reviewer *models.User, comment string) (*models.ChangeProposal, error) {
+	return c.review(number, reviewer, models.ProposalStatusRejected, comment)
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+var (
+	ErrReleaseNotFound  = errors.New("release not found")
+	ErrReleaseNameTaken = errors.New("a release with this name already exists")
+)
+
+type ReleaseController struct {
+	db *database.Database
+}
+
+func NewReleaseController(db *database.Database) *ReleaseController {
+	return &ReleaseController{db: db}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *ReleaseController) WithContext(ctx context.Context) *ReleaseController {
+	return &ReleaseController{db: c.db.WithContext(ctx)}
+}
+
+// Cut freezes the live hierarchy as a new release. The copy, the bundle
+// checksums and the release row are written in one repeatable-read
+// transaction, so the release matches a single point in time even while
+// edits continue.
+func (c *ReleaseController) Cut(input *models.CreateReleaseInput, createdBy string) (*models.Release, error) {
+	release := &models.Release{
+		Number:    commons.UUIDGenerator(),
+		Name:      input.Name,
+		Notes:     input.Notes,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ").Error; err != nil {
+			return err
+		}
+
+		var taken int64
+		if err := tx.Model(&models.Release{}).Where("LOWER(name) = LOWER(?)", input.Name).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrReleaseNameTaken
+		}
+
+		if err := tx.Model(&models.AuditLog{}).Select("COALESCE(MAX(sequence), 0)").Scan(&release.Sequence).Error; err != nil {
+			return err
+		}
+
+		for _, level := range models.LevelOrder {
+			meta := models.Levels[level]
+			parent, townStatus := "''", "FALSE"
+			if meta.ParentColumn != "" {
+				parent = "COALESCE(" + meta.ParentColumn + ", '')"
+			}
+			if level == models.LevelDistrict {
+				townStatus = "COALESCE(town_status, FALSE)"
+			}
+
+			copied := tx.Exec(
+				"INSERT INTO release_units (release_number, level, unit_number, name, parent_number, town_status) "+
+					"SELECT ?, ?, number, name, "+parent+", "+townStatus+" FROM "+meta.Table+" WHERE deleted_at IS NULL",
+				release.Number, level)
+			if copied.Error != nil {
+				return fmt.Errorf("failed to copy %s: %w", meta.Table, copied.Error)
+			}
+			release.UnitCount += copied.RowsAffected
+		}
+
+		for _, format := range []string{models.ReleaseFormatNDJSON, models.ReleaseFormatCSV} {
+			hash := sha256.New()
+			counter := &countingWriter{w: hash}
+			if err := writeBundle(tx, counter, release.Number, format); err != nil {
+				return err
+			}
+			checksum := hex.EncodeToString(hash.Sum(nil))
+			if format == models.ReleaseFormatCSV {
+				release.ChecksumCSV, release.SizeCSV = checksum, counter.n
+			} else {
+				release.ChecksumNDJSON, release.SizeNDJSON = checksum, counter.n
+			}
+		}
+
+		return tx.Create(release).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// Find returns the release called name, or the newest release when name is
+// "latest".
+func (c *ReleaseController) Find(name string) (*models.Release, error) {
+	query := c.db.DB.Model(&models.Release{})
+	if name == models.ReleaseLatest {
+		query = query.Order("created_at DESC")
+	} else {
+		query = query.Where("name = ?", name)
+	}
+
+	var release models.Release
+	if err := query.First(&release).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReleaseNotFound
+		}
+		return nil, err
+	}
+	return &release, nil
+}
+
+func (c *ReleaseController) List(pagination commons.PaginationParams) ([]models.Release, int64, error) {
+	var total int64
+	if err := c.db.DB.Model(&models.Release{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var releases []models.Release
+	err := c.db.DB.Order("created_at DESC").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&releases).Error
+	return releases, total, err
+}
+
+// LevelCounts returns how many units each level had in the release.
+func (c *ReleaseController) LevelCounts(releaseNumber string) (map[string]int64, error) {
+	var rows []struct {
+		Level string
+		Count int64
+	}
+	if err := c.db.DB.Model(&models.ReleaseUnit{}).
+		Select("level, COUNT(*) AS count").
+		Where("release_number = ?", releaseNumber).
+		Group("level").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Level] = row.Count
+	}
+	return counts, nil
+}
+
+// ReleaseUnitFilter narrows a read of a release's units. Empty fields match
+// everything.
+type ReleaseUnitFilter struct {
+	Number       string
+	ParentNumber string
+	Name         string
+}
+
+func (c *ReleaseController) Units(releaseNumber, level string, filter ReleaseUnitFilter, pagination commons.PaginationParams) ([]models.ReleaseUnit, int64, error) {
+	query := c.db.DB.Model(&models.ReleaseUnit{}).
+		Where("release_number = ? AND level = ?", releaseNumber, level)
+	if filter.Number != "" {
+		query = query.Where("unit_number = ?", filter.Number)
+	}
+	if filter.ParentNumber != "" {
+		query = query.Where("parent_number = ?", filter.ParentNumber)
+	}
+	if filter.Name != "" {
+		query = query.Where("name = ?", filter.Name)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var units []models.ReleaseUnit
+	err := query.Order("unit_number").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&units).Error
+	return units, total, err
+}
+
+// WriteBundle streams the release's units to w in format. The output is
+// byte-for-byte the same on every call, so it always matches the checksum
+// recorded when the release was cut.
+func (c *ReleaseController) WriteBundle(w io.Writer, release *models.Release, format string) error {
+	return writeBundle(c.db.DB, w, release.Number, format)
+}
+
+func writeBundle(tx *gorm.DB, w io.Writer, releaseNumber, format string) error {
+	var csvWriter *csv.Writer
+	if format == models.ReleaseFormatCSV {
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write([]string{"level", "number", "name", "parent_number", "town_status"}); err != nil {
+			return err
+		}
+	}
+
+	for _, level := range models.LevelOrder {
+		rows, err := tx.Model(&models.ReleaseUnit{}).
+			Where("release_number = ? AND level = ?", releaseNumber, level).
+			Order(`unit_number COLLATE "C"`).
+			Rows()
+		if err != nil {
+			return err
+		}
+		err = writeBundleRows(tx, rows, w, csvWriter)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return nil
+}
+
+func writeBundleRows(tx *gorm.DB, rows *sql.Rows, w io.Writer, csvWriter *csv.Writer) error {
+	for rows.Next() {
+		var unit models.ReleaseUnit
+		if err := tx.ScanRows(rows, &unit); err != nil {
+			return err
+		}
+
+		if csvWriter != nil {
+			if err := csvWriter.Write([]string{unit.Level, unit.UnitNumber, unit.Name, unit.ParentNumber, strconv.FormatBool(unit.TownStatus)}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		line, err := json.Marshal(unit)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+	"opendataug.org/models"
+)
+
+func TestCutRelease(t *testing.T) {
+	db := openTestDB(t)
+	releases := NewReleaseController(db)
+
+	region := createUnit(t, db, models.LevelRegion, "Central", "")
+	createUnit(t, db, models.LevelDistrict, "Kampala", region)
+	deleted := createUnit(t, db, models.LevelDistrict, "Wakiso", region)
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return NewUnitController().DeleteUnit(tx, models.LevelDistrict, deleted)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := releases.Cut(&models.CreateReleaseInput{Name: "2026.10"}, "")
+	if err != nil {
+		t.Fatalf("cut: %v", err)
+	}
+	if release.UnitCount != 2 {
+		t.Errorf("unit count = %d, want 2", release.UnitCount)
+	}
+
+	counts, err := releases.LevelCounts(release.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range models.LevelOrder {
+		want := int64(0)
+		if level == models.LevelRegion || level == models.LevelDistrict {
+			want = 1
+		}
+		if counts[level] != want {
+			t.Errorf("%s count = %d, want %d", level, counts[level], want)
+		}
+	}
+
+	for _, bundle := range []struct {
+		format   string
+		checksum string
+		size     int64
+	}{
+		{models.ReleaseFormatNDJSON, release.ChecksumNDJSON, release.SizeNDJSON},
+		{models.ReleaseFormatCSV, release.ChecksumCSV, release.SizeCSV},
+	} {
+		var buf bytes.Buffer
+		if err := releases.WriteBundle(&buf, release, bundle.format); err != nil {
+			t.Fatalf("write %s bundle: %v", bundle.format, err)
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		if got := hex.EncodeToString(sum[:]); got != bundle.checksum {
+			t.Errorf("%s checksum = %s, recorded %s", bundle.format, got, bundle.checksum)
+		}
+		if int64(buf.Len()) != bundle.size {
+			t.Errorf("%s size = %d, recorded %d", bundle.format, buf.Len(), bundle.size)
+		}
+	}
+
+	if _, err := releases.Cut(&models.CreateReleaseInput{Name: "2026.10"}, ""); !errors.Is(err, ErrReleaseNameTaken) {
+		t.Errorf("second cut with the same name: got %v, want ErrReleaseNameTaken", err)
+	}
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+var (
+	ErrReportNotFound          = errors.New("report not found")
+	ErrReportDuplicate         = errors.New("you already have an open report of this kind for this record")
+	ErrReportInvalidTransition = errors.New("report cannot move to that status")
+)
+
+type ReportController struct {
+	db    *database.Database
+	units *UnitController
+}
+
+func NewReportController(db *database.Database) *ReportController {
+	return &ReportController{
+		db:    db,
+		units: NewUnitController(),
+	}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *ReportController) WithContext(ctx context.Context) *ReportController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
+// File records a report against the unit at level. A reporter may only have
+// one open report per record and category, so repeat submissions do not
+// inflate the counts stewards prioritise by.
+func (c *ReportController) File(level, unitNumber string, input *models.IssueReportInput, reportedBy, apiKeyNumber string) (*models.IssueReport, error) {
+	if _, err := c.units.FindUnit(c.db.DB, level, unitNumber); err != nil {
+		return nil, err
+	}
+
+	if reportedBy != "" {
+		var count int64
+		if err := c.db.DB.Model(&models.IssueReport{}).
+			Where("level = ? AND unit_number = ? AND category = ? AND reported_by = ? AND status IN ?",
+				level, unitNumber, input.Category, reportedBy,
+				[]string{models.ReportStatusOpen, models.ReportStatusTriaged}).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrReportDuplicate
+		}
+	}
+
+	report := &models.IssueReport{
+		Number:       commons.UUIDGenerator(),
+		Level:        level,
+		UnitNumber:   unitNumber,
+		Category:     input.Category,
+		Comment:      input.Comment,
+		Status:       models.ReportStatusOpen,
+		ReportedBy:   reportedBy,
+		APIKeyNumber: apiKeyNumber,
+	}
+	if err := c.db.DB.Create(report).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (c *ReportController) Find(number string) (*models.IssueReport, error) {
+	var report models.IssueReport
+	if err := c.db.DB.Where("number = ?", number).First(&report).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ReportFilter narrows the triage queue.
+type ReportFilter struct {
+	Status     string
+	Level      string
+	UnitNumber string
+	Category   string
+}
+
+// List returns reports oldest first, so the queue is worked in the order
+// reports arrived.
+func (c *ReportController) List(filter ReportFilter, pagination commons.PaginationParams) ([]models.IssueReport, int64, error) {
+	query := c.db.DB.Model(&models.IssueReport{})
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Level != "" {
+		query = query.Where("level = ?", filter.Level)
+	}
+	if filter.UnitNumber != "" {
+		query = query.Where("unit_number = ?", filter.UnitNumber)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var reports []models.IssueReport
+	err := query.Order("created_at ASC").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&reports).Error
+	return reports, total, err
+}
+
+// Triage moves a report to status, recording who did it and why.
+func (c *ReportController) Triage(number string, steward *models.User, status, note string) (*models.IssueReport, error) {
+	var report models.IssueReport
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("number = ?", number).
+			First(&report).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrReportNotFound
+			}
+			return err
+		}
+		if !models.CanReportTransition(report.Status, status) {
+			return ErrReportInvalidTransition
+		}
+
+		report.Status = status
+		report.TriagedBy = steward.Number
+		report.TriageNote = note
+		report.ClosedAt = nil
+		if models.IsReportClosed(status) {
+			now := time.Now()
+			report.ClosedAt = &now
+		}
+		return tx.Model(&report).Updates(map[string]interface{}{
+			"status":      report.Status,
+			"triaged_by":  report.TriagedBy,
+			"triage_note": report.TriageNote,
+			"closed_at":   report.ClosedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Counts summarises the reports filed against one record.
+func (c *ReportController) Counts(level, unitNumber string) (*models.ReportCounts, error) {
+	var rows []struct {
+		Category string
+		Status   string
+		Count    int64
+	}
+	if err := c.db.DB.Model(&models.IssueReport{}).
+		Select("category, status, COUNT(*) AS count").
+		Where("level = ? AND unit_number = ?", level, unitNumber).
+		Group("category, status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := &models.ReportCounts{}
+	for _, row := range rows {
+		counts.Total += row.Count
+		if models.IsReportClosed(row.Status) {
+			continue
+		}
+		counts.Open += row.Count
+		if counts.ByCategory == nil {
+			counts.ByCategory = map[string]int64{}
+		}
+		counts.ByCategory[row.Category] += row.Count
+	}
+	return counts, nil
+}
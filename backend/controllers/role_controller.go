@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+type RoleController struct {
+	db *database.Database
+}
+
+func NewRoleController(db *database.Database) *RoleController {
+	return &RoleController{db: db}
+}
+
+func (c *RoleController) ListAssignments(userNumber string) ([]models.RoleAssignment, error) {
+	var assignments []models.RoleAssignment
+	query := c.db.DB.Order("created_at DESC")
+	if userNumber != "" {
+		query = query.Where("user_number = ?", userNumber)
+	}
+	err := query.Find(&assignments).Error
+	return assignments, err
+}
+
+func (c *RoleController) UnitExists(level, number string) (bool, error) {
+	meta, ok := models.Levels[level]
+	if !ok {
+		return false, nil
+	}
+
+	var count int64
+	err := c.db.DB.Table(meta.Table).Where("number = ? AND deleted_at IS NULL", number).Count(&count).Error
+	return count > 0, err
+}
+
+func (c *RoleController) AssignmentExists(input *models.RoleAssignmentInput) (bool, error) {
+	var count int64
+	err := c.db.DB.Model(&models.RoleAssignment{}).
+		Where("user_number = ? AND role = ? AND scope_level = ? AND scope_number = ?",
+			input.UserNumber, input.Role, input.ScopeLevel, input.ScopeNumber).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (c *RoleController) CreateAssignment(input *models.RoleAssignmentInput, grantedBy string) (*models.RoleAssignment, error) {
+	assignment := &models.RoleAssignment{
+		Number:      commons.UUIDGenerator(),
+		UserNumber:  input.UserNumber,
+		Role:        input.Role,
+		ScopeLevel:  input.ScopeLevel,
+		ScopeNumber: input.ScopeNumber,
+		GrantedBy:   grantedBy,
+	}
+
+	if err := c.db.DB.Create(assignment).Error; err != nil {
+		return nil, err
+	}
+
+	return assignment, nil
+}
+
+func (c *RoleController) DeleteAssignment(number string) error {
+	result := c.db.DB.Where("number = ?", number).Delete(&models.RoleAssignment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+const sessionTouchInterval = time.Minute
+
+var (
+	ErrRefreshTokenReused   = errors.New("refresh token has already been used")
+	ErrSessionFamilyMissing = errors.New("session has no token family")
+)
+
+type SessionController struct {
+	db *database.Database
+}
+
+func NewSessionController(db *database.Database) *SessionController {
+	return &SessionController{db: db}
+}
+
+// CreateSession starts a new token family for a fresh login.
+func (c *SessionController) CreateSession(userNumber string, tokens *services.TokenDetails, userAgent, ipAddress string) (*models.Session, error) {
+	session := newSession(commons.UUIDGenerator(), userNumber, tokens, userAgent, ipAddress)
+
+	if err := c.db.DB.Create(session).Error; err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func newSession(familyNumber, userNumber string, tokens *services.TokenDetails, userAgent, ipAddress string) *models.Session {
+	return &models.Session{
+		Number:           commons.UUIDGenerator(),
+		UserNumber:       userNumber,
+		FamilyNumber:     familyNumber,
+		AccessTokenUUID:  tokens.AccessTokenUUID,
+		RefreshTokenUUID: tokens.RefreshTokenUUID,
+		UserAgent:        userAgent,
+		IPAddress:        ipAddress,
+		LastSeenAt:       time.Now().UTC(),
+		ExpiresAt:        time.Unix(*tokens.RefreshTokenExpiresIn, 0).UTC(),
+	}
+}
+
+func (c *SessionController) FindActiveByAccessUUID(tokenUUID string) (*models.Session, error) {
+	var session models.Session
+	err := c.db.DB.Where("access_token_uuid = ? AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > ?", tokenUUID, time.Now()).
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindByRefreshUUID returns the session regardless of its state so callers can
+// tell a replayed refresh token apart from an unknown one.
+func (c *SessionController) FindByRefreshUUID(tokenUUID string) (*models.Session, error) {
+	var session models.Session
+	if err := c.db.DB.Where("refresh_token_uuid = ?", tokenUUID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Rotate marks the presented refresh token as used and records its successor
+// in the same family. A token that was already rotated yields
+// ErrRefreshTokenReused, including when two refreshes race each other.
+func (c *SessionController) Rotate(session *models.Session, tokens *services.TokenDetails, userAgent, ipAddress string) (*models.Session, error) {
+	next := newSession(session.FamilyNumber, session.UserNumber, tokens, userAgent, ipAddress)
+
+	err := c.db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Session{}).
+			Where("number = ? AND rotated_at IS NULL AND revoked_at IS NULL", session.Number).
+			Update("rotated_at", time.Now().UTC())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		return tx.Create(next).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// RevokeFamily ends every session in one of the user's token families.
+func (c *SessionController) RevokeFamily(userNumber, familyNumber string) error {
+	if familyNumber == "" {
+		return ErrSessionFamilyMissing
+	}
+	return c.db.DB.Model(&models.Session{}).
+		Where("user_number = ? AND family_number = ? AND revoked_at IS NULL", userNumber, familyNumber).
+		Update("revoked_at", time.Now().UTC()).Error
+}
+
+// SignedInAt returns when the sign-in behind session happened: the start
+// of its token family, which refreshes carry forward.
+func (c *SessionController) SignedInAt(session *models.Session) (time.Time, error) {
+	var first models.Session
+	if err := c.db.DB.Where("user_number = ? AND family_number = ?", session.UserNumber, session.FamilyNumber).
+		Order("created_at").
+		First(&first).Error; err != nil {
+		return time.Time{}, err
+	}
+	return first.CreatedAt, nil
+}
+
+func (c *SessionController) Touch(session *models.Session) error {
+	if time.Since(session.LastSeenAt) < sessionTouchInterval {
+		return nil
+	}
+	return c.db.DB.Model(session).Update("last_seen_at", time.Now().UTC()).Error
+}
+
+// ListActiveSessions returns the live head of every token family, i.e. one
+// entry per signed-in device.
+func (c *SessionController) ListActiveSessions(userNumber string) ([]models.Session, error) {
+	var sessions []models.Session
+	err := c.db.DB.Where("user_number = ? AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > ?", userNumber, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (c *SessionController) RevokeSession(userNumber, familyNumber string) error {
+	result := c.db.DB.Model(&models.Session{}).
+		Where("user_number = ? AND family_number = ? AND revoked_at IS NULL", userNumber, familyNumber).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (c *SessionController) RevokeOtherSessions(userNumber, keepFamilyNumber string) (int64, error) {
+	var families int64
+	if err := c.db.DB.Model(&models.Session{}).
+		Where("user_number = ? AND family_number <> ? AND revoked_at IS NULL AND rotated_at IS NULL", userNumber, keepFamilyNumber).
+		Count(&families).Error; err != nil {
+		return 0, err
+	}
+
+	result := c.db.DB.Model(&models.Session{}).
+		Where("user_number = ? AND family_number <> ? AND revoked_at IS NULL", userNumber, keepFamilyNumber).
+		Update("revoked_at", time.Now().UTC())
+	return families, result.Error
+}
+
+func (c *SessionController) RevokeAllSessions(tx *gorm.DB, userNumber string) error {
+	return tx.Model(&models.Session{}).
+		Where("user_number = ? AND revoked_at IS NULL", userNumber).
+		Update("revoked_at", time.Now().UTC()).Error
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/database/dbtest"
+	"opendataug.org/models"
+)
+
+func TestRevokeFamily(t *testing.T) {
+	db := dbtest.Open(t)
+	sessions := NewSessionController(db)
+
+	session := func(userNumber, familyNumber string) *models.Session {
+		t.Helper()
+		session := &models.Session{
+			Number:           commons.UUIDGenerator(),
+			UserNumber:       userNumber,
+			FamilyNumber:     familyNumber,
+			AccessTokenUUID:  commons.UUIDGenerator(),
+			RefreshTokenUUID: commons.UUIDGenerator(),
+			LastSeenAt:       time.Now(),
+			ExpiresAt:        time.Now().Add(time.Hour),
+		}
+		if err := db.DB.Create(session).Error; err != nil {
+			t.Fatal(err)
+		}
+		return session
+	}
+	revoked := func(session *models.Session) bool {
+		t.Helper()
+		var current models.Session
+		if err := db.DB.Where("number = ?", session.Number).First(&current).Error; err != nil {
+			t.Fatal(err)
+		}
+		return current.RevokedAt != nil
+	}
+
+	var users [2]string
+	for i := range users {
+		user := models.User{Number: commons.UUIDGenerator(), Email: commons.UUIDGenerator() + "@example.org", FirstName: "Session", Role: models.RoleUser, Status: models.UserStatusActive}
+		if err := db.DB.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		users[i] = user.Number
+	}
+
+	// Sessions from before token families had none of their own.
+	legacy := [2]*models.Session{session(users[0], ""), session(users[1], "")}
+	if err := database.Migrate(db.DB); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range legacy {
+		var current models.Session
+		if err := db.DB.Where("number = ?", s.Number).First(&current).Error; err != nil {
+			t.Fatal(err)
+		}
+		if current.FamilyNumber != s.Number {
+			t.Errorf("legacy session family = %q, want its own number", current.FamilyNumber)
+		}
+	}
+
+	if err := sessions.RevokeFamily(users[0], ""); !errors.Is(err, ErrSessionFamilyMissing) {
+		t.Errorf("revoke empty family: got %v, want ErrSessionFamilyMissing", err)
+	}
+
+	family := commons.UUIDGenerator()
+	reused := session(users[0], family)
+	successor := session(users[0], family)
+	// A family number presented for another user must not reach their
+	// sessions.
+	other := session(users[1], family)
+
+	if err := sessions.RevokeFamily(users[0], family); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name    string
+		session *models.Session
+		want    bool
+	}{
+		{"reused", reused, true},
+		{"successor", successor, true},
+		{"other user", other, false},
+		{"own legacy", legacy[0], false},
+		{"other legacy", legacy[1], false},
+	} {
+		if got := revoked(tt.session); got != tt.want {
+			t.Errorf("%s session revoked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+const (
+	// trashPurgeInterval is how often expired units are purged.
+	trashPurgeInterval = time.Hour
+	// defaultTrashRetention keeps deleted units for three months.
+	defaultTrashRetention = 90 * 24 * time.Hour
+)
+
+// TrashRetention reads TRASH_RETENTION, how long deleted units are kept
+// before the purge job removes them. Zero turns automatic purging off.
+func TrashRetention() time.Duration {
+	value := os.Getenv("TRASH_RETENTION")
+	if value == "" {
+		return defaultTrashRetention
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention < 0 {
+		log.Printf("Invalid TRASH_RETENTION %q, using default", value)
+		return defaultTrashRetention
+	}
+	return retention
+}
+
+// TrashController lists, restores and purges soft-deleted units.
+type TrashController struct {
+	db        *database.Database
+	units     *UnitController
+	retention time.Duration
+}
+
+func NewTrashController(db *database.Database) *TrashController {
+	return &TrashController{
+		db:        db,
+		units:     NewUnitController(),
+		retention: TrashRetention(),
+	}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *TrashController) WithContext(ctx context.Context) *TrashController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
+// List returns the deleted units at level, most recently deleted first,
+// optionally only those whose name contains query.
+func (c *TrashController) List(level, query string, pagination commons.PaginationParams) ([]models.TrashedUnit, int64, error) {
+	meta, err := levelMeta(level)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	base := c.db.DB.Table(meta.Table).Where("deleted_at IS NOT NULL")
+	if query != "" {
+		base = base.Where("LOWER(name) LIKE LOWER(?)", "%"+query+"%")
+	}
+
+	var total int64
+	if err := base.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	columns := "number, name, deleted_at"
+	if meta.ParentColumn != "" {
+		columns += ", " + meta.ParentColumn + " AS parent_number"
+	}
+
+	var rows []struct {
+		Number       string
+		Name         string
+		ParentNumber string
+		DeletedAt    time.Time
+	}
+	if err := base.Select(columns).
+		Order("deleted_at DESC").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	units := make([]models.TrashedUnit, len(rows))
+	for i, row := range rows {
+		unit := models.TrashedUnit{
+			Level:        level,
+			Number:       row.Number,
+			Name:         row.Name,
+			ParentNumber: row.ParentNumber,
+			DeletedAt:    row.DeletedAt,
+		}
+
+		if unit.MergedInto, err = c.units.ResolveAlias(c.db.DB, level, row.Number); err != nil {
+			return nil, 0, err
+		}
+
+		switch err := c.units.checkRestore(c.db.DB, level, row.Number); {
+		case err == nil:
+			unit.Restorable = true
+		case errors.Is(err, ErrUnitMerged), errors.Is(err, ErrUnitParentNotFound), errors.Is(err, ErrUnitNameTaken):
+		default:
+			return nil, 0, err
+		}
+
+		if c.retention > 0 {
+			purgeAfter := row.DeletedAt.Add(c.retention)
+			unit.PurgeAfter = &purgeAfter
+		}
+		units[i] = unit
+	}
+	return units, total, nil
+}
+
+func (c *TrashController) Restore(level, number string) error {
+	return c.db.DB.Transaction(func(tx *gorm.DB) error {
+		return c.units.RestoreUnit(tx, level, number)
+	})
+}
+
+func (c *TrashController) Purge(level, number string) error {
+	return c.db.DB.Transaction(func(tx *gorm.DB) error {
+		return c.units.PurgeUnit(tx, level, number)
+	})
+}
+
+// PurgeExpired purges units deleted longer ago than the retention period
+// and returns how many it removed. Levels are worked from the bottom up so
+// a deleted subtree goes in one run; units that still have children are
+// left for a later run. A failure at one level does not hold up the rest;
+// every error is returned together.
+func (c *TrashController) PurgeExpired(now time.Time) (int, error) {
+	if c.retention <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-c.retention)
+
+	purged := 0
+	var errs []error
+	for i := len(models.LevelOrder) - 1; i >= 0; i-- {
+		level := models.LevelOrder[i]
+		meta := models.Levels[level]
+
+		var numbers []string
+		if err := c.db.DB.Table(meta.Table).
+			Where("deleted_at IS NOT NULL AND deleted_at <= ?", cutoff).
+			Order("deleted_at").
+			Pluck("number", &numbers).Error; err != nil {
+			errs = append(errs, fmt.Errorf("list expired %s: %w", meta.Table, err))
+			continue
+		}
+
+		for _, number := range numbers {
+			if err := c.Purge(level, number); err != nil {
+				if !errors.Is(err, ErrUnitHasChildren) {
+					errs = append(errs, fmt.Errorf("purge %s %s: %w", level, number, err))
+				}
+				continue
+			}
+			purged++
+		}
+	}
+	return purged, errors.Join(errs...)
+}
+
+// RunPurger runs PurgeExpired every trashPurgeInterval until stop is
+// closed. Units still expired when the process stops are picked up by the
+// first run after the next start.
+func (c *TrashController) RunPurger(stop <-chan struct{}) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := c.PurgeExpired(time.Now())
+			if err != nil {
+				log.Printf("trash purger: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("trash purger: purged %d units", purged)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/database"
+	"opendataug.org/models"
+)
+
+func deleteUnit(t *testing.T, db *database.Database, level, number string, at time.Time) {
+	t.Helper()
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return NewUnitController().DeleteUnit(tx, level, number)
+	})
+	if err != nil {
+		t.Fatalf("delete %s %s: %v", level, number, err)
+	}
+	if err := db.DB.Table(models.Levels[level].Table).
+		Where("number = ?", number).
+		Update("deleted_at", at).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func unitExists(t *testing.T, db *database.Database, level, number string) bool {
+	t.Helper()
+	var count int64
+	if err := db.DB.Table(models.Levels[level].Table).Where("number = ?", number).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func TestPurgeExpired(t *testing.T) {
+	db := openTestDB(t)
+	trash := NewTrashController(db)
+	trash.retention = 24 * time.Hour
+
+	now := time.Now()
+	expired := now.Add(-48 * time.Hour)
+
+	// A deleted region with a deleted district goes in a single run.
+	gone := createUnit(t, db, models.LevelRegion, "Central", "")
+	goneDistrict := createUnit(t, db, models.LevelDistrict, "Kampala", gone)
+	deleteUnit(t, db, models.LevelDistrict, goneDistrict, expired)
+	deleteUnit(t, db, models.LevelRegion, gone, expired)
+
+	// An expired region whose district was deleted recently has to wait.
+	waiting := createUnit(t, db, models.LevelRegion, "Northern", "")
+	recent := createUnit(t, db, models.LevelDistrict, "Gulu", waiting)
+	deleteUnit(t, db, models.LevelDistrict, recent, now)
+	deleteUnit(t, db, models.LevelRegion, waiting, expired)
+
+	purged, err := trash.PurgeExpired(now)
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("purged %d units, want 2", purged)
+	}
+
+	for _, unit := range []struct {
+		level, number string
+		want          bool
+	}{
+		{models.LevelRegion, gone, false},
+		{models.LevelDistrict, goneDistrict, false},
+		{models.LevelRegion, waiting, true},
+		{models.LevelDistrict, recent, true},
+	} {
+		if got := unitExists(t, db, unit.level, unit.number); got != unit.want {
+			t.Errorf("%s %s exists = %v, want %v", unit.level, unit.number, got, unit.want)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	db := openTestDB(t)
+	trash := NewTrashController(db)
+	units := NewUnitController()
+
+	region := createUnit(t, db, models.LevelRegion, "Western", "")
+	district := createUnit(t, db, models.LevelDistrict, "Mbarara", region)
+	deleteUnit(t, db, models.LevelDistrict, district, time.Now())
+	deleteUnit(t, db, models.LevelRegion, region, time.Now())
+
+	if err := trash.Restore(models.LevelDistrict, district); !errors.Is(err, ErrUnitParentNotFound) {
+		t.Fatalf("restore before parent: got %v, want ErrUnitParentNotFound", err)
+	}
+	if err := trash.Restore(models.LevelRegion, region); err != nil {
+		t.Fatalf("restore region: %v", err)
+	}
+	if err := trash.Restore(models.LevelDistrict, district); err != nil {
+		t.Fatalf("restore district: %v", err)
+	}
+	if _, err := units.FindUnit(db.DB, models.LevelDistrict, district); err != nil {
+		t.Errorf("restored district: %v", err)
+	}
+	if err := trash.Restore(models.LevelDistrict, district); !errors.Is(err, ErrUnitNotDeleted) {
+		t.Errorf("restore live unit: got %v, want ErrUnitNotDeleted", err)
+	}
+}
@@ -0,0 +1,503 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+var (
+	ErrUnitNotFound       = errors.New("unit not found")
+	ErrUnitParentNotFound = errors.New("parent unit not found")
+	ErrUnitNameRequired   = errors.New("unit name is required")
+	ErrUnitNameTaken      = errors.New("a unit with this name already exists under the same parent")
+	ErrUnitHasNoParent    = errors.New("units at this level have no parent")
+	ErrUnitNotDeleted     = errors.New("unit is not deleted")
+	ErrUnitMerged         = errors.New("unit was merged into another unit")
+	ErrUnitHasChildren    = errors.New("unit still has child units, including deleted ones")
+)
+
+// UnitInput describes a new unit or the fields to change on an existing
+// one. Empty fields are left unchanged on update. TownStatus only applies to
+// districts.
+type UnitInput struct {
+	Name         string
+	ParentNumber string
+	TownStatus   bool
+}
+
+// UnitController writes administrative units at any level. The admin unit
+// handlers and approved change proposals both go through it, so the same
+// checks apply however a change arrives. Every method takes the transaction
+// to run in.
+type UnitController struct{}
+
+func NewUnitController() *UnitController {
+	return &UnitController{}
+}
+
+// UnitState is the current name and parent of a unit.
+type UnitState struct {
+	Name         string
+	ParentNumber string
+}
+
+func levelMeta(level string) (models.Level, error) {
+	meta, ok := models.Levels[level]
+	if !ok {
+		return models.Level{}, fmt.Errorf("unknown level %q", level)
+	}
+	return meta, nil
+}
+
+// unitModel returns an empty model for level with only Number set, which is
+// enough for updates to find the row and for the audit log to identify it.
+func unitModel(level, number string) (interface{}, error) {
+	switch level {
+	case models.LevelRegion:
+		return &models.Region{Number: number}, nil
+	case models.LevelDistrict:
+		return &models.District{Number: number}, nil
+	case models.LevelCounty:
+		return &models.County{Number: number}, nil
+	case models.LevelSubCounty:
+		return &models.SubCounty{Number: number}, nil
+	case models.LevelParish:
+		return &models.Parish{Number: number}, nil
+	case models.LevelVillage:
+		return &models.Village{Number: number}, nil
+	}
+	return nil, fmt.Errorf("unknown level %q", level)
+}
+
+func newUnit(level, number string, input UnitInput) (interface{}, error) {
+	switch level {
+	case models.LevelRegion:
+		return &models.Region{Number: number, Name: input.Name}, nil
+	case models.LevelDistrict:
+		return &models.District{Number: number, Name: input.Name, RegionNumber: input.ParentNumber, TownStatus: input.TownStatus}, nil
+	case models.LevelCounty:
+		return &models.County{Number: number, Name: input.Name, DistrictNumber: input.ParentNumber}, nil
+	case models.LevelSubCounty:
+		return &models.SubCounty{Number: number, Name: input.Name, CountyNumber: input.ParentNumber}, nil
+	case models.LevelParish:
+		return &models.Parish{Number: number, Name: input.Name, SubCountyNumber: input.ParentNumber}, nil
+	case models.LevelVillage:
+		return &models.Village{Number: number, Name: input.Name, ParishNumber: input.ParentNumber}, nil
+	}
+	return nil, fmt.Errorf("unknown level %q", level)
+}
+
+// FindUnit returns the current state of a live unit.
+func (c *UnitController) FindUnit(tx *gorm.DB, level, number string) (*UnitState, error) {
+	meta, err := levelMeta(level)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := "name"
+	if meta.ParentColumn != "" {
+		columns += ", " + meta.ParentColumn + " AS parent_number"
+	}
+
+	var state UnitState
+	result := tx.Table(meta.Table).
+		Select(columns).
+		Where("number = ? AND deleted_at IS NULL", number).
+		Limit(1).
+		Scan(&state)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrUnitNotFound
+	}
+	return &state, nil
+}
+
+func (c *UnitController) checkParent(tx *gorm.DB, meta models.Level, parentNumber string) error {
+	if meta.ParentLevel == "" {
+		return ErrUnitHasNoParent
+	}
+	if _, err := c.FindUnit(tx, meta.ParentLevel, parentNumber); err != nil {
+		if errors.Is(err, ErrUnitNotFound) {
+			return ErrUnitParentNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// checkNameFree rejects a name already used by a sibling, ignoring case.
+// Regions have no parent, so their names must be unique overall.
+func (c *UnitController) checkNameFree(tx *gorm.DB, meta models.Level, name, parentNumber, exceptNumber string) error {
+	query := tx.Table(meta.Table).
+		Where("deleted_at IS NULL AND LOWER(name) = LOWER(?)", name)
+	if meta.ParentColumn != "" {
+		query = query.Where(meta.ParentColumn+" = ?", parentNumber)
+	}
+	if exceptNumber != "" {
+		query = query.Where("number <> ?", exceptNumber)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUnitNameTaken
+	}
+	return nil
+}
+
+// CreateUnit adds a unit at level under input.ParentNumber and returns its
+// number.
+func (c *UnitController) CreateUnit(tx *gorm.DB, level string, input UnitInput) (string, error) {
+	meta, err := levelMeta(level)
+	if err != nil {
+		return "", err
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return "", ErrUnitNameRequired
+	}
+	if meta.ParentLevel != "" {
+		if err := c.checkParent(tx, meta, input.ParentNumber); err != nil {
+			return "", err
+		}
+	}
+	if err := c.checkNameFree(tx, meta, input.Name, input.ParentNumber, ""); err != nil {
+		return "", err
+	}
+
+	number := commons.UUIDGenerator()
+	unit, err := newUnit(level, number, input)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Create(unit).Error; err != nil {
+		return "", err
+	}
+	return number, nil
+}
+
+// UpdateUnit renames and/or re-parents a unit.
+func (c *UnitController) UpdateUnit(tx *gorm.DB, level, number string, input UnitInput) error {
+	meta, err := levelMeta(level)
+	if err != nil {
+		return err
+	}
+
+	current, err := c.FindUnit(tx, level, number)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	name := current.Name
+	if input.Name = strings.TrimSpace(input.Name); input.Name != "" && input.Name != current.Name {
+		name = input.Name
+		updates["name"] = name
+	}
+	parent := current.ParentNumber
+	if input.ParentNumber != "" && input.ParentNumber != current.ParentNumber {
+		if err := c.checkParent(tx, meta, input.ParentNumber); err != nil {
+			return err
+		}
+		parent = input.ParentNumber
+		updates[meta.ParentColumn] = parent
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if err := c.checkNameFree(tx, meta, name, parent, number); err != nil {
+		return err
+	}
+
+	model, err := unitModel(level, number)
+	if err != nil {
+		return err
+	}
+	return tx.Model(model).Where("number = ?", number).Updates(updates).Error
+}
+
+// DeleteUnit soft-deletes a unit.
+func (c *UnitController) DeleteUnit(tx *gorm.DB, level, number string) error {
+	if _, err := c.FindUnit(tx, level, number); err != nil {
+		return err
+	}
+
+	// Deletes take an empty model: gorm adds every primary key of a
+	// populated one, and ID is never loaded here.
+	model, err := unitModel(level, "")
+	if err != nil {
+		return err
+	}
+	return tx.Where("number = ?", number).Delete(model).Error
+}
+
+// findDeletedUnit returns the state of a soft-deleted unit. A live unit
+// gives ErrUnitNotDeleted.
+func (c *UnitController) findDeletedUnit(tx *gorm.DB, level, number string) (*UnitState, error) {
+	meta, err := levelMeta(level)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := "name, deleted_at"
+	if meta.ParentColumn != "" {
+		columns += ", " + meta.ParentColumn + " AS parent_number"
+	}
+
+	var row struct {
+		UnitState
+		DeletedAt *time.Time
+	}
+	result := tx.Table(meta.Table).
+		Select(columns).
+		Where("number = ?", number).
+		Limit(1).
+		Scan(&row)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrUnitNotFound
+	}
+	if row.DeletedAt == nil {
+		return nil, ErrUnitNotDeleted
+	}
+	return &row.UnitState, nil
+}
+
+// checkRestore reports why a soft-deleted unit cannot be restored: it was
+// merged away, its parent is gone or a sibling has taken its name.
+func (c *UnitController) checkRestore(tx *gorm.DB, level, number string) error {
+	meta, err := levelMeta(level)
+	if err != nil {
+		return err
+	}
+
+	state, err := c.findDeletedUnit(tx, level, number)
+	if err != nil {
+		return err
+	}
+
+	mergedInto, err := c.ResolveAlias(tx, level, number)
+	if err != nil {
+		return err
+	}
+	if mergedInto != "" {
+		return ErrUnitMerged
+	}
+
+	if meta.ParentLevel != "" {
+		if err := c.checkParent(tx, meta, state.ParentNumber); err != nil {
+			return err
+		}
+	}
+	return c.checkNameFree(tx, meta, state.Name, state.ParentNumber, number)
+}
+
+// RestoreUnit brings back a soft-deleted unit under its old number. Its
+// parent must still exist and its name must not have been taken by a
+// sibling since; units deleted by a merge stay merged.
+func (c *UnitController) RestoreUnit(tx *gorm.DB, level, number string) error {
+	if err := c.checkRestore(tx, level, number); err != nil {
+		return err
+	}
+
+	model, err := unitModel(level, number)
+	if err != nil {
+		return err
+	}
+	// Unscoped so the audit log snapshots the deleted row and records the
+	// restore.
+	return tx.Unscoped().Model(model).Where("number = ?", number).Update("deleted_at", nil).Error
+}
+
+// PurgeUnit permanently removes a soft-deleted unit, which frees its number.
+// Children, even deleted ones, must be purged first. Aliases redirecting to
+// the unit, duplicate dismissals naming it and role assignments scoped to
+// it go with it; aliases recording that it was merged away are kept.
+func (c *UnitController) PurgeUnit(tx *gorm.DB, level, number string) error {
+	if _, err := c.findDeletedUnit(tx, level, number); err != nil {
+		return err
+	}
+
+	for _, child := range childLevels(level) {
+		meta := models.Levels[child]
+		var count int64
+		if err := tx.Table(meta.Table).Where(meta.ParentColumn+" = ?", number).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUnitHasChildren
+		}
+	}
+
+	if err := tx.Unscoped().
+		Where("level = ? AND unit_number = ?", level, number).
+		Delete(&models.UnitAlias{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().
+		Where("level = ? AND (number_a = ? OR number_b = ?)", level, number, number).
+		Delete(&models.DuplicateDismissal{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("scope_level = ? AND scope_number = ?", level, number).
+		Delete(&models.RoleAssignment{}).Error; err != nil {
+		return err
+	}
+
+	model, err := unitModel(level, "")
+	if err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("number = ?", number).Delete(model).Error
+}
+
+// childLevels returns the levels whose parent is level.
+func childLevels(level string) []string {
+	var children []string
+	for _, child := range models.LevelOrder {
+		if models.Levels[child].ParentLevel == level {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// CountChildren returns how many live children each of numbers has across
+// all child levels.
+func (c *UnitController) CountChildren(tx *gorm.DB, level string, numbers []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(numbers))
+	if len(numbers) == 0 {
+		return counts, nil
+	}
+
+	for _, child := range childLevels(level) {
+		meta := models.Levels[child]
+
+		var rows []struct {
+			ParentNumber string
+			Count        int64
+		}
+		if err := tx.Table(meta.Table).
+			Select(meta.ParentColumn+" AS parent_number, COUNT(*) AS count").
+			Where("deleted_at IS NULL AND "+meta.ParentColumn+" IN ?", numbers).
+			Group(meta.ParentColumn).
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			counts[row.ParentNumber] += row.Count
+		}
+	}
+	return counts, nil
+}
+
+// ResolveAlias returns the unit a merged number now points to, or "" when
+// number was never merged.
+func (c *UnitController) ResolveAlias(tx *gorm.DB, level, number string) (string, error) {
+	var targets []string
+	if err := tx.Model(&models.UnitAlias{}).
+		Where("level = ? AND alias_number = ?", level, number).
+		Limit(1).
+		Pluck("unit_number", &targets).Error; err != nil {
+		return "", err
+	}
+	if len(targets) == 0 {
+		return "", nil
+	}
+	return targets[0], nil
+}
+
+// MergeUnit folds mergeNumber into keepNumber: children of the merged unit
+// move to the kept one, issue reports and scoped role assignments follow
+// it, and the merged unit is deleted and left behind as an alias. Children
+// that end up sharing a name under the kept unit are not merged themselves;
+// the duplicate finder will list them.
+func (c *UnitController) MergeUnit(tx *gorm.DB, level, keepNumber, mergeNumber, mergedBy string) (*models.MergeResult, error) {
+	if keepNumber == mergeNumber {
+		return nil, errors.New("cannot merge a unit into itself")
+	}
+	if _, err := c.FindUnit(tx, level, keepNumber); err != nil {
+		return nil, err
+	}
+	merged, err := c.FindUnit(tx, level, mergeNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.MergeResult{
+		Level:       level,
+		KeepNumber:  keepNumber,
+		MergeNumber: mergeNumber,
+		Reparented:  map[string]int{},
+	}
+
+	for _, child := range childLevels(level) {
+		meta := models.Levels[child]
+		model, err := unitModel(child, "")
+		if err != nil {
+			return nil, err
+		}
+		update := tx.Model(model).
+			Where(meta.ParentColumn+" = ?", mergeNumber).
+			Update(meta.ParentColumn, keepNumber)
+		if update.Error != nil {
+			return nil, update.Error
+		}
+		result.Reparented[child] = int(update.RowsAffected)
+
+		// Children in the trash move too, or they could never be restored
+		// and would keep the merged unit from being purged.
+		if err := tx.Unscoped().Model(model).
+			Where(meta.ParentColumn+" = ? AND deleted_at IS NOT NULL", mergeNumber).
+			Update(meta.ParentColumn, keepNumber).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Model(&models.IssueReport{}).
+		Where("level = ? AND unit_number = ?", level, mergeNumber).
+		Update("unit_number", keepNumber).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.RoleAssignment{}).
+		Where("scope_level = ? AND scope_number = ?", level, mergeNumber).
+		Update("scope_number", keepNumber).Error; err != nil {
+		return nil, err
+	}
+
+	// Earlier merges into the merged unit now point at the kept one, so
+	// redirects never chain.
+	if err := tx.Model(&models.UnitAlias{}).
+		Where("level = ? AND unit_number = ?", level, mergeNumber).
+		Update("unit_number", keepNumber).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&models.UnitAlias{
+		Level:       level,
+		AliasNumber: mergeNumber,
+		UnitNumber:  keepNumber,
+		AliasName:   merged.Name,
+		MergedBy:    mergedBy,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := c.DeleteUnit(tx, level, mergeNumber); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
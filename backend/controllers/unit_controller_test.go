@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/database"
+	"opendataug.org/database/dbtest"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+// openTestDB returns a migrated test schema with the audit log hooked in,
+// as in the running app.
+func openTestDB(t *testing.T) *database.Database {
+	t.Helper()
+	db := dbtest.Open(t)
+	if err := services.RegisterAuditCallbacks(db.DB); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createUnit(t *testing.T, db *database.Database, level, name, parent string) string {
+	t.Helper()
+	var number string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		number, err = NewUnitController().CreateUnit(tx, level, UnitInput{Name: name, ParentNumber: parent})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("create %s %q: %v", level, name, err)
+	}
+	return number
+}
+
+func TestMergeRegions(t *testing.T) {
+	db := openTestDB(t)
+	units := NewUnitController()
+
+	keep := createUnit(t, db, models.LevelRegion, "Central", "")
+	merge := createUnit(t, db, models.LevelRegion, "Centre", "")
+	district := createUnit(t, db, models.LevelDistrict, "Kampala", merge)
+	trashed := createUnit(t, db, models.LevelDistrict, "Wakiso", merge)
+	deleteUnit(t, db, models.LevelDistrict, trashed, time.Now())
+
+	var result *models.MergeResult
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = units.MergeUnit(tx, models.LevelRegion, keep, merge, "")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if got := result.Reparented[models.LevelDistrict]; got != 1 {
+		t.Errorf("reparented %d districts, want only the live one", got)
+	}
+
+	state, err := units.FindUnit(db.DB, models.LevelDistrict, district)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.ParentNumber != keep {
+		t.Errorf("district parent = %q, want %q", state.ParentNumber, keep)
+	}
+
+	if _, err := units.FindUnit(db.DB, models.LevelRegion, merge); !errors.Is(err, ErrUnitNotFound) {
+		t.Errorf("merged region lookup: got %v, want ErrUnitNotFound", err)
+	}
+	target, err := units.ResolveAlias(db.DB, models.LevelRegion, merge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != keep {
+		t.Errorf("alias points at %q, want %q", target, keep)
+	}
+
+	// The deleted district followed the merge, so it can come back under
+	// the kept region and does not hold up purging the merged one.
+	trash := NewTrashController(db)
+	if err := trash.Restore(models.LevelDistrict, trashed); err != nil {
+		t.Errorf("restore deleted district: %v", err)
+	}
+	if err := trash.Purge(models.LevelRegion, merge); err != nil {
+		t.Errorf("purge merged region: %v", err)
+	}
+}
@@ -1,9 +1,13 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"net/mail"
 
@@ -15,23 +19,39 @@ import (
 )
 
 type UserController struct {
-	db         *database.Database
-	jwtService *services.JWTService
+	db             *database.Database
+	jwtService     *services.JWTService
+	sessions       *SessionController
+	passwordPolicy *services.PasswordPolicy
+	authorizer     *services.Authorizer
 }
 
 func NewUserController(db *database.Database, jwtService *services.JWTService) *UserController {
 	return &UserController{
-		db:         db,
-		jwtService: jwtService,
+		db:             db,
+		jwtService:     jwtService,
+		sessions:       NewSessionController(db),
+		passwordPolicy: services.LoadPasswordPolicy(),
+		authorizer:     services.NewAuthorizer(db.DB),
 	}
 }
 
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *UserController) WithContext(ctx context.Context) *UserController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
 type LoginResponse struct {
 	AccessToken  *string `json:"access_token"`
 	RefreshToken *string `json:"refresh_token"`
 	UserNumber   string  `json:"user_number"`
 	Role         string  `json:"role"`
 	ExpiresIn    *int64  `json:"expires_in"`
+	// MFAEnrollmentRequired tells the client to send the user through TOTP
+	// enrollment; until then only /auth routes accept the session.
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
 }
 
 type ResetPasswordInput struct {
@@ -83,7 +103,7 @@ func (c *UserController) Create(user *models.User) error {
 
 func (c *UserController) GetPasswordByUserNumber(userNumber string) (*models.UserPassword, error) {
 	var password models.UserPassword
-	if err := c.db.DB.Where("user_number = ?", userNumber).First(&password).Error; err != nil {
+	if err := c.db.DB.Where("user_number = ?", userNumber).Order("created_at DESC").First(&password).Error; err != nil {
 		return nil, fmt.Errorf("password not found: %w", err)
 	}
 	return &password, nil
@@ -101,51 +121,182 @@ func (c *UserController) FindPasswordResetByToken(token string) (*models.Passwor
 	return &reset, nil
 }
 
+func (c *UserController) PasswordPolicy() *services.PasswordPolicy {
+	return c.passwordPolicy
+}
+
+// PasswordHistory returns the hashes of the user's most recent passwords,
+// newest first.
+func (c *UserController) PasswordHistory(tx *gorm.DB, userNumber string, limit int) ([]string, error) {
+	var hashes []string
+	err := tx.Model(&models.UserPassword{}).
+		Where("user_number = ?", userNumber).
+		Order("created_at DESC").
+		Limit(limit).
+		Pluck("user_password", &hashes).Error
+	return hashes, err
+}
+
+// ExecutePasswordReset stores a new password. Each change adds a row so the
+// policy can refuse recently used passwords; rows beyond the history the
+// policy needs are removed.
 func (c *UserController) ExecutePasswordReset(tx *gorm.DB, userNumber string, hashedPassword string) error {
-	var userPassword models.UserPassword
-	if err := tx.Where("user_number = ?", userNumber).First(&userPassword).Error; err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("failed to check existing password: %w", err)
-		}
-		userPassword = models.UserPassword{
-			Number:       commons.UUIDGenerator(),
-			UserPassword: hashedPassword,
-			UserNumber:   userNumber,
-		}
-		return tx.Create(&userPassword).Error
+	userPassword := models.UserPassword{
+		Number:       commons.UUIDGenerator(),
+		UserPassword: hashedPassword,
+		UserNumber:   userNumber,
+	}
+	if err := tx.Create(&userPassword).Error; err != nil {
+		return err
 	}
 
-	return tx.Model(&userPassword).Update("user_password", hashedPassword).Error
+	keep := max(c.passwordPolicy.HistorySize, 1)
+	recent := tx.Model(&models.UserPassword{}).
+		Select("number").
+		Where("user_number = ?", userNumber).
+		Order("created_at DESC").
+		Limit(keep)
+	return tx.Unscoped().
+		Where("user_number = ? AND number NOT IN (?)", userNumber, recent).
+		Delete(&models.UserPassword{}).Error
+}
+
+var (
+	// ErrInvalidCredentials covers an unknown email, a wrong password and an
+	// account without a password alike, so sign-in never reveals which
+	// addresses are registered.
+	ErrInvalidCredentials     = errors.New("invalid email or password")
+	ErrAccountSuspended       = errors.New("account has been suspended")
+	ErrAccountPendingDeletion = errors.New("account is scheduled for deletion, use the link in your email to cancel")
+	ErrAccountInactive        = errors.New("account is not active")
+)
+
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// spendPasswordCheck does the work of a password comparison that has no
+// account behind it, so unknown emails take as long as wrong passwords.
+func spendPasswordCheck(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = commons.HashPassword(commons.UUIDGenerator())
+	})
+	if dummyPasswordHash != "" {
+		_, _ = commons.ComparePassword(dummyPasswordHash, password)
+	}
 }
 
+// AuthenticateUser checks an email and password. The password is checked
+// before anything about the account is reported, so callers only learn an
+// account is suspended, being deleted or inactive once they have shown they
+// own it; every other failure is ErrInvalidCredentials.
 func (c *UserController) AuthenticateUser(email, password string) (*models.User, error) {
 	if _, err := mail.ParseAddress(email); err != nil {
-		return nil, fmt.Errorf("invalid email address")
+		spendPasswordCheck(password)
+		return nil, ErrInvalidCredentials
 	}
 
 	user, err := c.FindByEmail(strings.ToLower(email))
 	if err != nil {
-		return nil, err
-	}
-
-	if user.Status != "ACTIVE" {
-		return nil, fmt.Errorf("account is not active")
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		spendPasswordCheck(password)
+		return nil, ErrInvalidCredentials
 	}
 
 	userPassword, err := c.GetPasswordByUserNumber(user.Number)
 	if err != nil {
-		return nil, fmt.Errorf("password not set")
+		spendPasswordCheck(password)
+		return nil, ErrInvalidCredentials
 	}
 
 	if _, err := commons.ComparePassword(userPassword.UserPassword, password); err != nil {
-		return nil, fmt.Errorf("Invalid password or email address")
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.IsSuspended() {
+		return nil, ErrAccountSuspended
+	}
+	if user.IsPendingDeletion() {
+		return nil, ErrAccountPendingDeletion
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, ErrAccountInactive
 	}
 
 	return user, nil
 }
 
-func (c *UserController) RefreshUserSession(refreshToken string) (*services.TokenDetails, error) {
-	return c.jwtService.RefreshToken(refreshToken)
+// RefreshUserSession exchanges a refresh token for a new token pair. Each
+// refresh token is single use: presenting one that was already exchanged
+// revokes every session in its family and alerts the account owner.
+func (c *UserController) RefreshUserSession(refreshToken, userAgent, ipAddress string) (*services.TokenDetails, error) {
+	claims, err := c.jwtService.ValidateToken(refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token: %w", err)
+	}
+
+	tokenUUID, ok := claims["token_uuid"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	session, err := c.sessions.FindByRefreshUUID(tokenUUID)
+	if err != nil {
+		return nil, fmt.Errorf("session has been revoked or has expired")
+	}
+
+	if session.RotatedAt != nil {
+		c.handleRefreshTokenReuse(session, ipAddress)
+		return nil, ErrRefreshTokenReused
+	}
+
+	if !session.IsActive() {
+		return nil, fmt.Errorf("session has been revoked or has expired")
+	}
+
+	tokens, err := c.jwtService.RefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.sessions.Rotate(session, tokens, userAgent, ipAddress); err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			c.handleRefreshTokenReuse(session, ipAddress)
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (c *UserController) handleRefreshTokenReuse(session *models.Session, ipAddress string) {
+	log.Printf("Refresh token reuse detected for user %s (family %s) from %s", session.UserNumber, session.FamilyNumber, ipAddress)
+
+	if err := c.sessions.RevokeFamily(session.UserNumber, session.FamilyNumber); err != nil {
+		log.Printf("Failed to revoke session family %s: %v", session.FamilyNumber, err)
+	}
+
+	user, err := c.FindByNumber(session.UserNumber)
+	if err != nil {
+		log.Printf("Failed to load user for security alert: %v", err)
+		return
+	}
+
+	emailService := services.Info{
+		Email:       user.Email,
+		MailType:    "Security alert - Open Data Uganda",
+		UserName:    user.FirstName,
+		CurrentYear: time.Now().Year(),
+		Type:        services.EmailTypeSecurityAlert,
+	}
+
+	if err := emailService.SendEmail(); err != nil {
+		log.Printf("Failed to send security alert to %s: %v", user.Email, err)
+	}
 }
 
 func (c *UserController) InitiatePasswordReset(email string) (*models.User, error) {
@@ -175,6 +326,20 @@ func (c *UserController) SetNewPassword(token, userNumber, newPassword, confirmP
 		return fmt.Errorf("Password reset link has expired")
 	}
 
+	user, err := c.FindByNumber(reset.UserNumber)
+	if err != nil {
+		return err
+	}
+
+	history, err := c.PasswordHistory(c.db.DB, user.Number, c.passwordPolicy.HistorySize)
+	if err != nil {
+		return fmt.Errorf("failed to check password history: %w", err)
+	}
+
+	if err := c.passwordPolicy.Validate(newPassword, user, history); err != nil {
+		return err
+	}
+
 	hashedPassword, err := commons.HashPassword(newPassword)
 	if err != nil {
 		return fmt.Errorf("failed to hash password")
@@ -182,7 +347,9 @@ func (c *UserController) SetNewPassword(token, userNumber, newPassword, confirmP
 
 	tx := c.db.DB.Begin()
 
-	if err := tx.Model(&models.User{}).Where("number = ?", reset.UserNumber).Update("status", "ACTIVE").Error; err != nil {
+	if err := tx.Model(&models.User{}).
+		Where("number = ? AND status NOT IN ?", reset.UserNumber, []string{models.UserStatusSuspended, models.UserStatusPendingDeletion}).
+		Update("status", models.UserStatusActive).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("Failed to activate user account")
 	}
@@ -197,28 +364,41 @@ func (c *UserController) SetNewPassword(token, userNumber, newPassword, confirmP
 		return fmt.Errorf("failed to update reset token: %w", err)
 	}
 
-	return tx.Commit().Error
-}
+	if err := c.sessions.RevokeAllSessions(tx, reset.UserNumber); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
 
-func (c *UserController) InvalidateSession(refreshToken string) error {
-	if refreshToken == "" {
-		return fmt.Errorf("missing refresh token")
+	// A reset is how the owner takes an account back, so any email change
+	// started from a hijacked session must not complete afterwards.
+	if err := tx.Model(&models.EmailChange{}).
+		Where("user_number = ? AND status = ?", reset.UserNumber, models.EmailChangePending).
+		Update("status", models.EmailChangeSuperseded).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to cancel pending email changes: %w", err)
 	}
 
-	_, err := c.jwtService.ValidateToken(refreshToken)
-	if err != nil {
-		return fmt.Errorf("invalid refresh token: %w", err)
+	return tx.Commit().Error
+}
+
+func (c *UserController) InvalidateSession(session *models.Session) error {
+	if session == nil {
+		return fmt.Errorf("missing session")
 	}
 
-	return nil
+	return c.sessions.RevokeSession(session.UserNumber, session.FamilyNumber)
 }
 
-func (c *UserController) CreateLoginSession(user *models.User) (*LoginResponse, *services.TokenDetails, error) {
+func (c *UserController) CreateLoginSession(user *models.User, userAgent, ipAddress string) (*LoginResponse, *services.TokenDetails, error) {
 	tokenDetails, err := c.jwtService.CreateToken(user.Number, string(user.Role))
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create token: %w", err)
 	}
 
+	if _, err := c.sessions.CreateSession(user.Number, tokenDetails, userAgent, ipAddress); err != nil {
+		return nil, nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
 	response := &LoginResponse{
 		AccessToken:  tokenDetails.AccessToken,
 		RefreshToken: tokenDetails.RefreshToken,
@@ -226,6 +406,13 @@ func (c *UserController) CreateLoginSession(user *models.User) (*LoginResponse,
 		Role:         string(user.Role),
 		ExpiresIn:    tokenDetails.AccessTokenExpiresIn,
 	}
+	if !user.MFAEnabled() {
+		required, err := c.authorizer.RequiresMFA(user)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check two-factor requirement: %w", err)
+		}
+		response.MFAEnrollmentRequired = required
+	}
 
 	return response, tokenDetails, nil
 }
@@ -255,3 +442,81 @@ func (c *UserController) CheckPhoneExists(phone string) (bool, error) {
 	result := c.db.DB.Where("phone = ?", phone).First(&user)
 	return result.RowsAffected > 0, result.Error
 }
+
+func (c *UserController) Sessions() *SessionController {
+	return c.sessions
+}
+
+// UserFilter narrows the admin user listing. Search matches email, first
+// name or last name.
+type UserFilter struct {
+	Search string
+	Status string
+	Role   models.UserRole
+}
+
+func (c *UserController) ListUsers(filter UserFilter, pagination commons.PaginationParams) ([]models.User, int64, error) {
+	query := c.db.DB.Model(&models.User{})
+
+	if filter.Search != "" {
+		pattern := "%" + strings.ToLower(filter.Search) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ?", pattern, pattern, pattern)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	err := query.Order("created_at DESC").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&users).Error
+	return users, total, err
+}
+
+// SuspendUser blocks the account and revokes its sessions in one
+// transaction, so access tokens already issued stop working at once.
+func (c *UserController) SuspendUser(userNumber, reason string) error {
+	return c.db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.User{}).Where("number = ?", userNumber).Updates(map[string]interface{}{
+			"status":            models.UserStatusSuspended,
+			"suspended_at":      now,
+			"suspension_reason": reason,
+		}).Error; err != nil {
+			return err
+		}
+		return c.sessions.RevokeAllSessions(tx, userNumber)
+	})
+}
+
+// ReactivateUser lifts a suspension. Accounts that never set a password go
+// back to INACTIVE so they still have to complete activation.
+func (c *UserController) ReactivateUser(userNumber string) error {
+	status := models.UserStatusActive
+	if _, err := c.GetPasswordByUserNumber(userNumber); err != nil {
+		status = models.UserStatusInactive
+	}
+
+	return c.db.DB.Model(&models.User{}).Where("number = ?", userNumber).Updates(map[string]interface{}{
+		"status":            status,
+		"suspended_at":      nil,
+		"suspension_reason": "",
+	}).Error
+}
+
+func (c *UserController) ChangeRole(user *models.User, role models.UserRole) error {
+	user.Role = role
+	if err := user.ValidateRole(); err != nil {
+		return err
+	}
+	return c.db.DB.Model(&models.User{}).Where("number = ?", user.Number).Update("role", role).Error
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+func TestAuthenticateUser(t *testing.T) {
+	db := openTestDB(t)
+	users := NewUserController(db, nil)
+
+	hash, err := commons.HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range []struct{ email, status string }{
+		{"active@example.org", models.UserStatusActive},
+		{"suspended@example.org", models.UserStatusSuspended},
+		{"deleting@example.org", models.UserStatusPendingDeletion},
+		{"inactive@example.org", models.UserStatusInactive},
+	} {
+		user := models.User{Number: commons.UUIDGenerator(), Email: account.email, FirstName: "Test", Role: models.RoleUser, Status: account.status}
+		if err := db.DB.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.DB.Create(&models.UserPassword{Number: commons.UUIDGenerator(), UserNumber: user.Number, UserPassword: hash}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		email, password string
+		want            error
+	}{
+		{"active@example.org", "correct horse battery staple", nil},
+		{"Active@Example.org", "correct horse battery staple", nil},
+		{"active@example.org", "wrong", ErrInvalidCredentials},
+		{"nobody@example.org", "correct horse battery staple", ErrInvalidCredentials},
+		{"not an email", "correct horse battery staple", ErrInvalidCredentials},
+		// Without the password, account status stays hidden.
+		{"suspended@example.org", "wrong", ErrInvalidCredentials},
+		{"deleting@example.org", "wrong", ErrInvalidCredentials},
+		{"inactive@example.org", "wrong", ErrInvalidCredentials},
+		{"suspended@example.org", "correct horse battery staple", ErrAccountSuspended},
+		{"deleting@example.org", "correct horse battery staple", ErrAccountPendingDeletion},
+		{"inactive@example.org", "correct horse battery staple", ErrAccountInactive},
+	}
+
+	for _, tt := range tests {
+		user, err := users.AuthenticateUser(tt.email, tt.password)
+		if tt.want == nil {
+			if err != nil || user == nil {
+				t.Errorf("AuthenticateUser(%q) = %v, want success", tt.email, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("AuthenticateUser(%q, %q) = %v, want %v", tt.email, tt.password, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("delivery not found")
+)
+
+type WebhookController struct {
+	db *database.Database
+}
+
+func NewWebhookController(db *database.Database) *WebhookController {
+	return &WebhookController{db: db}
+}
+
+// WithContext returns a copy of the controller whose queries carry ctx.
+func (c *WebhookController) WithContext(ctx context.Context) *WebhookController {
+	scoped := *c
+	scoped.db = c.db.WithContext(ctx)
+	return &scoped
+}
+
+func newWebhookSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(raw), nil
+}
+
+// Create registers a webhook for createdBy, or for the organization named in
+// input. The signing secret is generated here and returned on the webhook.
+func (c *WebhookController) Create(input *models.WebhookInput, createdBy string) (*models.Webhook, error) {
+	events, err := models.NormalizeWebhookEvents(input.Events)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &models.Webhook{
+		Number:             commons.UUIDGenerator(),
+		UserNumber:         createdBy,
+		OrganizationNumber: input.OrganizationNumber,
+		URL:                input.URL,
+		Description:        input.Description,
+		Events:             events,
+		Secret:             secret,
+		Active:             true,
+	}
+	if err := c.db.DB.Create(webhook).Error; err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (c *WebhookController) Find(number string) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := c.db.DB.Where("number = ?", number).First(&webhook).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// List returns an organization's webhooks, or a user's personal ones when
+// organizationNumber is empty, newest first.
+func (c *WebhookController) List(userNumber, organizationNumber string, pagination commons.PaginationParams) ([]models.Webhook, int64, error) {
+	query := c.db.DB.Model(&models.Webhook{})
+	if organizationNumber != "" {
+		query = query.Where("organization_number = ?", organizationNumber)
+	} else {
+		query = query.Where("user_number = ? AND (organization_number = '' OR organization_number IS NULL)", userNumber)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var webhooks []models.Webhook
+	err := query.Order("created_at DESC").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&webhooks).Error
+	return webhooks, total, err
+}
+
+func (c *WebhookController) Update(webhook *models.Webhook, input *models.WebhookUpdateInput) error {
+	updates := map[string]interface{}{}
+	if input.URL != nil {
+		updates["url"] = *input.URL
+	}
+	if input.Description != nil {
+		updates["description"] = *input.Description
+	}
+	if input.Events != nil {
+		events, err := models.NormalizeWebhookEvents(input.Events)
+		if err != nil {
+			return err
+		}
+		updates["events"] = events
+	}
+	if input.Active != nil {
+		updates["active"] = *input.Active
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return c.db.DB.Model(webhook).Updates(updates).Error
+}
+
+// Delete removes a webhook. Its pending deliveries fail on their next
+// attempt, which keeps them in the delivery log.
+func (c *WebhookController) Delete(webhook *models.Webhook) error {
+	return c.db.DB.Delete(webhook).Error
+}
+
+// RotateSecret replaces the signing secret and returns the new one.
+func (c *WebhookController) RotateSecret(webhook *models.Webhook) (string, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := c.db.DB.Model(webhook).Update("secret", secret).Error; err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Deliveries returns a webhook's delivery log newest first, optionally
+// only those with status.
+func (c *WebhookController) Deliveries(webhookNumber, status string, pagination commons.PaginationParams) ([]models.WebhookDelivery, int64, error) {
+	query := c.db.DB.Model(&models.WebhookDelivery{}).Where("webhook_number = ?", webhookNumber)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := query.Order("created_at DESC").
+		Offset((pagination.Page - 1) * pagination.Limit).
+		Limit(pagination.Limit).
+		Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+func (c *WebhookController) FindDelivery(webhookNumber, number string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := c.db.DB.Where("webhook_number = ? AND number = ?", webhookNumber, number).First(&delivery).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (c *WebhookController) enqueue(webhookNumber, eventID, event, payload, redeliveryOf string) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{
+		Number:        commons.UUIDGenerator(),
+		WebhookNumber: webhookNumber,
+		EventID:       eventID,
+		Event:         event,
+		Payload:       payload,
+		RedeliveryOf:  redeliveryOf,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := c.db.DB.Create(delivery).Error; err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Redeliver queues the payload of an earlier delivery again. The original
+// stays in the log untouched; receivers can deduplicate on the event ID,
+// which both share.
+func (c *WebhookController) Redeliver(webhookNumber, deliveryNumber string) (*models.WebhookDelivery, error) {
+	original, err := c.FindDelivery(webhookNumber, deliveryNumber)
+	if err != nil {
+		return nil, err
+	}
+	return c.enqueue(webhookNumber, original.EventID, original.Event, original.Payload, original.Number)
+}
+
+// Ping queues a ping event, so owners can check their receiver and its
+// signature verification without waiting for a real change.
+func (c *WebhookController) Ping(webhook *models.Webhook) (*models.WebhookDelivery, error) {
+	eventID, payload, err := services.NewWebhookPayload(models.WebhookEventPing, 0, models.WebhookEventData{Number: webhook.Number})
+	if err != nil {
+		return nil, err
+	}
+	return c.enqueue(webhook.Number, eventID, models.WebhookEventPing, payload, "")
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -60,21 +61,90 @@ func NewDatabase(config *Config) (*Database, error) {
 		return nil, err
 	}
 
-	err = db.AutoMigrate(
+	err = Migrate(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return &Database{DB: db}, nil
+}
+
+// WithContext returns a Database whose queries carry ctx. Writes made through
+// it are attributed to the request in the audit log.
+func (d *Database) WithContext(ctx context.Context) *Database {
+	return &Database{DB: d.DB.WithContext(ctx)}
+}
+
+// Models lists every model the schema is migrated from. Each level in
+// models.Levels must be backed by one of them.
+func Models() []interface{} {
+	return []interface{}{
 		&models.User{},
 		&models.APIKey{},
+		&models.APIKeyUsage{},
 		&models.UserPassword{},
 		&models.PasswordReset{},
+		&models.Session{},
+		&models.SigningKey{},
+		&models.RoleAssignment{},
+		&models.Organization{},
+		&models.Membership{},
+		&models.OrganizationInvitation{},
+		&models.AuditLog{},
+		&models.LoginThrottle{},
+		&models.UserMFA{},
+		&models.MFARecoveryCode{},
+		&models.OIDCLoginState{},
+		&models.AccountDeletion{},
+		&models.EmailChange{},
+		&models.OutboxEmail{},
+		&models.ChangeProposal{},
+		&models.IssueReport{},
+		&models.UnitAlias{},
+		&models.DuplicateDismissal{},
+		&models.Release{},
+		&models.ReleaseUnit{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.WebhookCursor{},
 		&models.Region{},
 		&models.District{},
 		&models.County{},
 		&models.SubCounty{},
 		&models.Parish{},
 		&models.Village{},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
+}
 
-	return &Database{DB: db}, nil
+// Migrate brings the schema up to date with Models.
+func Migrate(db *gorm.DB) error {
+	if err := dropUsageNumber(db); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(Models()...); err != nil {
+		return err
+	}
+
+	// Sessions created before token families existed each start a family
+	// of their own, so revoking one never reaches another login.
+	return db.Exec("UPDATE sessions SET family_number = number WHERE family_number IS NULL OR family_number = ''").Error
+}
+
+// dropUsageNumber strips the gorm.Model columns and the number that API key
+// usage rows used to carry. The number was part of the primary key, which
+// goes with it, so id becomes the key on its own.
+func dropUsageNumber(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.APIKeyUsage{}) || !migrator.HasColumn(&models.APIKeyUsage{}, "number") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, column := range []string{"number", "created_at", "updated_at", "deleted_at"} {
+			if err := tx.Migrator().DropColumn(&models.APIKeyUsage{}, column); err != nil {
+				return err
+			}
+		}
+		return tx.Exec("ALTER TABLE api_key_usages ADD PRIMARY KEY (id)").Error
+	})
 }
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+	"opendataug.org/models"
+)
+
+func TestLevelsAreMigrated(t *testing.T) {
+	cache := &sync.Map{}
+	tables := map[string]bool{}
+	for _, model := range Models() {
+		parsed, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatalf("parse %T: %v", model, err)
+		}
+		tables[parsed.Table] = true
+	}
+
+	for level, meta := range models.Levels {
+		if !tables[meta.Table] {
+			t.Errorf("level %q uses table %q, which is not migrated", level, meta.Table)
+		}
+	}
+}
+
+func TestLevelOrder(t *testing.T) {
+	if len(models.LevelOrder) != len(models.Levels) {
+		t.Fatalf("LevelOrder has %d levels, Levels has %d", len(models.LevelOrder), len(models.Levels))
+	}
+
+	seen := map[string]bool{}
+	for _, level := range models.LevelOrder {
+		meta, ok := models.Levels[level]
+		if !ok {
+			t.Fatalf("LevelOrder lists unknown level %q", level)
+		}
+		if meta.ParentLevel != "" && !seen[meta.ParentLevel] {
+			t.Errorf("level %q comes before its parent %q", level, meta.ParentLevel)
+		}
+		seen[level] = true
+	}
+}
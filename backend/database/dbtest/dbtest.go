@@ -0,0 +1,85 @@
+// Package dbtest gives tests a freshly migrated Postgres schema of their
+// own. The schema features the app relies on, such as advisory locks and
+// SKIP LOCKED, have no stand-in, so these tests need a real server.
+package dbtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"opendataug.org/database"
+)
+
+// Open connects to the server named by TEST_DATABASE_URL, creates an empty
+// schema for the test, migrates it and drops it again when the test ends.
+// The test is skipped when TEST_DATABASE_URL is not set.
+func Open(t testing.TB) *database.Database {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(suffix)
+
+	if err := admin.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`).Error; err != nil {
+		t.Fatalf("create uuid-ossp extension: %v", err)
+	}
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema)), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect to test schema: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		if err := admin.Exec("DROP SCHEMA " + schema + " CASCADE").Error; err != nil {
+			t.Errorf("drop schema: %v", err)
+		}
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate test schema: %v", err)
+	}
+	return &database.Database{DB: db}
+}
+
+// withSearchPath points every connection made from dsn at schema, falling
+// back to public for extensions. dsn may be a URL or key=value pairs.
+func withSearchPath(dsn, schema string) string {
+	searchPath := schema + ",public"
+	if strings.Contains(dsn, "://") {
+		if parsed, err := url.Parse(dsn); err == nil {
+			query := parsed.Query()
+			query.Set("search_path", searchPath)
+			parsed.RawQuery = query.Encode()
+			return parsed.String()
+		}
+	}
+	return dsn + " search_path=" + searchPath
+}
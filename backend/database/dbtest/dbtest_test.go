@@ -0,0 +1,29 @@
+package dbtest
+
+import "testing"
+
+func TestWithSearchPath(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{
+			dsn:  "host=localhost user=postgres dbname=test",
+			want: "host=localhost user=postgres dbname=test search_path=test_1,public",
+		},
+		{
+			dsn:  "postgres://postgres@localhost/test",
+			want: "postgres://postgres@localhost/test?search_path=test_1%2Cpublic",
+		},
+		{
+			dsn:  "postgres://postgres@localhost/test?sslmode=disable",
+			want: "postgres://postgres@localhost/test?search_path=test_1%2Cpublic&sslmode=disable",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := withSearchPath(tt.dsn, "test_1"); got != tt.want {
+			t.Errorf("withSearchPath(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
@@ -1,14 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
 	"opendataug.org/database"
 	"opendataug.org/routes"
+	"opendataug.org/services"
 )
 
+// shutdownTimeout bounds how long in-flight requests get to finish once the
+// process is asked to stop.
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -29,14 +43,101 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	router := routes.SetupRouter(db)
+	if err := services.RegisterAuditCallbacks(db.DB); err != nil {
+		log.Fatalf("Failed to register audit callbacks: %v", err)
+	}
+
+	keyManager, err := commons.LoadKeyManager(db.DB, commons.KeyManagerConfig{
+		PrivateKey:        os.Getenv("ACCESS_TOKEN_PRIVATE_KEY"),
+		PublicKey:         os.Getenv("ACCESS_TOKEN_PUBLIC_KEY"),
+		Secret:            os.Getenv("JWT_SECRET"),
+		RotationInterval:  parseDuration("JWT_KEY_ROTATION_INTERVAL"),
+		VerificationGrace: services.RefreshTokenDuration,
+	})
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
+	// Background workers run until stop is closed at shutdown.
+	stop := make(chan struct{})
+	var workers sync.WaitGroup
+	runWorker := func(run func(stop <-chan struct{})) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(stop)
+		}()
+	}
+
+	runWorker(keyManager.Run)
+	runWorker(controllers.NewAccountController(db).RunDeletionPurger)
+	runWorker(controllers.NewTrashController(db).RunPurger)
+
+	emailOutbox, err := services.LoadEmailOutbox(db.DB, "./templates")
+	if err != nil {
+		log.Fatalf("Failed to set up email delivery: %v", err)
+	}
+
+	runWorker(emailOutbox.Run)
+
+	webhooks, err := services.LoadWebhookDispatcher(db.DB)
+	if err != nil {
+		log.Fatalf("Failed to set up webhook delivery: %v", err)
+	}
+
+	runWorker(webhooks.Run)
+
+	changeBus := services.NewChangeBus(db.DB, controllers.NewChangeFeedController(db))
+	runWorker(changeBus.Run)
+
+	usageRecorder := services.NewUsageRecorder(db.DB)
+	router := routes.SetupRouter(db, usageRecorder, changeBus)
 
 	port := os.Getenv("SERVER_PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{Addr: ":" + port, Handler: router}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+
+	// Stop the workers before draining: the change bus closes its
+	// subscriptions, which ends the open change streams that Shutdown
+	// would otherwise wait on until the timeout.
+	close(stop)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to drain requests: %v", err)
 	}
+
+	workers.Wait()
+	// Only once no request can record usage any more.
+	usageRecorder.Stop()
+}
+
+func parseDuration(name string) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+
+	return duration
 }
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AccountDeletionPending   = "PENDING"
+	AccountDeletionCanceled  = "CANCELED"
+	AccountDeletionCompleted = "COMPLETED"
+)
+
+// AccountDeletion is a user's request to delete their account. The account
+// is disabled at once and removed after ScheduledFor unless the request is
+// canceled through the emailed link first.
+type AccountDeletion struct {
+	gorm.Model
+	Number     string `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	UserNumber string `gorm:"type:varchar(36);not null;index" json:"user_number"`
+	// TokenHash is the sha256 of the cancel token sent by email.
+	TokenHash string `gorm:"not null;uniqueIndex" json:"-"`
+	Status    string `gorm:"size:20;not null;index" json:"status"`
+	// PreviousStatus is restored on the user when the request is canceled.
+	PreviousStatus string     `gorm:"size:100;not null" json:"-"`
+	ScheduledFor   time.Time  `gorm:"not null;index" json:"scheduled_for"`
+	CanceledAt     *time.Time `json:"canceled_at,omitempty"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+type AccountDeletionCancelInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type AccountDeletionResponse struct {
+	Message      string    `json:"message"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+// AccountExport is everything the service holds about a user, returned by
+// the self-service data export.
+type AccountExport struct {
+	ExportedAt      time.Time                 `json:"exported_at"`
+	Profile         AccountExportProfile      `json:"profile"`
+	APIKeys         []AccountExportAPIKey     `json:"api_keys"`
+	Sessions        []AccountExportSession    `json:"sessions"`
+	Usage           []AccountExportUsage      `json:"usage"`
+	Memberships     []AccountExportMembership `json:"memberships"`
+	RoleAssignments []AccountExportRole       `json:"role_assignments"`
+}
+
+type AccountExportProfile struct {
+	Number       string     `json:"number"`
+	Email        string     `json:"email"`
+	FirstName    string     `json:"first_name"`
+	LastName     string     `json:"last_name"`
+	Role         UserRole   `json:"role"`
+	Status       string     `json:"status"`
+	Provider     string     `json:"provider,omitempty"`
+	MFAEnabledAt *time.Time `json:"mfa_enabled_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// AccountExportAPIKey describes a key without its secret.
+type AccountExportAPIKey struct {
+	Number             string     `json:"number"`
+	Name               string     `json:"name"`
+	OrganizationNumber string     `json:"organization_number,omitempty"`
+	IsActive           bool       `json:"is_active"`
+	UsageCount         int64      `json:"usage_count"`
+	LastUsedAt         *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+type AccountExportSession struct {
+	Number     string     `json:"number"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type AccountExportUsage struct {
+	APIKeyNumber string    `json:"api_key_number"`
+	Method       string    `json:"method"`
+	Endpoint     string    `json:"endpoint"`
+	StatusCode   int       `json:"status_code"`
+	LatencyMs    int64     `json:"latency_ms"`
+	BytesOut     int64     `json:"bytes_out"`
+	RequestedAt  time.Time `json:"requested_at"`
+}
+
+type AccountExportMembership struct {
+	OrganizationNumber string           `json:"organization_number"`
+	Role               OrganizationRole `json:"role"`
+	CreatedAt          time.Time        `json:"created_at"`
+}
+
+type AccountExportRole struct {
+	Role        UserRole  `json:"role"`
+	ScopeLevel  string    `json:"scope_level,omitempty"`
+	ScopeNumber string    `json:"scope_number,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -10,17 +13,59 @@ type APIKey struct {
 	gorm.Model
 	Number     string `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
 	UserNumber string `gorm:"not null;index"`
-	Name       string `gorm:"not null"`
-	Key        string `gorm:"uniqueIndex;not null"`
-	LastUsedAt *time.Time
-	ExpiresAt  *time.Time
-	UsageCount int64 `gorm:"default:0"`
-	IsActive   bool  `gorm:"default:true"`
+	// OrganizationNumber is set for team-owned keys. UserNumber then records
+	// who created the key; the key outlives that user's account.
+	OrganizationNumber string `gorm:"type:varchar(36);index"`
+	Name               string `gorm:"not null"`
+	Key                string `gorm:"uniqueIndex;not null"`
+	LastUsedAt         *time.Time
+	ExpiresAt          *time.Time
+	UsageCount         int64 `gorm:"default:0"`
+	IsActive           bool  `gorm:"default:true"`
+	// Scopes is a space separated list of extra write scopes. Every key can
+	// read; scopes only widen what it may do.
+	Scopes string `gorm:"size:255;not null;default:''"`
+}
+
+// APIKeyScopeProposalsWrite lets a key submit change proposals.
+const APIKeyScopeProposalsWrite = "proposals:write"
+
+var apiKeyScopes = []string{APIKeyScopeProposalsWrite}
+
+func (k *APIKey) IsOrganizationKey() bool {
+	return k.OrganizationNumber != ""
+}
+
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.ScopeList(), scope)
+}
+
+// NormalizeAPIKeyScopes validates requested scopes and returns them in the
+// form stored on APIKey.Scopes.
+func NormalizeAPIKeyScopes(scopes []string) (string, error) {
+	var normalized []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !slices.Contains(apiKeyScopes, scope) {
+			return "", fmt.Errorf("unknown scope %q", scope)
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	return strings.Join(normalized, " "), nil
 }
 
 type APIKeyResponse struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Key       string    `json:"key"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                 string    `json:"id"`
+	Name               string    `json:"name"`
+	Key                string    `json:"key"`
+	OrganizationNumber string    `json:"organization_number,omitempty"`
+	Scopes             []string  `json:"scopes"`
+	CreatedBy          string    `json:"created_by,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
 }
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// APIKeyUsage records one request made with an API key. There is a row per
+// request and rows are never soft deleted, so it carries a plain serial key
+// rather than gorm.Model and a number.
+type APIKeyUsage struct {
+	ID           uint64 `gorm:"primaryKey" json:"id"`
+	APIKeyNumber string `gorm:"type:varchar(36);not null;index:idx_api_key_usage_key_time" json:"api_key_number"`
+	UserNumber   string `gorm:"type:varchar(36);not null;index" json:"user_number"`
+	// OrganizationNumber is copied from the key so monthly quotas can be
+	// counted without joining api_keys.
+	OrganizationNumber string    `gorm:"type:varchar(36);index:idx_api_key_usage_org_time" json:"organization_number,omitempty"`
+	Method             string    `gorm:"size:10;not null" json:"method"`
+	Endpoint           string    `gorm:"size:255;not null" json:"endpoint"`
+	StatusCode         int       `gorm:"not null" json:"status_code"`
+	LatencyMs          int64     `gorm:"not null" json:"latency_ms"`
+	BytesOut           int64     `gorm:"not null" json:"bytes_out"`
+	RequestedAt        time.Time `gorm:"not null;index:idx_api_key_usage_key_time;index:idx_api_key_usage_org_time" json:"requested_at"`
+}
+
+type APIKeyUsagePoint struct {
+	Bucket     time.Time `json:"bucket"`
+	Endpoint   string    `json:"endpoint"`
+	StatusCode int       `json:"status_code"`
+	Count      int64     `json:"count"`
+}
+
+type APIKeyUsageResponse struct {
+	APIKeyID    string             `json:"api_key_id"`
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Granularity string             `json:"granularity"`
+	Total       int64              `json:"total"`
+	Series      []APIKeyUsagePoint `json:"series"`
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+
+	AuditActorUser   = "user"
+	AuditActorAPIKey = "api_key"
+	AuditActorSystem = "system"
+)
+
+// AuditLog is an append-only record of a change to an audited table. Each
+// entry's Hash covers its own fields and the previous entry's hash, so
+// editing or removing a row breaks the chain from that point on. Entries are
+// never soft deleted, which is why gorm.Model is not embedded.
+//
+// The hash covers the digests of Before and After rather than the snapshots
+// themselves, so personal data can be redacted when an account is erased
+// without breaking the chain.
+type AuditLog struct {
+	Number       string     `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	Sequence     int64      `gorm:"not null;uniqueIndex" json:"sequence"`
+	ActorType    string     `gorm:"size:20;not null" json:"actor_type"`
+	ActorNumber  string     `gorm:"type:varchar(36);index" json:"actor_number,omitempty"`
+	Action       string     `gorm:"size:20;not null;index" json:"action"`
+	EntityType   string     `gorm:"size:50;not null;index:idx_audit_entity" json:"entity_type"`
+	EntityNumber string     `gorm:"type:varchar(36);index:idx_audit_entity" json:"entity_number"`
+	Before       *string    `gorm:"type:text" json:"-"`
+	After        *string    `gorm:"type:text" json:"-"`
+	BeforeHash   string     `gorm:"size:64;not null;default:''" json:"-"`
+	AfterHash    string     `gorm:"size:64;not null;default:''" json:"-"`
+	RedactedAt   *time.Time `json:"redacted_at,omitempty"`
+	IPAddress    string     `gorm:"size:45" json:"ip_address,omitempty"`
+	RequestID    string     `gorm:"size:64;index" json:"request_id,omitempty"`
+	PrevHash     string     `gorm:"size:64;not null" json:"prev_hash"`
+	Hash         string     `gorm:"size:64;not null" json:"hash"`
+	CreatedAt    time.Time  `gorm:"not null;index" json:"created_at"`
+}
+
+// snapshotDigest hashes a Before or After snapshot. Snapshots are stored as
+// text rather than jsonb so the bytes hashed are the bytes read back.
+func snapshotDigest(snapshot *string) string {
+	if snapshot == nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(*snapshot))
+	return hex.EncodeToString(sum[:])
+}
+
+// SealSnapshots records the digests of Before and After. Call it before
+// ComputeHash.
+func (a *AuditLog) SealSnapshots() {
+	a.BeforeHash = snapshotDigest(a.Before)
+	a.AfterHash = snapshotDigest(a.After)
+}
+
+// SnapshotsIntact reports whether Before and After still match their
+// digests. Redacted entries no longer hold their snapshots and are only
+// checked through the chain.
+func (a *AuditLog) SnapshotsIntact() bool {
+	if a.RedactedAt != nil {
+		return a.Before == nil && a.After == nil
+	}
+	return snapshotDigest(a.Before) == a.BeforeHash && snapshotDigest(a.After) == a.AfterHash
+}
+
+// ComputeHash hashes the entry's content together with PrevHash.
+func (a *AuditLog) ComputeHash() string {
+	payload := strings.Join([]string{
+		a.PrevHash,
+		strconv.FormatInt(a.Sequence, 10),
+		a.ActorType,
+		a.ActorNumber,
+		a.Action,
+		a.EntityType,
+		a.EntityNumber,
+		a.BeforeHash,
+		a.AfterHash,
+		a.IPAddress,
+		a.RequestID,
+		a.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}, "\x1f")
+
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+type AuditLogResponse struct {
+	Sequence     int64           `json:"sequence"`
+	ActorType    string          `json:"actor_type"`
+	ActorNumber  string          `json:"actor_number,omitempty"`
+	Action       string          `json:"action"`
+	EntityType   string          `json:"entity_type"`
+	EntityNumber string          `json:"entity_number"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	IPAddress    string          `json:"ip_address,omitempty"`
+	RequestID    string          `json:"request_id,omitempty"`
+	Hash         string          `json:"hash"`
+	RedactedAt   *time.Time      `json:"redacted_at,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+type AuditLogListResponse struct {
+	Data  []AuditLogResponse `json:"data"`
+	Page  int                `json:"page"`
+	Limit int                `json:"limit"`
+	Total int64              `json:"total"`
+}
+
+type AuditVerification struct {
+	Valid          bool   `json:"valid"`
+	Checked        int64  `json:"checked"`
+	BrokenSequence *int64 `json:"broken_sequence,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	ChangeOpUpsert    = "upsert"
+	ChangeOpTombstone = "tombstone"
+)
+
+// ChangeFeedEntry is the latest state of one unit in a page of the change
+// feed. Upserts carry the unit as it is now; tombstones mean the unit was
+// deleted and only carry its identity and last parent.
+type ChangeFeedEntry struct {
+	Sequence     int64     `json:"sequence"`
+	Op           string    `json:"op"`
+	Level        string    `json:"level"`
+	Number       string    `json:"number"`
+	Name         string    `json:"name,omitempty"`
+	ParentNumber string    `json:"parent_number,omitempty"`
+	TownStatus   bool      `json:"town_status,omitempty"`
+	ChangedAt    time.Time `json:"changed_at"`
+}
+
+// ChangeFeedResponse is one page of the change feed. Next is the cursor to
+// pass as since for the following page; it advances even when a page has
+// no entries, so polling never rereads the same history.
+type ChangeFeedResponse struct {
+	Since   int64             `json:"since"`
+	Next    int64             `json:"next"`
+	HasMore bool              `json:"has_more"`
+	Changes []ChangeFeedEntry `json:"changes"`
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ProposalActionCreate   = "create"
+	ProposalActionRename   = "rename"
+	ProposalActionReparent = "reparent"
+	ProposalActionDelete   = "delete"
+)
+
+const (
+	ProposalStatusPending   = "PENDING"
+	ProposalStatusApproved  = "APPROVED"
+	ProposalStatusRejected  = "REJECTED"
+	ProposalStatusWithdrawn = "WITHDRAWN"
+)
+
+// ChangeProposal is a suggested edit to the hierarchy from someone without
+// write access. Reviewers approve or reject it; approval applies the change
+// through the same checks as a direct edit.
+type ChangeProposal struct {
+	gorm.Model
+	Number string `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	Action string `gorm:"size:20;not null" json:"action"`
+	Level  string `gorm:"size:20;not null;index" json:"level"`
+	// UnitNumber is the unit being changed. For create proposals it is set
+	// when the proposal is approved.
+	UnitNumber   string `gorm:"type:varchar(36);index" json:"unit_number,omitempty"`
+	Name         string `json:"name,omitempty"`
+	ParentNumber string `gorm:"type:varchar(36)" json:"parent_number,omitempty"`
+	TownStatus   bool   `json:"town_status,omitempty"`
+	// CurrentName and CurrentParentNumber record the unit as it was when the
+	// proposal was made, so reviewers can see what would change.
+	CurrentName         string     `json:"current_name,omitempty"`
+	CurrentParentNumber string     `gorm:"type:varchar(36)" json:"current_parent_number,omitempty"`
+	Evidence            string     `gorm:"type:text;not null" json:"evidence"`
+	Status              string     `gorm:"size:20;not null;index" json:"status"`
+	ProposedBy          string     `gorm:"type:varchar(36);not null;index" json:"proposed_by"`
+	APIKeyNumber        string     `gorm:"type:varchar(36)" json:"api_key_number,omitempty"`
+	ReviewedBy          string     `gorm:"type:varchar(36)" json:"reviewed_by,omitempty"`
+	ReviewComment       string     `gorm:"type:text" json:"review_comment,omitempty"`
+	ReviewedAt          *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// Summary describes the proposal in a sentence for notifications.
+func (p *ChangeProposal) Summary() string {
+	switch p.Action {
+	case ProposalActionCreate:
+		return "add " + p.Level + " \"" + p.Name + "\""
+	case ProposalActionRename:
+		return "rename " + p.Level + " \"" + p.CurrentName + "\" to \"" + p.Name + "\""
+	case ProposalActionReparent:
+		return "move " + p.Level + " \"" + p.CurrentName + "\" to a new parent"
+	case ProposalActionDelete:
+		return "remove " + p.Level + " \"" + p.CurrentName + "\""
+	}
+	return p.Action + " " + p.Level
+}
+
+type ChangeProposalInput struct {
+	Action       string `json:"action" binding:"required"`
+	Level        string `json:"level" binding:"required"`
+	UnitNumber   string `json:"unit_number"`
+	Name         string `json:"name"`
+	ParentNumber string `json:"parent_number"`
+	TownStatus   bool   `json:"town_status"`
+	Evidence     string `json:"evidence" binding:"required"`
+}
+
+func (i *ChangeProposalInput) Prepare() {
+	i.Action = strings.ToLower(strings.TrimSpace(i.Action))
+	i.Level = strings.ToLower(strings.TrimSpace(i.Level))
+	i.UnitNumber = strings.TrimSpace(i.UnitNumber)
+	i.Name = strings.TrimSpace(i.Name)
+	i.ParentNumber = strings.TrimSpace(i.ParentNumber)
+	i.Evidence = strings.TrimSpace(i.Evidence)
+}
+
+func (i *ChangeProposalInput) Validate() error {
+	level, ok := Levels[i.Level]
+	if !ok {
+		return errors.New("invalid level")
+	}
+	if i.Evidence == "" {
+		return errors.New("evidence is required")
+	}
+
+	switch i.Action {
+	case ProposalActionCreate:
+		if i.Name == "" {
+			return errors.New("name is required")
+		}
+		if level.ParentLevel != "" && i.ParentNumber == "" {
+			return errors.New("parent number is required")
+		}
+	case ProposalActionRename:
+		if i.UnitNumber == "" || i.Name == "" {
+			return errors.New("unit number and name are required")
+		}
+	case ProposalActionReparent:
+		if level.ParentLevel == "" {
+			return errors.New("units at this level have no parent")
+		}
+		if i.UnitNumber == "" || i.ParentNumber == "" {
+			return errors.New("unit number and parent number are required")
+		}
+	case ProposalActionDelete:
+		if i.UnitNumber == "" {
+			return errors.New("unit number is required")
+		}
+	default:
+		return errors.New("action must be create, rename, reparent or delete")
+	}
+	return nil
+}
+
+type ChangeProposalReviewInput struct {
+	Comment string `json:"comment"`
+}
+
+type ChangeProposalListResponse struct {
+	Data  []ChangeProposal `json:"data"`
+	Page  int              `json:"page"`
+	Limit int              `json:"limit"`
+	Total int64            `json:"total"`
+}
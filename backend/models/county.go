@@ -18,4 +18,6 @@ type CountyResponse struct {
 	Name         string `json:"name"`
 	DistrictID   string `json:"district_id"`
 	DistrictName string `json:"district_name"`
+	// Reports is only filled in on detail responses.
+	Reports *ReportCounts `json:"reports,omitempty"`
 }
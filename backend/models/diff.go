@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+const (
+	DiffAdded      = "added"
+	DiffRemoved    = "removed"
+	DiffRenamed    = "renamed"
+	DiffReparented = "reparented"
+)
+
+// DiffPoint is one side of a diff: a release, or the hierarchy as it stood
+// at a moment in time.
+type DiffPoint struct {
+	Release string    `json:"release,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// DiffChange is one difference for one unit. A unit that was both renamed
+// and moved appears once under each.
+type DiffChange struct {
+	Change          string `json:"change"`
+	Level           string `json:"level"`
+	Number          string `json:"number"`
+	Name            string `json:"name"`
+	OldName         string `json:"old_name,omitempty"`
+	ParentNumber    string `json:"parent_number,omitempty"`
+	OldParentNumber string `json:"old_parent_number,omitempty"`
+}
+
+type DiffSummary struct {
+	Added      int `json:"added"`
+	Removed    int `json:"removed"`
+	Renamed    int `json:"renamed"`
+	Reparented int `json:"reparented"`
+}
+
+type DiffResponse struct {
+	From       DiffPoint    `json:"from"`
+	To         DiffPoint    `json:"to"`
+	Levels     []string     `json:"levels"`
+	Summary    DiffSummary  `json:"summary"`
+	Added      []DiffChange `json:"added"`
+	Removed    []DiffChange `json:"removed"`
+	Renamed    []DiffChange `json:"renamed"`
+	Reparented []DiffChange `json:"reparented"`
+	// Warnings note where a date-based side may be incomplete, such as a
+	// date before the audit trail began.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Changes returns every change in the order they appear in CSV output.
+func (d *DiffResponse) Changes() []DiffChange {
+	changes := make([]DiffChange, 0, len(d.Added)+len(d.Removed)+len(d.Renamed)+len(d.Reparented))
+	changes = append(changes, d.Added...)
+	changes = append(changes, d.Removed...)
+	changes = append(changes, d.Renamed...)
+	changes = append(changes, d.Reparented...)
+	return changes
+}
@@ -20,4 +20,6 @@ type DistrictResponse struct {
 	TownStatus bool   `json:"town_status"`
 	RegionID   string `json:"region_id"`
 	RegionName string `json:"region_name"`
+	// Reports is only filled in on detail responses.
+	Reports *ReportCounts `json:"reports,omitempty"`
 }
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/badoux/checkmail"
+	"gorm.io/gorm"
+)
+
+const (
+	EmailChangePending    = "PENDING"
+	EmailChangeConfirmed  = "CONFIRMED"
+	EmailChangeSuperseded = "SUPERSEDED"
+)
+
+// EmailChange is a request to move an account to a new email address. The
+// address is only swapped once the link sent to NewEmail is followed.
+type EmailChange struct {
+	gorm.Model
+	Number      string     `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	UserNumber  string     `gorm:"type:varchar(36);not null;index" json:"user_number"`
+	OldEmail    string     `gorm:"not null" json:"old_email"`
+	NewEmail    string     `gorm:"not null;index" json:"new_email"`
+	Status      string     `gorm:"size:20;not null;index" json:"status"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+}
+
+type EmailChangeInput struct {
+	Email string `json:"email" binding:"required"`
+	// Password re-authenticates the request. Accounts that only sign in
+	// through a social provider have no password and leave it empty; they
+	// must have signed in within the last few minutes instead.
+	Password string `json:"password"`
+}
+
+func (i *EmailChangeInput) Prepare() {
+	i.Email = strings.TrimSpace(strings.ToLower(i.Email))
+}
+
+func (i *EmailChangeInput) Validate() error {
+	if i.Email == "" {
+		return errors.New("email is required")
+	}
+	if err := checkmail.ValidateFormat(i.Email); err != nil {
+		return errors.New("email is invalid")
+	}
+	return nil
+}
+
+type EmailChangeConfirmInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type EmailChangeResponse struct {
+	Message   string    `json:"message"`
+	NewEmail  string    `json:"new_email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
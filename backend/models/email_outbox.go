@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxEmailPending = "PENDING"
+	OutboxEmailSent    = "SENT"
+	OutboxEmailFailed  = "FAILED"
+)
+
+// OutboxEmail is a rendered email waiting to be delivered. Rows are written
+// in the same transaction as the change that triggers them and sent by the
+// outbox worker, which retries with backoff until MaxAttempts. The bodies
+// can carry sign-in and reset tokens, so they are blanked as soon as the
+// email is sent or given up on.
+type OutboxEmail struct {
+	gorm.Model
+	Number        string     `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	Type          string     `gorm:"size:50;not null" json:"type"`
+	Recipient     string     `gorm:"not null" json:"recipient"`
+	Subject       string     `gorm:"not null" json:"subject"`
+	HTMLBody      string     `gorm:"type:text;not null" json:"-"`
+	TextBody      string     `gorm:"type:text;not null" json:"-"`
+	Status        string     `gorm:"size:20;not null;index:idx_outbox_emails_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_emails_due,priority:2" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
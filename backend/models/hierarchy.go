@@ -0,0 +1,89 @@
+package models
+
+const (
+	LevelRegion    = "region"
+	LevelDistrict  = "district"
+	LevelCounty    = "county"
+	LevelSubCounty = "subcounty"
+	LevelParish    = "parish"
+	LevelVillage   = "village"
+)
+
+// Level describes one tier of the administrative hierarchy and how it links
+// to its parent.
+type Level struct {
+	Name         string
+	Path         string
+	Table        string
+	ParentLevel  string
+	ParentColumn string
+	ParentField  string
+}
+
+// Levels describes every level the API serves. Each one must be backed by a
+// migrated table, since releases, the change feed, merges and the trash
+// query every level in turn.
+var Levels = map[string]Level{
+	LevelRegion: {
+		Name:  LevelRegion,
+		Path:  "regions",
+		Table: "regions",
+	},
+	LevelDistrict: {
+		Name:         LevelDistrict,
+		Path:         "districts",
+		Table:        "districts",
+		ParentLevel:  LevelRegion,
+		ParentColumn: "region_number",
+		ParentField:  "region_number",
+	},
+	LevelCounty: {
+		Name:         LevelCounty,
+		Path:         "counties",
+		Table:        "counties",
+		ParentLevel:  LevelDistrict,
+		ParentColumn: "district_number",
+		ParentField:  "district_number",
+	},
+	LevelSubCounty: {
+		Name:         LevelSubCounty,
+		Path:         "subcounties",
+		Table:        "sub_counties",
+		ParentLevel:  LevelCounty,
+		ParentColumn: "county_number",
+		ParentField:  "county_number",
+	},
+	LevelParish: {
+		Name:         LevelParish,
+		Path:         "parishes",
+		Table:        "parishes",
+		ParentLevel:  LevelSubCounty,
+		ParentColumn: "sub_county_number",
+		ParentField:  "subcounty_number",
+	},
+	LevelVillage: {
+		Name:         LevelVillage,
+		Path:         "villages",
+		Table:        "villages",
+		ParentLevel:  LevelParish,
+		ParentColumn: "parish_number",
+		ParentField:  "parish_number",
+	},
+}
+
+// LevelOrder lists the levels from the top of the hierarchy down, so
+// parents always come before their children.
+var LevelOrder = []string{
+	LevelRegion,
+	LevelDistrict,
+	LevelCounty,
+	LevelSubCounty,
+	LevelParish,
+	LevelVillage,
+}
+
+// UnitRef identifies a single administrative unit.
+type UnitRef struct {
+	Level  string `json:"level"`
+	Number string `json:"number"`
+}
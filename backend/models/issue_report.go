@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReportCategoryDuplicate      = "duplicate"
+	ReportCategoryMisspelling    = "misspelling"
+	ReportCategoryWrongParent    = "wrong_parent"
+	ReportCategoryNoLongerExists = "no_longer_exists"
+)
+
+var ReportCategories = []string{
+	ReportCategoryDuplicate,
+	ReportCategoryMisspelling,
+	ReportCategoryWrongParent,
+	ReportCategoryNoLongerExists,
+}
+
+const (
+	ReportStatusOpen      = "OPEN"
+	ReportStatusTriaged   = "TRIAGED"
+	ReportStatusResolved  = "RESOLVED"
+	ReportStatusDismissed = "DISMISSED"
+)
+
+// reportTransitions lists the statuses a report may move to from each
+// status. Closed reports can be reopened if the fix turns out to be wrong.
+var reportTransitions = map[string][]string{
+	ReportStatusOpen:      {ReportStatusTriaged, ReportStatusResolved, ReportStatusDismissed},
+	ReportStatusTriaged:   {ReportStatusOpen, ReportStatusResolved, ReportStatusDismissed},
+	ReportStatusResolved:  {ReportStatusOpen},
+	ReportStatusDismissed: {ReportStatusOpen},
+}
+
+// CanReportTransition reports whether a report in status from may be moved
+// to status to.
+func CanReportTransition(from, to string) bool {
+	for _, next := range reportTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReportClosed reports whether status ends the triage of a report.
+func IsReportClosed(status string) bool {
+	return status == ReportStatusResolved || status == ReportStatusDismissed
+}
+
+// IssueReport is a problem with a single record flagged by an API
+// consumer, waiting for a data steward to triage it.
+type IssueReport struct {
+	gorm.Model
+	Number     string `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	Level      string `gorm:"size:20;not null;index:idx_issue_reports_unit" json:"level"`
+	UnitNumber string `gorm:"type:varchar(36);not null;index:idx_issue_reports_unit" json:"unit_number"`
+	Category   string `gorm:"size:30;not null;index" json:"category"`
+	Comment    string `gorm:"type:text" json:"comment,omitempty"`
+	Status     string `gorm:"size:20;not null;index" json:"status"`
+	// ReportedBy is the user who filed the report, or the owner of the API
+	// key it came in with.
+	ReportedBy   string     `gorm:"type:varchar(36);index" json:"reported_by,omitempty"`
+	APIKeyNumber string     `gorm:"type:varchar(36)" json:"api_key_number,omitempty"`
+	TriagedBy    string     `gorm:"type:varchar(36)" json:"triaged_by,omitempty"`
+	TriageNote   string     `gorm:"type:text" json:"triage_note,omitempty"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+}
+
+type IssueReportInput struct {
+	Category string `json:"category" binding:"required"`
+	Comment  string `json:"comment"`
+}
+
+func (i *IssueReportInput) Prepare() {
+	i.Category = strings.ToLower(strings.TrimSpace(i.Category))
+	i.Comment = strings.TrimSpace(i.Comment)
+}
+
+func (i *IssueReportInput) Validate() error {
+	for _, category := range ReportCategories {
+		if i.Category == category {
+			if len(i.Comment) > 2000 {
+				return errors.New("comment must be at most 2000 characters")
+			}
+			return nil
+		}
+	}
+	return errors.New("category must be one of " + strings.Join(ReportCategories, ", "))
+}
+
+type IssueReportTriageInput struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
+}
+
+func (i *IssueReportTriageInput) Prepare() {
+	i.Status = strings.ToUpper(strings.TrimSpace(i.Status))
+	i.Note = strings.TrimSpace(i.Note)
+}
+
+type IssueReportListResponse struct {
+	Data  []IssueReport `json:"data"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+	Total int64         `json:"total"`
+}
+
+// ReportCounts summarises the reports filed against one record for record
+// detail responses. ByCategory counts only open reports.
+type ReportCounts struct {
+	Open       int64            `json:"open"`
+	Total      int64            `json:"total"`
+	ByCategory map[string]int64 `json:"by_category,omitempty"`
+}
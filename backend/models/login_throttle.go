@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ThrottleKindAccount = "account"
+	ThrottleKindIP      = "ip"
+)
+
+// LoginThrottle tracks consecutive failed sign-ins for one email address or
+// client IP. Rows persist across restarts so lockouts cannot be reset by
+// redeploying.
+type LoginThrottle struct {
+	gorm.Model
+	Number        string     `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	Kind          string     `gorm:"size:20;not null;uniqueIndex:idx_login_throttle_kind_key" json:"kind"`
+	Key           string     `gorm:"size:255;not null;uniqueIndex:idx_login_throttle_kind_key" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
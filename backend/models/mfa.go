@@ -0,0 +1,118 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserMFA holds a user's TOTP enrollment. The row exists from the moment
+// enrollment starts; two-factor sign-in is only required once ConfirmedAt is
+// set, which also sets User.MFAEnabledAt.
+type UserMFA struct {
+	gorm.Model
+	Number     string `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	UserNumber string `gorm:"size:36;not null;uniqueIndex" json:"user_number"`
+	// Secret is the base32 TOTP secret sealed with commons.EncryptTOTPSecret.
+	Secret      string     `gorm:"type:text;not null" json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	// LastUsedStep is the time step of the last accepted code, so the same
+	// code cannot be used twice.
+	LastUsedStep int64 `gorm:"not null;default:0" json:"-"`
+	User         User  `gorm:"foreignKey:UserNumber;references:Number;constraint: OnUpdate:CASCADE, OnDelete:CASCADE;" json:"-"`
+}
+
+// MFARecoveryCode is a single-use code for signing in without the
+// authenticator. Only the argon2id hash is stored.
+type MFARecoveryCode struct {
+	gorm.Model
+	Number     string     `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	UserNumber string     `gorm:"size:36;not null;index" json:"user_number"`
+	CodeHash   string     `gorm:"not null" json:"-"`
+	UsedAt     *time.Time `json:"used_at"`
+	User       User       `gorm:"foreignKey:UserNumber;references:Number;constraint: OnUpdate:CASCADE, OnDelete:CASCADE;" json:"-"`
+}
+
+type MFACodeInput struct {
+	Code string `json:"code"`
+}
+
+func (input *MFACodeInput) Prepare() {
+	input.Code = strings.TrimSpace(input.Code)
+}
+
+func (input *MFACodeInput) Validate() error {
+	if input.Code == "" {
+		return errors.New("code is required")
+	}
+	return nil
+}
+
+// MFAVerifyInput completes a sign-in that returned an MFA challenge. Either
+// a TOTP code or a recovery code is accepted.
+type MFAVerifyInput struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+func (input *MFAVerifyInput) Prepare() {
+	input.ChallengeToken = strings.TrimSpace(input.ChallengeToken)
+	input.Code = strings.TrimSpace(input.Code)
+	input.RecoveryCode = strings.TrimSpace(input.RecoveryCode)
+}
+
+func (input *MFAVerifyInput) Validate() error {
+	if input.ChallengeToken == "" {
+		return errors.New("challenge token is required")
+	}
+	if input.Code == "" && input.RecoveryCode == "" {
+		return errors.New("code or recovery code is required")
+	}
+	return nil
+}
+
+type MFADisableInput struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (input *MFADisableInput) Prepare() {
+	input.Code = strings.TrimSpace(input.Code)
+	input.RecoveryCode = strings.TrimSpace(input.RecoveryCode)
+}
+
+func (input *MFADisableInput) Validate() error {
+	if input.Password == "" {
+		return errors.New("password is required")
+	}
+	if input.Code == "" && input.RecoveryCode == "" {
+		return errors.New("code or recovery code is required")
+	}
+	return nil
+}
+
+type MFAEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	Required               bool       `json:"required"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+type MFAChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresIn      int64  `json:"expires_in"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OIDCLoginState carries a social sign-in from the redirect to the provider
+// until its callback. The row is deleted when the callback consumes it.
+type OIDCLoginState struct {
+	gorm.Model
+	Number       string    `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	State        string    `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Provider     string    `gorm:"size:50;not null" json:"provider"`
+	Nonce        string    `gorm:"size:64;not null" json:"-"`
+	CodeVerifier string    `gorm:"size:128;not null" json:"-"`
+	RedirectTo   string    `gorm:"type:text" json:"redirect_to"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+type OIDCProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	LoginURL    string `json:"login_url"`
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OrganizationRole string
+
+const (
+	OrgRoleOwner  OrganizationRole = "OWNER"
+	OrgRoleAdmin  OrganizationRole = "ADMIN"
+	OrgRoleMember OrganizationRole = "MEMBER"
+)
+
+const (
+	InvitationStatusPending  = "PENDING"
+	InvitationStatusAccepted = "ACCEPTED"
+	InvitationStatusRevoked  = "REVOKED"
+)
+
+type Organization struct {
+	gorm.Model
+	Number string `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	Name   string `gorm:"not null" json:"name"`
+	// MonthlyRequestQuota caps API requests across all of the organization's
+	// keys per calendar month. Zero means unlimited.
+	MonthlyRequestQuota int64        `gorm:"default:0" json:"monthly_request_quota"`
+	Memberships         []Membership `gorm:"foreignKey:OrganizationNumber;references:Number" json:"memberships,omitempty"`
+}
+
+type Membership struct {
+	gorm.Model
+	Number             string           `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	OrganizationNumber string           `gorm:"type:varchar(36);not null;uniqueIndex:idx_membership_org_user" json:"organization_number"`
+	UserNumber         string           `gorm:"type:varchar(36);not null;uniqueIndex:idx_membership_org_user;index" json:"user_number"`
+	Role               OrganizationRole `gorm:"type:text;size:20;not null" json:"role"`
+	User               User             `gorm:"foreignKey:UserNumber;references:Number;constraint: OnUpdate:CASCADE, OnDelete:RESTRICT;" json:"-"`
+}
+
+func (m *Membership) CanManage() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRoleAdmin
+}
+
+type OrganizationInvitation struct {
+	gorm.Model
+	Number             string           `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	OrganizationNumber string           `gorm:"type:varchar(36);not null;index" json:"organization_number"`
+	Email              string           `gorm:"not null;index" json:"email"`
+	Role               OrganizationRole `gorm:"type:text;size:20;not null" json:"role"`
+	Token              string           `gorm:"not null;uniqueIndex" json:"-"`
+	Status             string           `gorm:"size:20;not null" json:"status"`
+	InvitedBy          string           `gorm:"type:varchar(36);not null" json:"invited_by"`
+	ExpiresAt          time.Time        `gorm:"not null" json:"expires_at"`
+}
+
+type OrganizationInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func (o *OrganizationInput) Prepare() {
+	o.Name = strings.TrimSpace(o.Name)
+}
+
+func (o *OrganizationInput) Validate() error {
+	if o.Name == "" {
+		return errors.New("organization name is required")
+	}
+	return nil
+}
+
+type InvitationInput struct {
+	Email string           `json:"email" binding:"required"`
+	Role  OrganizationRole `json:"role"`
+}
+
+func (i *InvitationInput) Prepare() {
+	i.Email = strings.TrimSpace(strings.ToLower(i.Email))
+	i.Role = OrganizationRole(strings.ToUpper(strings.TrimSpace(string(i.Role))))
+	if i.Role == "" {
+		i.Role = OrgRoleMember
+	}
+}
+
+func (i *InvitationInput) Validate() error {
+	if i.Email == "" {
+		return errors.New("email is required")
+	}
+	if i.Role != OrgRoleAdmin && i.Role != OrgRoleMember {
+		return errors.New("role must be ADMIN or MEMBER")
+	}
+	return nil
+}
+
+type OrganizationResponse struct {
+	ID                  string           `json:"id"`
+	Name                string           `json:"name"`
+	Role                OrganizationRole `json:"role,omitempty"`
+	MonthlyRequestQuota int64            `json:"monthly_request_quota"`
+	CreatedAt           time.Time        `json:"created_at"`
+}
+
+type MembershipResponse struct {
+	UserNumber string           `json:"user_number"`
+	Email      string           `json:"email"`
+	Name       string           `json:"name"`
+	Role       OrganizationRole `json:"role"`
+	JoinedAt   time.Time        `json:"joined_at"`
+}
+
+type InvitationResponse struct {
+	ID        string           `json:"id"`
+	Email     string           `json:"email"`
+	Role      OrganizationRole `json:"role"`
+	Status    string           `json:"status"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
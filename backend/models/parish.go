@@ -16,4 +16,6 @@ type Parish struct {
 type ParishResponse struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	// Reports is only filled in on detail responses.
+	Reports *ReportCounts `json:"reports,omitempty"`
 }
@@ -13,4 +13,6 @@ type Region struct {
 type RegionResponse struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	// Reports is only filled in on detail responses.
+	Reports *ReportCounts `json:"reports,omitempty"`
 }
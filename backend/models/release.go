@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ReleaseFormatNDJSON = "ndjson"
+	ReleaseFormatCSV    = "csv"
+
+	// ReleaseLatest can be passed wherever a release name is expected to
+	// mean the most recent release.
+	ReleaseLatest = "latest"
+)
+
+var releaseNamePattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]{0,39}$`)
+
+// Release is a named, frozen copy of the whole hierarchy. Releases and their
+// units are never changed once cut, which is why gorm.Model is not
+// embedded. The checksums are SHA-256 digests of the download bundles.
+// Sequence is the change feed position the release was cut at; mirrors
+// that load a bundle can follow /changes?since=<sequence> from there.
+type Release struct {
+	Number         string    `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	Name           string    `gorm:"size:40;not null;uniqueIndex" json:"name"`
+	Notes          string    `gorm:"type:text" json:"notes,omitempty"`
+	UnitCount      int64     `gorm:"not null" json:"unit_count"`
+	Sequence       int64     `gorm:"not null;default:0" json:"sequence"`
+	ChecksumNDJSON string    `gorm:"size:64;not null" json:"checksum_ndjson"`
+	SizeNDJSON     int64     `gorm:"not null" json:"size_ndjson"`
+	ChecksumCSV    string    `gorm:"size:64;not null" json:"checksum_csv"`
+	SizeCSV        int64     `gorm:"not null" json:"size_csv"`
+	CreatedBy      string    `gorm:"type:varchar(36)" json:"created_by,omitempty"`
+	CreatedAt      time.Time `gorm:"not null;index" json:"created_at"`
+}
+
+// Checksum returns the digest and size of the bundle in format.
+func (r *Release) Checksum(format string) (string, int64) {
+	if format == ReleaseFormatCSV {
+		return r.ChecksumCSV, r.SizeCSV
+	}
+	return r.ChecksumNDJSON, r.SizeNDJSON
+}
+
+// BundleName is the file name a bundle in format is downloaded as.
+func (r *Release) BundleName(format string) string {
+	return "opendataug-" + r.Name + "." + format
+}
+
+// ReleaseUnit is one unit as it stood when a release was cut.
+type ReleaseUnit struct {
+	ReleaseNumber string `gorm:"primaryKey;type:varchar(36);index:idx_release_units_parent,priority:1" json:"-"`
+	Level         string `gorm:"primaryKey;size:20;index:idx_release_units_parent,priority:2" json:"level"`
+	UnitNumber    string `gorm:"primaryKey;type:varchar(36)" json:"number"`
+	Name          string `gorm:"not null" json:"name"`
+	ParentNumber  string `gorm:"type:varchar(36);index:idx_release_units_parent,priority:3" json:"parent_number,omitempty"`
+	TownStatus    bool   `gorm:"not null;default:false" json:"town_status,omitempty"`
+}
+
+type CreateReleaseInput struct {
+	Name  string `json:"name" binding:"required"`
+	Notes string `json:"notes"`
+}
+
+func (i *CreateReleaseInput) Prepare() {
+	i.Name = strings.TrimSpace(i.Name)
+	i.Notes = strings.TrimSpace(i.Notes)
+}
+
+func (i *CreateReleaseInput) Validate() error {
+	if !releaseNamePattern.MatchString(i.Name) {
+		return errors.New("release name must be 1-40 letters, digits, dots, dashes or underscores, such as 2026.10")
+	}
+	if strings.EqualFold(i.Name, ReleaseLatest) {
+		return errors.New("\"latest\" is reserved")
+	}
+	return nil
+}
+
+// ReleaseDetailResponse is a release with the number of units frozen at
+// each level.
+type ReleaseDetailResponse struct {
+	Release
+	Levels map[string]int64 `json:"levels"`
+}
+
+type ReleaseListResponse struct {
+	Data  []Release `json:"data"`
+	Page  int       `json:"page"`
+	Limit int       `json:"limit"`
+	Total int64     `json:"total"`
+}
+
+// ReleaseUnitListResponse answers list endpoints read with ?release=.
+type ReleaseUnitListResponse struct {
+	Release string        `json:"release"`
+	Data    []ReleaseUnit `json:"data"`
+	Page    int           `json:"page"`
+	Limit   int           `json:"limit"`
+	Total   int64         `json:"total"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// RoleAssignment grants a role to a user in addition to User.Role. When
+// ScopeLevel and ScopeNumber are set the role only applies to that unit and
+// everything below it.
+type RoleAssignment struct {
+	gorm.Model
+	Number      string   `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	UserNumber  string   `gorm:"type:varchar(36);not null;index" json:"user_number"`
+	Role        UserRole `gorm:"type:text;size:100;not null" json:"role"`
+	ScopeLevel  string   `gorm:"size:20" json:"scope_level,omitempty"`
+	ScopeNumber string   `gorm:"type:varchar(36);index" json:"scope_number,omitempty"`
+	GrantedBy   string   `gorm:"type:varchar(36)" json:"granted_by"`
+	User        User     `gorm:"foreignKey:UserNumber;references:Number;constraint: OnUpdate:CASCADE, OnDelete:CASCADE;" json:"-"`
+}
+
+func (r *RoleAssignment) IsScoped() bool {
+	return r.ScopeNumber != ""
+}
+
+type RoleAssignmentInput struct {
+	UserNumber  string   `json:"user_number" binding:"required"`
+	Role        UserRole `json:"role" binding:"required"`
+	ScopeLevel  string   `json:"scope_level"`
+	ScopeNumber string   `json:"scope_number"`
+}
+
+func (r *RoleAssignmentInput) Prepare() {
+	r.UserNumber = strings.TrimSpace(r.UserNumber)
+	r.Role = UserRole(strings.ToUpper(strings.TrimSpace(string(r.Role))))
+	r.ScopeLevel = strings.ToLower(strings.TrimSpace(r.ScopeLevel))
+	r.ScopeNumber = strings.TrimSpace(r.ScopeNumber)
+}
+
+func (r *RoleAssignmentInput) Validate() error {
+	if !IsValidRole(r.Role) {
+		return errors.New("invalid role")
+	}
+
+	if (r.ScopeLevel == "") != (r.ScopeNumber == "") {
+		return errors.New("scope level and scope number must be provided together")
+	}
+
+	if r.ScopeLevel != "" && r.ScopeLevel != LevelRegion && r.ScopeLevel != LevelDistrict {
+		return errors.New("roles can only be scoped to a region or district")
+	}
+
+	if r.Role == RoleDistrictSteward && r.ScopeNumber == "" {
+		return errors.New("district stewards must be scoped to a region or district")
+	}
+
+	return nil
+}
+
+type RoleAssignmentResponse struct {
+	ID          string   `json:"id"`
+	UserNumber  string   `json:"user_number"`
+	Role        UserRole `json:"role"`
+	ScopeLevel  string   `json:"scope_level,omitempty"`
+	ScopeNumber string   `json:"scope_number,omitempty"`
+	GrantedBy   string   `json:"granted_by"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Session struct {
+	gorm.Model
+	Number           string     `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	UserNumber       string     `gorm:"type:varchar(36);not null;index" json:"user_number"`
+	FamilyNumber     string     `gorm:"type:varchar(36);index" json:"family_number"`
+	AccessTokenUUID  string     `gorm:"type:varchar(36);not null;uniqueIndex" json:"-"`
+	RefreshTokenUUID string     `gorm:"type:varchar(36);not null;uniqueIndex" json:"-"`
+	UserAgent        string     `gorm:"size:512" json:"user_agent"`
+	IPAddress        string     `gorm:"size:64" json:"ip_address"`
+	LastSeenAt       time.Time  `json:"last_seen_at"`
+	ExpiresAt        time.Time  `gorm:"not null;index" json:"expires_at"`
+	RotatedAt        *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt        *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	User             User       `gorm:"foreignKey:UserNumber;references:Number;constraint: OnUpdate:CASCADE, OnDelete:CASCADE;" json:"-"`
+}
+
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && s.RotatedAt == nil && s.ExpiresAt.After(time.Now())
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
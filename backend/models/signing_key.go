@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SigningKey struct {
+	gorm.Model
+	Number              string    `gorm:"primaryKey;type:varchar(64);not null;unique" json:"kid"`
+	Algorithm           string    `gorm:"size:10;not null" json:"alg"`
+	PublicKey           string    `gorm:"type:text;not null" json:"-"`
+	EncryptedPrivateKey string    `gorm:"type:text;not null" json:"-"`
+	ExpiresAt           time.Time `gorm:"not null;index" json:"expires_at"`
+}
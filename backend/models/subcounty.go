@@ -11,6 +11,8 @@ type SubCounty struct {
 	County       County   `gorm:"foreignKey:CountyNumber;references:Number;constraint: OnUpdate:CASCADE, OnDelete:RESTRICT;" json:"county_details,omitempty"`
 	Parishes     []Parish `gorm:"foreignKey:SubCountyNumber;references:Number;constraint: OnUpdate:CASCADE, OnDelete:RESTRICT;" json:"parishes,omitempty"`
 	gorm.Model
+	// Reports is only filled in on detail responses.
+	Reports *ReportCounts `gorm:"-" json:"reports,omitempty"`
 }
 
 type SubCountyResponse struct {
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// TrashedUnit is a soft-deleted unit as listed in the trash.
+type TrashedUnit struct {
+	Level        string    `json:"level"`
+	Number       string    `json:"number"`
+	Name         string    `json:"name"`
+	ParentNumber string    `json:"parent_number,omitempty"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	// MergedInto is set for units deleted by a merge, which cannot be
+	// restored.
+	MergedInto string `json:"merged_into,omitempty"`
+	// Restorable is false when the unit was merged, or its parent or name
+	// is no longer available.
+	Restorable bool `json:"restorable"`
+	// PurgeAfter is when the retention job will remove the unit for good;
+	// it is omitted when automatic purging is off.
+	PurgeAfter *time.Time `json:"purge_after,omitempty"`
+}
+
+type TrashListResponse struct {
+	Data  []TrashedUnit `json:"data"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+	Total int64         `json:"total"`
+}
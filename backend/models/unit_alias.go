@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// UnitAlias records that a unit number was merged into another. Lookups of
+// the old number are redirected to UnitNumber.
+type UnitAlias struct {
+	gorm.Model
+	Level       string `gorm:"size:20;not null;uniqueIndex:idx_unit_aliases_alias" json:"level"`
+	AliasNumber string `gorm:"type:varchar(36);not null;uniqueIndex:idx_unit_aliases_alias" json:"alias_number"`
+	UnitNumber  string `gorm:"type:varchar(36);not null;index" json:"unit_number"`
+	AliasName   string `json:"alias_name"`
+	MergedBy    string `gorm:"type:varchar(36)" json:"merged_by,omitempty"`
+}
+
+// DuplicateDismissal marks a candidate pair as reviewed and not a
+// duplicate, so the finder stops suggesting it. NumberA sorts before
+// NumberB.
+type DuplicateDismissal struct {
+	gorm.Model
+	Level       string `gorm:"size:20;not null;uniqueIndex:idx_duplicate_dismissals_pair" json:"level"`
+	NumberA     string `gorm:"type:varchar(36);not null;uniqueIndex:idx_duplicate_dismissals_pair" json:"number_a"`
+	NumberB     string `gorm:"type:varchar(36);not null;uniqueIndex:idx_duplicate_dismissals_pair" json:"number_b"`
+	DismissedBy string `gorm:"type:varchar(36)" json:"dismissed_by,omitempty"`
+}
+
+type MergeUnitsInput struct {
+	Level       string `json:"level" binding:"required"`
+	KeepNumber  string `json:"keep_number" binding:"required"`
+	MergeNumber string `json:"merge_number" binding:"required"`
+}
+
+func (i *MergeUnitsInput) Prepare() {
+	i.Level = strings.ToLower(strings.TrimSpace(i.Level))
+	i.KeepNumber = strings.TrimSpace(i.KeepNumber)
+	i.MergeNumber = strings.TrimSpace(i.MergeNumber)
+}
+
+func (i *MergeUnitsInput) Validate() error {
+	if _, ok := Levels[i.Level]; !ok {
+		return errors.New("invalid level")
+	}
+	if i.KeepNumber == i.MergeNumber {
+		return errors.New("cannot merge a unit into itself")
+	}
+	return nil
+}
+
+type DismissDuplicateInput struct {
+	Level   string `json:"level" binding:"required"`
+	NumberA string `json:"number_a" binding:"required"`
+	NumberB string `json:"number_b" binding:"required"`
+}
+
+func (i *DismissDuplicateInput) Prepare() {
+	i.Level = strings.ToLower(strings.TrimSpace(i.Level))
+	i.NumberA = strings.TrimSpace(i.NumberA)
+	i.NumberB = strings.TrimSpace(i.NumberB)
+	if i.NumberB < i.NumberA {
+		i.NumberA, i.NumberB = i.NumberB, i.NumberA
+	}
+}
+
+func (i *DismissDuplicateInput) Validate() error {
+	if _, ok := Levels[i.Level]; !ok {
+		return errors.New("invalid level")
+	}
+	if i.NumberA == i.NumberB {
+		return errors.New("a unit cannot be a duplicate of itself")
+	}
+	return nil
+}
+
+// MergeResult reports what a merge changed.
+type MergeResult struct {
+	Level       string         `json:"level"`
+	KeepNumber  string         `json:"keep_number"`
+	MergeNumber string         `json:"merge_number"`
+	Reparented  map[string]int `json:"reparented"`
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html"
 	"strings"
+	"time"
 
 	"github.com/badoux/checkmail"
 	"gorm.io/gorm"
@@ -13,10 +14,31 @@ import (
 type UserRole string
 
 const (
-	RoleAdmin UserRole = "ADMIN"
-	RoleUser  UserRole = "USER"
+	RoleAdmin           UserRole = "ADMIN"
+	RoleUser            UserRole = "USER"
+	RoleDataEditor      UserRole = "DATA_EDITOR"
+	RoleReviewer        UserRole = "REVIEWER"
+	RoleDistrictSteward UserRole = "DISTRICT_STEWARD"
 )
 
+const (
+	UserStatusActive    = "ACTIVE"
+	UserStatusInactive  = "INACTIVE"
+	UserStatusSuspended = "SUSPENDED"
+	// UserStatusPendingDeletion marks an account the owner asked to delete.
+	// It cannot be used until the request is canceled or carried out.
+	UserStatusPendingDeletion = "PENDING_DELETION"
+)
+
+func IsValidRole(role UserRole) bool {
+	switch role {
+	case RoleAdmin, RoleUser, RoleDataEditor, RoleReviewer, RoleDistrictSteward:
+		return true
+	default:
+		return false
+	}
+}
+
 type User struct {
 	Number    string   `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
 	Email     string   `gorm:"uniqueIndex;not null" json:"email"`
@@ -25,6 +47,17 @@ type User struct {
 	LastName  string   `gorm:"type:text;size:255;" json:"last_name"`
 	Role      UserRole `gorm:"type:text;size:100;default:USER;" json:"role"`
 	Status    string   `json:"status" gorm:"size:100;not null"`
+	// SuspendedAt and SuspensionReason are set while an admin has suspended
+	// the account. The password flows never lift a suspension.
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty"`
+	SuspensionReason string     `gorm:"type:text" json:"suspension_reason,omitempty"`
+	// MFAEnabledAt is set once the user has confirmed a TOTP enrollment.
+	MFAEnabledAt *time.Time `json:"mfa_enabled_at,omitempty"`
+	// Provider and AuthNumber link the account to an external identity
+	// provider and the subject it assigned. Both are empty for password-only
+	// accounts.
+	Provider   string `gorm:"size:50;uniqueIndex:idx_users_provider_auth_number,where:auth_number <> ''" json:"provider,omitempty"`
+	AuthNumber string `gorm:"size:255;uniqueIndex:idx_users_provider_auth_number,where:auth_number <> ''" json:"-"`
 	gorm.Model
 }
 
@@ -32,13 +65,28 @@ func (u *User) IsAdmin() bool {
 	return u.Role == RoleAdmin
 }
 
+func (u *User) IsSuspended() bool {
+	return u.Status == UserStatusSuspended
+}
+
+func (u *User) IsPendingDeletion() bool {
+	return u.Status == UserStatusPendingDeletion
+}
+
+func (u *User) MFAEnabled() bool {
+	return u.MFAEnabledAt != nil
+}
+
 func (u *User) ValidateRole() error {
-	switch u.Role {
-	case RoleAdmin, RoleUser:
-		return nil
-	default:
+	if !IsValidRole(u.Role) {
 		return errors.New("invalid role")
 	}
+	// Stewards are always scoped, so they are granted through a
+	// RoleAssignment rather than as an account-wide role.
+	if u.Role == RoleDistrictSteward {
+		return errors.New("district steward must be granted as a scoped role assignment")
+	}
+	return nil
 }
 
 type SignInRequest struct {
@@ -132,10 +180,9 @@ type (
 )
 
 type SignUpInput struct {
-	FirstName string   `json:"first_name" binding:"required"`
-	LastName  string   `json:"last_name"`
-	Email     string   `json:"email" binding:"required"`
-	Role      UserRole `json:"role"`
+	FirstName string `json:"first_name" binding:"required"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email" binding:"required"`
 }
 
 func (s *SignUpInput) Prepare() {
@@ -151,13 +198,25 @@ func (s *SignUpInput) Validate() error {
 	if s.Email == "" {
 		return errors.New("email is required")
 	}
-	if s.Role != "" {
-		switch s.Role {
-		case RoleAdmin, RoleUser:
-			return nil
-		default:
-			return errors.New("invalid role")
-		}
-	}
 	return nil
 }
+
+type AdminUserResponse struct {
+	Number           string     `json:"number"`
+	Email            string     `json:"email"`
+	FirstName        string     `json:"first_name"`
+	LastName         string     `json:"last_name"`
+	Role             UserRole   `json:"role"`
+	Status           string     `json:"status"`
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	MFAEnabled       bool       `json:"mfa_enabled"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type UserListResponse struct {
+	Data  []AdminUserResponse `json:"data"`
+	Page  int                 `json:"page"`
+	Limit int                 `json:"limit"`
+	Total int64               `json:"total"`
+}
@@ -10,6 +10,8 @@ type Village struct {
 	ParishNumber string `gorm:"type:varchar(36)" json:"parish_number"`
 	Parish       Parish `gorm:"foreignKey:ParishNumber;references:Number;constraint: OnUpdate:CASCADE, OnDelete:RESTRICT;" json:"parish_details,omitempty"`
 	gorm.Model
+	// Reports is only filled in on detail responses.
+	Reports *ReportCounts `gorm:"-" json:"reports,omitempty"`
 }
 
 type VillageResponse struct {
@@ -0,0 +1,273 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookActionCreated  = "created"
+	WebhookActionUpdated  = "updated"
+	WebhookActionRenamed  = "renamed"
+	WebhookActionMoved    = "moved"
+	WebhookActionDeleted  = "deleted"
+	WebhookActionRestored = "restored"
+	WebhookActionPurged   = "purged"
+
+	// WebhookEventReleasePublished fires when a release is cut.
+	WebhookEventReleasePublished = "release.published"
+	// WebhookEventPing is only sent on request, to check an endpoint.
+	WebhookEventPing = "ping"
+
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliveryDelivered = "DELIVERED"
+	WebhookDeliveryFailed    = "FAILED"
+
+	maxWebhookURLLength = 2048
+)
+
+var webhookUnitActions = []string{
+	WebhookActionCreated,
+	WebhookActionUpdated,
+	WebhookActionRenamed,
+	WebhookActionMoved,
+	WebhookActionDeleted,
+	WebhookActionRestored,
+	WebhookActionPurged,
+}
+
+// WebhookEvents lists every event a webhook can subscribe to.
+func WebhookEvents() []string {
+	events := make([]string, 0, len(LevelOrder)*len(webhookUnitActions)+1)
+	for _, level := range LevelOrder {
+		for _, action := range webhookUnitActions {
+			events = append(events, level+"."+action)
+		}
+	}
+	return append(events, WebhookEventReleasePublished)
+}
+
+// MatchWebhookEvent reports whether filter selects event. Either half of a
+// filter may be "*", so "district.*" matches every district event and
+// "*.deleted" every deletion; "*" alone matches everything.
+func MatchWebhookEvent(filter, event string) bool {
+	if filter == "*" || filter == event {
+		return true
+	}
+	filterSubject, filterAction, ok := strings.Cut(filter, ".")
+	if !ok {
+		return false
+	}
+	subject, action, ok := strings.Cut(event, ".")
+	if !ok {
+		return false
+	}
+	return (filterSubject == "*" || filterSubject == subject) && (filterAction == "*" || filterAction == action)
+}
+
+// NormalizeWebhookEvents validates event filters and returns them in the
+// form stored on Webhook.Events.
+func NormalizeWebhookEvents(filters []string) (string, error) {
+	if len(filters) == 0 {
+		return "", errors.New("at least one event is required")
+	}
+
+	events := WebhookEvents()
+	var normalized []string
+	for _, filter := range filters {
+		filter = strings.ToLower(strings.TrimSpace(filter))
+		if !slices.ContainsFunc(events, func(event string) bool { return MatchWebhookEvent(filter, event) }) {
+			return "", fmt.Errorf("unknown event %q", filter)
+		}
+		if !slices.Contains(normalized, filter) {
+			normalized = append(normalized, filter)
+		}
+	}
+	return strings.Join(normalized, " "), nil
+}
+
+// Webhook is an endpoint that receives signed change events. Like API keys,
+// a webhook belongs to a user or, when OrganizationNumber is set, to an
+// organization; UserNumber then records who registered it.
+type Webhook struct {
+	gorm.Model
+	Number             string `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	UserNumber         string `gorm:"type:varchar(36);not null;index" json:"created_by"`
+	OrganizationNumber string `gorm:"type:varchar(36);index" json:"organization_number,omitempty"`
+	URL                string `gorm:"size:2048;not null" json:"url"`
+	Description        string `gorm:"size:255" json:"description,omitempty"`
+	// Events is a space separated list of event filters.
+	Events string `gorm:"size:1024;not null" json:"-"`
+	// Secret signs every payload; it is only shown when the webhook is
+	// created.
+	Secret string `gorm:"size:100;not null" json:"-"`
+	Active bool   `gorm:"not null;default:true" json:"active"`
+}
+
+func (w *Webhook) EventList() []string {
+	return strings.Fields(w.Events)
+}
+
+// Wants reports whether the webhook subscribes to event.
+func (w *Webhook) Wants(event string) bool {
+	return slices.ContainsFunc(w.EventList(), func(filter string) bool { return MatchWebhookEvent(filter, event) })
+}
+
+func (w *Webhook) IsOrganizationWebhook() bool {
+	return w.OrganizationNumber != ""
+}
+
+// WebhookDelivery is one attempt, with retries, to deliver an event to a
+// webhook. Payload is the exact body sent, so a redelivery repeats it byte
+// for byte under the same event ID.
+type WebhookDelivery struct {
+	gorm.Model
+	Number         string     `gorm:"primaryKey;type:varchar(36);not null;unique" json:"number"`
+	WebhookNumber  string     `gorm:"type:varchar(36);not null;index" json:"webhook_number"`
+	EventID        string     `gorm:"type:varchar(36);not null;index" json:"event_id"`
+	Event          string     `gorm:"size:50;not null" json:"event"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	RedeliveryOf   string     `gorm:"type:varchar(36)" json:"redelivery_of,omitempty"`
+	Status         string     `gorm:"size:20;not null;index:idx_webhook_deliveries_due,priority:1" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null;index:idx_webhook_deliveries_due,priority:2" json:"next_attempt_at"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookCursor records how far into the audit log events have been
+// dispatched.
+type WebhookCursor struct {
+	Name      string `gorm:"primaryKey;size:50"`
+	Sequence  int64  `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+// WebhookPayload is the JSON body posted to a webhook.
+type WebhookPayload struct {
+	ID        string           `json:"id"`
+	Event     string           `json:"event"`
+	Sequence  int64            `json:"sequence,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      WebhookEventData `json:"data"`
+}
+
+// WebhookEventData describes what changed. Before and After hold the
+// changed columns for updates and the whole record for creates and
+// deletes.
+type WebhookEventData struct {
+	Level  string          `json:"level,omitempty"`
+	Number string          `json:"number,omitempty"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+type WebhookInput struct {
+	URL                string   `json:"url" binding:"required"`
+	Description        string   `json:"description"`
+	Events             []string `json:"events" binding:"required"`
+	OrganizationNumber string   `json:"organization_number"`
+}
+
+func (i *WebhookInput) Prepare() {
+	i.URL = strings.TrimSpace(i.URL)
+	i.Description = strings.TrimSpace(i.Description)
+	i.OrganizationNumber = strings.TrimSpace(i.OrganizationNumber)
+}
+
+func (i *WebhookInput) Validate() error {
+	if err := ValidateWebhookURL(i.URL); err != nil {
+		return err
+	}
+	if len(i.Description) > 255 {
+		return errors.New("description must be at most 255 characters")
+	}
+	_, err := NormalizeWebhookEvents(i.Events)
+	return err
+}
+
+// WebhookUpdateInput changes a webhook; omitted fields are left alone.
+type WebhookUpdateInput struct {
+	URL         *string  `json:"url"`
+	Description *string  `json:"description"`
+	Events      []string `json:"events"`
+	Active      *bool    `json:"active"`
+}
+
+func (i *WebhookUpdateInput) Prepare() {
+	if i.URL != nil {
+		trimmed := strings.TrimSpace(*i.URL)
+		i.URL = &trimmed
+	}
+	if i.Description != nil {
+		trimmed := strings.TrimSpace(*i.Description)
+		i.Description = &trimmed
+	}
+}
+
+func (i *WebhookUpdateInput) Validate() error {
+	if i.URL != nil {
+		if err := ValidateWebhookURL(*i.URL); err != nil {
+			return err
+		}
+	}
+	if i.Description != nil && len(*i.Description) > 255 {
+		return errors.New("description must be at most 255 characters")
+	}
+	if i.Events != nil {
+		if _, err := NormalizeWebhookEvents(i.Events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateWebhookURL accepts absolute http and https URLs. Whether the host
+// may be on a private network is checked when connecting.
+func ValidateWebhookURL(value string) error {
+	if len(value) > maxWebhookURLLength {
+		return errors.New("url is too long")
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if parsed.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	return nil
+}
+
+type WebhookResponse struct {
+	Webhook
+	Events []string `json:"events"`
+}
+
+// WebhookCreatedResponse is returned once, when the signing secret can
+// still be shown.
+type WebhookCreatedResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+type WebhookListResponse struct {
+	Data  []WebhookResponse `json:"data"`
+	Page  int               `json:"page"`
+	Limit int               `json:"limit"`
+	Total int64             `json:"total"`
+}
+
+type WebhookDeliveryListResponse struct {
+	Data  []WebhookDelivery `json:"data"`
+	Page  int               `json:"page"`
+	Limit int               `json:"limit"`
+	Total int64             `json:"total"`
+}
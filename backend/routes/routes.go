@@ -11,9 +11,14 @@ import (
 	"opendataug.org/database"
 	"opendataug.org/middleware"
 	v1 "opendataug.org/routes/v1"
+	"opendataug.org/services"
 )
 
-func SetupRouter(db *database.Database) *gin.Engine {
+// SetupRouter builds the API. usageRecorder receives a row per API key
+// request; the caller stops it once the server has drained. changeBus feeds
+// the change streams; the caller runs it and stops it before draining, so
+// open streams end.
+func SetupRouter(db *database.Database, usageRecorder *services.UsageRecorder, changeBus *services.ChangeBus) *gin.Engine {
 	router := gin.Default()
 
 	if os.Getenv("ENVIRONMENT") == "prod" {
@@ -24,22 +29,33 @@ func SetupRouter(db *database.Database) *gin.Engine {
 
 	router.Use(static.Serve("./templates/*", static.LocalFile("./templates/*", false)))
 	router.Use(middleware.CorsMiddleware())
+	router.Use(middleware.RequestID())
 	router.NoRoute(commons.RouteNotFound)
 
+	jwksHandler := v1.NewJWKSHandler(commons.DefaultKeyManager())
+	jwksHandler.RegisterRoutes(router)
+
 	v1Group := router.Group("v1")
+	// Streams stay open for as long as the client listens, so they get a
+	// group of their own without the request timeout.
+	streamGroup := router.Group("v1")
 	if os.Getenv("ENVIRONMENT") == constants.ENVIRONMENT_PROD {
-		v1Group.Use(middleware.RateLimit(60, time.Minute, 1))
+		rateLimit := middleware.RateLimit(60, time.Minute, 1)
+		v1Group.Use(rateLimit)
+		streamGroup.Use(rateLimit)
 	}
 
 	v1Group.Use(middleware.TimeoutMiddleware(30 * time.Second))
 
 	{
 		// Public routes
-		authHandler := v1.NewAuthHandler(db)
+		quotaTracker := services.NewQuotaTracker(db.DB)
+		authHandler := v1.NewAuthHandler(db, usageRecorder, quotaTracker)
 		authHandler.RegisterRoutes(v1Group)
 
 		// Protected routes
 		protected := v1Group.Group("")
+		protected.Use(changeBus.Notifier())
 		{
 			regionHandler := v1.NewRegionHandler(db)
 			regionHandler.RegisterRoutes(protected, authHandler)
@@ -61,6 +77,48 @@ func SetupRouter(db *database.Database) *gin.Engine {
 
 			apiKeyHandler := v1.NewAPIKeyHandler(db)
 			apiKeyHandler.RegisterRoutes(protected, authHandler)
+
+			roleHandler := v1.NewRoleHandler(db)
+			roleHandler.RegisterRoutes(protected, authHandler)
+
+			organizationHandler := v1.NewOrganizationHandler(db, quotaTracker)
+			organizationHandler.RegisterRoutes(protected, authHandler)
+
+			proposalHandler := v1.NewProposalHandler(db)
+			proposalHandler.RegisterRoutes(protected, authHandler)
+
+			reportHandler := v1.NewReportHandler(db)
+			reportHandler.RegisterRoutes(protected, authHandler)
+
+			duplicateHandler := v1.NewDuplicateHandler(db)
+			duplicateHandler.RegisterRoutes(protected, authHandler)
+
+			releaseHandler := v1.NewReleaseHandler(db)
+			releaseHandler.RegisterRoutes(protected, authHandler)
+
+			diffHandler := v1.NewDiffHandler(db)
+			diffHandler.RegisterRoutes(protected, authHandler)
+
+			changeFeedHandler := v1.NewChangeFeedHandler(db)
+			changeFeedHandler.RegisterRoutes(protected, authHandler)
+
+			webhookHandler := v1.NewWebhookHandler(db)
+			webhookHandler.RegisterRoutes(protected, authHandler)
+
+			trashHandler := v1.NewTrashHandler(db)
+			trashHandler.RegisterRoutes(protected, authHandler)
+
+			streamHandler := v1.NewStreamHandler(db, changeBus)
+			streamHandler.RegisterRoutes(streamGroup, authHandler)
+
+			adminHandler := v1.NewAdminHandler(db, authHandler)
+			adminHandler.RegisterRoutes(protected)
+
+			auditHandler := v1.NewAuditHandler(db)
+			auditHandler.RegisterRoutes(protected, authHandler)
+
+			qualityHandler := v1.NewQualityHandler(db)
+			qualityHandler.RegisterRoutes(protected, authHandler)
 		}
 	}
 
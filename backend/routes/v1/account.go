@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/controllers"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+// defaultDeletionGracePeriod gives users a month to change their mind.
+const defaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// deletionGracePeriod reads ACCOUNT_DELETION_GRACE_PERIOD. Zero deletes
+// accounts immediately.
+func deletionGracePeriod() time.Duration {
+	value := os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD")
+	if value == "" {
+		return defaultDeletionGracePeriod
+	}
+	grace, err := time.ParseDuration(value)
+	if err != nil || grace < 0 {
+		log.Printf("Invalid ACCOUNT_DELETION_GRACE_PERIOD %q, using default", value)
+		return defaultDeletionGracePeriod
+	}
+	return grace
+}
+
+func (h *AuthHandler) ExportAccount(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	export, err := h.accounts.Export(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to export account data"))
+		return
+	}
+
+	filename := fmt.Sprintf("opendataug-account-%s", export.ExportedAt.Format("20060102"))
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		c.JSON(http.StatusOK, export)
+	case "zip":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+		c.Header("Content-Type", "application/zip")
+		c.Status(http.StatusOK)
+		if err := writeAccountExportZip(c.Writer, export); err != nil {
+			log.Printf("Failed to write account export for %s: %v", user.Number, err)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("format must be json or zip"))
+	}
+}
+
+// writeAccountExportZip writes one JSON file per section of the export.
+func writeAccountExportZip(w http.ResponseWriter, export *models.AccountExport) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"api_keys.json", export.APIKeys},
+		{"sessions.json", export.Sessions},
+		{"usage.json", export.Usage},
+		{"memberships.json", export.Memberships},
+		{"role_assignments.json", export.RoleAssignments},
+	}
+
+	for _, file := range files {
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// DeleteAccount schedules the account for deletion after the grace period
+// and emails a link that cancels it. The account is signed out and
+// disabled straight away.
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	accounts := h.accounts.WithContext(c)
+
+	grace := deletionGracePeriod()
+	if grace == 0 {
+		if err := h.deleteAccountNow(c, accounts, user); err != nil {
+			return
+		}
+		h.clearAuthCookies(c)
+		c.JSON(http.StatusOK, gin.H{"message": "Account successfully deleted"})
+		return
+	}
+
+	deletion, token, err := accounts.ScheduleDeletion(user, grace)
+	if err != nil {
+		switch {
+		case errors.Is(err, controllers.ErrOwnsOrganizations):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Transfer ownership of your organizations before deleting your account"))
+		case errors.Is(err, controllers.ErrDeletionAlreadyPending):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to schedule account deletion"))
+		}
+		return
+	}
+
+	emailService := services.Info{
+		Email:        user.Email,
+		Token:        token,
+		MailType:     "Account deletion scheduled - Open Data Uganda",
+		UserName:     user.FirstName,
+		DeletionDate: deletion.ScheduledFor.UTC().Format("2 Jan 2006 15:04 MST"),
+		CurrentYear:  time.Now().Year(),
+		Type:         services.EmailTypeAccountDeletion,
+	}
+	if err := emailService.SendEmail(); err != nil {
+		log.Printf("Failed to send deletion notice to %s: %v", user.Email, err)
+	}
+
+	h.clearAuthCookies(c)
+	c.JSON(http.StatusAccepted, models.AccountDeletionResponse{
+		Message:      "Account scheduled for deletion. Use the link sent to your email to cancel.",
+		ScheduledFor: deletion.ScheduledFor,
+	})
+}
+
+func (h *AuthHandler) deleteAccountNow(c *gin.Context, accounts *controllers.AccountController, user *models.User) error {
+	owned, err := h.organizations.CountOwned(user.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to check organization ownership"))
+		return err
+	}
+	if owned > 0 {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Transfer ownership of your organizations before deleting your account"))
+		return controllers.ErrOwnsOrganizations
+	}
+
+	tx := h.db.DB.WithContext(c).Begin()
+	if err := accounts.DeleteAccount(tx, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete account"))
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete account"))
+		return err
+	}
+	return nil
+}
+
+func (h *AuthHandler) CancelAccountDeletion(c *gin.Context) {
+	var payload models.AccountDeletionCancelInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+
+	if _, err := h.accounts.WithContext(c).CancelDeletion(payload.Token); err != nil {
+		if errors.Is(err, controllers.ErrDeletionTokenInvalid) {
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to cancel account deletion"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion canceled. You can sign in again."})
+}
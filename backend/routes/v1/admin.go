@@ -0,0 +1,282 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+const maxAdminPageSize = 100
+
+type AdminHandler struct {
+	auth           *AuthHandler
+	userController *controllers.UserController
+}
+
+func NewAdminHandler(db *database.Database, authHandler *AuthHandler) *AdminHandler {
+	return &AdminHandler{
+		auth:           authHandler,
+		userController: controllers.NewUserController(db, services.NewJWTService()),
+	}
+}
+
+func (h *AdminHandler) RegisterRoutes(r *gin.RouterGroup) {
+	admin := r.Group("/admin")
+	admin.Use(h.auth.TokenAuthMiddleware())
+
+	users := admin.Group("/users")
+	users.Use(h.auth.RequirePermission(services.PermUsersManage))
+	{
+		users.GET("", h.listUsers)
+		users.GET("/:id", h.getUser)
+		users.PATCH("/:id/role", h.changeRole)
+		users.POST("/:id/suspend", h.suspendUser)
+		users.POST("/:id/reactivate", h.reactivateUser)
+		users.POST("/:id/resend-activation", h.resendActivation)
+		users.POST("/:id/password-reset", h.triggerPasswordReset)
+		users.POST("/:id/unlock", h.unlockUser)
+		users.POST("/:id/mfa/reset", h.resetMFA)
+	}
+}
+
+func toAdminUserResponse(user *models.User) models.AdminUserResponse {
+	return models.AdminUserResponse{
+		Number:           user.Number,
+		Email:            user.Email,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		Role:             user.Role,
+		Status:           user.Status,
+		SuspendedAt:      user.SuspendedAt,
+		SuspensionReason: user.SuspensionReason,
+		MFAEnabled:       user.MFAEnabled(),
+		CreatedAt:        user.CreatedAt,
+	}
+}
+
+// targetUser loads the user named by :id, writing a 404 when it is missing.
+func (h *AdminHandler) targetUser(c *gin.Context) (*models.User, bool) {
+	user, err := h.userController.FindByNumber(commons.Sanitize(c.Param("id")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("User not found"))
+		return nil, false
+	}
+	return user, true
+}
+
+func isCurrentUser(c *gin.Context, user *models.User) bool {
+	return c.MustGet("user").(*models.User).Number == user.Number
+}
+
+func (h *AdminHandler) listUsers(c *gin.Context) {
+	pagination := commons.GetPaginationParams(c)
+	pagination.Limit = min(pagination.Limit, maxAdminPageSize)
+
+	filter := controllers.UserFilter{
+		Search: strings.TrimSpace(c.Query("q")),
+		Status: strings.ToUpper(strings.TrimSpace(c.Query("status"))),
+		Role:   models.UserRole(strings.ToUpper(strings.TrimSpace(c.Query("role")))),
+	}
+
+	if filter.Role != "" && !models.IsValidRole(filter.Role) {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Invalid role filter"))
+		return
+	}
+
+	users, total, err := h.userController.ListUsers(filter, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch users"))
+		return
+	}
+
+	response := models.UserListResponse{
+		Data:  make([]models.AdminUserResponse, len(users)),
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: total,
+	}
+	for i := range users {
+		response.Data[i] = toAdminUserResponse(&users[i])
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AdminHandler) getUser(c *gin.Context) {
+	user, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, toAdminUserResponse(user))
+}
+
+func (h *AdminHandler) changeRole(c *gin.Context) {
+	var payload struct {
+		Role models.UserRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	user, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	if isCurrentUser(c, user) {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("You cannot change your own role"))
+		return
+	}
+
+	role := models.UserRole(strings.ToUpper(strings.TrimSpace(string(payload.Role))))
+	if err := h.userController.WithContext(c).ChangeRole(user, role); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, toAdminUserResponse(user))
+}
+
+func (h *AdminHandler) suspendUser(c *gin.Context) {
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+			return
+		}
+	}
+
+	user, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	if isCurrentUser(c, user) {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("You cannot suspend your own account"))
+		return
+	}
+
+	if user.IsSuspended() {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("User is already suspended"))
+		return
+	}
+
+	if err := h.userController.WithContext(c).SuspendUser(user.Number, strings.TrimSpace(payload.Reason)); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to suspend user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User suspended successfully"})
+}
+
+func (h *AdminHandler) reactivateUser(c *gin.Context) {
+	user, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.IsSuspended() {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("User is not suspended"))
+		return
+	}
+
+	if err := h.userController.WithContext(c).ReactivateUser(user.Number); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to reactivate user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User reactivated successfully"})
+}
+
+func (h *AdminHandler) resendActivation(c *gin.Context) {
+	user, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Status != models.UserStatusInactive {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Only inactive accounts can be sent an activation link"))
+		return
+	}
+
+	if err := h.auth.sendPasswordToken(user, services.EmailTypeRegistration, "Open Data Uganda - Registration"); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Activation link sent"})
+}
+
+func (h *AdminHandler) unlockUser(c *gin.Context) {
+	user, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	unlocked, err := h.auth.loginGuard.Unlock(user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to unlock user"))
+		return
+	}
+	if !unlocked {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("User has no failed sign-in attempts"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+func (h *AdminHandler) triggerPasswordReset(c *gin.Context) {
+	user, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	if user.IsSuspended() {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Reactivate the account before resetting its password"))
+		return
+	}
+
+	if err := h.auth.sendPasswordToken(user, services.EmailTypeResetPwd, "Password reset - Open Data Uganda"); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset link sent"})
+}
+
+func (h *AdminHandler) resetMFA(c *gin.Context) {
+	user, ok := h.targetUser(c)
+	if !ok {
+		return
+	}
+
+	if isCurrentUser(c, user) {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("You cannot reset your own two-factor authentication"))
+		return
+	}
+
+	if !user.MFAEnabled() {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("User does not have two-factor authentication enabled"))
+		return
+	}
+
+	if err := h.auth.mfa.WithContext(c).Reset(user); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to reset two-factor authentication"))
+		return
+	}
+
+	h.auth.sendMFADisabledNotice(user)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
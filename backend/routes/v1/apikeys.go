@@ -25,13 +25,24 @@ func init() {
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
+const defaultUsageWindow = 30 * 24 * time.Hour
+
+var usageGranularities = map[string]bool{
+	"hour":  true,
+	"day":   true,
+	"week":  true,
+	"month": true,
+}
+
 type APIKeyHandler struct {
-	controller *controllers.APIKeyController
+	controller    *controllers.APIKeyController
+	organizations *controllers.OrganizationController
 }
 
 func NewAPIKeyHandler(db *database.Database) *APIKeyHandler {
 	return &APIKeyHandler{
-		controller: controllers.NewAPIKeyController(db),
+		controller:    controllers.NewAPIKeyController(db),
+		organizations: controllers.NewOrganizationController(db),
 	}
 }
 
@@ -42,12 +53,14 @@ func (h *APIKeyHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHand
 		keys.GET("", h.listAPIKeys)
 		keys.POST("", h.createAPIKey)
 		keys.DELETE("/:id", h.deleteAPIKey)
+		keys.GET("/:id/usage", h.getAPIKeyUsage)
 	}
 }
 
 type CreateAPIKeyRequest struct {
 	Name      string     `json:"name" binding:"required"`
 	ExpiresAt *time.Time `json:"expires_at"`
+	Scopes    []string   `json:"scopes"`
 }
 
 func generateRandomString(n int) string {
@@ -80,6 +93,12 @@ func (h *APIKeyHandler) createAPIKey(c *gin.Context) {
 		return
 	}
 
+	scopes, err := models.NormalizeAPIKeyScopes(payload.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
 	user, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("User not found in context"))
@@ -87,7 +106,7 @@ func (h *APIKeyHandler) createAPIKey(c *gin.Context) {
 	}
 	currentUser := user.(*models.User)
 
-	exists, err := h.controller.APIKeyNameExists(currentUser.Number, payload.Name)
+	exists, err = h.controller.APIKeyNameExists(currentUser.Number, payload.Name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to check API key name"))
 		return
@@ -109,9 +128,10 @@ func (h *APIKeyHandler) createAPIKey(c *gin.Context) {
 		Name:       payload.Name,
 		Key:        key,
 		ExpiresAt:  payload.ExpiresAt,
+		Scopes:     scopes,
 	}
 
-	if err := h.controller.CreateAPIKey(apiKey); err != nil {
+	if err := h.controller.WithContext(c).CreateAPIKey(apiKey); err != nil {
 		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to create API key"))
 		return
 	}
@@ -139,6 +159,7 @@ func (h *APIKeyHandler) listAPIKeys(c *gin.Context) {
 			ID:        key.Number,
 			Name:      key.Name,
 			Key:       key.Key,
+			Scopes:    key.ScopeList(),
 			CreatedAt: key.CreatedAt,
 		}
 	}
@@ -162,7 +183,7 @@ func (h *APIKeyHandler) deleteAPIKey(c *gin.Context) {
 
 	keyNumber = commons.Sanitize(keyNumber)
 
-	if err := h.controller.DeleteAPIKey(currentUser.Number, keyNumber); err != nil {
+	if err := h.controller.WithContext(c).DeleteAPIKey(currentUser.Number, keyNumber); err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("API key not found"))
 			return
@@ -173,3 +194,88 @@ func (h *APIKeyHandler) deleteAPIKey(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
 }
+
+// canViewAPIKey allows owners of personal keys and any member of the owning
+// organization to read a key's usage.
+func (h *APIKeyHandler) canViewAPIKey(apiKey *models.APIKey, user *models.User) bool {
+	if !apiKey.IsOrganizationKey() {
+		return apiKey.UserNumber == user.Number
+	}
+	_, err := h.organizations.FindMembership(apiKey.OrganizationNumber, user.Number)
+	return err == nil
+}
+
+func parseUsageTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func (h *APIKeyHandler) getAPIKeyUsage(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("User not found in context"))
+		return
+	}
+	currentUser := user.(*models.User)
+
+	keyNumber := commons.Sanitize(c.Param("id"))
+	if keyNumber == "" {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("API key number is missing"))
+		return
+	}
+
+	apiKey, err := h.controller.GetAPIKeyByNumber(keyNumber)
+	if err != nil || !h.canViewAPIKey(apiKey, currentUser) {
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("API key not found"))
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", "day")
+	if !usageGranularities[granularity] {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("granularity must be one of hour, day, week or month"))
+		return
+	}
+
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		if to, err = parseUsageTime(value); err != nil {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Invalid 'to' date"))
+			return
+		}
+	}
+
+	from := to.Add(-defaultUsageWindow)
+	if value := c.Query("from"); value != "" {
+		if from, err = parseUsageTime(value); err != nil {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Invalid 'from' date"))
+			return
+		}
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("'from' must be before 'to'"))
+		return
+	}
+
+	series, err := h.controller.GetAPIKeyUsage(apiKey.Number, from, to, granularity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch API key usage"))
+		return
+	}
+
+	var total int64
+	for _, point := range series {
+		total += point.Count
+	}
+
+	c.JSON(http.StatusOK, models.APIKeyUsageResponse{
+		APIKeyID:    apiKey.Number,
+		From:        from,
+		To:          to,
+		Granularity: granularity,
+		Total:       total,
+		Series:      series,
+	})
+}
@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+type AuditHandler struct {
+	controller *controllers.AuditController
+}
+
+func NewAuditHandler(db *database.Database) *AuditHandler {
+	return &AuditHandler{
+		controller: controllers.NewAuditController(db),
+	}
+}
+
+func (h *AuditHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	audit := r.Group("/admin/audit")
+	audit.Use(authHandler.TokenAuthMiddleware(), authHandler.RequirePermission(services.PermAuditRead))
+	{
+		audit.GET("", h.listAuditLogs)
+		audit.GET("/verify", h.verifyAuditLog)
+	}
+}
+
+func toAuditLogResponse(entry *models.AuditLog) models.AuditLogResponse {
+	response := models.AuditLogResponse{
+		Sequence:     entry.Sequence,
+		ActorType:    entry.ActorType,
+		ActorNumber:  entry.ActorNumber,
+		Action:       entry.Action,
+		EntityType:   entry.EntityType,
+		EntityNumber: entry.EntityNumber,
+		IPAddress:    entry.IPAddress,
+		RequestID:    entry.RequestID,
+		Hash:         entry.Hash,
+		RedactedAt:   entry.RedactedAt,
+		CreatedAt:    entry.CreatedAt,
+	}
+	if entry.Before != nil {
+		response.Before = json.RawMessage(*entry.Before)
+	}
+	if entry.After != nil {
+		response.After = json.RawMessage(*entry.After)
+	}
+	return response
+}
+
+// optionalTimeQuery parses an optional date query parameter, writing a 400
+// when it is malformed.
+func optionalTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+
+	parsed, err := parseUsageTime(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Invalid '"+name+"' date"))
+		return nil, false
+	}
+	return &parsed, true
+}
+
+func (h *AuditHandler) listAuditLogs(c *gin.Context) {
+	pagination := commons.GetPaginationParams(c)
+	pagination.Limit = min(pagination.Limit, maxAdminPageSize)
+
+	filter := controllers.AuditFilter{
+		ActorNumber:  commons.Sanitize(c.Query("actor")),
+		Action:       strings.ToLower(strings.TrimSpace(c.Query("action"))),
+		EntityType:   strings.TrimSpace(c.Query("entity_type")),
+		EntityNumber: commons.Sanitize(c.Query("entity_number")),
+		RequestID:    strings.TrimSpace(c.Query("request_id")),
+	}
+
+	var ok bool
+	if filter.From, ok = optionalTimeQuery(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = optionalTimeQuery(c, "to"); !ok {
+		return
+	}
+
+	entries, total, err := h.controller.ListAuditLogs(filter, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch audit log"))
+		return
+	}
+
+	response := models.AuditLogListResponse{
+		Data:  make([]models.AuditLogResponse, len(entries)),
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: total,
+	}
+	for i := range entries {
+		response.Data[i] = toAuditLogResponse(&entries[i])
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AuditHandler) verifyAuditLog(c *gin.Context) {
+	verification, err := h.controller.VerifyChain()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to verify audit log"))
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
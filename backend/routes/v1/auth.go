@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -25,14 +26,37 @@ type AuthHandler struct {
 	db             *database.Database
 	userController *controllers.UserController
 	jwtService     *services.JWTService
+	sessions       *controllers.SessionController
+	authorizer     *services.Authorizer
+	usageRecorder  *services.UsageRecorder
+	quotas         *services.QuotaTracker
+	organizations  *controllers.OrganizationController
+	loginGuard     *services.LoginGuard
+	mfa            *controllers.MFAController
+	oidc           *services.OIDCRegistry
+	oidcLogins     *controllers.OIDCController
+	accounts       *controllers.AccountController
+	emailChanges   *controllers.EmailChangeController
 }
 
-func NewAuthHandler(db *database.Database) *AuthHandler {
+func NewAuthHandler(db *database.Database, usageRecorder *services.UsageRecorder, quotas *services.QuotaTracker) *AuthHandler {
 	jwtService := services.NewJWTService()
+	userController := controllers.NewUserController(db, jwtService)
 	return &AuthHandler{
 		db:             db,
-		userController: controllers.NewUserController(db, jwtService),
+		userController: userController,
 		jwtService:     jwtService,
+		sessions:       controllers.NewSessionController(db),
+		authorizer:     services.NewAuthorizer(db.DB),
+		usageRecorder:  usageRecorder,
+		quotas:         quotas,
+		organizations:  controllers.NewOrganizationController(db),
+		loginGuard:     services.NewLoginGuard(db.DB),
+		mfa:            controllers.NewMFAController(db),
+		oidc:           services.LoadOIDCProviders(),
+		oidcLogins:     controllers.NewOIDCController(db, userController),
+		accounts:       controllers.NewAccountController(db),
+		emailChanges:   controllers.NewEmailChangeController(db, jwtService),
 	}
 }
 
@@ -47,7 +71,14 @@ func (h *AuthHandler) RegisterRoutes(r *gin.RouterGroup) {
 		auth.POST("/refresh", h.RefreshAccessToken)
 		auth.POST("/reset-password", h.ResetPassword)
 		auth.POST("/set-password", h.SetPassword)
+		auth.GET("/password-policy", h.GetPasswordPolicy)
+		auth.POST("/account/deletion/cancel", h.CancelAccountDeletion)
+		auth.POST("/email/confirm", h.ConfirmEmailChange)
 		auth.POST("/register", h.RegisterUser)
+		auth.POST("/mfa/verify", h.VerifyMFA)
+		auth.GET("/oidc/providers", h.ListOIDCProviders)
+		auth.GET("/oidc/:provider/login", h.OIDCLogin)
+		auth.GET("/oidc/:provider/callback", h.OIDCCallback)
 
 		protected := auth.Group("")
 		protected.Use(h.TokenAuthMiddleware())
@@ -55,7 +86,17 @@ func (h *AuthHandler) RegisterRoutes(r *gin.RouterGroup) {
 			protected.POST("/logout", h.LogoutUser)
 			protected.GET("/profile", h.Profile)
 			protected.PATCH("/profile", h.UpdateProfile)
+			protected.POST("/email/change", h.RequestEmailChange)
 			protected.DELETE("/account", h.DeleteAccount)
+			protected.GET("/account/export", h.ExportAccount)
+			protected.GET("/sessions", h.ListSessions)
+			protected.POST("/sessions/revoke-others", h.RevokeOtherSessions)
+			protected.DELETE("/sessions/:id", h.RevokeSession)
+			protected.GET("/mfa", h.MFAStatus)
+			protected.POST("/mfa/enroll", h.EnrollMFA)
+			protected.POST("/mfa/confirm", h.ConfirmMFA)
+			protected.POST("/mfa/disable", h.DisableMFA)
+			protected.POST("/mfa/recovery-codes", h.RegenerateRecoveryCodes)
 		}
 	}
 }
@@ -67,13 +108,42 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	email := strings.ToLower(strings.TrimSpace(payload.Email))
+	ip := c.ClientIP()
+
+	if err := h.loginGuard.Check(email, ip); err != nil {
+		writeThrottled(c, err)
+		return
+	}
+
 	user, err := h.userController.AuthenticateUser(payload.Email, payload.Password)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		switch {
+		case errors.Is(err, controllers.ErrInvalidCredentials):
+			h.recordLoginFailure(email, ip)
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Invalid email or password"))
+		case errors.Is(err, controllers.ErrAccountSuspended):
+			c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Account has been suspended"))
+		case errors.Is(err, controllers.ErrAccountPendingDeletion):
+			c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Account is scheduled for deletion, use the link in your email to cancel"))
+		case errors.Is(err, controllers.ErrAccountInactive):
+			c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Account is not active"))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to sign in"))
+		}
 		return
 	}
 
-	response, tokens, err := h.userController.CreateLoginSession(user)
+	if user.MFAEnabled() {
+		h.issueMFAChallenge(c, user)
+		return
+	}
+
+	if err := h.loginGuard.RecordSuccess(email); err != nil {
+		log.Printf("Failed to reset sign-in attempts for %s: %v", email, err)
+	}
+
+	response, tokens, err := h.userController.CreateLoginSession(user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
 		return
@@ -83,6 +153,38 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// recordLoginFailure counts a failed sign-in and, when it locks the account,
+// emails the owner. Unknown emails are counted too so responses do not
+// reveal which addresses are registered.
+func (h *AuthHandler) recordLoginFailure(email, ip string) {
+	lockedUntil, err := h.loginGuard.RecordFailure(email, ip)
+	if err != nil {
+		log.Printf("Failed to record sign-in failure for %s: %v", email, err)
+		return
+	}
+	if lockedUntil == nil {
+		return
+	}
+
+	user, err := h.userController.FindByEmail(email)
+	if err != nil {
+		return
+	}
+
+	emailService := services.Info{
+		Email:       user.Email,
+		MailType:    "Account locked - Open Data Uganda",
+		UserName:    user.FirstName,
+		LockedUntil: lockedUntil.UTC().Format("2 Jan 2006 15:04 MST"),
+		CurrentYear: time.Now().Year(),
+		Type:        services.EmailTypeAccountLocked,
+	}
+
+	if err := emailService.SendEmail(); err != nil {
+		log.Printf("Failed to send lockout notice to %s: %v", user.Email, err)
+	}
+}
+
 func (h *AuthHandler) RefreshAccessToken(c *gin.Context) {
 	refreshToken, err := c.Cookie("refresh_token")
 	if err != nil {
@@ -112,8 +214,13 @@ func (h *AuthHandler) RefreshAccessToken(c *gin.Context) {
 		return
 	}
 
-	newTokens, err := h.userController.RefreshUserSession(refreshToken)
+	newTokens, err := h.userController.RefreshUserSession(refreshToken, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
+		if errors.Is(err, controllers.ErrRefreshTokenReused) {
+			h.clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Session has been revoked, please log in again"))
+			return
+		}
 		c.JSON(http.StatusUnauthorized, customerrors.NewBadRequestError(err.Error()))
 		return
 	}
@@ -195,6 +302,18 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
+	if err := h.sendPasswordToken(user, services.EmailTypeResetPwd, "Password reset - Open Data Uganda"); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewInternalError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset link sent to your email"})
+}
+
+// sendPasswordToken issues a single-use password token for user and emails
+// it using the given template. Used for self-service and admin-triggered
+// resets as well as resending activation links.
+func (h *AuthHandler) sendPasswordToken(user *models.User, emailType, subject string) error {
 	tx := h.db.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -217,8 +336,7 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 
 	userToken, err := h.jwtService.GenerateTokenWithClaims(claims, tx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate reset token"})
-		return
+		return errors.New("Failed to generate reset token")
 	}
 
 	saveUserPasswordToken := models.PasswordReset{
@@ -230,32 +348,28 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 
 	if err := tx.Create(&saveUserPasswordToken).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusBadRequest, customerrors.NewDatabaseError("Failed to save user password token"))
-		return
+		return errors.New("Failed to save user password token")
 	}
 
 	emailService := services.Info{
-		Email:       payload.Email,
+		Email:       user.Email,
 		Token:       userToken,
-		MailType:    "Password reset - Open Data Uganda",
-		UserName:    "",
+		MailType:    subject,
+		UserName:    user.FirstName,
 		CurrentYear: time.Now().Year(),
-		Type:        "password_reset",
+		Type:        emailType,
 	}
 
-	if err := emailService.SendEmail(); err != nil {
+	if err := emailService.QueueEmail(tx); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusBadRequest, customerrors.NewInternalError("Failed to send email"))
-		return
+		return errors.New("Failed to queue email")
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, customerrors.NewDatabaseError("Failed to complete password reset"))
-		return
+		return errors.New("Failed to complete password reset")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset link sent to your email"})
+	return nil
 }
 
 func (h *AuthHandler) SetPassword(c *gin.Context) {
@@ -264,6 +378,10 @@ func (h *AuthHandler) SetPassword(c *gin.Context) {
 		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
 		return
 	}
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
 
 	tokenString := c.Query("token")
 	if tokenString == "" {
@@ -292,7 +410,7 @@ func (h *AuthHandler) SetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.userController.SetNewPassword(tokenString, userNumber, payload.Password, payload.ConfirmPassword); err != nil {
+	if err := h.userController.WithContext(c).SetNewPassword(tokenString, userNumber, payload.Password, payload.ConfirmPassword); err != nil {
 		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
 		return
 	}
@@ -300,9 +418,21 @@ func (h *AuthHandler) SetPassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Your account is now active. Log in to your account"})
 }
 
+func (h *AuthHandler) GetPasswordPolicy(c *gin.Context) {
+	policy := h.userController.PasswordPolicy()
+	c.JSON(http.StatusOK, gin.H{
+		"min_length":           policy.MinLength,
+		"max_length":           policy.MaxLength,
+		"min_score":            policy.MinScore,
+		"history_size":         policy.HistorySize,
+		"breach_check_enabled": policy.BreachCheckEnabled(),
+	})
+}
+
 func (h *AuthHandler) LogoutUser(c *gin.Context) {
-	refreshToken, _ := c.Cookie("refresh_token")
-	if err := h.userController.InvalidateSession(refreshToken); err != nil {
+	session, _ := c.Get("session")
+	currentSession, _ := session.(*models.Session)
+	if err := h.userController.InvalidateSession(currentSession); err != nil {
 		c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Failed to invalidate session"))
 		return
 	}
@@ -372,12 +502,14 @@ func (h *AuthHandler) RegisterUser(c *gin.Context) {
 		return
 	}
 
+	// Everyone signs up as a plain user; elevated roles are only granted
+	// through role assignments.
 	user := models.User{
 		Number:    commons.UUIDGenerator(),
 		FirstName: payload.FirstName,
 		LastName:  payload.LastName,
 		Email:     strings.ToLower(payload.Email),
-		Role:      models.UserRole(map[bool]string{true: constants.RoleAdmin, false: "USER"}[payload.Role == constants.RoleAdmin]),
+		Role:      models.RoleUser,
 		Status:    "INACTIVE",
 	}
 
@@ -387,7 +519,7 @@ func (h *AuthHandler) RegisterUser(c *gin.Context) {
 		return
 	}
 
-	tx := h.db.DB.Begin()
+	tx := h.db.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -441,8 +573,9 @@ func (h *AuthHandler) RegisterUser(c *gin.Context) {
 		Type:        "registration",
 	}
 
-	if err := emailService.SendEmail(); err != nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewInternalError("Failed to send email"))
+	if err := emailService.QueueEmail(tx); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to queue email"))
 		return
 	}
 
@@ -472,6 +605,12 @@ func (h *AuthHandler) TokenAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if tokenType, ok := claims["type"].(string); !ok || tokenType != "access" {
+			c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Invalid token type"))
+			c.Abort()
+			return
+		}
+
 		userNumber, ok := claims["user_number"].(string)
 		if !ok {
 			c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Invalid token claims"))
@@ -479,6 +618,20 @@ func (h *AuthHandler) TokenAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		tokenUUID, ok := claims["token_uuid"].(string)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Invalid token claims"))
+			c.Abort()
+			return
+		}
+
+		session, err := h.sessions.FindActiveByAccessUUID(tokenUUID)
+		if err != nil || session.UserNumber != userNumber {
+			c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Session has been revoked or has expired"))
+			c.Abort()
+			return
+		}
+
 		var user models.User
 		if err := h.db.DB.Where("number = ?", userNumber).First(&user).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -491,7 +644,40 @@ func (h *AuthHandler) TokenAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if user.IsSuspended() {
+			c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Account has been suspended"))
+			c.Abort()
+			return
+		}
+
+		if user.IsPendingDeletion() {
+			c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Account is scheduled for deletion"))
+			c.Abort()
+			return
+		}
+
+		// Accounts holding administrative permissions may only manage
+		// themselves until they have enrolled a second factor.
+		if !user.MFAEnabled() && !strings.HasPrefix(c.FullPath(), "/v1/auth/") {
+			required, err := h.authorizer.RequiresMFA(&user)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to check two-factor requirement"))
+				c.Abort()
+				return
+			}
+			if required {
+				c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Two-factor authentication must be enabled for this account"))
+				c.Abort()
+				return
+			}
+		}
+
+		if err := h.sessions.Touch(session); err != nil {
+			log.Printf("Failed to update session activity: %v", err)
+		}
+
 		c.Set("user", &user)
+		c.Set("session", session)
 		c.Next()
 	}
 }
@@ -523,27 +709,127 @@ func (h *AuthHandler) APIAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		var user models.User
-		if err := h.db.DB.Where("number = ?", apiKeyModel.UserNumber).First(&user).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusUnauthorized, customerrors.NewNotFoundError("User not found"))
+		// Organization keys belong to the team and keep working after the
+		// member who created them leaves, so only personal keys are tied to
+		// an active user.
+		if apiKeyModel.IsOrganizationKey() {
+			organization, err := h.organizations.FindOrganization(apiKeyModel.OrganizationNumber)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Invalid API key"))
+					c.Abort()
+					return
+				}
+				c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch organization"))
 				c.Abort()
 				return
 			}
-			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch user"))
-			c.Abort()
-			return
+
+			allowed, err := h.quotas.Allow(organization.Number, organization.MonthlyRequestQuota)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to check request quota"))
+				c.Abort()
+				return
+			}
+			if !allowed {
+				c.JSON(http.StatusTooManyRequests, customerrors.NewRateLimitError("Monthly request quota exceeded for this organization"))
+				c.Abort()
+				return
+			}
+
+			c.Set("organization", organization)
+		} else {
+			var user models.User
+			if err := h.db.DB.Where("number = ?", apiKeyModel.UserNumber).First(&user).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusUnauthorized, customerrors.NewNotFoundError("User not found"))
+					c.Abort()
+					return
+				}
+				c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch user"))
+				c.Abort()
+				return
+			}
+			if user.IsSuspended() {
+				c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Account has been suspended"))
+				c.Abort()
+				return
+			}
+			if user.IsPendingDeletion() {
+				c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Account is scheduled for deletion"))
+				c.Abort()
+				return
+			}
+			c.Set("user", &user)
 		}
 
-		updates := map[string]interface{}{
-			"last_used_at": time.Now(),
-			"usage_count":  gorm.Expr("usage_count + ?", 1),
+		c.Set("api_key", &apiKeyModel)
+
+		start := time.Now()
+		c.Next()
+
+		endpoint := c.FullPath()
+		if endpoint == "" {
+			endpoint = c.Request.URL.Path
 		}
 
-		h.db.DB.Model(&apiKeyModel).Updates(updates)
+		h.usageRecorder.Record(models.APIKeyUsage{
+			APIKeyNumber:       apiKeyModel.Number,
+			UserNumber:         apiKeyModel.UserNumber,
+			OrganizationNumber: apiKeyModel.OrganizationNumber,
+			Method:             c.Request.Method,
+			Endpoint:           endpoint,
+			StatusCode:         c.Writer.Status(),
+			LatencyMs:          time.Since(start).Milliseconds(),
+			BytesOut:           int64(max(c.Writer.Size(), 0)),
+			RequestedAt:        start.UTC(),
+		})
+	}
+}
 
-		c.Set("api_key", &apiKeyModel)
-		c.Set("user", &user)
+// SessionOrAPIKeyMiddleware accepts either a signed-in session or an API key
+// sent in x-api-key. Pair it with RequireAPIKeyScope to limit which keys get
+// through.
+func (h *AuthHandler) SessionOrAPIKeyMiddleware() gin.HandlerFunc {
+	session := h.TokenAuthMiddleware()
+	apiKey := h.APIAuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("x-api-key") != "" {
+			apiKey(c)
+			return
+		}
+		session(c)
+	}
+}
+
+// OptionalAPIKeyMiddleware lets anonymous requests through to the public
+// dataset reads. A request that does send x-api-key is checked, metered and
+// counted against its quota exactly as APIAuthMiddleware would.
+func (h *AuthHandler) OptionalAPIKeyMiddleware() gin.HandlerFunc {
+	apiKey := h.APIAuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("x-api-key") != "" {
+			apiKey(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAPIKeyScope rejects requests made with an API key that lacks
+// scope. Requests made with a session pass through.
+func (h *AuthHandler) RequireAPIKeyScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("api_key")
+		if !exists {
+			c.Next()
+			return
+		}
+		if !value.(*models.APIKey).HasScope(scope) {
+			c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("API key is missing the "+scope+" scope"))
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -570,12 +856,12 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 		LastName:  payload.LastName,
 	}
 
-	if err := h.db.DB.Model(&user).Where("number = ?", user.Number).Updates(&updateUser).Error; err != nil {
+	if err := h.db.DB.WithContext(c).Model(&user).Where("number = ?", user.Number).Updates(&updateUser).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to update profile"))
 		return
 	}
 
-	if err := h.db.DB.Model(&user).Updates(updateUser).Error; err != nil {
+	if err := h.db.DB.WithContext(c).Model(&user).Updates(updateUser).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to update profile"))
 		return
 	}
@@ -589,46 +875,88 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, profile)
 }
 
-func (h *AuthHandler) DeleteAccount(c *gin.Context) {
-	user, err := commons.GetUserFromHeader(c, h.db.DB)
-	if err != nil {
+func (h *AuthHandler) currentSession(c *gin.Context) (*models.User, *models.Session, bool) {
+	user, userExists := c.Get("user")
+	session, sessionExists := c.Get("session")
+	if !userExists || !sessionExists {
+		return nil, nil, false
+	}
+	return user.(*models.User), session.(*models.Session), true
+}
+
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	user, current, ok := h.currentSession(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
 		return
 	}
 
-	tx := h.db.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	sessions, err := h.sessions.ListActiveSessions(user.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch sessions"))
+		return
+	}
+
+	response := make([]models.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = models.SessionResponse{
+			ID:         session.FamilyNumber,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.FamilyNumber == current.FamilyNumber,
 		}
-	}()
+	}
 
-	if err := tx.Where("user_number = ?", user.Number).Delete(&models.PasswordReset{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete user data"))
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	user, current, ok := h.currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
 		return
 	}
 
-	if err := tx.Where("user_number = ?", user.Number).Delete(&models.APIKey{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete user data"))
+	sessionNumber := commons.Sanitize(c.Param("id"))
+	if sessionNumber == "" {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Session id is required"))
 		return
 	}
 
-	if err := tx.Delete(&user).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete account"))
+	if err := h.sessions.RevokeSession(user.Number, sessionNumber); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Session not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to revoke session"))
 		return
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete account"))
+	if sessionNumber == current.FamilyNumber {
+		h.clearAuthCookies(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	user, current, ok := h.currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
 		return
 	}
 
-	h.clearAuthCookies(c)
+	revoked, err := h.sessions.RevokeOtherSessions(user.Number, current.FamilyNumber)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to revoke sessions"))
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Account successfully deleted",
+		"message": "Logged out of all other sessions",
+		"revoked": revoked,
 	})
 }
@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+)
+
+type ChangeFeedHandler struct {
+	controller *controllers.ChangeFeedController
+}
+
+func NewChangeFeedHandler(db *database.Database) *ChangeFeedHandler {
+	return &ChangeFeedHandler{
+		controller: controllers.NewChangeFeedController(db),
+	}
+}
+
+func (h *ChangeFeedHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	r.GET("/changes", authHandler.APIAuthMiddleware(), h.changes)
+}
+
+// changes pages through unit changes after since, which is a sequence
+// number from a previous page (or a release), a date (YYYY-MM-DD) or an
+// RFC 3339 timestamp. Mirrors bootstrap from a release bundle, then poll
+// with since set to the release sequence and follow next from there.
+func (h *ChangeFeedHandler) changes(c *gin.Context) {
+	var since int64
+	if value := commons.Sanitize(c.Query("since")); value != "" {
+		sequence, err := h.parseSince(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("'since' must be a sequence number, a date or an RFC 3339 timestamp"))
+			return
+		}
+		since = sequence
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(controllers.DefaultChangeFeedLimit)))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Invalid limit parameter"))
+		return
+	}
+	limit = min(limit, controllers.MaxChangeFeedLimit)
+
+	changes, err := h.controller.Changes(since, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch changes"))
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+func (h *ChangeFeedHandler) parseSince(value string) (int64, error) {
+	if sequence, err := strconv.ParseInt(value, 10, 64); err == nil && sequence >= 0 {
+		return sequence, nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		if at, err = time.Parse("2006-01-02", value); err != nil {
+			return 0, err
+		}
+	}
+	return h.controller.SequenceAt(at)
+}
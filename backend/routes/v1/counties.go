@@ -5,38 +5,43 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"opendataug.org/commons"
-	"opendataug.org/commons/constants"
+	"opendataug.org/controllers"
 	"opendataug.org/database"
 	customerrors "opendataug.org/errors"
 	"opendataug.org/models"
+	"opendataug.org/services"
 )
 
 type CountyHandler struct {
-	db *database.Database
+	db      *database.Database
+	units   *controllers.UnitController
+	reports *controllers.ReportController
 }
 
 func NewCountyHandler(db *database.Database) *CountyHandler {
 	return &CountyHandler{
-		db: db,
+		db:      db,
+		units:   controllers.NewUnitController(),
+		reports: controllers.NewReportController(db),
 	}
 }
 
 func (h *CountyHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
 	counties := r.Group("/counties")
 	{
-		apiProtected := counties.Group("")
-		apiProtected.Use(authHandler.APIAuthMiddleware())
+		public := counties.Group("")
+		public.Use(authHandler.OptionalAPIKeyMiddleware())
 		{
-			counties.GET("", h.handleAllCounties)
-			counties.GET("/:id", h.handleGetCounty)
+			public.GET("", FromRelease(h.db, models.LevelCounty, releaseReadAll), h.handleAllCounties)
+			public.GET("/:id", FromRelease(h.db, models.LevelCounty, releaseReadByNumber), RedirectMerged(h.db, models.LevelCounty), h.handleGetCounty)
 		}
 
 		private := counties.Group("")
 		private.Use(authHandler.TokenAuthMiddleware())
 		{
-			counties.POST("", h.createCounty)
-			counties.PUT("/:id", h.updateCounty)
-			counties.DELETE("/:id", h.deleteCounty)
+			private.POST("", authHandler.RequirePermission(services.PermUnitsCreate, ScopeFromBody(models.LevelCounty)), h.createCounty)
+			private.PUT("/:id", authHandler.RequirePermission(services.PermUnitsUpdate, ScopeFromParam(models.LevelCounty), ScopeFromBody(models.LevelCounty)), h.updateCounty)
+			private.DELETE("/:id", authHandler.RequirePermission(services.PermUnitsDelete, ScopeFromParam(models.LevelCounty)), h.deleteCounty)
 		}
 	}
 }
@@ -68,38 +73,21 @@ func (h *CountyHandler) handleAllCounties(c *gin.Context) {
 }
 
 func (h *CountyHandler) createCounty(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("You are not authorized to create counties"))
-		return
-	}
-
 	var payload models.County
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
 		return
 	}
 
-	var existingCounty models.County
-	if err := h.db.DB.Where("name = ? AND district_number = ?", payload.Name, payload.DistrictNumber).First(&existingCounty).Error; err == nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("County with this name already exists in this district"))
+	if _, err := h.units.CreateUnit(h.db.DB.WithContext(c), models.LevelCounty, controllers.UnitInput{
+		Name:         payload.Name,
+		ParentNumber: payload.DistrictNumber,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to create county")
 		return
 	}
 
-	county := models.County{
-		Number:         commons.UUIDGenerator(),
-		Name:           payload.Name,
-		DistrictNumber: payload.DistrictNumber,
-	}
-
-	if err := h.db.DB.Create(&county).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to create county"))
-		return
-	}
-
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "County created successfully",
-	})
+	c.JSON(http.StatusCreated, gin.H{"message": "County created successfully"})
 }
 
 func (h *CountyHandler) handleGetCounty(c *gin.Context) {
@@ -116,82 +104,44 @@ func (h *CountyHandler) handleGetCounty(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, h.toCountyResponse(county))
-}
-
-func (h *CountyHandler) updateCounty(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("You are not authorized to update counties"))
+	response := h.toCountyResponse(county)
+	reports, err := h.reports.Counts(models.LevelCounty, county.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch report counts"))
 		return
 	}
+	response.Reports = reports
 
-	number := c.Param("id")
-	if number == "" {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Invalid county id"))
-		return
-	}
-	number = commons.Sanitize(number)
+	c.JSON(http.StatusOK, response)
+}
 
-	var county models.County
-	if err := h.db.DB.First(&county, "number = ?", number).Error; err != nil {
-		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("County not found"))
-		return
-	}
+func (h *CountyHandler) updateCounty(c *gin.Context) {
+	number := commons.Sanitize(c.Param("id"))
 
 	var payload models.County
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
-		return
-	}
-
-	var existingCounty models.County
-	if err := h.db.DB.Where("name = ? AND district_number = ? AND number != ?",
-		payload.Name, payload.DistrictNumber, number).First(&existingCounty).Error; err == nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("County with this name already exists in this district"))
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
 		return
 	}
 
-	county.Name = payload.Name
-	county.DistrictNumber = payload.DistrictNumber
-
-	if err := h.db.DB.Save(&county).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to save county"))
+	if err := h.units.UpdateUnit(h.db.DB.WithContext(c), models.LevelCounty, number, controllers.UnitInput{
+		Name:         payload.Name,
+		ParentNumber: payload.DistrictNumber,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to update county")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "County updated successfully",
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "County updated successfully"})
 }
 
 func (h *CountyHandler) deleteCounty(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("You are not authorized to delete counties"))
-		return
-	}
-
-	number := c.Param("id")
-	if number == "" {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Invalid county id"))
-		return
-	}
-
-	number = commons.Sanitize(number)
-
-	var county models.County
-	if err := h.db.DB.First(&county, "number = ?", number).Error; err != nil {
-		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("County not found"))
-		return
-	}
+	number := commons.Sanitize(c.Param("id"))
 
-	if err := h.db.DB.Delete(&county).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete county"))
+	if err := h.units.DeleteUnit(h.db.DB.WithContext(c), models.LevelCounty, number); err != nil {
+		writeUnitError(c, err, "Failed to delete county")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "County deleted successfully",
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "County deleted successfully"})
 }
@@ -0,0 +1,137 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/database"
+	"opendataug.org/database/dbtest"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+// datasetRouter registers the handlers for every level of the hierarchy.
+func datasetRouter(db *database.Database, auth *AuthHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	group := router.Group("/v1")
+
+	handlers := []interface {
+		RegisterRoutes(*gin.RouterGroup, *AuthHandler)
+	}{
+		NewRegionHandler(db),
+		NewDistrictHandler(db),
+		NewCountyHandler(db),
+		NewSubcountyHandler(db),
+		NewParishHandler(db),
+		NewVillageHandler(db),
+	}
+	for _, handler := range handlers {
+		handler.RegisterRoutes(group, auth)
+	}
+	return router
+}
+
+// The region list and detail have always needed an API key. Every other
+// read is public, and only metered when a key is sent.
+func TestDatasetReadAccess(t *testing.T) {
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &database.Database{DB: gormDB}
+	router := datasetRouter(db, NewAuthHandler(db, nil, nil))
+
+	keyed := map[string]bool{
+		"/v1/regions":     true,
+		"/v1/regions/:id": true,
+	}
+
+	public := 0
+	for _, route := range router.Routes() {
+		if route.Method != http.MethodGet {
+			continue
+		}
+
+		path := route.Path
+		for _, param := range []string{":id", ":name"} {
+			path = strings.ReplaceAll(path, param, "x")
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+		rejected := recorder.Code == http.StatusUnauthorized && strings.Contains(recorder.Body.String(), "No API Key provided")
+		if keyed[route.Path] != rejected {
+			t.Errorf("GET %s without a key: %d %s", route.Path, recorder.Code, recorder.Body.String())
+		}
+		if !keyed[route.Path] {
+			public++
+		}
+	}
+	if public == 0 {
+		t.Fatal("no public dataset reads registered")
+	}
+}
+
+func TestDatasetReadsCountAgainstOrganizationQuota(t *testing.T) {
+	db := dbtest.Open(t)
+
+	organization := models.Organization{Number: commons.UUIDGenerator(), Name: "Test", MonthlyRequestQuota: 1}
+	if err := db.DB.Create(&organization).Error; err != nil {
+		t.Fatal(err)
+	}
+	key := models.APIKey{
+		Number:             commons.UUIDGenerator(),
+		UserNumber:         commons.UUIDGenerator(),
+		OrganizationNumber: organization.Number,
+		Name:               "test",
+		Key:                commons.UUIDGenerator(),
+		IsActive:           true,
+	}
+	if err := db.DB.Create(&key).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	usage := services.NewUsageRecorder(db.DB)
+	defer usage.Stop()
+	router := datasetRouter(db, NewAuthHandler(db, usage, services.NewQuotaTracker(db.DB)))
+
+	// Anonymous reads in between neither use up the quota nor show up in
+	// the key's usage.
+	for _, tt := range []struct {
+		key  string
+		want int
+	}{
+		{key.Key, http.StatusOK},
+		{"", http.StatusOK},
+		{"", http.StatusOK},
+		{key.Key, http.StatusTooManyRequests},
+		{"not-a-key", http.StatusUnauthorized},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/v1/villages", nil)
+		if tt.key != "" {
+			request.Header.Set("x-api-key", tt.key)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != tt.want {
+			t.Fatalf("GET /v1/villages with key %q = %d %s, want %d", tt.key, recorder.Code, recorder.Body.String(), tt.want)
+		}
+	}
+
+	usage.Stop()
+	var recorded int64
+	if err := db.DB.Model(&models.APIKeyUsage{}).Count(&recorded).Error; err != nil {
+		t.Fatal(err)
+	}
+	if recorded != 1 {
+		t.Errorf("recorded %d reads, want only the one made with the key", recorded)
+	}
+}
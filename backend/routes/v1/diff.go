@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+)
+
+type DiffHandler struct {
+	controller *controllers.DiffController
+}
+
+func NewDiffHandler(db *database.Database) *DiffHandler {
+	return &DiffHandler{
+		controller: controllers.NewDiffController(db),
+	}
+}
+
+func (h *DiffHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	r.GET("/diff", authHandler.APIAuthMiddleware(), h.diff)
+}
+
+// diff compares two points, each a release name, a date (YYYY-MM-DD or
+// RFC 3339) or "live". to defaults to live.
+func (h *DiffHandler) diff(c *gin.Context) {
+	from := commons.Sanitize(c.Query("from"))
+	if from == "" {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("'from' is required"))
+		return
+	}
+	to := commons.Sanitize(c.Query("to"))
+
+	format := strings.ToLower(c.DefaultQuery("format", "json"))
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Format must be json or csv"))
+		return
+	}
+
+	levels := models.LevelOrder
+	if requested := listQuery(c, "level"); len(requested) > 0 {
+		wanted := map[string]bool{}
+		for _, level := range requested {
+			if _, ok := models.Levels[level]; !ok {
+				c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Unknown level '"+level+"'"))
+				return
+			}
+			wanted[level] = true
+		}
+		levels = nil
+		for _, level := range models.LevelOrder {
+			if wanted[level] {
+				levels = append(levels, level)
+			}
+		}
+	}
+
+	diff, err := h.controller.Diff(from, to, levels)
+	if err != nil {
+		if errors.Is(err, controllers.ErrDiffPointInvalid) {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("'from' and 'to' "+err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to compute diff"))
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, diff)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "opendataug-diff.csv"))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"change", "level", "number", "name", "old_name", "parent_number", "old_parent_number"})
+	for _, change := range diff.Changes() {
+		_ = w.Write([]string{change.Change, change.Level, change.Number, change.Name, change.OldName, change.ParentNumber, change.OldParentNumber})
+	}
+	w.Flush()
+}
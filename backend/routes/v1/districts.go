@@ -8,76 +8,64 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"opendataug.org/commons"
-	"opendataug.org/commons/constants"
+	"opendataug.org/controllers"
 	"opendataug.org/database"
 	customerrors "opendataug.org/errors"
 	"opendataug.org/models"
+	"opendataug.org/services"
 )
 
 type DistrictHandler struct {
-	db *database.Database
+	db      *database.Database
+	units   *controllers.UnitController
+	reports *controllers.ReportController
 }
 
 func NewDistrictHandler(db *database.Database) *DistrictHandler {
 	return &DistrictHandler{
-		db: db,
+		db:      db,
+		units:   controllers.NewUnitController(),
+		reports: controllers.NewReportController(db),
 	}
 }
 
 func (h *DistrictHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
 	districts := r.Group("/districts")
 	{
-		apiProtected := districts.Group("")
-		apiProtected.Use(authHandler.APIAuthMiddleware())
+		public := districts.Group("")
+		public.Use(authHandler.OptionalAPIKeyMiddleware())
 		{
-			districts.GET("", h.handleAllDistricts)
-			districts.GET("/:id", h.handleDistrictByNumber)
-			districts.GET("/name/:name", h.handleDistrictByName)
+			public.GET("", FromRelease(h.db, models.LevelDistrict, releaseReadAll), h.handleAllDistricts)
+			public.GET("/:id", FromRelease(h.db, models.LevelDistrict, releaseReadByNumber), RedirectMerged(h.db, models.LevelDistrict), h.handleDistrictByNumber)
+			public.GET("/name/:name", FromRelease(h.db, models.LevelDistrict, releaseReadByName), h.handleDistrictByName)
 		}
 
 		private := districts.Group("")
 		private.Use(authHandler.TokenAuthMiddleware())
 		{
-			districts.POST("", h.createDistrict)
-			districts.DELETE("/:id", h.deleteDistrict)
+			private.POST("", authHandler.RequirePermission(services.PermUnitsCreate, ScopeFromBody(models.LevelDistrict)), h.createDistrict)
+			private.DELETE("/:id", authHandler.RequirePermission(services.PermUnitsDelete, ScopeFromParam(models.LevelDistrict)), h.deleteDistrict)
 		}
 	}
 }
 
 func (h *DistrictHandler) createDistrict(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
-
 	var payload models.District
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Invalid request payload"))
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
 		return
 	}
 
-	district := models.District{
-		Number:       commons.UUIDGenerator(),
+	if _, err := h.units.CreateUnit(h.db.DB.WithContext(c), models.LevelDistrict, controllers.UnitInput{
 		Name:         payload.Name,
-		RegionNumber: payload.RegionNumber,
+		ParentNumber: payload.RegionNumber,
 		TownStatus:   payload.TownStatus,
-	}
-
-	var region models.Region
-	if err := h.db.DB.First(&region, "number = ?", payload.RegionNumber).Error; err != nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Invalid region number"))
-		return
-	}
-
-	if err := h.db.DB.Create(&district).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to create district"))
+	}); err != nil {
+		writeUnitError(c, err, "Failed to create district")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "District created successfully",
-	})
+	c.JSON(http.StatusCreated, gin.H{"message": "District created successfully"})
 }
 
 func (h *DistrictHandler) handleAllDistricts(c *gin.Context) {
@@ -134,6 +122,13 @@ func (h *DistrictHandler) handleDistrictByNumber(c *gin.Context) {
 		RegionName: district.Region.Name,
 	}
 
+	reports, err := h.reports.Counts(models.LevelDistrict, district.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch report counts"))
+		return
+	}
+	response.Reports = reports
+
 	c.JSON(http.StatusOK, response)
 }
 
@@ -161,31 +156,21 @@ func (h *DistrictHandler) handleDistrictByName(c *gin.Context) {
 		RegionName: district.Region.Name,
 	}
 
-	c.JSON(http.StatusOK, response)
-}
-
-func (h *DistrictHandler) deleteDistrict(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
+	reports, err := h.reports.Counts(models.LevelDistrict, district.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch report counts"))
 		return
 	}
+	response.Reports = reports
 
-	districtNumber := c.Param("id")
-	if districtNumber == "" {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("District number is required"))
-		return
-	}
-	districtNumber = commons.Sanitize(districtNumber)
+	c.JSON(http.StatusOK, response)
+}
 
-	var district models.District
-	if err := h.db.DB.Where("number = ?", districtNumber).First(&district).Error; err != nil {
-		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("District not found"))
-		return
-	}
+func (h *DistrictHandler) deleteDistrict(c *gin.Context) {
+	number := commons.Sanitize(c.Param("id"))
 
-	if err := h.db.DB.Delete(&district).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete district"))
+	if err := h.units.DeleteUnit(h.db.DB.WithContext(c), models.LevelDistrict, number); err != nil {
+		writeUnitError(c, err, "Failed to delete district")
 		return
 	}
 
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+type DuplicateHandler struct {
+	controller *controllers.DuplicateController
+}
+
+func NewDuplicateHandler(db *database.Database) *DuplicateHandler {
+	return &DuplicateHandler{
+		controller: controllers.NewDuplicateController(db),
+	}
+}
+
+func (h *DuplicateHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	duplicates := r.Group("/admin/duplicates")
+	duplicates.Use(authHandler.TokenAuthMiddleware())
+	{
+		duplicates.GET("", authHandler.RequirePermission(services.PermChangesReview), h.listCandidates)
+		duplicates.POST("/dismiss", authHandler.RequirePermission(services.PermChangesReview), h.dismissCandidate)
+		duplicates.POST("/merge", authHandler.RequirePermission(services.PermUnitsDelete), h.mergeUnits)
+	}
+}
+
+func (h *DuplicateHandler) listCandidates(c *gin.Context) {
+	level := strings.ToLower(strings.TrimSpace(c.Query("level")))
+	if _, ok := models.Levels[level]; !ok {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("A valid 'level' is required"))
+		return
+	}
+
+	minScore := services.DefaultDuplicateScore
+	if value := c.Query("min_score"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("'min_score' must be between 0 and 1"))
+			return
+		}
+		minScore = parsed
+	}
+
+	pagination := commons.GetPaginationParams(c)
+	pagination.Limit = min(pagination.Limit, maxAdminPageSize)
+
+	candidates, err := h.controller.Candidates(level, commons.Sanitize(c.Query("parent")), minScore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to find duplicates"))
+		return
+	}
+
+	start := min((pagination.Page-1)*pagination.Limit, len(candidates))
+	end := min(start+pagination.Limit, len(candidates))
+
+	c.JSON(http.StatusOK, services.DuplicateCandidateListResponse{
+		Data:  candidates[start:end],
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: int64(len(candidates)),
+	})
+}
+
+func (h *DuplicateHandler) dismissCandidate(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.DismissDuplicateInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	if err := h.controller.WithContext(c).Dismiss(&payload, user.Number); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to dismiss candidate"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Candidate dismissed"})
+}
+
+func (h *DuplicateHandler) mergeUnits(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.MergeUnitsInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	result, err := h.controller.WithContext(c).Merge(&payload, user.Number)
+	if err != nil {
+		writeUnitError(c, err, "Failed to merge units")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
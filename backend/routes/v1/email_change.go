@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/controllers"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+// RequestEmailChange emails a confirmation link to the new address and
+// warns the current one. The email on the account is unchanged until the
+// link is followed.
+func (h *AuthHandler) RequestEmailChange(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.EmailChangeInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	var session *models.Session
+	if value, ok := c.Get("session"); ok {
+		session = value.(*models.Session)
+	}
+
+	change, token, err := h.emailChanges.WithContext(c).RequestChange(user, session, payload.Email, payload.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, controllers.ErrEmailChangePassword), errors.Is(err, controllers.ErrEmailChangeReauth):
+			c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError(err.Error()))
+		case errors.Is(err, controllers.ErrEmailUnchanged), errors.Is(err, controllers.ErrEmailTaken):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to start email change"))
+		}
+		return
+	}
+
+	confirmation := services.Info{
+		Email:       change.NewEmail,
+		Token:       token,
+		MailType:    "Confirm your new email - Open Data Uganda",
+		UserName:    user.FirstName,
+		CurrentYear: time.Now().Year(),
+		Type:        services.EmailTypeEmailChange,
+	}
+	if err := confirmation.SendEmail(); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to send confirmation email"))
+		return
+	}
+
+	notice := services.Info{
+		Email:       change.OldEmail,
+		MailType:    "Email change requested - Open Data Uganda",
+		UserName:    user.FirstName,
+		NewEmail:    change.NewEmail,
+		CurrentYear: time.Now().Year(),
+		Type:        services.EmailTypeEmailChanging,
+	}
+	if err := notice.SendEmail(); err != nil {
+		log.Printf("Failed to send email change notice to %s: %v", change.OldEmail, err)
+	}
+
+	c.JSON(http.StatusAccepted, models.EmailChangeResponse{
+		Message:   "Confirmation link sent to your new email address",
+		NewEmail:  change.NewEmail,
+		ExpiresAt: change.ExpiresAt,
+	})
+}
+
+// ConfirmEmailChange completes a change from the emailed link. It is public
+// because the link is often opened on another device; every session of the
+// account is revoked, so the user signs in again with the new address.
+func (h *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	var payload models.EmailChangeConfirmInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+
+	if err := h.emailChanges.WithContext(c).ConfirmChange(payload.Token); err != nil {
+		switch {
+		case errors.Is(err, controllers.ErrEmailChangeTokenInvalid), errors.Is(err, controllers.ErrEmailTaken):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to change email"))
+		}
+		return
+	}
+
+	h.clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed. Sign in with your new email address."})
+}
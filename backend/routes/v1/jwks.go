@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+)
+
+type JWKSHandler struct {
+	keys *commons.KeyManager
+}
+
+func NewJWKSHandler(keys *commons.KeyManager) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+func (h *JWKSHandler) RegisterRoutes(r gin.IRoutes) {
+	r.GET("/.well-known/jwks.json", h.handleJWKS)
+}
+
+func (h *JWKSHandler) handleJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
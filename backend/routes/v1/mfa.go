@@ -0,0 +1,300 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+// writeThrottled answers a LoginGuard.Check error, setting Retry-After when
+// the caller has to wait.
+func writeThrottled(c *gin.Context, err error) {
+	var throttled *services.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, customerrors.NewRateLimitError(throttled.Error()))
+		return
+	}
+	c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to check sign-in attempts"))
+}
+
+// issueMFAChallenge ends the password step of a sign-in for accounts with
+// two-factor authentication. No session exists until /auth/mfa/verify.
+func (h *AuthHandler) issueMFAChallenge(c *gin.Context, user *models.User) {
+	token, expiresAt, err := h.jwtService.CreateMFAChallenge(user.Number, string(user.Role))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to create two-factor challenge"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFAChallengeResponse{
+		MFARequired:    true,
+		ChallengeToken: token,
+		ExpiresIn:      expiresAt,
+	})
+}
+
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var payload models.MFAVerifyInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	claims, err := h.jwtService.ValidateToken(payload.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Invalid or expired challenge token"))
+		return
+	}
+	if tokenType, ok := claims["type"].(string); !ok || tokenType != "mfa_challenge" {
+		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Invalid token type"))
+		return
+	}
+	userNumber, ok := claims["user_number"].(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Invalid token claims"))
+		return
+	}
+
+	user, err := h.userController.FindByNumber(userNumber)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Invalid or expired challenge token"))
+		return
+	}
+	if user.IsSuspended() {
+		c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Account has been suspended"))
+		return
+	}
+
+	ip := c.ClientIP()
+	if err := h.loginGuard.Check(user.Email, ip); err != nil {
+		writeThrottled(c, err)
+		return
+	}
+
+	if err := h.mfa.WithContext(c).Verify(user, payload.Code, payload.RecoveryCode); err != nil {
+		switch {
+		case errors.Is(err, controllers.ErrMFAInvalidCode):
+			h.recordLoginFailure(user.Email, ip)
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Invalid two-factor code"))
+		case errors.Is(err, controllers.ErrMFANotEnabled):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to verify two-factor code"))
+		}
+		return
+	}
+
+	if err := h.loginGuard.RecordSuccess(user.Email); err != nil {
+		log.Printf("Failed to reset sign-in attempts for %s: %v", user.Email, err)
+	}
+
+	response, tokens, err := h.userController.CreateLoginSession(user, c.Request.UserAgent(), ip)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	h.setAuthCookies(c, tokens)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AuthHandler) MFAStatus(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	remaining, err := h.mfa.RemainingRecoveryCodes(user.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch two-factor status"))
+		return
+	}
+	required, err := h.authorizer.RequiresMFA(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch two-factor status"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFAStatusResponse{
+		Enabled:                user.MFAEnabled(),
+		EnabledAt:              user.MFAEnabledAt,
+		Required:               required,
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	enrollment, err := h.mfa.WithContext(c).BeginEnrollment(user)
+	if err != nil {
+		if errors.Is(err, controllers.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to start two-factor enrollment"))
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func (h *AuthHandler) ConfirmMFA(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.MFACodeInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	codes, err := h.mfa.WithContext(c).ConfirmEnrollment(user, payload.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, controllers.ErrMFAAlreadyEnabled), errors.Is(err, controllers.ErrMFANotEnrolling):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		case errors.Is(err, controllers.ErrMFAInvalidCode):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Invalid two-factor code"))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to enable two-factor authentication"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.MFADisableInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	required, err := h.authorizer.RequiresMFA(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to check two-factor requirement"))
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Two-factor authentication cannot be disabled for this account"))
+		return
+	}
+	if !user.MFAEnabled() {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(controllers.ErrMFANotEnabled.Error()))
+		return
+	}
+
+	ip := c.ClientIP()
+	if err := h.loginGuard.Check(user.Email, ip); err != nil {
+		writeThrottled(c, err)
+		return
+	}
+
+	password, err := h.userController.GetPasswordByUserNumber(user.Number)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Invalid password"))
+		return
+	}
+	if match, _ := commons.ComparePassword(password.UserPassword, payload.Password); !match {
+		h.recordLoginFailure(user.Email, ip)
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Invalid password"))
+		return
+	}
+
+	mfa := h.mfa.WithContext(c)
+	if err := mfa.Verify(user, payload.Code, payload.RecoveryCode); err != nil {
+		if errors.Is(err, controllers.ErrMFAInvalidCode) {
+			h.recordLoginFailure(user.Email, ip)
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Invalid two-factor code"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to verify two-factor code"))
+		return
+	}
+
+	if err := mfa.Disable(user); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to disable two-factor authentication"))
+		return
+	}
+
+	h.sendMFADisabledNotice(user)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.MFACodeInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	mfa := h.mfa.WithContext(c)
+	if err := mfa.Verify(user, payload.Code, ""); err != nil {
+		switch {
+		case errors.Is(err, controllers.ErrMFAInvalidCode):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Invalid two-factor code"))
+		case errors.Is(err, controllers.ErrMFANotEnabled):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to verify two-factor code"))
+		}
+		return
+	}
+
+	codes, err := mfa.RegenerateRecoveryCodes(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to generate recovery codes"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// sendMFADisabledNotice tells the owner that two-factor sign-in was turned
+// off, whether by them or by an administrator.
+func (h *AuthHandler) sendMFADisabledNotice(user *models.User) {
+	emailService := services.Info{
+		Email:       user.Email,
+		MailType:    "Two-factor authentication disabled - Open Data Uganda",
+		UserName:    user.FirstName,
+		CurrentYear: time.Now().Year(),
+		Type:        services.EmailTypeMFADisabled,
+	}
+
+	if err := emailService.SendEmail(); err != nil {
+		log.Printf("Failed to send two-factor notice to %s: %v", user.Email, err)
+	}
+}
@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/controllers"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+// Frontend pages the social sign-in callback sends the browser to.
+const (
+	oidcErrorPath = "/login"
+	oidcMFAPath   = "/login/mfa"
+)
+
+func (h *AuthHandler) ListOIDCProviders(c *gin.Context) {
+	providers := make([]models.OIDCProviderResponse, 0)
+	for _, provider := range h.oidc.Providers() {
+		providers = append(providers, models.OIDCProviderResponse{
+			Name:        provider.Name(),
+			DisplayName: provider.DisplayName(),
+			LoginURL:    "/v1/auth/oidc/" + provider.Name() + "/login",
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": providers})
+}
+
+// safeRedirectPath only allows paths on the frontend itself, so the login
+// flow cannot be used as an open redirect.
+func safeRedirectPath(path string) string {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, `\`) {
+		return "/"
+	}
+	return path
+}
+
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	provider, ok := h.oidc.Get(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Sign-in provider not found"))
+		return
+	}
+
+	state, err := h.oidcLogins.CreateState(provider.Name(), safeRedirectPath(c.Query("redirect_to")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to start sign-in"))
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c, h.oidc.RedirectURI(provider.Name()), state.State, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		log.Printf("Failed to build %s sign-in URL: %v", provider.Name(), err)
+		c.JSON(http.StatusBadGateway, customerrors.NewInternalError("Sign-in provider is unavailable"))
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback finishes a social sign-in. It always redirects back to the
+// frontend: to the original page with session cookies set, to the
+// two-factor page with a challenge token, or to the login page with an
+// error code.
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	provider, ok := h.oidc.Get(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Sign-in provider not found"))
+		return
+	}
+
+	if c.Query("error") != "" {
+		h.oidcRedirectError(c, "provider_denied")
+		return
+	}
+
+	state, err := h.oidcLogins.ConsumeState(provider.Name(), c.Query("state"))
+	if err != nil {
+		if !errors.Is(err, controllers.ErrOIDCStateInvalid) {
+			log.Printf("Failed to load %s sign-in state: %v", provider.Name(), err)
+		}
+		h.oidcRedirectError(c, "invalid_state")
+		return
+	}
+
+	identity, err := provider.Authenticate(c, c.Query("code"), state.CodeVerifier, h.oidc.RedirectURI(provider.Name()), state.Nonce)
+	if err != nil {
+		log.Printf("Failed %s sign-in: %v", provider.Name(), err)
+		h.oidcRedirectError(c, "provider_error")
+		return
+	}
+
+	user, created, err := h.oidcLogins.WithContext(c).ResolveUser(identity)
+	if err != nil {
+		if errors.Is(err, services.ErrOIDCEmailUnverified) {
+			h.oidcRedirectError(c, "email_unverified")
+			return
+		}
+		log.Printf("Failed to resolve %s identity: %v", provider.Name(), err)
+		h.oidcRedirectError(c, "server_error")
+		return
+	}
+	if created {
+		log.Printf("Provisioned user %s from %s sign-in", user.Number, provider.Name())
+	}
+
+	if user.IsSuspended() {
+		h.oidcRedirectError(c, "account_suspended")
+		return
+	}
+	if user.IsPendingDeletion() {
+		h.oidcRedirectError(c, "account_pending_deletion")
+		return
+	}
+
+	if user.MFAEnabled() {
+		token, _, err := h.jwtService.CreateMFAChallenge(user.Number, string(user.Role))
+		if err != nil {
+			h.oidcRedirectError(c, "server_error")
+			return
+		}
+		// The fragment keeps the challenge out of server logs.
+		c.Redirect(http.StatusFound, os.Getenv("FRONTEND_URL")+oidcMFAPath+"#challenge_token="+url.QueryEscape(token))
+		return
+	}
+
+	_, tokens, err := h.userController.CreateLoginSession(user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		h.oidcRedirectError(c, "server_error")
+		return
+	}
+
+	h.setAuthCookies(c, tokens)
+	c.Redirect(http.StatusFound, os.Getenv("FRONTEND_URL")+state.RedirectTo)
+}
+
+func (h *AuthHandler) oidcRedirectError(c *gin.Context, code string) {
+	c.Redirect(http.StatusFound, os.Getenv("FRONTEND_URL")+oidcErrorPath+"?error="+url.QueryEscape(code))
+}
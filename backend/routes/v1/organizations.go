@@ -0,0 +1,556 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/badoux/checkmail"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+type OrganizationHandler struct {
+	controller     *controllers.OrganizationController
+	apiKeys        *controllers.APIKeyController
+	userController *controllers.UserController
+	quotas         *services.QuotaTracker
+}
+
+func NewOrganizationHandler(db *database.Database, quotas *services.QuotaTracker) *OrganizationHandler {
+	return &OrganizationHandler{
+		controller:     controllers.NewOrganizationController(db),
+		apiKeys:        controllers.NewAPIKeyController(db),
+		userController: controllers.NewUserController(db, services.NewJWTService()),
+		quotas:         quotas,
+	}
+}
+
+func (h *OrganizationHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	organizations := r.Group("/organizations")
+	organizations.Use(authHandler.TokenAuthMiddleware())
+	{
+		organizations.GET("", h.listOrganizations)
+		organizations.POST("", h.createOrganization)
+		organizations.GET("/:id", h.requireMembership(false), h.getOrganization)
+		organizations.PATCH("/:id", h.requireMembership(true), h.updateOrganization)
+		organizations.DELETE("/:id", h.requireMembership(true), h.deleteOrganization)
+		organizations.PUT("/:id/quota", authHandler.RequirePermission(services.PermOrgsManage), h.setQuota)
+		organizations.POST("/:id/transfer", h.requireMembership(true), h.transferOwnership)
+
+		organizations.GET("/:id/members", h.requireMembership(false), h.listMembers)
+		organizations.PATCH("/:id/members/:user", h.requireMembership(true), h.updateMember)
+		organizations.DELETE("/:id/members/:user", h.requireMembership(false), h.removeMember)
+
+		organizations.GET("/:id/invitations", h.requireMembership(true), h.listInvitations)
+		organizations.POST("/:id/invitations", h.requireMembership(true), h.createInvitation)
+		organizations.DELETE("/:id/invitations/:invitation", h.requireMembership(true), h.revokeInvitation)
+
+		organizations.GET("/:id/api-keys", h.requireMembership(false), h.listAPIKeys)
+		organizations.POST("/:id/api-keys", h.requireMembership(true), h.createAPIKey)
+		organizations.DELETE("/:id/api-keys/:key", h.requireMembership(true), h.deleteAPIKey)
+	}
+
+	invitations := r.Group("/invitations")
+	invitations.Use(authHandler.TokenAuthMiddleware())
+	{
+		invitations.POST("/accept", h.acceptInvitation)
+	}
+}
+
+// requireMembership loads the organization named by :id and the current
+// user's membership in it. Non-members get a 404 so organization numbers
+// are not disclosed; manage additionally requires the OWNER or ADMIN role.
+func (h *OrganizationHandler) requireMembership(manage bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currentUser := c.MustGet("user").(*models.User)
+
+		organization, err := h.controller.FindOrganization(commons.Sanitize(c.Param("id")))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Organization not found"))
+				c.Abort()
+				return
+			}
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch organization"))
+			c.Abort()
+			return
+		}
+
+		membership, err := h.controller.FindMembership(organization.Number, currentUser.Number)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Organization not found"))
+				c.Abort()
+				return
+			}
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch membership"))
+			c.Abort()
+			return
+		}
+
+		if manage && !membership.CanManage() {
+			c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Only organization owners and admins can perform this action"))
+			c.Abort()
+			return
+		}
+
+		c.Set("organization", organization)
+		c.Set("membership", membership)
+		c.Next()
+	}
+}
+
+func currentMembership(c *gin.Context) (*models.Organization, *models.Membership) {
+	return c.MustGet("organization").(*models.Organization), c.MustGet("membership").(*models.Membership)
+}
+
+func toOrganizationResponse(organization *models.Organization, role models.OrganizationRole) models.OrganizationResponse {
+	return models.OrganizationResponse{
+		ID:                  organization.Number,
+		Name:                organization.Name,
+		Role:                role,
+		MonthlyRequestQuota: organization.MonthlyRequestQuota,
+		CreatedAt:           organization.CreatedAt,
+	}
+}
+
+func (h *OrganizationHandler) listOrganizations(c *gin.Context) {
+	currentUser := c.MustGet("user").(*models.User)
+
+	organizations, err := h.controller.ListForUser(currentUser.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch organizations"))
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+func (h *OrganizationHandler) createOrganization(c *gin.Context) {
+	var payload models.OrganizationInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	currentUser := c.MustGet("user").(*models.User)
+	organization, err := h.controller.CreateOrganization(payload.Name, currentUser.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to create organization"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toOrganizationResponse(organization, models.OrgRoleOwner))
+}
+
+func (h *OrganizationHandler) getOrganization(c *gin.Context) {
+	organization, membership := currentMembership(c)
+
+	usage, err := h.quotas.Usage(organization.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch organization usage"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization":        toOrganizationResponse(organization, membership.Role),
+		"requests_this_month": usage,
+	})
+}
+
+func (h *OrganizationHandler) updateOrganization(c *gin.Context) {
+	organization, membership := currentMembership(c)
+
+	var payload models.OrganizationInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	if err := h.controller.RenameOrganization(organization.Number, payload.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to update organization"))
+		return
+	}
+
+	organization.Name = payload.Name
+	c.JSON(http.StatusOK, toOrganizationResponse(organization, membership.Role))
+}
+
+func (h *OrganizationHandler) deleteOrganization(c *gin.Context) {
+	organization, membership := currentMembership(c)
+	if membership.Role != models.OrgRoleOwner {
+		c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Only the owner can delete the organization"))
+		return
+	}
+
+	if err := h.controller.WithContext(c).DeleteOrganization(organization.Number); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete organization"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+func (h *OrganizationHandler) setQuota(c *gin.Context) {
+	var payload struct {
+		MonthlyRequestQuota *int64 `json:"monthly_request_quota" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	if *payload.MonthlyRequestQuota < 0 {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Quota cannot be negative"))
+		return
+	}
+
+	if err := h.controller.SetQuota(commons.Sanitize(c.Param("id")), *payload.MonthlyRequestQuota); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Organization not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to update quota"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quota updated successfully"})
+}
+
+func (h *OrganizationHandler) transferOwnership(c *gin.Context) {
+	organization, membership := currentMembership(c)
+	if membership.Role != models.OrgRoleOwner {
+		c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Only the owner can transfer ownership"))
+		return
+	}
+
+	var payload struct {
+		UserNumber string `json:"user_number" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	newOwner := commons.Sanitize(payload.UserNumber)
+	if newOwner == membership.UserNumber {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("You already own this organization"))
+		return
+	}
+
+	if err := h.controller.TransferOwnership(organization.Number, membership.UserNumber, newOwner); err != nil {
+		if errors.Is(err, controllers.ErrNotMember) {
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Ownership can only be transferred to an existing member"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to transfer ownership"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transferred successfully"})
+}
+
+func (h *OrganizationHandler) listMembers(c *gin.Context) {
+	organization, _ := currentMembership(c)
+
+	members, err := h.controller.ListMembers(organization.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch members"))
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func (h *OrganizationHandler) updateMember(c *gin.Context) {
+	organization, _ := currentMembership(c)
+
+	var payload struct {
+		Role models.OrganizationRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	if payload.Role != models.OrgRoleAdmin && payload.Role != models.OrgRoleMember {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Role must be ADMIN or MEMBER"))
+		return
+	}
+
+	err := h.controller.UpdateMemberRole(organization.Number, commons.Sanitize(c.Param("user")), payload.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, controllers.ErrNotMember):
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Member not found"))
+		case errors.Is(err, controllers.ErrOwnerRoleImmutable):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to update member"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member updated successfully"})
+}
+
+// removeMember lets owners and admins remove others and any member leave.
+func (h *OrganizationHandler) removeMember(c *gin.Context) {
+	organization, membership := currentMembership(c)
+	userNumber := commons.Sanitize(c.Param("user"))
+
+	if userNumber != membership.UserNumber && !membership.CanManage() {
+		c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Only organization owners and admins can remove members"))
+		return
+	}
+
+	if err := h.controller.RemoveMember(organization.Number, userNumber); err != nil {
+		switch {
+		case errors.Is(err, controllers.ErrNotMember):
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Member not found"))
+		case errors.Is(err, controllers.ErrOwnerCannotLeave):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to remove member"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func toInvitationResponse(invitation *models.OrganizationInvitation) models.InvitationResponse {
+	return models.InvitationResponse{
+		ID:        invitation.Number,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		Status:    invitation.Status,
+		ExpiresAt: invitation.ExpiresAt,
+	}
+}
+
+func (h *OrganizationHandler) listInvitations(c *gin.Context) {
+	organization, _ := currentMembership(c)
+
+	invitations, err := h.controller.ListInvitations(organization.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch invitations"))
+		return
+	}
+
+	response := make([]models.InvitationResponse, len(invitations))
+	for i := range invitations {
+		response[i] = toInvitationResponse(&invitations[i])
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *OrganizationHandler) createInvitation(c *gin.Context) {
+	organization, membership := currentMembership(c)
+
+	var payload models.InvitationInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	if err := checkmail.ValidateFormat(payload.Email); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Invalid email address"))
+		return
+	}
+
+	if invitee, err := h.userController.FindByEmail(payload.Email); err == nil {
+		if _, err := h.controller.FindMembership(organization.Number, invitee.Number); err == nil {
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("User is already a member of this organization"))
+			return
+		}
+	}
+
+	invitation, token, err := h.controller.CreateInvitation(organization.Number, &payload, membership.UserNumber)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to create invitation"))
+		return
+	}
+
+	emailService := services.Info{
+		Email:            invitation.Email,
+		Token:            token,
+		MailType:         "You have been invited to " + organization.Name + " - Open Data Uganda",
+		OrganizationName: organization.Name,
+		CurrentYear:      time.Now().Year(),
+		Type:             services.EmailTypeInvitation,
+	}
+
+	if err := emailService.SendEmail(); err != nil {
+		log.Printf("Failed to send invitation email: %v", err)
+		if err := h.controller.RevokeInvitation(organization.Number, invitation.Number); err != nil {
+			log.Printf("Failed to revoke unsent invitation: %v", err)
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to send email"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toInvitationResponse(invitation))
+}
+
+func (h *OrganizationHandler) revokeInvitation(c *gin.Context) {
+	organization, _ := currentMembership(c)
+
+	if err := h.controller.RevokeInvitation(organization.Number, commons.Sanitize(c.Param("invitation"))); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Invitation not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to revoke invitation"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+func (h *OrganizationHandler) acceptInvitation(c *gin.Context) {
+	var payload struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	currentUser := c.MustGet("user").(*models.User)
+	organization, err := h.controller.AcceptInvitation(payload.Token, currentUser)
+	if err != nil {
+		switch {
+		case errors.Is(err, controllers.ErrInvitationInvalid):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		case errors.Is(err, controllers.ErrInvitationEmail):
+			c.JSON(http.StatusForbidden, customerrors.NewForbiddenError(err.Error()))
+		case errors.Is(err, controllers.ErrAlreadyMember):
+			c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to accept invitation"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Invitation accepted",
+		"organization": toOrganizationResponse(organization, ""),
+	})
+}
+
+func (h *OrganizationHandler) listAPIKeys(c *gin.Context) {
+	organization, _ := currentMembership(c)
+
+	keys, err := h.controller.ListAPIKeys(organization.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to fetch API keys"))
+		return
+	}
+
+	response := make([]models.APIKeyResponse, len(keys))
+	for i, key := range keys {
+		response[i] = models.APIKeyResponse{
+			ID:                 key.Number,
+			Name:               key.Name,
+			Key:                key.Key,
+			OrganizationNumber: key.OrganizationNumber,
+			Scopes:             key.ScopeList(),
+			CreatedBy:          key.UserNumber,
+			CreatedAt:          key.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *OrganizationHandler) createAPIKey(c *gin.Context) {
+	organization, membership := currentMembership(c)
+
+	var payload CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	scopes, err := models.NormalizeAPIKeyScopes(payload.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	exists, err := h.controller.APIKeyNameExists(organization.Number, payload.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to check API key name"))
+		return
+	}
+	if exists {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("API key with this name already exists"))
+		return
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to generate API key"))
+		return
+	}
+
+	apiKey := &models.APIKey{
+		Number:             commons.UUIDGenerator(),
+		UserNumber:         membership.UserNumber,
+		OrganizationNumber: organization.Number,
+		Name:               payload.Name,
+		Key:                key,
+		ExpiresAt:          payload.ExpiresAt,
+		Scopes:             scopes,
+	}
+
+	if err := h.apiKeys.WithContext(c).CreateAPIKey(apiKey); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to create API key"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "API Key created successfully", "key": apiKey.Key})
+}
+
+func (h *OrganizationHandler) deleteAPIKey(c *gin.Context) {
+	organization, _ := currentMembership(c)
+
+	if err := h.controller.WithContext(c).DeleteAPIKey(organization.Number, commons.Sanitize(c.Param("key"))); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("API key not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewInternalError("Failed to delete API key"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
@@ -7,77 +7,64 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 	"opendataug.org/commons"
-	"opendataug.org/commons/constants"
+	"opendataug.org/controllers"
 	"opendataug.org/database"
 	customerrors "opendataug.org/errors"
 	"opendataug.org/models"
+	"opendataug.org/services"
 )
 
 type ParishHandler struct {
-	db *database.Database
+	db      *database.Database
+	units   *controllers.UnitController
+	reports *controllers.ReportController
 }
 
 func NewParishHandler(db *database.Database) *ParishHandler {
 	return &ParishHandler{
-		db: db,
+		db:      db,
+		units:   controllers.NewUnitController(),
+		reports: controllers.NewReportController(db),
 	}
 }
 
 func (h *ParishHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
 	parishes := r.Group("/parishes")
 	{
-		apiProtected := parishes.Group("")
-		apiProtected.Use(authHandler.APIAuthMiddleware())
+		public := parishes.Group("")
+		public.Use(authHandler.OptionalAPIKeyMiddleware())
 		{
-			parishes.GET("", h.handleAllParishes)
-			parishes.GET("/:id", h.handleParish)
-			parishes.GET("/:id/villages", h.handleParishVillages)
+			public.GET("", FromRelease(h.db, models.LevelParish, releaseReadAll), h.handleAllParishes)
+			public.GET("/:id", FromRelease(h.db, models.LevelParish, releaseReadByNumber), RedirectMerged(h.db, models.LevelParish), h.handleParish)
+			public.GET("/:id/villages", FromRelease(h.db, models.LevelVillage, releaseReadByParent), RedirectMerged(h.db, models.LevelParish), h.handleParishVillages)
 		}
 
 		private := parishes.Group("")
 		private.Use(authHandler.TokenAuthMiddleware())
 		{
-			parishes.POST("", h.createParish)
-			parishes.PUT("/:id", h.handleUpdateParish)
-			parishes.DELETE("/:id", h.handleDeleteParish)
+			private.POST("", authHandler.RequirePermission(services.PermUnitsCreate, ScopeFromBody(models.LevelParish)), h.createParish)
+			private.PUT("/:id", authHandler.RequirePermission(services.PermUnitsUpdate, ScopeFromParam(models.LevelParish), ScopeFromBody(models.LevelParish)), h.handleUpdateParish)
+			private.DELETE("/:id", authHandler.RequirePermission(services.PermUnitsDelete, ScopeFromParam(models.LevelParish)), h.handleDeleteParish)
 		}
 	}
 }
 
 func (h *ParishHandler) createParish(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
-
 	var payload models.Parish
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse requestbody"))
-		return
-	}
-
-	var existingParish models.Parish
-	if err := h.db.DB.Where("name = ? AND sub_county_number = ?", payload.Name, payload.SubCountyNumber).
-		First(&existingParish).Error; err == nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Parish with this name already exists in this subcounty"))
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
 		return
 	}
 
-	parish := models.Parish{
-		Number:          commons.UUIDGenerator(),
-		SubCountyNumber: payload.SubCountyNumber,
-		Name:            payload.Name,
-	}
-
-	if err := h.db.DB.Create(&parish).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
+	if _, err := h.units.CreateUnit(h.db.DB.WithContext(c), models.LevelParish, controllers.UnitInput{
+		Name:         payload.Name,
+		ParentNumber: payload.SubCountyNumber,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to create parish")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Parish created successfully",
-	})
+	c.JSON(http.StatusCreated, gin.H{"message": "Parish created successfully"})
 }
 
 func (h *ParishHandler) handleAllParishes(c *gin.Context) {
@@ -116,17 +103,18 @@ func (h *ParishHandler) handleParish(c *gin.Context) {
 		ID:   parish.Number,
 	}
 
+	reports, err := h.reports.Counts(models.LevelParish, parish.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch report counts"))
+		return
+	}
+	response.Reports = reports
+
 	c.JSON(http.StatusOK, response)
 }
 
 func (h *ParishHandler) handleUpdateParish(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
-
-	parishNumber := c.Param("id")
+	number := commons.Sanitize(c.Param("id"))
 
 	var payload models.Parish
 	if err := c.ShouldBindJSON(&payload); err != nil {
@@ -134,55 +122,26 @@ func (h *ParishHandler) handleUpdateParish(c *gin.Context) {
 		return
 	}
 
-	var parish models.Parish
-	if err := h.db.DB.Where("number = ?", parishNumber).First(&parish).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Parish not found"))
-			return
-		}
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
+	if err := h.units.UpdateUnit(h.db.DB.WithContext(c), models.LevelParish, number, controllers.UnitInput{
+		Name:         payload.Name,
+		ParentNumber: payload.SubCountyNumber,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to update parish")
 		return
 	}
 
-	parish.Name = payload.Name
-
-	if err := h.db.DB.Save(&parish).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Parish updated successfully",
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "Parish updated successfully"})
 }
 
 func (h *ParishHandler) handleDeleteParish(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
-
-	parishNumber := c.Param("id")
+	number := commons.Sanitize(c.Param("id"))
 
-	var parish models.District
-	if err := h.db.DB.Where("number = ?", parishNumber).First(&parish).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Parish not found"))
-			return
-		}
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
-		return
-	}
-
-	if err := h.db.DB.Delete(&parish).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
+	if err := h.units.DeleteUnit(h.db.DB.WithContext(c), models.LevelParish, number); err != nil {
+		writeUnitError(c, err, "Failed to delete parish")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Parish deleted successfully",
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "Parish deleted successfully"})
 }
 
 func (h *ParishHandler) handleParishVillages(c *gin.Context) {
@@ -0,0 +1,279 @@
+package v1
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+type ProposalHandler struct {
+	controller     *controllers.ProposalController
+	userController *controllers.UserController
+	authorizer     *services.Authorizer
+}
+
+func NewProposalHandler(db *database.Database) *ProposalHandler {
+	return &ProposalHandler{
+		controller:     controllers.NewProposalController(db),
+		userController: controllers.NewUserController(db, services.NewJWTService()),
+		authorizer:     services.NewAuthorizer(db.DB),
+	}
+}
+
+func (h *ProposalHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	proposals := r.Group("/proposals")
+	{
+		proposals.POST("", authHandler.SessionOrAPIKeyMiddleware(), authHandler.RequireAPIKeyScope(models.APIKeyScopeProposalsWrite), h.submitProposal)
+
+		private := proposals.Group("")
+		private.Use(authHandler.TokenAuthMiddleware())
+		{
+			private.GET("", h.listProposals)
+			private.GET("/:id", h.getProposal)
+			private.POST("/:id/withdraw", h.withdrawProposal)
+			private.POST("/:id/approve", h.approveProposal)
+			private.POST("/:id/reject", h.rejectProposal)
+		}
+	}
+}
+
+func writeProposalError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, controllers.ErrProposalNotFound):
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Proposal not found"))
+	case errors.Is(err, controllers.ErrProposalNotPending):
+		c.JSON(http.StatusConflict, customerrors.NewBadRequestError(err.Error()))
+	case errors.Is(err, controllers.ErrProposalSelfReview), errors.Is(err, controllers.ErrProposalNotOwner):
+		c.JSON(http.StatusForbidden, customerrors.NewForbiddenError(err.Error()))
+	default:
+		writeUnitError(c, err, fallback)
+	}
+}
+
+func (h *ProposalHandler) submitProposal(c *gin.Context) {
+	var payload models.ChangeProposalInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	// Organization keys carry no user; the proposal is credited to the
+	// member who created the key.
+	var proposedBy, apiKeyNumber string
+	if value, ok := c.Get("api_key"); ok {
+		apiKey := value.(*models.APIKey)
+		proposedBy, apiKeyNumber = apiKey.UserNumber, apiKey.Number
+	}
+	if value, ok := c.Get("user"); ok {
+		proposedBy = value.(*models.User).Number
+	}
+
+	proposal, err := h.controller.WithContext(c).Submit(&payload, proposedBy, apiKeyNumber)
+	if err != nil {
+		writeProposalError(c, err, "Failed to submit proposal")
+		return
+	}
+
+	c.JSON(http.StatusCreated, proposal)
+}
+
+// isReviewer reports whether user may review proposals for any part of the
+// hierarchy. Reviewers see every proposal; other users only their own.
+func (h *ProposalHandler) isReviewer(c *gin.Context, user *models.User) (bool, bool) {
+	reviewer, err := h.authorizer.CanAnywhere(user, services.PermChangesReview)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to check permissions"))
+		return false, false
+	}
+	return reviewer, true
+}
+
+func (h *ProposalHandler) listProposals(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	pagination := commons.GetPaginationParams(c)
+	pagination.Limit = min(pagination.Limit, maxAdminPageSize)
+
+	filter := controllers.ProposalFilter{
+		Status: strings.ToUpper(strings.TrimSpace(c.Query("status"))),
+		Level:  strings.ToLower(strings.TrimSpace(c.Query("level"))),
+	}
+
+	reviewer, ok := h.isReviewer(c, user)
+	if !ok {
+		return
+	}
+	if !reviewer || c.Query("mine") == "true" {
+		filter.ProposedBy = user.Number
+	}
+
+	proposals, total, err := h.controller.List(filter, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch proposals"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ChangeProposalListResponse{
+		Data:  proposals,
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: total,
+	})
+}
+
+func (h *ProposalHandler) getProposal(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	proposal, err := h.controller.Find(commons.Sanitize(c.Param("id")))
+	if err != nil {
+		writeProposalError(c, err, "Failed to fetch proposal")
+		return
+	}
+
+	if proposal.ProposedBy != user.Number {
+		reviewer, ok := h.isReviewer(c, user)
+		if !ok {
+			return
+		}
+		if !reviewer {
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Proposal not found"))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, proposal)
+}
+
+func (h *ProposalHandler) withdrawProposal(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	proposal, err := h.controller.WithContext(c).Withdraw(commons.Sanitize(c.Param("id")), user.Number)
+	if err != nil {
+		writeProposalError(c, err, "Failed to withdraw proposal")
+		return
+	}
+
+	c.JSON(http.StatusOK, proposal)
+}
+
+// canReview checks PermChangesReview against the unit a proposal changes
+// and the parent it names, so scoped reviewers only see to their own area.
+// Units that no longer exist are skipped; approval will fail on them anyway.
+func (h *ProposalHandler) canReview(user *models.User, proposal *models.ChangeProposal) (bool, error) {
+	return h.authorizer.Can(user, services.PermChangesReview, func() ([][]models.UnitRef, error) {
+		var lineages [][]models.UnitRef
+
+		if proposal.UnitNumber != "" {
+			lineage, err := h.authorizer.Lineage(proposal.Level, proposal.UnitNumber)
+			if err != nil && !errors.Is(err, services.ErrUnitNotFound) {
+				return nil, err
+			}
+			if lineage != nil {
+				lineages = append(lineages, lineage)
+			}
+		}
+
+		if parentLevel := models.Levels[proposal.Level].ParentLevel; proposal.ParentNumber != "" && parentLevel != "" {
+			lineage, err := h.authorizer.Lineage(parentLevel, proposal.ParentNumber)
+			if err != nil && !errors.Is(err, services.ErrUnitNotFound) {
+				return nil, err
+			}
+			if lineage != nil {
+				lineages = append(lineages, lineage)
+			}
+		}
+
+		return lineages, nil
+	})
+}
+
+func (h *ProposalHandler) approveProposal(c *gin.Context) {
+	h.reviewProposal(c, models.ProposalStatusApproved)
+}
+
+func (h *ProposalHandler) rejectProposal(c *gin.Context) {
+	h.reviewProposal(c, models.ProposalStatusRejected)
+}
+
+func (h *ProposalHandler) reviewProposal(c *gin.Context, status string) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.ChangeProposalReviewInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Comment = strings.TrimSpace(payload.Comment)
+	if status == models.ProposalStatusRejected && payload.Comment == "" {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("A comment explaining the rejection is required"))
+		return
+	}
+
+	proposal, err := h.controller.Find(commons.Sanitize(c.Param("id")))
+	if err != nil {
+		writeProposalError(c, err, "Failed to fetch proposal")
+		return
+	}
+
+	allowed, err := h.canReview(user, proposal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to check permissions"))
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("You do not have permission to perform this action"))
+		return
+	}
+
+	controller := h.controller.WithContext(c)
+	if status == models.ProposalStatusApproved {
+		proposal, err = controller.Approve(proposal.Number, user, payload.Comment)
+	} else {
+		proposal, err = controller.Reject(proposal.Number, user, payload.Comment)
+	}
+	if err != nil {
+		writeProposalError(c, err, "Failed to review proposal")
+		return
+	}
+
+	h.notifyProposer(proposal)
+	c.JSON(http.StatusOK, proposal)
+}
+
+// notifyProposer emails the author the outcome of their proposal.
+func (h *ProposalHandler) notifyProposer(proposal *models.ChangeProposal) {
+	author, err := h.userController.FindByNumber(proposal.ProposedBy)
+	if err != nil {
+		return
+	}
+
+	emailService := services.Info{
+		Email:           author.Email,
+		MailType:        "Your change proposal was " + strings.ToLower(proposal.Status) + " - Open Data Uganda",
+		UserName:        author.FirstName,
+		ProposalSummary: proposal.Summary(),
+		ProposalStatus:  strings.ToLower(proposal.Status),
+		ReviewComment:   proposal.ReviewComment,
+		CurrentYear:     time.Now().Year(),
+		Type:            services.EmailTypeProposalReview,
+	}
+
+	if err := emailService.SendEmail(); err != nil {
+		log.Printf("Failed to send proposal notice to %s: %v", author.Email, err)
+	}
+}
@@ -0,0 +1,63 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/services"
+)
+
+type QualityHandler struct {
+	validator *services.QualityValidator
+}
+
+func NewQualityHandler(db *database.Database) *QualityHandler {
+	return &QualityHandler{
+		validator: services.NewQualityValidator(db.DB),
+	}
+}
+
+func (h *QualityHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	quality := r.Group("/admin/quality")
+	quality.Use(authHandler.TokenAuthMiddleware(), authHandler.RequirePermission(services.PermChangesReview))
+	{
+		quality.GET("", h.qualityReport)
+		quality.GET("/rules", h.qualityRules)
+	}
+}
+
+// listQuery splits a comma-separated query parameter, dropping empty items.
+func listQuery(c *gin.Context, name string) []string {
+	var values []string
+	for _, value := range strings.Split(c.Query(name), ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (h *QualityHandler) qualityReport(c *gin.Context) {
+	report, err := h.validator.Validate(services.QualityOptions{
+		Levels: listQuery(c, "level"),
+		Rules:  listQuery(c, "rule"),
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownQualityOption) {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to validate data"))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *QualityHandler) qualityRules(c *gin.Context) {
+	c.JSON(http.StatusOK, services.QualityRules())
+}
@@ -5,19 +5,24 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"opendataug.org/commons"
-	"opendataug.org/commons/constants"
+	"opendataug.org/controllers"
 	"opendataug.org/database"
 	customerrors "opendataug.org/errors"
 	"opendataug.org/models"
+	"opendataug.org/services"
 )
 
 type RegionHandler struct {
-	db *database.Database
+	db      *database.Database
+	units   *controllers.UnitController
+	reports *controllers.ReportController
 }
 
 func NewRegionHandler(db *database.Database) *RegionHandler {
 	return &RegionHandler{
-		db: db,
+		db:      db,
+		units:   controllers.NewUnitController(),
+		reports: controllers.NewReportController(db),
 	}
 }
 
@@ -27,17 +32,22 @@ func (h *RegionHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHand
 		apiProtected := regions.Group("")
 		apiProtected.Use(authHandler.APIAuthMiddleware())
 		{
-			apiProtected.GET("", h.handleAllRegions)
-			apiProtected.GET("/:id", h.handleGetRegion)
-			regions.GET("/:id/districts", h.getDistricts)
+			apiProtected.GET("", FromRelease(h.db, models.LevelRegion, releaseReadAll), h.handleAllRegions)
+			apiProtected.GET("/:id", FromRelease(h.db, models.LevelRegion, releaseReadByNumber), RedirectMerged(h.db, models.LevelRegion), h.handleGetRegion)
+		}
+
+		public := regions.Group("")
+		public.Use(authHandler.OptionalAPIKeyMiddleware())
+		{
+			public.GET("/:id/districts", FromRelease(h.db, models.LevelDistrict, releaseReadByParent), RedirectMerged(h.db, models.LevelRegion), h.getDistricts)
 		}
 
 		private := regions.Group("")
 		private.Use(authHandler.TokenAuthMiddleware())
 		{
-			private.POST("", h.createRegion)
-			private.PUT("/:id", h.updateRegion)
-			private.DELETE("/:id", h.deleteRegion)
+			private.POST("", authHandler.RequirePermission(services.PermUnitsCreate), h.createRegion)
+			private.PUT("/:id", authHandler.RequirePermission(services.PermUnitsUpdate, ScopeFromParam(models.LevelRegion)), h.updateRegion)
+			private.DELETE("/:id", authHandler.RequirePermission(services.PermUnitsDelete, ScopeFromParam(models.LevelRegion)), h.deleteRegion)
 		}
 	}
 }
@@ -81,111 +91,57 @@ func (h *RegionHandler) handleGetRegion(c *gin.Context) {
 		Name: region.Name,
 	}
 
+	reports, err := h.reports.Counts(models.LevelRegion, region.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch report counts"))
+		return
+	}
+	response.Reports = reports
+
 	c.JSON(http.StatusOK, response)
 }
 
 func (h *RegionHandler) createRegion(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
-
 	var payload models.Region
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
 		return
 	}
 
-	var existingRegion models.Region
-	if err := h.db.DB.Where("name = ?", payload.Name).First(&existingRegion).Error; err == nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Region with this name already exists"))
-		return
-	}
-
-	region := models.Region{
-		Number: commons.UUIDGenerator(),
-		Name:   payload.Name,
-	}
-
-	if err := h.db.DB.Create(&region).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
+	if _, err := h.units.CreateUnit(h.db.DB.WithContext(c), models.LevelRegion, controllers.UnitInput{
+		Name: payload.Name,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to create region")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Region created successfully",
-	})
+	c.JSON(http.StatusCreated, gin.H{"message": "Region created successfully"})
 }
 
 func (h *RegionHandler) updateRegion(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
-
-	number := c.Param("id")
-	if number == "" {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Region id is required"))
-		return
-	}
-
-	number = commons.Sanitize(number)
-
-	var region models.Region
-	if err := h.db.DB.First(&region, "number = ?", number).Error; err != nil {
-		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Region not found"))
-		return
-	}
+	number := commons.Sanitize(c.Param("id"))
 
 	var payload models.Region
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request bidy"))
-		return
-	}
-
-	var existingRegion models.Region
-	if err := h.db.DB.Where("name = ? AND number != ?", payload.Name, number).First(&existingRegion).Error; err == nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Region with this name already exists"))
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
 		return
 	}
 
-	region.Name = payload.Name
-
-	if err := h.db.DB.Save(&region).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
+	if err := h.units.UpdateUnit(h.db.DB.WithContext(c), models.LevelRegion, number, controllers.UnitInput{
+		Name: payload.Name,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to update region")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Region updated successfully",
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "Region updated successfully"})
 }
 
 func (h *RegionHandler) deleteRegion(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
-
-	number := c.Param("id")
-	if number == "" {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Region id is required"))
-		return
-	}
+	number := commons.Sanitize(c.Param("id"))
 
-	number = commons.Sanitize(number)
-
-	var region models.Region
-	if err := h.db.DB.First(&region, "number = ?", number).Error; err != nil {
-		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Region not found"))
-		return
-	}
-
-	if err := h.db.DB.Delete(&region).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
+	if err := h.units.DeleteUnit(h.db.DB.WithContext(c), models.LevelRegion, number); err != nil {
+		writeUnitError(c, err, "Failed to delete region")
 		return
 	}
 
@@ -0,0 +1,241 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+type ReleaseHandler struct {
+	controller *controllers.ReleaseController
+}
+
+func NewReleaseHandler(db *database.Database) *ReleaseHandler {
+	return &ReleaseHandler{
+		controller: controllers.NewReleaseController(db),
+	}
+}
+
+func (h *ReleaseHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	releases := r.Group("/releases")
+	{
+		apiProtected := releases.Group("")
+		apiProtected.Use(authHandler.APIAuthMiddleware())
+		{
+			apiProtected.GET("", h.listReleases)
+			apiProtected.GET("/:name", h.getRelease)
+			apiProtected.GET("/:name/download", h.downloadRelease)
+			apiProtected.GET("/:name/checksums", h.releaseChecksums)
+		}
+
+		private := releases.Group("")
+		private.Use(authHandler.TokenAuthMiddleware())
+		{
+			private.POST("", authHandler.RequirePermission(services.PermReleasesCut), h.cutRelease)
+		}
+	}
+}
+
+func writeReleaseError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, controllers.ErrReleaseNotFound):
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Release not found"))
+	case errors.Is(err, controllers.ErrReleaseNameTaken):
+		c.JSON(http.StatusConflict, customerrors.NewBadRequestError(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError(fallback))
+	}
+}
+
+func (h *ReleaseHandler) cutRelease(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.CreateReleaseInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	release, err := h.controller.WithContext(c).Cut(&payload, user.Number)
+	if err != nil {
+		writeReleaseError(c, err, "Failed to cut release")
+		return
+	}
+
+	c.JSON(http.StatusCreated, release)
+}
+
+func (h *ReleaseHandler) listReleases(c *gin.Context) {
+	pagination := commons.GetPaginationParams(c)
+
+	releases, total, err := h.controller.List(pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch releases"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ReleaseListResponse{
+		Data:  releases,
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: total,
+	})
+}
+
+func (h *ReleaseHandler) getRelease(c *gin.Context) {
+	release, err := h.controller.Find(commons.Sanitize(c.Param("name")))
+	if err != nil {
+		writeReleaseError(c, err, "Failed to fetch release")
+		return
+	}
+
+	levels, err := h.controller.LevelCounts(release.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch release"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ReleaseDetailResponse{Release: *release, Levels: levels})
+}
+
+// downloadRelease streams a release bundle. The checksum header is the one
+// recorded when the release was cut, so clients can verify what they
+// received.
+func (h *ReleaseHandler) downloadRelease(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", models.ReleaseFormatNDJSON))
+	if format != models.ReleaseFormatNDJSON && format != models.ReleaseFormatCSV {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Format must be ndjson or csv"))
+		return
+	}
+
+	release, err := h.controller.Find(commons.Sanitize(c.Param("name")))
+	if err != nil {
+		writeReleaseError(c, err, "Failed to fetch release")
+		return
+	}
+
+	checksum, size := release.Checksum(format)
+	if c.GetHeader("If-None-Match") == `"`+checksum+`"` {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	contentType := "application/x-ndjson"
+	if format == models.ReleaseFormatCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", release.BundleName(format)))
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
+	c.Header("ETag", `"`+checksum+`"`)
+	c.Header("X-Checksum-SHA256", checksum)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Status(http.StatusOK)
+
+	if err := h.controller.WithContext(c).WriteBundle(c.Writer, release, format); err != nil {
+		// Headers are already sent; the short body fails the checksum.
+		log.Printf("Failed to stream release %s: %v", release.Name, err)
+	}
+}
+
+// releaseChecksums lists the bundle digests in sha256sum format.
+func (h *ReleaseHandler) releaseChecksums(c *gin.Context) {
+	release, err := h.controller.Find(commons.Sanitize(c.Param("name")))
+	if err != nil {
+		writeReleaseError(c, err, "Failed to fetch release")
+		return
+	}
+
+	var body strings.Builder
+	for _, format := range []string{models.ReleaseFormatNDJSON, models.ReleaseFormatCSV} {
+		checksum, _ := release.Checksum(format)
+		fmt.Fprintf(&body, "%s  %s\n", checksum, release.BundleName(format))
+	}
+	c.String(http.StatusOK, body.String())
+}
+
+const (
+	releaseReadAll      = ""
+	releaseReadByNumber = "number"
+	releaseReadByParent = "parent"
+	releaseReadByName   = "name"
+)
+
+// FromRelease answers a read endpoint from a release when the request has
+// ?release=<name>, and otherwise passes it on to the live handler. by says
+// which path parameter selects units: "number" and "name" read :id or :name
+// as a single unit, "parent" lists the children of :id, and "" lists the
+// whole level.
+func FromRelease(db *database.Database, level, by string) gin.HandlerFunc {
+	releases := controllers.NewReleaseController(db)
+
+	return func(c *gin.Context) {
+		name := commons.Sanitize(c.Query("release"))
+		if name == "" {
+			c.Next()
+			return
+		}
+		c.Abort()
+
+		release, err := releases.Find(name)
+		if err != nil {
+			writeReleaseError(c, err, "Failed to fetch release")
+			return
+		}
+		c.Header("X-Release", release.Name)
+
+		var filter controllers.ReleaseUnitFilter
+		switch by {
+		case releaseReadByNumber:
+			filter.Number = commons.Sanitize(c.Param("id"))
+		case releaseReadByParent:
+			filter.ParentNumber = commons.Sanitize(c.Param("id"))
+		case releaseReadByName:
+			filter.Name = commons.Sanitize(c.Param("name"))
+		}
+
+		pagination := commons.GetPaginationParams(c)
+		if by == releaseReadByNumber || by == releaseReadByName {
+			pagination = commons.PaginationParams{Page: 1, Limit: 1}
+		}
+
+		units, total, err := releases.Units(release.Number, level, filter, pagination)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch release data"))
+			return
+		}
+
+		if by == releaseReadByNumber || by == releaseReadByName {
+			if len(units) == 0 {
+				c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Record not found in release "+release.Name))
+				return
+			}
+			c.JSON(http.StatusOK, units[0])
+			return
+		}
+
+		c.JSON(http.StatusOK, models.ReleaseUnitListResponse{
+			Release: release.Name,
+			Data:    units,
+			Page:    pagination.Page,
+			Limit:   pagination.Limit,
+			Total:   total,
+		})
+	}
+}
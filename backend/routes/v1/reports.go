@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+type ReportHandler struct {
+	controller *controllers.ReportController
+}
+
+func NewReportHandler(db *database.Database) *ReportHandler {
+	return &ReportHandler{
+		controller: controllers.NewReportController(db),
+	}
+}
+
+func (h *ReportHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	for level, meta := range models.Levels {
+		r.POST("/"+meta.Path+"/:id/reports", authHandler.SessionOrAPIKeyMiddleware(), h.fileReport(level))
+	}
+
+	queue := r.Group("/admin/reports")
+	queue.Use(authHandler.TokenAuthMiddleware(), authHandler.RequirePermission(services.PermChangesReview))
+	{
+		queue.GET("", h.listReports)
+		queue.GET("/:id", h.getReport)
+		queue.PATCH("/:id", h.triageReport)
+	}
+}
+
+func writeReportError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, controllers.ErrReportNotFound):
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Report not found"))
+	case errors.Is(err, controllers.ErrReportDuplicate), errors.Is(err, controllers.ErrReportInvalidTransition):
+		c.JSON(http.StatusConflict, customerrors.NewBadRequestError(err.Error()))
+	default:
+		writeUnitError(c, err, fallback)
+	}
+}
+
+func (h *ReportHandler) fileReport(level string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload models.IssueReportInput
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+			return
+		}
+		payload.Prepare()
+		if err := payload.Validate(); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+			return
+		}
+
+		var reportedBy, apiKeyNumber string
+		if value, ok := c.Get("api_key"); ok {
+			apiKey := value.(*models.APIKey)
+			reportedBy, apiKeyNumber = apiKey.UserNumber, apiKey.Number
+		}
+		if value, ok := c.Get("user"); ok {
+			reportedBy = value.(*models.User).Number
+		}
+
+		report, err := h.controller.WithContext(c).File(level, commons.Sanitize(c.Param("id")), &payload, reportedBy, apiKeyNumber)
+		if err != nil {
+			writeReportError(c, err, "Failed to file report")
+			return
+		}
+
+		c.JSON(http.StatusCreated, report)
+	}
+}
+
+func (h *ReportHandler) listReports(c *gin.Context) {
+	pagination := commons.GetPaginationParams(c)
+	pagination.Limit = min(pagination.Limit, maxAdminPageSize)
+
+	filter := controllers.ReportFilter{
+		Status:     strings.ToUpper(strings.TrimSpace(c.Query("status"))),
+		Level:      strings.ToLower(strings.TrimSpace(c.Query("level"))),
+		UnitNumber: commons.Sanitize(c.Query("unit_number")),
+		Category:   strings.ToLower(strings.TrimSpace(c.Query("category"))),
+	}
+
+	reports, total, err := h.controller.List(filter, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch reports"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.IssueReportListResponse{
+		Data:  reports,
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: total,
+	})
+}
+
+func (h *ReportHandler) getReport(c *gin.Context) {
+	report, err := h.controller.Find(commons.Sanitize(c.Param("id")))
+	if err != nil {
+		writeReportError(c, err, "Failed to fetch report")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *ReportHandler) triageReport(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.IssueReportTriageInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+
+	report, err := h.controller.WithContext(c).Triage(commons.Sanitize(c.Param("id")), user, payload.Status, payload.Note)
+	if err != nil {
+		writeReportError(c, err, "Failed to update report")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,137 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+type RoleHandler struct {
+	controller     *controllers.RoleController
+	userController *controllers.UserController
+}
+
+func NewRoleHandler(db *database.Database) *RoleHandler {
+	return &RoleHandler{
+		controller:     controllers.NewRoleController(db),
+		userController: controllers.NewUserController(db, services.NewJWTService()),
+	}
+}
+
+func (h *RoleHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	roles := r.Group("/roles")
+	roles.Use(authHandler.TokenAuthMiddleware(), authHandler.RequirePermission(services.PermRolesManage))
+	{
+		roles.GET("", h.listRoles)
+		roles.GET("/assignments", h.listAssignments)
+		roles.POST("/assignments", h.createAssignment)
+		roles.DELETE("/assignments/:id", h.deleteAssignment)
+	}
+}
+
+func toRoleAssignmentResponse(assignment models.RoleAssignment) models.RoleAssignmentResponse {
+	return models.RoleAssignmentResponse{
+		ID:          assignment.Number,
+		UserNumber:  assignment.UserNumber,
+		Role:        assignment.Role,
+		ScopeLevel:  assignment.ScopeLevel,
+		ScopeNumber: assignment.ScopeNumber,
+		GrantedBy:   assignment.GrantedBy,
+	}
+}
+
+func (h *RoleHandler) listRoles(c *gin.Context) {
+	c.JSON(http.StatusOK, services.RolePermissions())
+}
+
+func (h *RoleHandler) listAssignments(c *gin.Context) {
+	assignments, err := h.controller.ListAssignments(commons.Sanitize(c.Query("user_number")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch role assignments"))
+		return
+	}
+
+	response := make([]models.RoleAssignmentResponse, len(assignments))
+	for i, assignment := range assignments {
+		response[i] = toRoleAssignmentResponse(assignment)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *RoleHandler) createAssignment(c *gin.Context) {
+	var payload models.RoleAssignmentInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
+		return
+	}
+
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	if _, err := h.userController.FindByNumber(payload.UserNumber); err != nil {
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("User not found"))
+		return
+	}
+
+	if payload.ScopeNumber != "" {
+		exists, err := h.controller.UnitExists(payload.ScopeLevel, payload.ScopeNumber)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to check scope"))
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Scope unit not found"))
+			return
+		}
+	}
+
+	exists, err := h.controller.AssignmentExists(&payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to check role assignment"))
+		return
+	}
+	if exists {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Role is already assigned"))
+		return
+	}
+
+	currentUser := c.MustGet("user").(*models.User)
+	assignment, err := h.controller.CreateAssignment(&payload, currentUser.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to assign role"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, toRoleAssignmentResponse(*assignment))
+}
+
+func (h *RoleHandler) deleteAssignment(c *gin.Context) {
+	number := commons.Sanitize(c.Param("id"))
+	if number == "" {
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Assignment id is required"))
+		return
+	}
+
+	if err := h.controller.DeleteAssignment(number); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Role assignment not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to remove role assignment"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role assignment removed successfully"})
+}
@@ -0,0 +1,153 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+const (
+	// streamHeartbeatInterval keeps idle connections from being closed by
+	// proxies.
+	streamHeartbeatInterval = 25 * time.Second
+	// streamRetry is how long browsers wait before reconnecting, in
+	// milliseconds.
+	streamRetry = 3000
+)
+
+type StreamHandler struct {
+	db    *database.Database
+	bus   *services.ChangeBus
+	units *controllers.UnitController
+}
+
+func NewStreamHandler(db *database.Database, bus *services.ChangeBus) *StreamHandler {
+	return &StreamHandler{
+		db:    db,
+		bus:   bus,
+		units: controllers.NewUnitController(),
+	}
+}
+
+// RegisterRoutes expects a group without the request timeout, since streams
+// stay open for as long as the client listens.
+func (h *StreamHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	r.GET("/stream/changes", authHandler.APIAuthMiddleware(), h.streamChanges)
+}
+
+func changeEvent(entry models.ChangeFeedEntry) sse.Event {
+	return sse.Event{
+		Event: entry.Op,
+		Id:    strconv.FormatInt(entry.Sequence, 10),
+		Data:  entry,
+	}
+}
+
+// streamChanges pushes unit changes as server-sent events. Each event is
+// named after the change's op and carries its sequence as the event ID, so
+// a reconnecting client's Last-Event-ID (or ?last_event_id=) replays what
+// it missed before going live. ?level= and ?scope_level=&scope_number=
+// narrow the stream to some levels or to one unit's subtree.
+func (h *StreamHandler) streamChanges(c *gin.Context) {
+	filter := services.ChangeFilter{Levels: listQuery(c, "level")}
+	for _, level := range filter.Levels {
+		if _, ok := models.Levels[level]; !ok {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Unknown level '"+level+"'"))
+			return
+		}
+	}
+
+	scopeLevel := strings.ToLower(strings.TrimSpace(c.Query("scope_level")))
+	scopeNumber := commons.Sanitize(c.Query("scope_number"))
+	if (scopeLevel == "") != (scopeNumber == "") {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("scope_level and scope_number must be provided together"))
+		return
+	}
+	if scopeLevel != "" {
+		if _, ok := models.Levels[scopeLevel]; !ok {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Unknown level '"+scopeLevel+"'"))
+			return
+		}
+		if _, err := h.units.FindUnit(h.db.DB, scopeLevel, scopeNumber); err != nil {
+			if errors.Is(err, controllers.ErrUnitNotFound) {
+				c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Scope unit not found"))
+				return
+			}
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch scope unit"))
+			return
+		}
+		filter.Scope = &models.UnitRef{Level: scopeLevel, Number: scopeNumber}
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var since int64 = -1
+	if lastEventID != "" {
+		parsed, err := strconv.ParseInt(strings.TrimSpace(lastEventID), 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Last-Event-ID must be a sequence number"))
+			return
+		}
+		since = parsed
+	}
+
+	// Subscribe before replaying so nothing committed in between is lost;
+	// live changes the replay already sent are skipped below.
+	subscription := h.bus.Subscribe(filter)
+	defer subscription.Close()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Render(http.StatusOK, sse.Event{Event: "ready", Retry: streamRetry, Data: gin.H{"filter": filter}})
+	c.Writer.Flush()
+
+	if since >= 0 {
+		replayed, err := h.bus.Replay(since, filter, func(entry models.ChangeFeedEntry) error {
+			c.Render(-1, changeEvent(entry))
+			c.Writer.Flush()
+			return c.Request.Context().Err()
+		})
+		if err != nil {
+			return
+		}
+		since = replayed
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case entry, ok := <-subscription.Events():
+			if !ok {
+				// Fell too far behind or the server is stopping; the
+				// client reconnects and resumes from its Last-Event-ID.
+				return false
+			}
+			if entry.Sequence > since {
+				c.Render(-1, changeEvent(entry))
+			}
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
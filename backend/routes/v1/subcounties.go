@@ -5,71 +5,60 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"opendataug.org/commons"
-	"opendataug.org/commons/constants"
+	"opendataug.org/controllers"
 	"opendataug.org/database"
 	customerrors "opendataug.org/errors"
 	"opendataug.org/models"
+	"opendataug.org/services"
 )
 
 type SubcountyHandle struct {
-	db *database.Database
+	db      *database.Database
+	units   *controllers.UnitController
+	reports *controllers.ReportController
 }
 
 func NewSubcountyHandler(db *database.Database) *SubcountyHandle {
 	return &SubcountyHandle{
-		db: db,
+		db:      db,
+		units:   controllers.NewUnitController(),
+		reports: controllers.NewReportController(db),
 	}
 }
 
 func (h *SubcountyHandle) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
 	subcounties := r.Group("/subcounties")
 	{
-		apiProtected := subcounties.Group("")
-		apiProtected.Use(authHandler.APIAuthMiddleware())
+		public := subcounties.Group("")
+		public.Use(authHandler.OptionalAPIKeyMiddleware())
 		{
-			subcounties.GET("", h.handleAllSubCounties)
-			subcounties.GET("/:id", h.handleGetSubCounty)
-			subcounties.GET("/:id/parishes", h.handleParishes)
+			public.GET("", FromRelease(h.db, models.LevelSubCounty, releaseReadAll), h.handleAllSubCounties)
+			public.GET("/:id", FromRelease(h.db, models.LevelSubCounty, releaseReadByNumber), RedirectMerged(h.db, models.LevelSubCounty), h.handleGetSubCounty)
+			public.GET("/:id/parishes", FromRelease(h.db, models.LevelParish, releaseReadByParent), RedirectMerged(h.db, models.LevelSubCounty), h.handleParishes)
 		}
 
 		private := subcounties.Group("")
 		private.Use(authHandler.TokenAuthMiddleware())
 		{
-			subcounties.POST("", h.createSubcounty)
-			subcounties.PUT("/:id", h.updateSubCounty)
-			subcounties.DELETE("/:id", h.deleteSubCounty)
+			private.POST("", authHandler.RequirePermission(services.PermUnitsCreate, ScopeFromBody(models.LevelSubCounty)), h.createSubcounty)
+			private.PUT("/:id", authHandler.RequirePermission(services.PermUnitsUpdate, ScopeFromParam(models.LevelSubCounty), ScopeFromBody(models.LevelSubCounty)), h.updateSubCounty)
+			private.DELETE("/:id", authHandler.RequirePermission(services.PermUnitsDelete, ScopeFromParam(models.LevelSubCounty)), h.deleteSubCounty)
 		}
 	}
 }
 
 func (h *SubcountyHandle) createSubcounty(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
-
 	var payload models.SubCounty
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
 		return
 	}
 
-	var existingSubcounty models.SubCounty
-	if err := h.db.DB.Where("name = ? AND county_number = ?", payload.Name, payload.CountyNumber).
-		First(&existingSubcounty).Error; err == nil {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("Subcounty with this name already exists in this county"))
-		return
-	}
-
-	subcounty := models.SubCounty{
-		Number:       commons.UUIDGenerator(),
-		CountyNumber: payload.CountyNumber,
+	if _, err := h.units.CreateUnit(h.db.DB.WithContext(c), models.LevelSubCounty, controllers.UnitInput{
 		Name:         payload.Name,
-	}
-
-	if err := h.db.DB.Create(&subcounty).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
+		ParentNumber: payload.CountyNumber,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to create subcounty")
 		return
 	}
 
@@ -101,23 +90,18 @@ func (h *SubcountyHandle) handleGetSubCounty(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, subcounty)
-}
-
-func (h *SubcountyHandle) updateSubCounty(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
+	reports, err := h.reports.Counts(models.LevelSubCounty, subcounty.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch report counts"))
 		return
 	}
+	subcounty.Reports = reports
 
-	number := c.Param("id")
+	c.JSON(http.StatusOK, subcounty)
+}
 
-	var subcounty models.SubCounty
-	if err := h.db.DB.First(&subcounty, "number = ?", number).Error; err != nil {
-		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Sub county not found"))
-		return
-	}
+func (h *SubcountyHandle) updateSubCounty(c *gin.Context) {
+	number := commons.Sanitize(c.Param("id"))
 
 	var payload models.SubCounty
 	if err := c.ShouldBindJSON(&payload); err != nil {
@@ -125,11 +109,11 @@ func (h *SubcountyHandle) updateSubCounty(c *gin.Context) {
 		return
 	}
 
-	subcounty.Name = payload.Name
-	subcounty.CountyNumber = payload.CountyNumber
-
-	if err := h.db.DB.Save(&subcounty).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
+	if err := h.units.UpdateUnit(h.db.DB.WithContext(c), models.LevelSubCounty, number, controllers.UnitInput{
+		Name:         payload.Name,
+		ParentNumber: payload.CountyNumber,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to update subcounty")
 		return
 	}
 
@@ -137,27 +121,10 @@ func (h *SubcountyHandle) updateSubCounty(c *gin.Context) {
 }
 
 func (h *SubcountyHandle) deleteSubCounty(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.db.DB)
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
-
-	number := c.Param("id")
-	if number == "" {
-		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError("ID is required"))
-		return
-	}
-	number = commons.Sanitize(number)
+	number := commons.Sanitize(c.Param("id"))
 
-	var subcounty models.SubCounty
-	if err := h.db.DB.First(&subcounty, "number = ?", number).Error; err != nil {
-		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Subcounty not found"))
-		return
-	}
-
-	if err := h.db.DB.Delete(&subcounty).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Database level error occurred"))
+	if err := h.units.DeleteUnit(h.db.DB.WithContext(c), models.LevelSubCounty, number); err != nil {
+		writeUnitError(c, err, "Failed to delete subcounty")
 		return
 	}
 
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
+)
+
+type TrashHandler struct {
+	controller *controllers.TrashController
+}
+
+func NewTrashHandler(db *database.Database) *TrashHandler {
+	return &TrashHandler{
+		controller: controllers.NewTrashController(db),
+	}
+}
+
+func (h *TrashHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	trash := r.Group("/admin/trash")
+	trash.Use(authHandler.TokenAuthMiddleware())
+	{
+		trash.GET("", authHandler.RequirePermission(services.PermUnitsDelete), h.listTrash)
+		trash.POST("/:level/:id/restore", authHandler.RequirePermission(services.PermUnitsDelete), h.restoreUnit)
+		trash.DELETE("/:level/:id", authHandler.RequirePermission(services.PermUnitsPurge), h.purgeUnit)
+	}
+}
+
+func trashLevel(c *gin.Context, value string) (string, bool) {
+	level := strings.ToLower(strings.TrimSpace(value))
+	if _, ok := models.Levels[level]; !ok {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("A valid 'level' is required"))
+		return "", false
+	}
+	return level, true
+}
+
+func (h *TrashHandler) listTrash(c *gin.Context) {
+	level, ok := trashLevel(c, c.Query("level"))
+	if !ok {
+		return
+	}
+
+	pagination := commons.GetPaginationParams(c)
+	pagination.Limit = min(pagination.Limit, maxAdminPageSize)
+
+	units, total, err := h.controller.List(level, commons.Sanitize(c.Query("q")), pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch deleted units"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TrashListResponse{
+		Data:  units,
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: total,
+	})
+}
+
+func (h *TrashHandler) restoreUnit(c *gin.Context) {
+	level, ok := trashLevel(c, c.Param("level"))
+	if !ok {
+		return
+	}
+
+	if err := h.controller.WithContext(c).Restore(level, commons.Sanitize(c.Param("id"))); err != nil {
+		if errors.Is(err, controllers.ErrUnitParentNotFound) {
+			c.JSON(http.StatusConflict, customerrors.NewBadRequestError("The unit's parent no longer exists; restore the parent first"))
+			return
+		}
+		writeUnitError(c, err, "Failed to restore unit")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unit restored"})
+}
+
+// purgeUnit removes a deleted unit for good. The audit log keeps its last
+// state.
+func (h *TrashHandler) purgeUnit(c *gin.Context) {
+	level, ok := trashLevel(c, c.Param("level"))
+	if !ok {
+		return
+	}
+
+	if err := h.controller.WithContext(c).Purge(level, commons.Sanitize(c.Param("id"))); err != nil {
+		writeUnitError(c, err, "Failed to purge unit")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unit purged"})
+}
@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+)
+
+// writeUnitError maps UnitController errors to responses. fallback is the
+// message for unexpected failures.
+func writeUnitError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, controllers.ErrUnitNotFound):
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Record not found"))
+	case errors.Is(err, controllers.ErrUnitParentNotFound):
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Invalid parent number"))
+	case errors.Is(err, controllers.ErrUnitNameRequired),
+		errors.Is(err, controllers.ErrUnitNameTaken),
+		errors.Is(err, controllers.ErrUnitHasNoParent):
+		c.JSON(http.StatusBadRequest, customerrors.NewBadRequestError(err.Error()))
+	case errors.Is(err, controllers.ErrUnitNotDeleted),
+		errors.Is(err, controllers.ErrUnitMerged),
+		errors.Is(err, controllers.ErrUnitHasChildren):
+		c.JSON(http.StatusConflict, customerrors.NewBadRequestError(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError(fallback))
+	}
+}
+
+// RedirectMerged sends lookups of a unit number that was merged away to the
+// unit it was merged into, keeping the rest of the path and the query.
+// Numbers that were never merged fall through to the handler.
+func RedirectMerged(db *database.Database, level string) gin.HandlerFunc {
+	units := controllers.NewUnitController()
+	path := "/" + models.Levels[level].Path + "/"
+
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		target, err := units.ResolveAlias(db.DB.WithContext(c), level, commons.Sanitize(id))
+		if err != nil || target == "" {
+			c.Next()
+			return
+		}
+
+		location := *c.Request.URL
+		location.Path = strings.Replace(location.Path, path+id, path+target, 1)
+		location.RawPath = ""
+		c.Redirect(http.StatusMovedPermanently, location.String())
+		c.Abort()
+	}
+}
@@ -5,68 +5,81 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"opendataug.org/commons"
-	"opendataug.org/commons/constants"
 	"opendataug.org/controllers"
 	"opendataug.org/database"
 	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+	"opendataug.org/services"
 )
 
 type VillageHandler struct {
+	db         *database.Database
 	controller *controllers.VillageController
+	units      *controllers.UnitController
+	reports    *controllers.ReportController
 }
 
 func NewVillageHandler(db *database.Database) *VillageHandler {
 	return &VillageHandler{
+		db:         db,
 		controller: controllers.NewVillageController(db),
+		units:      controllers.NewUnitController(),
+		reports:    controllers.NewReportController(db),
 	}
 }
 
 func (h *VillageHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
 	villages := r.Group("/villages")
 	{
-		apiProtected := villages.Group("")
-		apiProtected.Use(authHandler.APIAuthMiddleware())
+		public := villages.Group("")
+		public.Use(authHandler.OptionalAPIKeyMiddleware())
 		{
-			villages.GET("", h.handleAllVillages)
-			villages.GET("/:id", h.handleGetVillage)
+			public.GET("", FromRelease(h.db, models.LevelVillage, releaseReadAll), h.handleAllVillages)
+			public.GET("/:id", FromRelease(h.db, models.LevelVillage, releaseReadByNumber), RedirectMerged(h.db, models.LevelVillage), h.handleGetVillage)
 		}
 
 		private := villages.Group("")
 		private.Use(authHandler.TokenAuthMiddleware())
 		{
-			villages.POST("", h.createVillage)
-			villages.PUT("/:id", h.updateVillage)
-			villages.DELETE("/:id", h.deleteVillage)
+			private.POST("", authHandler.RequirePermission(services.PermUnitsCreate, ScopeFromBody(models.LevelVillage)), h.createVillage)
+			private.PUT("/:id", authHandler.RequirePermission(services.PermUnitsUpdate, ScopeFromParam(models.LevelVillage), ScopeFromBody(models.LevelVillage)), h.updateVillage)
+			private.DELETE("/:id", authHandler.RequirePermission(services.PermUnitsDelete, ScopeFromParam(models.LevelVillage)), h.deleteVillage)
 		}
 	}
 }
 
 func (h *VillageHandler) createVillage(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.controller.GetDB())
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
+	var payload models.Village
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
 		return
 	}
 
-	if err := h.controller.CreateVillage(c); err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to create village"))
+	if _, err := h.units.CreateUnit(h.db.DB.WithContext(c), models.LevelVillage, controllers.UnitInput{
+		Name:         payload.Name,
+		ParentNumber: payload.ParishNumber,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to create village")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Village created successfully",
-	})
+	c.JSON(http.StatusCreated, gin.H{"message": "Village created successfully"})
 }
 
 func (h *VillageHandler) updateVillage(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.controller.GetDB())
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
+	number := commons.Sanitize(c.Param("id"))
+
+	var payload models.Village
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Failed to parse request body"))
 		return
 	}
 
-	if err := h.controller.UpdateVillage(c); err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to update village"))
+	if err := h.units.UpdateUnit(h.db.DB.WithContext(c), models.LevelVillage, number, controllers.UnitInput{
+		Name:         payload.Name,
+		ParentNumber: payload.ParishNumber,
+	}); err != nil {
+		writeUnitError(c, err, "Failed to update village")
 		return
 	}
 
@@ -74,20 +87,14 @@ func (h *VillageHandler) updateVillage(c *gin.Context) {
 }
 
 func (h *VillageHandler) deleteVillage(c *gin.Context) {
-	user, _ := commons.GetUserFromHeader(c, h.controller.GetDB())
-	if user.Role != constants.RoleAdmin {
-		c.JSON(http.StatusUnauthorized, customerrors.NewUnauthorizedError("Unauthorized"))
-		return
-	}
+	number := commons.Sanitize(c.Param("id"))
 
-	if err := h.controller.DeleteVillage(c); err != nil {
-		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete village"))
+	if err := h.units.DeleteUnit(h.db.DB.WithContext(c), models.LevelVillage, number); err != nil {
+		writeUnitError(c, err, "Failed to delete village")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Village deleted successfully",
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "Village deleted successfully"})
 }
 
 func (h *VillageHandler) handleAllVillages(c *gin.Context) {
@@ -107,5 +114,11 @@ func (h *VillageHandler) handleGetVillage(c *gin.Context) {
 		return
 	}
 
+	village.Reports, err = h.reports.Counts(models.LevelVillage, village.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch report counts"))
+		return
+	}
+
 	c.JSON(http.StatusOK, village)
 }
@@ -0,0 +1,321 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"opendataug.org/commons"
+	"opendataug.org/controllers"
+	"opendataug.org/database"
+	customerrors "opendataug.org/errors"
+	"opendataug.org/models"
+)
+
+type WebhookHandler struct {
+	controller    *controllers.WebhookController
+	organizations *controllers.OrganizationController
+}
+
+func NewWebhookHandler(db *database.Database) *WebhookHandler {
+	return &WebhookHandler{
+		controller:    controllers.NewWebhookController(db),
+		organizations: controllers.NewOrganizationController(db),
+	}
+}
+
+func (h *WebhookHandler) RegisterRoutes(r *gin.RouterGroup, authHandler *AuthHandler) {
+	webhooks := r.Group("/webhooks")
+	webhooks.Use(authHandler.TokenAuthMiddleware())
+	{
+		webhooks.GET("/events", h.listEvents)
+		webhooks.GET("", h.listWebhooks)
+		webhooks.POST("", h.createWebhook)
+		webhooks.GET("/:id", h.requireWebhook(false), h.getWebhook)
+		webhooks.PATCH("/:id", h.requireWebhook(true), h.updateWebhook)
+		webhooks.DELETE("/:id", h.requireWebhook(true), h.deleteWebhook)
+		webhooks.POST("/:id/secret", h.requireWebhook(true), h.rotateSecret)
+		webhooks.POST("/:id/ping", h.requireWebhook(true), h.pingWebhook)
+
+		webhooks.GET("/:id/deliveries", h.requireWebhook(false), h.listDeliveries)
+		webhooks.GET("/:id/deliveries/:delivery", h.requireWebhook(false), h.getDelivery)
+		webhooks.POST("/:id/deliveries/:delivery/redeliver", h.requireWebhook(true), h.redeliver)
+	}
+}
+
+func writeWebhookError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, controllers.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Webhook not found"))
+	case errors.Is(err, controllers.ErrWebhookDeliveryNotFound):
+		c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Delivery not found"))
+	default:
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError(fallback))
+	}
+}
+
+// canAccessOrganization reports whether user belongs to the organization
+// and, with manage, may administer it.
+func (h *WebhookHandler) canAccessOrganization(organizationNumber string, user *models.User, manage bool) (bool, error) {
+	membership, err := h.organizations.FindMembership(organizationNumber, user.Number)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return !manage || membership.CanManage(), nil
+}
+
+// requireWebhook loads the webhook named by :id. Personal webhooks are only
+// visible to their owner and organization webhooks to members; manage
+// additionally requires the OWNER or ADMIN role. Anyone else gets a 404.
+func (h *WebhookHandler) requireWebhook(manage bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.User)
+
+		webhook, err := h.controller.Find(commons.Sanitize(c.Param("id")))
+		if err != nil {
+			writeWebhookError(c, err, "Failed to fetch webhook")
+			c.Abort()
+			return
+		}
+
+		allowed := webhook.UserNumber == user.Number
+		if webhook.IsOrganizationWebhook() {
+			if allowed, err = h.canAccessOrganization(webhook.OrganizationNumber, user, false); err != nil {
+				c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch membership"))
+				c.Abort()
+				return
+			}
+		}
+		if !allowed {
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Webhook not found"))
+			c.Abort()
+			return
+		}
+
+		if manage && webhook.IsOrganizationWebhook() {
+			canManage, err := h.canAccessOrganization(webhook.OrganizationNumber, user, true)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch membership"))
+				c.Abort()
+				return
+			}
+			if !canManage {
+				c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Only organization owners and admins can manage its webhooks"))
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("webhook", webhook)
+		c.Next()
+	}
+}
+
+func toWebhookResponse(webhook *models.Webhook) models.WebhookResponse {
+	return models.WebhookResponse{Webhook: *webhook, Events: webhook.EventList()}
+}
+
+func (h *WebhookHandler) listEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"events": models.WebhookEvents()})
+}
+
+// listWebhooks returns the caller's personal webhooks, or with
+// ?organization= those of an organization they belong to.
+func (h *WebhookHandler) listWebhooks(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	pagination := commons.GetPaginationParams(c)
+	pagination.Limit = min(pagination.Limit, maxAdminPageSize)
+
+	organizationNumber := commons.Sanitize(c.Query("organization"))
+	if organizationNumber != "" {
+		allowed, err := h.canAccessOrganization(organizationNumber, user, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch membership"))
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusNotFound, customerrors.NewNotFoundError("Organization not found"))
+			return
+		}
+	}
+
+	webhooks, total, err := h.controller.List(user.Number, organizationNumber, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch webhooks"))
+		return
+	}
+
+	data := make([]models.WebhookResponse, len(webhooks))
+	for i := range webhooks {
+		data[i] = toWebhookResponse(&webhooks[i])
+	}
+
+	c.JSON(http.StatusOK, models.WebhookListResponse{
+		Data:  data,
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: total,
+	})
+}
+
+func (h *WebhookHandler) createWebhook(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var payload models.WebhookInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	if payload.OrganizationNumber != "" {
+		allowed, err := h.canAccessOrganization(payload.OrganizationNumber, user, true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch membership"))
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, customerrors.NewForbiddenError("Only organization owners and admins can add webhooks"))
+			return
+		}
+	}
+
+	webhook, err := h.controller.WithContext(c).Create(&payload, user.Number)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to create webhook"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.WebhookCreatedResponse{
+		WebhookResponse: toWebhookResponse(webhook),
+		Secret:          webhook.Secret,
+	})
+}
+
+func (h *WebhookHandler) getWebhook(c *gin.Context) {
+	c.JSON(http.StatusOK, toWebhookResponse(c.MustGet("webhook").(*models.Webhook)))
+}
+
+func (h *WebhookHandler) updateWebhook(c *gin.Context) {
+	webhook := c.MustGet("webhook").(*models.Webhook)
+
+	var payload models.WebhookUpdateInput
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError("Failed to process input"))
+		return
+	}
+	payload.Prepare()
+	if err := payload.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, customerrors.NewValidationError(err.Error()))
+		return
+	}
+
+	if err := h.controller.WithContext(c).Update(webhook, &payload); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to update webhook"))
+		return
+	}
+
+	updated, err := h.controller.Find(webhook.Number)
+	if err != nil {
+		writeWebhookError(c, err, "Failed to fetch webhook")
+		return
+	}
+	c.JSON(http.StatusOK, toWebhookResponse(updated))
+}
+
+func (h *WebhookHandler) deleteWebhook(c *gin.Context) {
+	webhook := c.MustGet("webhook").(*models.Webhook)
+
+	if err := h.controller.WithContext(c).Delete(webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to delete webhook"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// rotateSecret issues a new signing secret. Deliveries still queued are
+// signed with the new one when they are sent.
+func (h *WebhookHandler) rotateSecret(c *gin.Context) {
+	webhook := c.MustGet("webhook").(*models.Webhook)
+
+	secret, err := h.controller.WithContext(c).RotateSecret(webhook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to rotate secret"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret})
+}
+
+func (h *WebhookHandler) pingWebhook(c *gin.Context) {
+	webhook := c.MustGet("webhook").(*models.Webhook)
+
+	delivery, err := h.controller.WithContext(c).Ping(webhook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to queue ping"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func (h *WebhookHandler) listDeliveries(c *gin.Context) {
+	webhook := c.MustGet("webhook").(*models.Webhook)
+	pagination := commons.GetPaginationParams(c)
+	pagination.Limit = min(pagination.Limit, maxAdminPageSize)
+
+	status := strings.ToUpper(strings.TrimSpace(c.Query("status")))
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed:
+	default:
+		c.JSON(http.StatusBadRequest, customerrors.NewValidationError("Status must be PENDING, DELIVERED or FAILED"))
+		return
+	}
+
+	deliveries, total, err := h.controller.Deliveries(webhook.Number, status, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, customerrors.NewDatabaseError("Failed to fetch deliveries"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WebhookDeliveryListResponse{
+		Data:  deliveries,
+		Page:  pagination.Page,
+		Limit: pagination.Limit,
+		Total: total,
+	})
+}
+
+func (h *WebhookHandler) getDelivery(c *gin.Context) {
+	webhook := c.MustGet("webhook").(*models.Webhook)
+
+	delivery, err := h.controller.FindDelivery(webhook.Number, commons.Sanitize(c.Param("delivery")))
+	if err != nil {
+		writeWebhookError(c, err, "Failed to fetch delivery")
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+func (h *WebhookHandler) redeliver(c *gin.Context) {
+	webhook := c.MustGet("webhook").(*models.Webhook)
+
+	delivery, err := h.controller.WithContext(c).Redeliver(webhook.Number, commons.Sanitize(c.Param("delivery")))
+	if err != nil {
+		writeWebhookError(c, err, "Failed to queue redelivery")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+const (
+	auditSkipKey   = "audit:skip"
+	auditBeforeKey = "audit:before"
+
+	// auditLockKey serializes writers appending to the hash chain.
+	auditLockKey int64 = 7_310_411_300
+)
+
+// auditedTables lists the tables whose changes are recorded.
+var auditedTables = map[string]bool{
+	"users":        true,
+	"api_keys":     true,
+	"regions":      true,
+	"districts":    true,
+	"counties":     true,
+	"sub_counties": true,
+	"parishes":     true,
+	"villages":     true,
+	"releases":     true,
+}
+
+// auditIgnoredColumns never make an update worth recording on their own.
+var auditIgnoredColumns = map[string]bool{
+	"updated_at":   true,
+	"last_used_at": true,
+	"usage_count":  true,
+}
+
+// auditRedactedColumns are dropped from snapshots so secrets never reach
+// the log.
+var auditRedactedColumns = map[string]map[string]bool{
+	"api_keys": {"key": true},
+}
+
+// SkipAudit marks a statement as exempt from auditing. Use it for
+// bookkeeping writes such as usage counters.
+func SkipAudit(db *gorm.DB) *gorm.DB {
+	return db.Set(auditSkipKey, true)
+}
+
+// RegisterAuditCallbacks hooks the audit log into every create, update and
+// delete on the audited tables. Entries are written by the same transaction
+// as the change, so a failed audit write rolls the change back. The actor,
+// client IP and request ID are read from the statement context; handlers
+// pass the *gin.Context through db.WithContext.
+func RegisterAuditCallbacks(db *gorm.DB) error {
+	callbacks := db.Callback()
+
+	if err := callbacks.Create().After("gorm:create").Register("audit:after_create", auditAfterCreate); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("audit:before_update", auditCapture); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("audit:after_update", auditAfterUpdate); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("audit:before_delete", auditCapture); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register("audit:after_delete", auditAfterDelete)
+}
+
+func auditEnabled(db *gorm.DB) bool {
+	if db.Statement.Table == "" || !auditedTables[db.Statement.Table] {
+		return false
+	}
+	if skip, ok := db.Get(auditSkipKey); ok && skip == true {
+		return false
+	}
+	return true
+}
+
+func auditCapture(db *gorm.DB) {
+	if db.Error != nil || !auditEnabled(db) {
+		return
+	}
+
+	rows, err := auditSnapshot(db, nil)
+	if err != nil {
+		_ = db.AddError(fmt.Errorf("audit: %w", err))
+		return
+	}
+	db.InstanceSet(auditBeforeKey, rows)
+}
+
+func auditAfterCreate(db *gorm.DB) {
+	if db.Error != nil || db.RowsAffected == 0 || !auditEnabled(db) {
+		return
+	}
+
+	var entries []models.AuditLog
+	for _, row := range auditRecords(db) {
+		entries = append(entries, newAuditEntry(db, models.AuditActionCreate, row, nil, row))
+	}
+	auditWrite(db, entries)
+}
+
+func auditAfterUpdate(db *gorm.DB) {
+	if db.Error != nil || db.RowsAffected == 0 || !auditEnabled(db) {
+		return
+	}
+
+	before := auditCaptured(db)
+	if len(before) == 0 {
+		return
+	}
+
+	numbers := make([]interface{}, 0, len(before))
+	for _, row := range before {
+		numbers = append(numbers, row["number"])
+	}
+
+	after, err := auditSnapshot(db, numbers)
+	if err != nil {
+		_ = db.AddError(fmt.Errorf("audit: %w", err))
+		return
+	}
+
+	afterByNumber := make(map[interface{}]map[string]interface{}, len(after))
+	for _, row := range after {
+		afterByNumber[row["number"]] = row
+	}
+
+	var entries []models.AuditLog
+	for _, old := range before {
+		current, ok := afterByNumber[old["number"]]
+		if !ok {
+			continue
+		}
+		from, to := auditDiff(old, current)
+		if len(from) == 0 {
+			continue
+		}
+		entries = append(entries, newAuditEntry(db, models.AuditActionUpdate, old, from, to))
+	}
+	auditWrite(db, entries)
+}
+
+func auditAfterDelete(db *gorm.DB) {
+	if db.Error != nil || db.RowsAffected == 0 || !auditEnabled(db) {
+		return
+	}
+
+	var entries []models.AuditLog
+	for _, row := range auditCaptured(db) {
+		entries = append(entries, newAuditEntry(db, models.AuditActionDelete, row, row, nil))
+	}
+	auditWrite(db, entries)
+}
+
+func auditCaptured(db *gorm.DB) []map[string]interface{} {
+	value, ok := db.InstanceGet(auditBeforeKey)
+	if !ok {
+		return nil
+	}
+	rows, _ := value.([]map[string]interface{})
+	return rows
+}
+
+// auditSnapshot loads the rows a statement is about to touch. When numbers
+// is nil the statement's own conditions are used; statements without any
+// condition or primary key are not snapshotted.
+func auditSnapshot(db *gorm.DB, numbers []interface{}) ([]map[string]interface{}, error) {
+	stmt := db.Statement
+
+	var conditions []clause.Expression
+	if numbers == nil {
+		if where, ok := stmt.Clauses["WHERE"]; ok {
+			if w, ok := where.Expression.(clause.Where); ok {
+				conditions = append(conditions, w.Exprs...)
+			}
+		}
+		numbers = auditModelNumbers(db)
+	}
+	if len(numbers) > 0 {
+		conditions = append(conditions, clause.IN{Column: clause.Column{Name: "number"}, Values: numbers})
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	if stmt.Schema != nil && stmt.Schema.LookUpField("DeletedAt") != nil && !stmt.Unscoped {
+		conditions = append(conditions, clause.Expr{SQL: "deleted_at IS NULL"})
+	}
+
+	var rows []map[string]interface{}
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Table(stmt.Table).
+		Clauses(clause.Where{Exprs: conditions}).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		auditRedact(stmt.Table, row)
+	}
+	return rows, nil
+}
+
+// auditModelNumbers returns the non-empty Number values of the statement's
+// model, which identify the rows for Save and Delete on loaded records.
+func auditModelNumbers(db *gorm.DB) []interface{} {
+	var numbers []interface{}
+	for _, row := range auditRecords(db) {
+		if number, ok := row["number"].(string); ok && number != "" {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers
+}
+
+// auditRecords converts the statement's model values into column maps.
+func auditRecords(db *gorm.DB) []map[string]interface{} {
+	stmt := db.Statement
+	if stmt.Schema == nil || !stmt.ReflectValue.IsValid() {
+		return nil
+	}
+
+	toRow := func(value reflect.Value) map[string]interface{} {
+		row := make(map[string]interface{}, len(stmt.Schema.Fields))
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			fieldValue, _ := field.ValueOf(stmt.Context, value)
+			row[field.DBName] = fieldValue
+		}
+		auditRedact(stmt.Table, row)
+		return row
+	}
+
+	var rows []map[string]interface{}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			rows = append(rows, toRow(reflect.Indirect(stmt.ReflectValue.Index(i))))
+		}
+	case reflect.Struct:
+		rows = append(rows, toRow(stmt.ReflectValue))
+	}
+	return rows
+}
+
+func auditRedact(table string, row map[string]interface{}) {
+	for column := range auditRedactedColumns[table] {
+		delete(row, column)
+	}
+}
+
+// auditDiff returns the changed columns of a row before and after an update.
+func auditDiff(before, after map[string]interface{}) (from, to map[string]interface{}) {
+	from = make(map[string]interface{})
+	to = make(map[string]interface{})
+	for column, old := range before {
+		if auditIgnoredColumns[column] {
+			continue
+		}
+		current := after[column]
+		if reflect.DeepEqual(old, current) {
+			continue
+		}
+		from[column] = old
+		to[column] = current
+	}
+	return from, to
+}
+
+func auditJSON(value map[string]interface{}) *string {
+	if value == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded = []byte(fmt.Sprintf("%q", err.Error()))
+	}
+	result := string(encoded)
+	return &result
+}
+
+func newAuditEntry(db *gorm.DB, action string, row, before, after map[string]interface{}) models.AuditLog {
+	entry := models.AuditLog{
+		Number:     commons.UUIDGenerator(),
+		ActorType:  models.AuditActorSystem,
+		Action:     action,
+		EntityType: db.Statement.Table,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+		// Postgres keeps microseconds; truncating keeps the hash stable
+		// after a round trip.
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	if number, ok := row["number"].(string); ok {
+		entry.EntityNumber = number
+	}
+
+	auditAttribute(db.Statement.Context, &entry)
+	return entry
+}
+
+// auditAttribute fills in who made the change from the request context.
+func auditAttribute(ctx context.Context, entry *models.AuditLog) {
+	if ctx == nil {
+		return
+	}
+
+	if apiKey, ok := ctx.Value("api_key").(*models.APIKey); ok && apiKey != nil {
+		entry.ActorType = models.AuditActorAPIKey
+		entry.ActorNumber = apiKey.Number
+	} else if user, ok := ctx.Value("user").(*models.User); ok && user != nil {
+		entry.ActorType = models.AuditActorUser
+		entry.ActorNumber = user.Number
+	}
+
+	if c, ok := ctx.(*gin.Context); ok {
+		entry.IPAddress = c.ClientIP()
+		entry.RequestID = c.GetString("request_id")
+	}
+}
+
+// auditWrite appends entries to the chain. The advisory lock is held until
+// the surrounding transaction ends, so sequences and hashes never fork.
+func auditWrite(db *gorm.DB, entries []models.AuditLog) {
+	if len(entries) == 0 {
+		return
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditLockKey).Error; err != nil {
+			return err
+		}
+
+		var last models.AuditLog
+		result := tx.Order("sequence DESC").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		sequence, prevHash := int64(0), ""
+		if result.RowsAffected > 0 {
+			sequence, prevHash = last.Sequence, last.Hash
+		}
+
+		for i := range entries {
+			sequence++
+			entries[i].Sequence = sequence
+			entries[i].PrevHash = prevHash
+			entries[i].SealSnapshots()
+			entries[i].Hash = entries[i].ComputeHash()
+			prevHash = entries[i].Hash
+		}
+
+		return tx.Create(&entries).Error
+	})
+	if err != nil {
+		_ = db.AddError(fmt.Errorf("audit: %w", err))
+	}
+}
+
+// RedactAuditSnapshots removes the Before and After snapshots of every entry
+// about one entity, for erasing personal data. The digests are kept, so the
+// chain still verifies.
+func RedactAuditSnapshots(tx *gorm.DB, entityType, entityNumber string) error {
+	return tx.Model(&models.AuditLog{}).
+		Where("entity_type = ? AND entity_number = ? AND redacted_at IS NULL", entityType, entityNumber).
+		Updates(map[string]interface{}{
+			"before":      nil,
+			"after":       nil,
+			"redacted_at": time.Now(),
+		}).Error
+}
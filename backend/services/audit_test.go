@@ -0,0 +1,146 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"opendataug.org/models"
+)
+
+func TestAuditDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   map[string]interface{}
+		after    map[string]interface{}
+		wantFrom map[string]interface{}
+		wantTo   map[string]interface{}
+	}{
+		{
+			name:     "unchanged",
+			before:   map[string]interface{}{"name": "Kampala", "town_status": true},
+			after:    map[string]interface{}{"name": "Kampala", "town_status": true},
+			wantFrom: map[string]interface{}{},
+			wantTo:   map[string]interface{}{},
+		},
+		{
+			name:     "rename",
+			before:   map[string]interface{}{"number": "1", "name": "Kampala"},
+			after:    map[string]interface{}{"number": "1", "name": "Kampala City"},
+			wantFrom: map[string]interface{}{"name": "Kampala"},
+			wantTo:   map[string]interface{}{"name": "Kampala City"},
+		},
+		{
+			name:     "ignored columns alone",
+			before:   map[string]interface{}{"updated_at": "a", "last_used_at": "a", "usage_count": 1},
+			after:    map[string]interface{}{"updated_at": "b", "last_used_at": "b", "usage_count": 2},
+			wantFrom: map[string]interface{}{},
+			wantTo:   map[string]interface{}{},
+		},
+		{
+			name:     "column cleared",
+			before:   map[string]interface{}{"deleted_at": "2026-01-01T00:00:00Z"},
+			after:    map[string]interface{}{"deleted_at": nil},
+			wantFrom: map[string]interface{}{"deleted_at": "2026-01-01T00:00:00Z"},
+			wantTo:   map[string]interface{}{"deleted_at": nil},
+		},
+		{
+			name:     "column missing after",
+			before:   map[string]interface{}{"region_number": "r1"},
+			after:    map[string]interface{}{},
+			wantFrom: map[string]interface{}{"region_number": "r1"},
+			wantTo:   map[string]interface{}{"region_number": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := auditDiff(tt.before, tt.after)
+			if !reflect.DeepEqual(from, tt.wantFrom) {
+				t.Errorf("from = %v, want %v", from, tt.wantFrom)
+			}
+			if !reflect.DeepEqual(to, tt.wantTo) {
+				t.Errorf("to = %v, want %v", to, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestAuditJSON(t *testing.T) {
+	if got := auditJSON(nil); got != nil {
+		t.Errorf("auditJSON(nil) = %q, want nil", *got)
+	}
+	got := auditJSON(map[string]interface{}{"name": "Gulu", "town_status": false})
+	if got == nil || *got != `{"name":"Gulu","town_status":false}` {
+		t.Errorf("auditJSON = %v", got)
+	}
+}
+
+func TestAuditHashChain(t *testing.T) {
+	before := `{"name":"Kampala"}`
+	after := `{"name":"Kampala City"}`
+	entry := models.AuditLog{
+		Sequence:     2,
+		ActorType:    models.AuditActorUser,
+		ActorNumber:  "u1",
+		Action:       models.AuditActionUpdate,
+		EntityType:   "districts",
+		EntityNumber: "d1",
+		Before:       &before,
+		After:        &after,
+		PrevHash:     "abc",
+		CreatedAt:    time.Date(2026, 10, 1, 12, 0, 0, 123000, time.UTC),
+	}
+	entry.SealSnapshots()
+	hash := entry.ComputeHash()
+	if len(hash) != 64 {
+		t.Fatalf("hash %q is not hex SHA-256", hash)
+	}
+	if !entry.SnapshotsIntact() {
+		t.Fatal("sealed snapshots do not verify")
+	}
+
+	// The same content in another time zone hashes the same.
+	moved := entry
+	moved.CreatedAt = entry.CreatedAt.In(time.FixedZone("EAT", 3*60*60))
+	if moved.ComputeHash() != hash {
+		t.Error("hash depends on the time zone of CreatedAt")
+	}
+
+	verifies := func(a *models.AuditLog) bool {
+		return a.ComputeHash() == hash && a.SnapshotsIntact()
+	}
+	edits := map[string]func(*models.AuditLog){
+		"prev hash":   func(a *models.AuditLog) { a.PrevHash = "abd" },
+		"sequence":    func(a *models.AuditLog) { a.Sequence = 3 },
+		"actor":       func(a *models.AuditLog) { a.ActorNumber = "u2" },
+		"action":      func(a *models.AuditLog) { a.Action = models.AuditActionDelete },
+		"entity":      func(a *models.AuditLog) { a.EntityNumber = "d2" },
+		"before":      func(a *models.AuditLog) { changed := `{"name":"Kla"}`; a.Before = &changed },
+		"after":       func(a *models.AuditLog) { a.After = nil },
+		"time":        func(a *models.AuditLog) { a.CreatedAt = a.CreatedAt.Add(time.Microsecond) },
+		"before hash": func(a *models.AuditLog) { a.Before = nil; a.BeforeHash = "" },
+		"resealed": func(a *models.AuditLog) {
+			changed := `{"name":"Kla"}`
+			a.After = &changed
+			a.SealSnapshots()
+		},
+		"redaction kept text": func(a *models.AuditLog) { now := time.Now(); a.RedactedAt = &now },
+	}
+	for name, edit := range edits {
+		tampered := entry
+		edit(&tampered)
+		if verifies(&tampered) {
+			t.Errorf("editing the %s goes unnoticed", name)
+		}
+	}
+
+	// Redaction drops the snapshots but keeps the digests, so the entry
+	// still verifies.
+	redacted := entry
+	now := time.Now()
+	redacted.Before, redacted.After, redacted.RedactedAt = nil, nil, &now
+	if !verifies(&redacted) {
+		t.Error("redacted entry does not verify")
+	}
+}
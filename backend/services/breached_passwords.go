@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const breachPrefixLength = 5
+
+// BreachedPasswords looks passwords up in an offline copy of a breach
+// corpus in the Have I Been Pwned format, using the same k-anonymity range
+// lookup as the online API: only the lines sharing the first five hex
+// characters of the SHA-1 are read. Path is either a directory of range
+// files named by prefix (as produced by the official downloader, lines are
+// SUFFIX:COUNT) or a single file of full HASH:COUNT lines sorted by hash.
+type BreachedPasswords struct {
+	path  string
+	isDir bool
+}
+
+func NewBreachedPasswords(path string) (*BreachedPasswords, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password corpus: %w", err)
+	}
+	return &BreachedPasswords{path: path, isDir: info.IsDir()}, nil
+}
+
+// Count returns how many times password appears in the corpus.
+func (b *BreachedPasswords) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachPrefixLength], hash[breachPrefixLength:]
+
+	if b.isDir {
+		return b.countInRangeFile(prefix, suffix)
+	}
+	return b.countInSortedFile(prefix, hash)
+}
+
+func (b *BreachedPasswords) countInRangeFile(prefix, suffix string) (int, error) {
+	file, err := os.Open(filepath.Join(b.path, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.Open(filepath.Join(b.path, prefix+".txt"))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if count, ok := matchBreachLine(scanner.Text(), suffix); ok {
+			return count, nil
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// countInSortedFile binary searches for the start of the prefix's range and
+// scans only that range.
+func (b *BreachedPasswords) countInSortedFile(prefix, hash string) (int, error) {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := lineAtOrAfter(file, mid, info.Size())
+		if err != nil {
+			return 0, err
+		}
+		if start >= info.Size() || strings.ToUpper(line) >= prefix {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	start, _, err := lineAtOrAfter(file, lo, info.Size())
+	if err != nil {
+		return 0, err
+	}
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(strings.ToUpper(line), prefix) {
+			break
+		}
+		if count, ok := matchBreachLine(line, hash); ok {
+			return count, nil
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// lineAtOrAfter returns the first line starting at or after offset.
+func lineAtOrAfter(file *os.File, offset, size int64) (int64, string, error) {
+	if offset >= size {
+		return size, "", nil
+	}
+
+	start := offset
+	if offset > 0 {
+		// Step back one byte so a line starting exactly at offset is kept.
+		start = offset - 1
+	}
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return 0, "", err
+	}
+
+	reader := bufio.NewReader(file)
+	if offset > 0 {
+		skipped, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return size, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start += int64(len(skipped))
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	return start, strings.TrimRight(line, "\r\n"), nil
+}
+
+func matchBreachLine(line, hash string) (int, bool) {
+	candidate, countText, found := strings.Cut(strings.TrimSpace(line), ":")
+	if !found || !strings.EqualFold(candidate, hash) {
+		return 0, false
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(countText))
+	if err != nil {
+		count = 1
+	}
+	return count, true
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"opendataug.org/models"
+)
+
+const (
+	changeBusPollInterval = 2 * time.Second
+	changeBusBatch        = 500
+	// changeSubscriptionBuffer is how many changes a subscriber may fall
+	// behind by before it is disconnected.
+	changeSubscriptionBuffer = 256
+)
+
+// ChangeSource reads the change feed; ChangeFeedController implements it.
+type ChangeSource interface {
+	Latest() (int64, error)
+	Changes(since int64, limit int) (*models.ChangeFeedResponse, error)
+}
+
+// ChangeFilter selects the changes a subscriber receives. An empty filter
+// matches everything.
+type ChangeFilter struct {
+	Levels []string `json:"levels,omitempty"`
+	// Scope limits changes to a unit and everything below it.
+	Scope *models.UnitRef `json:"scope,omitempty"`
+}
+
+// ChangeSubscription receives changes from a ChangeBus until it is closed.
+// The bus closes Events itself when the subscriber falls too far behind or
+// the bus stops; the client should then reconnect and resume from the last
+// sequence seen.
+type ChangeSubscription struct {
+	Filter ChangeFilter
+	events chan models.ChangeFeedEntry
+	bus    *ChangeBus
+}
+
+func (s *ChangeSubscription) Events() <-chan models.ChangeFeedEntry {
+	return s.events
+}
+
+func (s *ChangeSubscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subscribers[s]; ok {
+		delete(s.bus.subscribers, s)
+		close(s.events)
+	}
+}
+
+// ChangeBus fans unit changes out to live subscribers. It reads committed
+// changes from the change feed rather than taking them from handlers
+// directly, so subscribers only ever see changes that committed, in
+// sequence order. Write handlers call Notify to have new changes published
+// at once; a short poll picks up writes made by other instances.
+type ChangeBus struct {
+	source      ChangeSource
+	authorizer  *Authorizer
+	wake        chan struct{}
+	mu          sync.Mutex
+	subscribers map[*ChangeSubscription]struct{}
+	sequence    int64
+	started     bool
+	stopped     bool
+}
+
+func NewChangeBus(db *gorm.DB, source ChangeSource) *ChangeBus {
+	return &ChangeBus{
+		source:      source,
+		authorizer:  NewAuthorizer(db),
+		wake:        make(chan struct{}, 1),
+		subscribers: map[*ChangeSubscription]struct{}{},
+	}
+}
+
+// Notify asks the bus to publish new changes without waiting for the next
+// poll.
+func (b *ChangeBus) Notify() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Notifier is middleware that calls Notify after every successful write
+// request, so changes reach subscribers as soon as the handler commits.
+func (b *ChangeBus) Notifier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if c.Writer.Status() < http.StatusBadRequest {
+			b.Notify()
+		}
+	}
+}
+
+func (b *ChangeBus) Subscribe(filter ChangeFilter) *ChangeSubscription {
+	subscription := &ChangeSubscription{
+		Filter: filter,
+		events: make(chan models.ChangeFeedEntry, changeSubscriptionBuffer),
+		bus:    b,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		close(subscription.events)
+		return subscription
+	}
+	b.subscribers[subscription] = struct{}{}
+	return subscription
+}
+
+// lineage returns the changed unit followed by its ancestors. The unit
+// itself may be deleted, so the walk starts from its parent.
+func (b *ChangeBus) lineage(entry *models.ChangeFeedEntry) ([]models.UnitRef, error) {
+	lineage := []models.UnitRef{{Level: entry.Level, Number: entry.Number}}
+	parentLevel := models.Levels[entry.Level].ParentLevel
+	if parentLevel == "" || entry.ParentNumber == "" {
+		return lineage, nil
+	}
+
+	ancestors, err := b.authorizer.Lineage(parentLevel, entry.ParentNumber)
+	if err != nil {
+		if errors.Is(err, ErrUnitNotFound) {
+			return lineage, nil
+		}
+		return nil, err
+	}
+	return append(lineage, ancestors...), nil
+}
+
+// Matches reports whether entry passes filter. lineage is resolved only
+// when the filter has a scope; pass nil to have it looked up.
+func (b *ChangeBus) Matches(filter ChangeFilter, entry *models.ChangeFeedEntry, lineage []models.UnitRef) (bool, error) {
+	if len(filter.Levels) > 0 && !slices.Contains(filter.Levels, entry.Level) {
+		return false, nil
+	}
+	if filter.Scope == nil {
+		return true, nil
+	}
+
+	if lineage == nil {
+		var err error
+		if lineage, err = b.lineage(entry); err != nil {
+			return false, err
+		}
+	}
+	return slices.Contains(lineage, *filter.Scope), nil
+}
+
+// Replay passes every change after since that matches filter to emit, for
+// subscribers resuming from a Last-Event-ID. It returns the sequence it
+// read up to.
+func (b *ChangeBus) Replay(since int64, filter ChangeFilter, emit func(models.ChangeFeedEntry) error) (int64, error) {
+	for {
+		page, err := b.source.Changes(since, changeBusBatch)
+		if err != nil {
+			return since, err
+		}
+		for i := range page.Changes {
+			ok, err := b.Matches(filter, &page.Changes[i], nil)
+			if err != nil {
+				return since, err
+			}
+			if ok {
+				if err := emit(page.Changes[i]); err != nil {
+					return since, err
+				}
+			}
+		}
+		since = page.Next
+		if !page.HasMore {
+			return since, nil
+		}
+	}
+}
+
+// publish hands entry to every matching subscriber. Subscribers whose
+// buffer is full are dropped rather than slowing everyone else down.
+func (b *ChangeBus) publish(entry *models.ChangeFeedEntry) {
+	b.mu.Lock()
+	scoped := false
+	for subscription := range b.subscribers {
+		if subscription.Filter.Scope != nil {
+			scoped = true
+			break
+		}
+	}
+	b.mu.Unlock()
+
+	// Resolve the lineage once, outside the lock, for every scoped
+	// subscriber to share.
+	var lineage []models.UnitRef
+	if scoped {
+		var err error
+		if lineage, err = b.lineage(entry); err != nil {
+			log.Printf("change bus: %v", err)
+			lineage = []models.UnitRef{{Level: entry.Level, Number: entry.Number}}
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscription := range b.subscribers {
+		ok, _ := b.Matches(subscription.Filter, entry, lineage)
+		if !ok {
+			continue
+		}
+		select {
+		case subscription.events <- *entry:
+		default:
+			delete(b.subscribers, subscription)
+			close(subscription.events)
+		}
+	}
+}
+
+// poll publishes every change since the last poll. The first poll starts
+// from the end of the feed.
+func (b *ChangeBus) poll() error {
+	if !b.started {
+		latest, err := b.source.Latest()
+		if err != nil {
+			return err
+		}
+		b.sequence, b.started = latest, true
+		return nil
+	}
+
+	for {
+		page, err := b.source.Changes(b.sequence, changeBusBatch)
+		if err != nil {
+			return err
+		}
+		for i := range page.Changes {
+			b.publish(&page.Changes[i])
+		}
+		b.sequence = page.Next
+		if !page.HasMore {
+			return nil
+		}
+	}
+}
+
+// closeSubscriptions ends every subscription and turns new ones away, so
+// open streams return and the server can finish shutting down.
+func (b *ChangeBus) closeSubscriptions() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+	for subscription := range b.subscribers {
+		delete(b.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+// Run publishes changes as writes are notified, or every poll interval,
+// until stop is closed. Stopping closes every subscription; streams must
+// end before the HTTP server can drain, so stop the bus first.
+func (b *ChangeBus) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(changeBusPollInterval)
+	defer ticker.Stop()
+
+	if err := b.poll(); err != nil {
+		log.Printf("change bus: %v", err)
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.wake:
+		case <-stop:
+			b.closeSubscriptions()
+			return
+		}
+
+		if err := b.poll(); err != nil {
+			log.Printf("change bus: %v", err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"opendataug.org/models"
+)
+
+// memoryChangeSource is a change feed held in memory.
+type memoryChangeSource struct {
+	mu      sync.Mutex
+	changes []models.ChangeFeedEntry
+}
+
+func (s *memoryChangeSource) add(entry models.ChangeFeedEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.Sequence = int64(len(s.changes) + 1)
+	s.changes = append(s.changes, entry)
+}
+
+func (s *memoryChangeSource) Latest() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.changes)), nil
+}
+
+func (s *memoryChangeSource) Changes(since int64, limit int) (*models.ChangeFeedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	page := &models.ChangeFeedResponse{Since: since, Next: since}
+	for _, entry := range s.changes {
+		if entry.Sequence <= since {
+			continue
+		}
+		if len(page.Changes) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Changes = append(page.Changes, entry)
+		page.Next = entry.Sequence
+	}
+	return page, nil
+}
+
+func TestChangeBusClosesSubscriptionsOnStop(t *testing.T) {
+	source := &memoryChangeSource{}
+	source.add(models.ChangeFeedEntry{Level: models.LevelRegion, Number: "r0", Name: "Before start"})
+	bus := NewChangeBus(nil, source)
+
+	districts := bus.Subscribe(ChangeFilter{Levels: []string{models.LevelDistrict}})
+	everything := bus.Subscribe(ChangeFilter{})
+
+	// Take the starting point now, so the change below cannot race Run's
+	// first poll and be mistaken for history.
+	if err := bus.poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		bus.Run(stop)
+		close(done)
+	}()
+
+	receive := func(subscription *ChangeSubscription) (models.ChangeFeedEntry, bool) {
+		t.Helper()
+		select {
+		case entry, ok := <-subscription.Events():
+			return entry, ok
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the bus")
+			return models.ChangeFeedEntry{}, false
+		}
+	}
+
+	source.add(models.ChangeFeedEntry{Level: models.LevelDistrict, Number: "d1", Name: "Gulu"})
+	bus.Notify()
+
+	if entry, ok := receive(everything); !ok || entry.Number != "d1" {
+		t.Fatalf("everything got %+v, %v; changes before start must not be replayed", entry, ok)
+	}
+	if entry, ok := receive(districts); !ok || entry.Number != "d1" {
+		t.Fatalf("districts got %+v, %v", entry, ok)
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after stop")
+	}
+
+	for name, subscription := range map[string]*ChangeSubscription{"districts": districts, "everything": everything} {
+		if _, ok := receive(subscription); ok {
+			t.Errorf("%s subscription still open after stop", name)
+		}
+		subscription.Close()
+	}
+
+	late := bus.Subscribe(ChangeFilter{})
+	if _, ok := receive(late); ok {
+		t.Error("subscription made after stop is open")
+	}
+	late.Close()
+}
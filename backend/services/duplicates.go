@@ -0,0 +1,209 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"opendataug.org/models"
+)
+
+// DefaultDuplicateScore is the lowest similarity reported as a duplicate
+// candidate unless the caller asks for another.
+const DefaultDuplicateScore = 0.8
+
+// genericNameWords are words that sources add to a name to say what kind
+// of unit it is. "Kyabazinga" and "Kyabazinga Village" name the same place.
+var genericNameWords = map[string]bool{
+	"village":      true,
+	"vill":         true,
+	"cell":         true,
+	"ward":         true,
+	"parish":       true,
+	"subcounty":    true,
+	"county":       true,
+	"district":     true,
+	"division":     true,
+	"municipality": true,
+	"region":       true,
+	"tc":           true,
+}
+
+// DuplicateUnit is one side of a candidate pair.
+type DuplicateUnit struct {
+	Number   string `json:"number"`
+	Name     string `json:"name"`
+	Children int64  `json:"children"`
+}
+
+// DuplicateCandidate is a pair of units under the same parent whose names
+// are similar enough to be the same place.
+type DuplicateCandidate struct {
+	Level        string        `json:"level"`
+	ParentNumber string        `json:"parent_number,omitempty"`
+	Score        float64       `json:"score"`
+	Reasons      []string      `json:"reasons"`
+	A            DuplicateUnit `json:"a"`
+	B            DuplicateUnit `json:"b"`
+}
+
+type DuplicateCandidateListResponse struct {
+	Data  []DuplicateCandidate `json:"data"`
+	Page  int                  `json:"page"`
+	Limit int                  `json:"limit"`
+	Total int64                `json:"total"`
+}
+
+// coreName reduces a name to the letters and digits that identify the
+// place, dropping words like "village" that only describe its kind.
+func coreName(name string) string {
+	lower := strings.ToLower(name)
+	lower = strings.NewReplacer("sub-county", "subcounty", "sub county", "subcounty", "town council", "tc", "t.c.", "tc", "t.c", "tc").Replace(lower)
+
+	var words []string
+	for _, word := range strings.FieldsFunc(lower, func(r rune) bool {
+		return normalizeName(string(r)) == ""
+	}) {
+		if !genericNameWords[word] {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return normalizeName(name)
+	}
+	return strings.Join(words, "")
+}
+
+// phoneticKey is a Soundex-style code over the whole name, without
+// Soundex's four-character cut-off, so spellings that sound alike such as
+// "Kyabazinga" and "Kiabazinga" share a key.
+func phoneticKey(name string) string {
+	codes := map[rune]byte{
+		'b': '1', 'f': '1', 'p': '1', 'v': '1',
+		'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+		'd': '3', 't': '3',
+		'l': '4',
+		'm': '5', 'n': '5',
+		'r': '6',
+	}
+
+	var key []byte
+	var last byte
+	for i, r := range name {
+		code, ok := codes[r]
+		if i == 0 {
+			key = append(key, byte(r))
+			last = code
+			continue
+		}
+		if !ok {
+			// Vowels separate repeated codes; h, w and y do not.
+			if r != 'h' && r != 'w' && r != 'y' {
+				last = 0
+			}
+			continue
+		}
+		if code != last {
+			key = append(key, code)
+		}
+		last = code
+	}
+	return string(key)
+}
+
+// nameSimilarity scores how likely two names are to refer to the same
+// place, from 0 to 1, with the reasons behind the score.
+func nameSimilarity(a, b string) (float64, []string) {
+	ca, cb := coreName(a), coreName(b)
+	if ca == "" || cb == "" {
+		return 0, nil
+	}
+	if ca == cb {
+		if normalizeName(a) == normalizeName(b) {
+			return 1, []string{"same name after removing spacing and punctuation"}
+		}
+		return 0.95, []string{"same name after removing words like \"village\""}
+	}
+
+	var reasons []string
+	distance := editDistance(ca, cb)
+	score := 1 - float64(distance)/float64(max(len([]rune(ca)), len([]rune(cb))))
+	if distance <= 2 {
+		reasons = append(reasons, fmt.Sprintf("%d letter(s) apart", distance))
+	}
+
+	// Sounding alike only counts for names that are already close in
+	// spelling; short codes collide too easily on their own.
+	if score >= 0.6 && len(ca) >= 4 && phoneticKey(ca) == phoneticKey(cb) {
+		score = max(score, 0.85)
+		reasons = append(reasons, "sound alike")
+	}
+
+	return math.Round(score*100) / 100, reasons
+}
+
+// DuplicateFinder looks for units that are probably the same place entered
+// twice under one parent.
+type DuplicateFinder struct {
+	db *gorm.DB
+}
+
+func NewDuplicateFinder(db *gorm.DB) *DuplicateFinder {
+	return &DuplicateFinder{db: db}
+}
+
+// Find returns candidate pairs at level scoring at least minScore, best
+// first. parentNumber limits the search to one parent's children.
+func (f *DuplicateFinder) Find(level, parentNumber string, minScore float64) ([]DuplicateCandidate, error) {
+	meta, ok := models.Levels[level]
+	if !ok {
+		return nil, fmt.Errorf("unknown level %q", level)
+	}
+
+	columns := "number, name"
+	if meta.ParentColumn != "" {
+		columns += ", " + meta.ParentColumn + " AS parent_number"
+	}
+	query := f.db.Table(meta.Table).Select(columns).Where("deleted_at IS NULL")
+	if parentNumber != "" && meta.ParentColumn != "" {
+		query = query.Where(meta.ParentColumn+" = ?", parentNumber)
+	}
+
+	var units []qualityUnit
+	if err := query.Order("number").Scan(&units).Error; err != nil {
+		return nil, err
+	}
+
+	scan := &qualityScan{units: map[string][]qualityUnit{level: units}}
+
+	candidates := []DuplicateCandidate{}
+	for _, group := range scan.siblings(level) {
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				a, b := group[i], group[j]
+				if numberedSiblings(a.Name, b.Name) {
+					continue
+				}
+				score, reasons := nameSimilarity(a.Name, b.Name)
+				if score < minScore {
+					continue
+				}
+				candidates = append(candidates, DuplicateCandidate{
+					Level:        level,
+					ParentNumber: a.ParentNumber,
+					Score:        score,
+					Reasons:      reasons,
+					A:            DuplicateUnit{Number: a.Number, Name: a.Name},
+					B:            DuplicateUnit{Number: b.Number, Name: b.Name},
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates, nil
+}
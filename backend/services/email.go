@@ -2,67 +2,129 @@ package services
 
 import (
 	"bytes"
-	"html/template"
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"path/filepath"
+	texttemplate "text/template"
 
-	"github.com/resend/resend-go/v2"
+	"gorm.io/gorm"
 )
 
 const (
-	EmailTypeRegistration = "registration"
-	EmailTypeInvitation   = "invitation"
-	EmailTypeResetPwd     = "password_reset"
+	EmailTypeRegistration    = "registration"
+	EmailTypeInvitation      = "invitation"
+	EmailTypeResetPwd        = "password_reset"
+	EmailTypeSecurityAlert   = "security_alert"
+	EmailTypeAccountLocked   = "account_locked"
+	EmailTypeMFADisabled     = "mfa_disabled"
+	EmailTypeAccountDeletion = "account_deletion"
+	EmailTypeEmailChange     = "email_change"
+	EmailTypeEmailChanging   = "email_change_notice"
+	EmailTypeProposalReview  = "proposal_reviewed"
 )
 
+// emailTemplateFiles maps each email type to its template name in the
+// templates directory. Every name has an .html and a plain text .txt file.
+var emailTemplateFiles = map[string]string{
+	EmailTypeRegistration:    "registration",
+	EmailTypeInvitation:      "invitation",
+	EmailTypeResetPwd:        "reset_password",
+	EmailTypeSecurityAlert:   "security_alert",
+	EmailTypeAccountLocked:   "account_locked",
+	EmailTypeMFADisabled:     "mfa_disabled",
+	EmailTypeAccountDeletion: "account_deletion",
+	EmailTypeEmailChange:     "email_change",
+	EmailTypeEmailChanging:   "email_change_notice",
+	EmailTypeProposalReview:  "proposal_reviewed",
+}
+
+var ErrEmailOutboxNotConfigured = errors.New("email outbox is not configured")
+
 type Info struct {
-	Email       string
-	Token       string
-	MailType    string
-	UserName    string
-	CurrentYear int
-	Type        string
+	Email            string
+	Token            string
+	MailType         string
+	UserName         string
+	OrganizationName string
+	LockedUntil      string
+	DeletionDate     string
+	NewEmail         string
+	ProposalSummary  string
+	ProposalStatus   string
+	ReviewComment    string
+	CurrentYear      int
+	Type             string
 }
 
+// SendEmail queues the email for delivery by the outbox worker. It only
+// fails when the email cannot be rendered or stored, never because the
+// provider is down.
 func (info Info) SendEmail() error {
-	client := resend.NewClient(os.Getenv("RESEND_API_KEY"))
-
-	templateURL := ""
-	switch info.Type {
-	case EmailTypeRegistration:
-		templateURL = "./templates/registration.html"
-	case EmailTypeInvitation:
-		templateURL = "./templates/invitation.html"
-	case EmailTypeResetPwd:
-		templateURL = "./templates/reset_password.html"
+	outbox := DefaultEmailOutbox()
+	if outbox == nil {
+		return ErrEmailOutboxNotConfigured
 	}
+	return outbox.Enqueue(nil, info)
+}
 
-	t, err := template.ParseFiles(templateURL)
-	if err != nil {
-		log.Println(err)
-		return err
+// QueueEmail queues the email inside tx, so it is only sent if tx commits.
+func (info Info) QueueEmail(tx *gorm.DB) error {
+	outbox := DefaultEmailOutbox()
+	if outbox == nil {
+		return ErrEmailOutboxNotConfigured
 	}
+	return outbox.Enqueue(tx, info)
+}
 
-	buff := new(bytes.Buffer)
-	if err := t.Execute(buff, info); err != nil {
-		log.Println(err)
-		return err
-	}
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
 
-	result := buff.String()
+// EmailTemplates holds every email template, parsed once at startup.
+type EmailTemplates struct {
+	templates map[string]emailTemplate
+}
 
-	params := &resend.SendEmailRequest{
-		To:      []string{info.Email},
-		From:    os.Getenv("FROM_EMAIL"),
-		Html:    result,
-		Subject: info.MailType,
+// LoadEmailTemplates parses the HTML and plain text template of every
+// email type from dir. A missing or broken template fails startup rather
+// than the first send.
+func LoadEmailTemplates(dir string) (*EmailTemplates, error) {
+	loaded := &EmailTemplates{templates: make(map[string]emailTemplate, len(emailTemplateFiles))}
+	for emailType, name := range emailTemplateFiles {
+		html, err := htmltemplate.ParseFiles(filepath.Join(dir, name+".html"))
+		if err != nil {
+			return nil, fmt.Errorf("email templates: %w", err)
+		}
+		text, err := texttemplate.ParseFiles(filepath.Join(dir, name+".txt"))
+		if err != nil {
+			return nil, fmt.Errorf("email templates: %w", err)
+		}
+		loaded.templates[emailType] = emailTemplate{html: html, text: text}
 	}
+	return loaded, nil
+}
 
-	_, err = client.Emails.Send(params)
-	if err != nil {
-		log.Println("Error sending email:", err)
-		return err
+// Render produces the message for info.
+func (t *EmailTemplates) Render(info Info) (*Message, error) {
+	tmpl, ok := t.templates[info.Type]
+	if !ok {
+		return nil, fmt.Errorf("email templates: unknown email type %q", info.Type)
 	}
 
-	return nil
+	var html, text bytes.Buffer
+	if err := tmpl.html.Execute(&html, info); err != nil {
+		return nil, fmt.Errorf("email templates: render %s html: %w", info.Type, err)
+	}
+	if err := tmpl.text.Execute(&text, info); err != nil {
+		return nil, fmt.Errorf("email templates: render %s text: %w", info.Type, err)
+	}
+
+	return &Message{
+		To:      info.Email,
+		Subject: info.MailType,
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
 }
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+const (
+	outboxPollInterval = 5 * time.Second
+	outboxSendTimeout  = 30 * time.Second
+	outboxBaseDelay    = 30 * time.Second
+	outboxMaxDelay     = time.Hour
+	// OutboxMaxAttempts is how many deliveries are tried, about a day of
+	// retries with the backoff above, before an email is marked failed.
+	OutboxMaxAttempts = 30
+	// OutboxRetention is how long sent and failed emails are kept, without
+	// their bodies, to answer "did my email go out?".
+	OutboxRetention     = 30 * 24 * time.Hour
+	outboxPurgeInterval = time.Hour
+)
+
+var defaultEmailOutbox *EmailOutbox
+
+// DefaultEmailOutbox returns the outbox registered by LoadEmailOutbox.
+func DefaultEmailOutbox() *EmailOutbox {
+	return defaultEmailOutbox
+}
+
+// EmailOutbox stores rendered emails in the database and delivers them in
+// the background, so a provider outage delays email instead of failing the
+// request that triggered it.
+type EmailOutbox struct {
+	db        *gorm.DB
+	mailer    Mailer
+	templates *EmailTemplates
+	wake      chan struct{}
+}
+
+func NewEmailOutbox(db *gorm.DB, mailer Mailer, templates *EmailTemplates) *EmailOutbox {
+	return &EmailOutbox{
+		db:        db,
+		mailer:    mailer,
+		templates: templates,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// LoadEmailOutbox builds the outbox from the environment and the templates
+// in templateDir, and makes it the default used by Info.SendEmail.
+func LoadEmailOutbox(db *gorm.DB, templateDir string) (*EmailOutbox, error) {
+	mailer, err := NewMailerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	templates, err := LoadEmailTemplates(templateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultEmailOutbox = NewEmailOutbox(db, mailer, templates)
+	return defaultEmailOutbox, nil
+}
+
+// Enqueue renders info and stores it for delivery. With a non-nil tx the
+// email is written in that transaction and only goes out if it commits.
+func (o *EmailOutbox) Enqueue(tx *gorm.DB, info Info) error {
+	msg, err := o.templates.Render(info)
+	if err != nil {
+		return err
+	}
+
+	if tx == nil {
+		tx = o.db
+	}
+	if err := tx.Create(&models.OutboxEmail{
+		Number:        commons.UUIDGenerator(),
+		Type:          info.Type,
+		Recipient:     msg.To,
+		Subject:       msg.Subject,
+		HTMLBody:      msg.HTML,
+		TextBody:      msg.Text,
+		Status:        models.OutboxEmailPending,
+		NextAttemptAt: time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+
+	// Nudge the worker; an email queued in an open transaction is picked
+	// up on the next poll instead.
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// retryDelay doubles from outboxBaseDelay with each failed attempt, capped
+// at outboxMaxDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxDelay)
+}
+
+// deliverNext sends the oldest due email, if any. Rows are claimed with
+// SKIP LOCKED so several instances can run the worker side by side. It
+// reports whether an email was found.
+func (o *EmailOutbox) deliverNext(now time.Time) (bool, error) {
+	found := false
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		var email models.OutboxEmail
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxEmailPending, now).
+			Order("next_attempt_at").
+			First(&email).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		found = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), outboxSendTimeout)
+		defer cancel()
+
+		attempts := email.Attempts + 1
+		updates := map[string]interface{}{"attempts": attempts}
+
+		sendErr := o.mailer.Send(ctx, &Message{
+			To:      email.Recipient,
+			Subject: email.Subject,
+			HTML:    email.HTMLBody,
+			Text:    email.TextBody,
+		})
+		switch {
+		case sendErr == nil:
+			sentAt := time.Now()
+			updates["status"] = models.OutboxEmailSent
+			updates["sent_at"] = &sentAt
+			updates["last_error"] = ""
+			updates["html_body"] = ""
+			updates["text_body"] = ""
+		case attempts >= OutboxMaxAttempts:
+			log.Printf("email outbox: giving up on %s to %s after %d attempts: %v", email.Number, email.Recipient, attempts, sendErr)
+			updates["status"] = models.OutboxEmailFailed
+			updates["last_error"] = sendErr.Error()
+			updates["html_body"] = ""
+			updates["text_body"] = ""
+		default:
+			log.Printf("email outbox: delivery of %s to %s failed, retrying: %v", email.Number, email.Recipient, sendErr)
+			updates["next_attempt_at"] = time.Now().Add(retryDelay(attempts))
+			updates["last_error"] = sendErr.Error()
+		}
+
+		return tx.Model(&email).Updates(updates).Error
+	})
+	return found, err
+}
+
+// Flush delivers every email that is due at now and returns how many it
+// attempted.
+func (o *EmailOutbox) Flush(now time.Time) (int, error) {
+	attempted := 0
+	for {
+		found, err := o.deliverNext(now)
+		if err != nil || !found {
+			return attempted, err
+		}
+		attempted++
+	}
+}
+
+// Purge deletes sent and failed emails older than OutboxRetention and
+// returns how many were removed. It also blanks any finished email still
+// holding its bodies, such as rows sent before bodies were cleared on
+// delivery.
+func (o *EmailOutbox) Purge(now time.Time) (int64, error) {
+	finished := []string{models.OutboxEmailSent, models.OutboxEmailFailed}
+
+	if err := o.db.Model(&models.OutboxEmail{}).
+		Where("status IN ? AND (html_body <> '' OR text_body <> '')", finished).
+		Updates(map[string]interface{}{"html_body": "", "text_body": ""}).Error; err != nil {
+		return 0, err
+	}
+
+	result := o.db.Unscoped().
+		Where("status IN ? AND updated_at < ?", finished, now.Add(-OutboxRetention)).
+		Delete(&models.OutboxEmail{})
+	return result.RowsAffected, result.Error
+}
+
+// Run delivers queued email as it is enqueued or falls due, and purges old
+// email hourly. It returns once stop is closed and any flush under way has
+// finished; email still queued goes out on the next start.
+func (o *EmailOutbox) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	purge := time.NewTicker(outboxPurgeInterval)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-o.wake:
+		case <-purge.C:
+			if purged, err := o.Purge(time.Now()); err != nil {
+				log.Printf("email outbox: purge: %v", err)
+			} else if purged > 0 {
+				log.Printf("email outbox: purged %d old emails", purged)
+			}
+			continue
+		case <-stop:
+			return
+		}
+
+		if _, err := o.Flush(time.Now()); err != nil {
+			log.Printf("email outbox: %v", err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"opendataug.org/commons"
+	"opendataug.org/database/dbtest"
+	"opendataug.org/models"
+)
+
+type stubMailer struct {
+	err  error
+	sent []*Message
+}
+
+func (m *stubMailer) Send(_ context.Context, msg *Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, outboxBaseDelay},
+		{2, 2 * outboxBaseDelay},
+		{3, 4 * outboxBaseDelay},
+		{7, 32 * time.Minute},
+		{8, outboxMaxDelay},
+		{OutboxMaxAttempts, outboxMaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestEmailOutboxClearsBodies(t *testing.T) {
+	db := dbtest.Open(t)
+	mailer := &stubMailer{}
+	outbox := NewEmailOutbox(db.DB, mailer, nil)
+	now := time.Now()
+
+	queue := func(recipient string, attempts int) string {
+		email := models.OutboxEmail{
+			Number:        commons.UUIDGenerator(),
+			Type:          "password_reset",
+			Recipient:     recipient,
+			Subject:       "Reset your password",
+			HTMLBody:      "<a href=\"/reset?token=secret\">reset</a>",
+			TextBody:      "/reset?token=secret",
+			Status:        models.OutboxEmailPending,
+			Attempts:      attempts,
+			NextAttemptAt: now.Add(-time.Minute),
+		}
+		if err := db.DB.Create(&email).Error; err != nil {
+			t.Fatal(err)
+		}
+		return email.Number
+	}
+	load := func(number string) models.OutboxEmail {
+		var email models.OutboxEmail
+		if err := db.DB.Unscoped().Where("number = ?", number).First(&email).Error; err != nil {
+			t.Fatal(err)
+		}
+		return email
+	}
+
+	sent := queue("sent@example.org", 0)
+	if _, err := outbox.Flush(now); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].Text != "/reset?token=secret" {
+		t.Fatalf("sent %+v", mailer.sent)
+	}
+	if email := load(sent); email.Status != models.OutboxEmailSent || email.HTMLBody != "" || email.TextBody != "" {
+		t.Errorf("sent email = %s with bodies %q / %q", email.Status, email.HTMLBody, email.TextBody)
+	}
+
+	mailer.err = errors.New("provider down")
+	retrying := queue("retry@example.org", 0)
+	failed := queue("failed@example.org", OutboxMaxAttempts-1)
+	if _, err := outbox.Flush(now); err != nil {
+		t.Fatal(err)
+	}
+	if email := load(retrying); email.Status != models.OutboxEmailPending || email.TextBody == "" {
+		t.Errorf("retrying email = %s, bodies must be kept for the retry", email.Status)
+	}
+	if email := load(failed); email.Status != models.OutboxEmailFailed || email.HTMLBody != "" || email.TextBody != "" {
+		t.Errorf("failed email = %s with bodies %q / %q", email.Status, email.HTMLBody, email.TextBody)
+	}
+
+	// Rows finished before bodies were cleared are blanked by the purge,
+	// and finished rows past the retention period are deleted.
+	legacy := queue("legacy@example.org", 0)
+	if err := db.DB.Model(&models.OutboxEmail{}).Where("number = ?", legacy).Update("status", models.OutboxEmailSent).Error; err != nil {
+		t.Fatal(err)
+	}
+	purged, err := outbox.Purge(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Errorf("purged %d recent emails", purged)
+	}
+	if email := load(legacy); email.HTMLBody != "" || email.TextBody != "" {
+		t.Errorf("legacy email still holds its bodies")
+	}
+
+	purged, err = outbox.Purge(now.Add(OutboxRetention + time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 3 {
+		t.Errorf("purged %d emails, want the 3 finished ones", purged)
+	}
+	if email := load(retrying); email.Status != models.OutboxEmailPending {
+		t.Errorf("pending email was purged")
+	}
+}
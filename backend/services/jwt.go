@@ -1,7 +1,6 @@
 package services
 
 import (
-	"encoding/base64"
 	"fmt"
 	"os"
 	"strings"
@@ -25,21 +24,37 @@ type TokenDetails struct {
 const (
 	AccessTokenDuration  = time.Minute * 15
 	RefreshTokenDuration = time.Hour * 24 * 7
-	MaxTokens            = 5
+	// MFAChallengeDuration is how long a user has to enter their second
+	// factor after a correct password.
+	MFAChallengeDuration = time.Minute * 5
+	// EmailChangeDuration is how long the link confirming a new email
+	// address stays valid.
+	EmailChangeDuration = time.Hour * 24
+	MaxTokens           = 5
 )
 
-type JWTService struct{}
+type JWTService struct {
+	keys *commons.KeyManager
+}
 
 func NewJWTService() *JWTService {
-	return &JWTService{}
+	return &JWTService{
+		keys: commons.DefaultKeyManager(),
+	}
 }
 
-func (s *JWTService) CreateToken(userNumber string, userRole string) (*TokenDetails, error) {
-	privateKey := os.Getenv("ACCESS_TOKEN_PRIVATE_KEY")
-	if privateKey == "" {
-		return nil, fmt.Errorf("missing ACCESS_TOKEN_PRIVATE_KEY environment variable")
+func (s *JWTService) sign(claims jwt.MapClaims) (string, error) {
+	kid, key, err := s.keys.SigningKey()
+	if err != nil {
+		return "", err
 	}
 
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+func (s *JWTService) CreateToken(userNumber string, userRole string) (*TokenDetails, error) {
 	now := time.Now().UTC()
 	td := &TokenDetails{
 		AccessToken:           new(string),
@@ -54,16 +69,6 @@ func (s *JWTService) CreateToken(userNumber string, userRole string) (*TokenDeta
 	*td.AccessTokenExpiresIn = now.Add(AccessTokenDuration).Unix()
 	*td.RefreshTokenExpiresIn = now.Add(RefreshTokenDuration).Unix()
 
-	decodedPrivateKey, err := base64.StdEncoding.DecodeString(privateKey)
-	if err != nil {
-		return nil, fmt.Errorf("could not decode token private key: %w", err)
-	}
-
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(decodedPrivateKey)
-	if err != nil {
-		return nil, fmt.Errorf("create: parse token private key: %w", err)
-	}
-
 	baseURL := os.Getenv("BASE_URL")
 
 	accessClaims := jwt.MapClaims{
@@ -90,12 +95,13 @@ func (s *JWTService) CreateToken(userNumber string, userRole string) (*TokenDeta
 		"user_role":   userRole,
 	}
 
-	*td.AccessToken, err = jwt.NewWithClaims(jwt.SigningMethodRS256, accessClaims).SignedString(key)
+	var err error
+	*td.AccessToken, err = s.sign(accessClaims)
 	if err != nil {
 		return nil, fmt.Errorf("create: sign access token: %w", err)
 	}
 
-	*td.RefreshToken, err = jwt.NewWithClaims(jwt.SigningMethodRS256, refreshClaims).SignedString(key)
+	*td.RefreshToken, err = s.sign(refreshClaims)
 	if err != nil {
 		return nil, fmt.Errorf("create: sign refresh token: %w", err)
 	}
@@ -103,28 +109,60 @@ func (s *JWTService) CreateToken(userNumber string, userRole string) (*TokenDeta
 	return td, nil
 }
 
-func (s JWTService) ValidateToken(tokenString string) (jwt.MapClaims, error) {
-	publicKey := os.Getenv("ACCESS_TOKEN_PUBLIC_KEY")
-	if publicKey == "" {
-		return nil, fmt.Errorf("missing ACCESS_TOKEN_PUBLIC_KEY environment variable")
-	}
+// CreateMFAChallenge issues the short-lived token returned by a password
+// sign-in when the account has two-factor authentication enabled. It only
+// proves the password step and is exchanged for a session at
+// /auth/mfa/verify.
+func (s *JWTService) CreateMFAChallenge(userNumber string, userRole string) (string, int64, error) {
+	now := time.Now().UTC()
+	expiresAt := now.Add(MFAChallengeDuration).Unix()
+	baseURL := os.Getenv("BASE_URL")
 
-	decodedPublicKey, err := base64.StdEncoding.DecodeString(publicKey)
+	token, err := s.sign(jwt.MapClaims{
+		"user_number": userNumber,
+		"token_uuid":  commons.UUIDGenerator(),
+		"exp":         expiresAt,
+		"iat":         now.Unix(),
+		"nbf":         now.Unix(),
+		"iss":         baseURL,
+		"aud":         baseURL,
+		"type":        "mfa_challenge",
+		"user_role":   userRole,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("could not decode public key: %w", err)
+		return "", 0, fmt.Errorf("create: sign mfa challenge: %w", err)
 	}
 
-	key, err := jwt.ParseRSAPublicKeyFromPEM(decodedPublicKey)
+	return token, expiresAt, nil
+}
+
+// CreateEmailChangeToken signs the link sent to a new email address. The
+// token_uuid is the EmailChange number, which makes the link single use.
+func (s *JWTService) CreateEmailChangeToken(userNumber, userRole, changeNumber, newEmail string) (string, error) {
+	now := time.Now().UTC()
+	baseURL := os.Getenv("BASE_URL")
+
+	token, err := s.sign(jwt.MapClaims{
+		"user_number": userNumber,
+		"token_uuid":  changeNumber,
+		"new_email":   newEmail,
+		"exp":         now.Add(EmailChangeDuration).Unix(),
+		"iat":         now.Unix(),
+		"nbf":         now.Unix(),
+		"iss":         baseURL,
+		"aud":         baseURL,
+		"type":        "email_change",
+		"user_role":   userRole,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("validate: parse key: %w", err)
+		return "", fmt.Errorf("create: sign email change token: %w", err)
 	}
 
-	parsedToken, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %s", t.Header["alg"])
-		}
-		return key, nil
-	})
+	return token, nil
+}
+
+func (s JWTService) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	parsedToken, err := jwt.Parse(tokenString, s.keys.Keyfunc)
 
 	if err != nil {
 		return nil, fmt.Errorf("validating token: %w", err)
@@ -196,24 +234,7 @@ func (s *JWTService) ExtractTokenFromHeader(authHeader string) (string, error) {
 }
 
 func (s *JWTService) GenerateTokenWithClaims(claims jwt.MapClaims, tx *gorm.DB) (string, error) {
-	privateKeyBytes, err := base64.StdEncoding.DecodeString(os.Getenv("ACCESS_TOKEN_PRIVATE_KEY"))
-	if err != nil {
-		if tx != nil {
-			tx.Rollback()
-		}
-		return "", fmt.Errorf("failed to decode private key: %w", err)
-	}
-
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privateKeyBytes)
-	if err != nil {
-		if tx != nil {
-			tx.Rollback()
-		}
-		return "", fmt.Errorf("failed to parse private key: %w", err)
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	signedToken, err := token.SignedString(privateKey)
+	signedToken, err := s.sign(claims)
 	if err != nil {
 		if tx != nil {
 			tx.Rollback()
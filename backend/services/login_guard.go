@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+const (
+	LoginBackoffBase     = time.Second
+	LoginBackoffMax      = 5 * time.Minute
+	LoginLockoutDuration = 30 * time.Minute
+	// LoginFailureWindow is how long a failure is remembered. A quiet
+	// period this long starts the count again.
+	LoginFailureWindow = 24 * time.Hour
+)
+
+type throttlePolicy struct {
+	backoffAfter int
+	lockAfter    int
+}
+
+// IP limits are looser than account limits since many users can share an
+// address behind NAT.
+var throttlePolicies = map[string]throttlePolicy{
+	models.ThrottleKindAccount: {backoffAfter: 3, lockAfter: 10},
+	models.ThrottleKindIP:      {backoffAfter: 10, lockAfter: 50},
+}
+
+// LoginThrottledError is returned while an account or IP must wait before
+// trying again.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed sign-in attempts, try again in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("please wait %s before trying again", e.RetryAfter.Round(time.Second))
+}
+
+// LoginGuard slows down password guessing with per-account and per-IP
+// failure counters: exponential backoff after a few failures and a
+// temporary lockout after many.
+type LoginGuard struct {
+	db *gorm.DB
+}
+
+func NewLoginGuard(db *gorm.DB) *LoginGuard {
+	return &LoginGuard{db: db}
+}
+
+func normalizeThrottleKey(kind, key string) string {
+	if kind == models.ThrottleKindAccount {
+		return strings.ToLower(strings.TrimSpace(key))
+	}
+	return strings.TrimSpace(key)
+}
+
+// Check returns a *LoginThrottledError when either the account or the IP is
+// currently backing off or locked.
+func (g *LoginGuard) Check(email, ip string) error {
+	now := time.Now()
+
+	var throttles []models.LoginThrottle
+	if err := g.db.Where("(kind = ? AND key = ?) OR (kind = ? AND key = ?)",
+		models.ThrottleKindAccount, normalizeThrottleKey(models.ThrottleKindAccount, email),
+		models.ThrottleKindIP, normalizeThrottleKey(models.ThrottleKindIP, ip)).
+		Find(&throttles).Error; err != nil {
+		return err
+	}
+
+	var result *LoginThrottledError
+	for _, throttle := range throttles {
+		var wait time.Duration
+		locked := false
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			wait, locked = throttle.LockedUntil.Sub(now), true
+		} else if throttle.NextAttemptAt != nil && throttle.NextAttemptAt.After(now) {
+			wait = throttle.NextAttemptAt.Sub(now)
+		}
+		if wait == 0 {
+			continue
+		}
+		if result == nil || wait > result.RetryAfter {
+			result = &LoginThrottledError{RetryAfter: wait, Locked: locked}
+		}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return result
+}
+
+// RecordFailure counts a failed attempt against both the account and the IP.
+// It returns the account's lock expiry when this failure triggered a new
+// lockout, so the caller can notify the owner once.
+func (g *LoginGuard) RecordFailure(email, ip string) (*time.Time, error) {
+	var lockedUntil *time.Time
+
+	err := g.db.Transaction(func(tx *gorm.DB) error {
+		locked, err := g.recordFailure(tx, models.ThrottleKindAccount, email)
+		if err != nil {
+			return err
+		}
+		lockedUntil = locked
+
+		_, err = g.recordFailure(tx, models.ThrottleKindIP, ip)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lockedUntil, nil
+}
+
+func (g *LoginGuard) recordFailure(tx *gorm.DB, kind, key string) (*time.Time, error) {
+	key = normalizeThrottleKey(kind, key)
+	if key == "" {
+		return nil, nil
+	}
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{
+		Number: commons.UUIDGenerator(),
+		Kind:   kind,
+		Key:    key,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("kind = ? AND key = ?", kind, key).
+		First(&throttle).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expired := throttle.LastFailureAt == nil || now.Sub(*throttle.LastFailureAt) > LoginFailureWindow
+	lockServed := throttle.LockedUntil != nil && !throttle.LockedUntil.After(now)
+	if expired || lockServed {
+		throttle.Failures = 0
+		throttle.LockedUntil = nil
+	}
+
+	policy := throttlePolicies[kind]
+	throttle.Failures++
+	throttle.LastFailureAt = &now
+	throttle.NextAttemptAt = nil
+
+	if delay := loginBackoff(policy, throttle.Failures); delay > 0 {
+		next := now.Add(delay)
+		throttle.NextAttemptAt = &next
+	}
+
+	var newlyLocked *time.Time
+	if throttle.Failures >= policy.lockAfter && throttle.LockedUntil == nil {
+		until := now.Add(LoginLockoutDuration)
+		throttle.LockedUntil = &until
+		newlyLocked = &until
+	}
+
+	err := tx.Model(&throttle).Select("failures", "last_failure_at", "next_attempt_at", "locked_until").Updates(&throttle).Error
+	return newlyLocked, err
+}
+
+// loginBackoff is how long to wait after failures: nothing until the
+// policy's threshold, then LoginBackoffBase doubling up to LoginBackoffMax.
+func loginBackoff(policy throttlePolicy, failures int) time.Duration {
+	if failures < policy.backoffAfter {
+		return 0
+	}
+	return min(LoginBackoffBase<<min(failures-policy.backoffAfter, 20), LoginBackoffMax)
+}
+
+// RecordSuccess clears the account's failure count. The IP count is kept so
+// one valid login cannot launder a spray across many accounts.
+func (g *LoginGuard) RecordSuccess(email string) error {
+	_, err := g.reset(models.ThrottleKindAccount, email)
+	return err
+}
+
+// Unlock lifts any backoff or lockout on the account and reports whether
+// there was one.
+func (g *LoginGuard) Unlock(email string) (bool, error) {
+	return g.reset(models.ThrottleKindAccount, email)
+}
+
+func (g *LoginGuard) reset(kind, key string) (bool, error) {
+	result := g.db.Model(&models.LoginThrottle{}).
+		Where("kind = ? AND key = ? AND failures > 0", kind, normalizeThrottleKey(kind, key)).
+		Updates(map[string]interface{}{
+			"failures":        0,
+			"next_attempt_at": nil,
+			"locked_until":    nil,
+		})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"opendataug.org/database/dbtest"
+	"opendataug.org/models"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	account := throttlePolicies[models.ThrottleKindAccount]
+	ip := throttlePolicies[models.ThrottleKindIP]
+
+	tests := []struct {
+		name     string
+		policy   throttlePolicy
+		failures int
+		want     time.Duration
+	}{
+		{"account first failure", account, 1, 0},
+		{"account below threshold", account, 2, 0},
+		{"account at threshold", account, 3, time.Second},
+		{"account doubles", account, 4, 2 * time.Second},
+		{"account doubles again", account, 6, 8 * time.Second},
+		{"account capped", account, 12, LoginBackoffMax},
+		{"account far past cap", account, 500, LoginBackoffMax},
+		{"ip below threshold", ip, 9, 0},
+		{"ip at threshold", ip, 10, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := loginBackoff(tt.policy, tt.failures); got != tt.want {
+				t.Errorf("loginBackoff(%d) = %s, want %s", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeThrottleKey(t *testing.T) {
+	if got := normalizeThrottleKey(models.ThrottleKindAccount, "  Jane@Example.ORG "); got != "jane@example.org" {
+		t.Errorf("account key = %q", got)
+	}
+	if got := normalizeThrottleKey(models.ThrottleKindIP, " 2001:DB8::1 "); got != "2001:DB8::1" {
+		t.Errorf("ip key = %q", got)
+	}
+}
+
+func TestLoginGuard(t *testing.T) {
+	db := dbtest.Open(t)
+	guard := NewLoginGuard(db.DB)
+	policy := throttlePolicies[models.ThrottleKindAccount]
+
+	email, ip := "Jane@example.org", "192.0.2.1"
+	var lockedUntil *time.Time
+	for i := 1; i <= policy.lockAfter; i++ {
+		locked, err := guard.RecordFailure(email, ip)
+		if err != nil {
+			t.Fatalf("failure %d: %v", i, err)
+		}
+		if i < policy.lockAfter && locked != nil {
+			t.Fatalf("locked after %d failures, want %d", i, policy.lockAfter)
+		}
+		lockedUntil = locked
+
+		err = guard.Check(email, "198.51.100.1")
+		var throttled *LoginThrottledError
+		if i < policy.backoffAfter {
+			if err != nil {
+				t.Fatalf("throttled after %d failures: %v", i, err)
+			}
+			continue
+		}
+		if !errors.As(err, &throttled) {
+			t.Fatalf("not throttled after %d failures: %v", i, err)
+		}
+	}
+	if lockedUntil == nil {
+		t.Fatal("no lockout reported")
+	}
+
+	var throttled *LoginThrottledError
+	if err := guard.Check("JANE@example.org", "198.51.100.1"); !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("account not locked: %v", err)
+	}
+
+	unlocked, err := guard.Unlock(email)
+	if err != nil || !unlocked {
+		t.Fatalf("unlock = %v, %v", unlocked, err)
+	}
+	if err := guard.Check(email, "198.51.100.1"); err != nil {
+		t.Errorf("still throttled after unlock: %v", err)
+	}
+}
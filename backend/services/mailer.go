@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/resend/resend-go/v2"
+	"gopkg.in/gomail.v2"
+)
+
+// Message is a rendered email ready for delivery.
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers a single message. Implementations must be safe for
+// concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewMailerFromEnv picks the delivery backend named by MAIL_BACKEND:
+// "resend", "smtp", "file" or "log". When unset it uses Resend if
+// RESEND_API_KEY is present and logs messages otherwise.
+func NewMailerFromEnv() (Mailer, error) {
+	from := os.Getenv("FROM_EMAIL")
+
+	backend := os.Getenv("MAIL_BACKEND")
+	if backend == "" {
+		backend = "log"
+		if os.Getenv("RESEND_API_KEY") != "" {
+			backend = "resend"
+		}
+	}
+
+	switch backend {
+	case "resend":
+		apiKey := os.Getenv("RESEND_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("mailer: RESEND_API_KEY is required for the resend backend")
+		}
+		return NewResendMailer(apiKey, from), nil
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("mailer: SMTP_HOST is required for the smtp backend")
+		}
+		port := 587
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("mailer: invalid SMTP_PORT %q", value)
+			}
+			port = parsed
+		}
+		return NewSMTPMailer(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from), nil
+	case "file":
+		dir := os.Getenv("MAIL_FILE_DIR")
+		if dir == "" {
+			dir = "./tmp/mail"
+		}
+		return NewFileMailer(dir, from)
+	case "log":
+		return LogMailer{}, nil
+	default:
+		return nil, fmt.Errorf("mailer: unknown MAIL_BACKEND %q", backend)
+	}
+}
+
+// ResendMailer sends through the Resend API.
+type ResendMailer struct {
+	client *resend.Client
+	from   string
+}
+
+func NewResendMailer(apiKey, from string) *ResendMailer {
+	return &ResendMailer{client: resend.NewClient(apiKey), from: from}
+}
+
+func (m *ResendMailer) Send(ctx context.Context, msg *Message) error {
+	_, err := m.client.Emails.SendWithContext(ctx, &resend.SendEmailRequest{
+		From:    m.from,
+		To:      []string{msg.To},
+		Subject: msg.Subject,
+		Html:    msg.HTML,
+		Text:    msg.Text,
+	})
+	return err
+}
+
+// newMIMEMessage builds a multipart/alternative message with the plain text
+// part first, so clients prefer the HTML version when they can show it.
+func newMIMEMessage(from string, msg *Message) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", from)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	m.SetBody("text/plain", msg.Text)
+	m.AddAlternative("text/html", msg.HTML)
+	return m
+}
+
+// SMTPMailer sends through an SMTP relay, using STARTTLS when offered.
+type SMTPMailer struct {
+	dialer *gomail.Dialer
+	from   string
+}
+
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{dialer: gomail.NewDialer(host, port, username, password), from: from}
+}
+
+func (m *SMTPMailer) Send(_ context.Context, msg *Message) error {
+	return m.dialer.DialAndSend(newMIMEMessage(m.from, msg))
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]+`)
+
+// FileMailer writes each message to dir as an .eml file, for local
+// development and tests.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("mailer: create %s: %w", dir, err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(_ context.Context, msg *Message) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	file, err := os.OpenFile(filepath.Join(m.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := newMIMEMessage(m.from, msg).WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LogMailer prints the plain text version of each message to the log.
+type LogMailer struct{}
+
+func (LogMailer) Send(_ context.Context, msg *Message) error {
+	log.Printf("mailer: to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
@@ -0,0 +1,532 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	ProviderGoogle    = "google"
+	ProviderGitHub    = "github"
+	ProviderMicrosoft = "microsoft"
+
+	oidcHTTPTimeout = 10 * time.Second
+	// oidcKeyRefreshGap stops tokens with unknown kids from forcing a JWKS
+	// fetch on every callback.
+	oidcKeyRefreshGap = time.Minute
+)
+
+var ErrOIDCEmailUnverified = errors.New("identity provider did not return a verified email address")
+
+// OIDCProviderConfig describes one login provider. Providers that speak
+// OpenID Connect only need an Issuer; the endpoints are discovered. GitHub
+// is plain OAuth2 and uses the explicit URLs instead.
+type OIDCProviderConfig struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	GitHub   bool
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+}
+
+// OIDCIdentity is what a provider tells us about the person signing in.
+type OIDCIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+type OIDCProvider struct {
+	config OIDCProviderConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+func NewOIDCProvider(config OIDCProviderConfig) *OIDCProvider {
+	return &OIDCProvider{
+		config: config,
+		client: &http.Client{Timeout: oidcHTTPTimeout},
+	}
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *OIDCProvider) DisplayName() string {
+	return p.config.DisplayName
+}
+
+// OIDCRegistry holds the providers enabled through the environment.
+type OIDCRegistry struct {
+	providers    map[string]*OIDCProvider
+	order        []string
+	redirectBase string
+}
+
+// LoadOIDCProviders reads provider credentials from the environment. A
+// provider is enabled when its client ID and secret are both set. Any
+// OpenID Connect issuer, including a local mock, can be added through the
+// OIDC_GENERIC_* variables.
+func LoadOIDCProviders() *OIDCRegistry {
+	registry := &OIDCRegistry{
+		providers:    make(map[string]*OIDCProvider),
+		redirectBase: strings.TrimRight(os.Getenv("OIDC_REDIRECT_BASE_URL"), "/"),
+	}
+
+	registry.add(OIDCProviderConfig{
+		Name:         ProviderGoogle,
+		DisplayName:  "Google",
+		Issuer:       "https://accounts.google.com",
+		ClientID:     os.Getenv("OIDC_GOOGLE_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_GOOGLE_CLIENT_SECRET"),
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+
+	tenant := os.Getenv("OIDC_MICROSOFT_TENANT")
+	if tenant == "" {
+		tenant = "common"
+	}
+	registry.add(OIDCProviderConfig{
+		Name:         ProviderMicrosoft,
+		DisplayName:  "Microsoft",
+		Issuer:       "https://login.microsoftonline.com/" + tenant + "/v2.0",
+		ClientID:     os.Getenv("OIDC_MICROSOFT_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_MICROSOFT_CLIENT_SECRET"),
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+
+	registry.add(OIDCProviderConfig{
+		Name:         ProviderGitHub,
+		DisplayName:  "GitHub",
+		ClientID:     os.Getenv("OAUTH_GITHUB_CLIENT_ID"),
+		ClientSecret: os.Getenv("OAUTH_GITHUB_CLIENT_SECRET"),
+		Scopes:       []string{"read:user", "user:email"},
+		GitHub:       true,
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		APIURL:       "https://api.github.com",
+	})
+
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("OIDC_GENERIC_NAME")))
+	if name == "" {
+		name = "oidc"
+	}
+	displayName := os.Getenv("OIDC_GENERIC_DISPLAY_NAME")
+	if displayName == "" {
+		displayName = "Single sign-on"
+	}
+	scopes := strings.Fields(os.Getenv("OIDC_GENERIC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	if issuer := os.Getenv("OIDC_GENERIC_ISSUER"); issuer != "" {
+		registry.add(OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  displayName,
+			Issuer:       strings.TrimRight(issuer, "/"),
+			ClientID:     os.Getenv("OIDC_GENERIC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_GENERIC_CLIENT_SECRET"),
+			Scopes:       scopes,
+		})
+	}
+
+	return registry
+}
+
+func (r *OIDCRegistry) add(config OIDCProviderConfig) {
+	if config.ClientID == "" || config.ClientSecret == "" {
+		return
+	}
+	if _, exists := r.providers[config.Name]; exists {
+		return
+	}
+	r.providers[config.Name] = NewOIDCProvider(config)
+	r.order = append(r.order, config.Name)
+}
+
+func (r *OIDCRegistry) Get(name string) (*OIDCProvider, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Providers returns the enabled providers in a stable order.
+func (r *OIDCRegistry) Providers() []*OIDCProvider {
+	providers := make([]*OIDCProvider, 0, len(r.order))
+	for _, name := range r.order {
+		providers = append(providers, r.providers[name])
+	}
+	return providers
+}
+
+// RedirectURI is the callback registered with the provider.
+func (r *OIDCRegistry) RedirectURI(name string) string {
+	return r.redirectBase + "/v1/auth/oidc/" + name + "/callback"
+}
+
+// PKCEChallenge derives the S256 code challenge for a verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL builds the URL the browser is sent to for sign-in.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, verifier string) (string, error) {
+	authURL := p.config.AuthURL
+	if !p.config.GitHub {
+		discovery, err := p.discover(ctx)
+		if err != nil {
+			return "", err
+		}
+		authURL = discovery.AuthorizationEndpoint
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", PKCEChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	if !p.config.GitHub {
+		query.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(authURL, "?") {
+		separator = "&"
+	}
+	return authURL + separator + query.Encode(), nil
+}
+
+// Authenticate exchanges an authorization code and returns the verified
+// identity behind it.
+func (p *OIDCProvider) Authenticate(ctx context.Context, code, verifier, redirectURI, nonce string) (*OIDCIdentity, error) {
+	tokenURL := p.config.TokenURL
+	if !p.config.GitHub {
+		discovery, err := p.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tokenURL = discovery.TokenEndpoint
+	}
+
+	tokens, err := p.exchange(ctx, tokenURL, code, verifier, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.GitHub {
+		return p.githubIdentity(ctx, tokens.AccessToken)
+	}
+	return p.verifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+func (p *OIDCProvider) exchange(ctx context.Context, tokenURL, code, verifier, redirectURI string) (*oidcTokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens oidcTokenResponse
+	if err := p.do(req, &tokens); err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+	if tokens.Error != "" {
+		return nil, fmt.Errorf("exchange code: %s: %s", tokens.Error, tokens.Description)
+	}
+	if tokens.AccessToken == "" {
+		return nil, errors.New("exchange code: no access token returned")
+	}
+	if !p.config.GitHub && tokens.IDToken == "" {
+		return nil, errors.New("exchange code: no id token returned")
+	}
+	return &tokens, nil
+}
+
+func (p *OIDCProvider) do(req *http.Request, target interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		// Token endpoints describe failures in a JSON body, which the
+		// caller reports.
+		if tokens, ok := target.(*oidcTokenResponse); ok {
+			if json.Unmarshal(body, tokens) == nil && tokens.Error != "" {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("decode response from %s: %w", req.URL.Host, err)
+	}
+	return nil
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery oidcDiscovery
+	if err := p.do(req, &discovery); err != nil {
+		return nil, fmt.Errorf("discover %s: %w", p.config.Name, err)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discover %s: incomplete provider metadata", p.config.Name)
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// verificationKey returns the provider key for kid, refreshing the JWKS
+// when the provider has rotated its keys.
+func (p *OIDCProvider) verificationKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < oidcKeyRefreshGap {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.do(req, &set); err != nil {
+		return nil, fmt.Errorf("fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, idToken, nonce string) (*OIDCIdentity, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}))
+	token, err := parser.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.verificationKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("verify id token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("verify id token: invalid token")
+	}
+
+	// Multi-tenant issuers such as Microsoft's "common" endpoint advertise
+	// a {tenantid} placeholder that is filled from the token.
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	issuer := discovery.Issuer
+	if tid, ok := claims["tid"].(string); ok {
+		issuer = strings.ReplaceAll(issuer, "{tenantid}", tid)
+	}
+	if !claims.VerifyIssuer(issuer, true) {
+		return nil, errors.New("verify id token: unexpected issuer")
+	}
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return nil, errors.New("verify id token: unexpected audience")
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce == "" || claimNonce != nonce {
+		return nil, errors.New("verify id token: nonce mismatch")
+	}
+
+	identity := &OIDCIdentity{Provider: p.config.Name}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.GivenName, _ = claims["given_name"].(string)
+	identity.FamilyName, _ = claims["family_name"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.EmailVerified = claimBool(claims["email_verified"])
+	// Microsoft omits email_verified; xms_edov marks a verified domain
+	// owner.
+	if _, present := claims["email_verified"]; !present {
+		identity.EmailVerified = claimBool(claims["xms_edov"])
+	}
+
+	if identity.Subject == "" {
+		return nil, errors.New("verify id token: missing subject")
+	}
+	return identity, nil
+}
+
+func claimBool(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		parsed, _ := strconv.ParseBool(v)
+		return parsed
+	default:
+		return false
+	}
+}
+
+func (p *OIDCProvider) githubGet(ctx context.Context, accessToken, path string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.APIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	return p.do(req, target)
+}
+
+func (p *OIDCProvider) githubIdentity(ctx context.Context, accessToken string) (*OIDCIdentity, error) {
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.githubGet(ctx, accessToken, "/user", &profile); err != nil {
+		return nil, fmt.Errorf("fetch github profile: %w", err)
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.githubGet(ctx, accessToken, "/user/emails", &emails); err != nil {
+		return nil, fmt.Errorf("fetch github emails: %w", err)
+	}
+
+	identity := &OIDCIdentity{
+		Provider: p.config.Name,
+		Subject:  strconv.FormatInt(profile.ID, 10),
+		Name:     profile.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = profile.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+			break
+		}
+	}
+
+	if profile.ID == 0 {
+		return nil, errors.New("fetch github profile: missing id")
+	}
+	return identity, nil
+}
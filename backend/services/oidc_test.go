@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	mockClientID     = "open-data-ug"
+	mockClientSecret = "client-secret"
+	mockRedirectURI  = "https://api.example.org/v1/auth/oidc/mock/callback"
+)
+
+// mockOIDCServer is a minimal OpenID Connect provider. Its token endpoint
+// returns an ID token carrying whatever claims the test sets, signed with
+// the current key.
+type mockOIDCServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PrivateKey
+	kid       string
+	claims    jwt.MapClaims
+	discovery map[string]string
+	jwksHits  int
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+
+	m := &mockOIDCServer{keys: make(map[string]*rsa.PrivateKey)}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+	m.rotate(t, "key-1")
+	return m
+}
+
+// rotate publishes a new signing key and retires the old ones, as providers
+// do on a schedule.
+func (m *mockOIDCServer) rotate(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = map[string]*rsa.PrivateKey{kid: key}
+	m.kid = kid
+}
+
+func (m *mockOIDCServer) setClaims(claims jwt.MapClaims) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.claims = claims
+}
+
+func (m *mockOIDCServer) keyFetches() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jwksHits
+}
+
+func (m *mockOIDCServer) validClaims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            m.URL,
+		"aud":            mockClientID,
+		"sub":            "mock-subject",
+		"email":          "jane@example.org",
+		"email_verified": true,
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+		"nonce":          nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func (m *mockOIDCServer) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		discovery := m.discovery
+		if discovery == nil {
+			discovery = map[string]string{
+				"issuer":                 m.URL,
+				"authorization_endpoint": m.URL + "/authorize",
+				"token_endpoint":         m.URL + "/token",
+				"jwks_uri":               m.URL + "/jwks",
+			}
+		}
+		json.NewEncoder(w).Encode(discovery)
+
+	case "/jwks":
+		m.jwksHits++
+		keys := make([]map[string]string, 0, len(m.keys))
+		for kid, key := range m.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+
+	case "/token":
+		r.ParseForm()
+		if r.PostForm.Get("client_secret") != mockClientSecret || r.PostForm.Get("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "bad code"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims)
+		token.Header["kid"] = m.kid
+		signed, err := token.SignedString(m.keys[m.kid])
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access-token",
+			"id_token":     signed,
+			"token_type":   "Bearer",
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *mockOIDCServer) provider() *OIDCProvider {
+	return NewOIDCProvider(OIDCProviderConfig{
+		Name:         "mock",
+		DisplayName:  "Mock",
+		Issuer:       m.URL,
+		ClientID:     mockClientID,
+		ClientSecret: mockClientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	mock := newMockOIDCServer(t)
+	provider := mock.provider()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), mockRedirectURI, "the-state", "the-nonce", "the-verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Scheme + "://" + parsed.Host + parsed.Path; got != mock.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %s, want the discovered one", got)
+	}
+
+	query := parsed.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             mockClientID,
+		"redirect_uri":          mockRedirectURI,
+		"scope":                 "openid email profile",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        PKCEChallenge("the-verifier"),
+		"code_challenge_method": "S256",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+
+	incomplete := newMockOIDCServer(t)
+	incomplete.discovery = map[string]string{"issuer": incomplete.URL, "authorization_endpoint": incomplete.URL + "/authorize"}
+	if _, err := incomplete.provider().AuthCodeURL(context.Background(), mockRedirectURI, "s", "n", "v"); err == nil {
+		t.Error("AuthCodeURL accepted metadata without token or JWKS endpoints")
+	}
+}
+
+func TestOIDCAuthenticate(t *testing.T) {
+	mock := newMockOIDCServer(t)
+	const nonce = "expected-nonce"
+
+	tests := []struct {
+		name    string
+		code    string
+		edit    func(jwt.MapClaims)
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "rejected code", code: "bad-code", wantErr: "invalid_grant"},
+		{name: "wrong nonce", edit: func(c jwt.MapClaims) { c["nonce"] = "replayed-nonce" }, wantErr: "nonce mismatch"},
+		{name: "missing nonce", edit: func(c jwt.MapClaims) { delete(c, "nonce") }, wantErr: "nonce mismatch"},
+		{name: "wrong issuer", edit: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.org" }, wantErr: "unexpected issuer"},
+		{name: "wrong audience", edit: func(c jwt.MapClaims) { c["aud"] = "another-client" }, wantErr: "unexpected audience"},
+		{name: "audience list", edit: func(c jwt.MapClaims) { c["aud"] = []string{"another-client", mockClientID} }},
+		{name: "expired", edit: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: "expired"},
+		{name: "missing subject", edit: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: "missing subject"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := mock.validClaims(nonce)
+			if tt.edit != nil {
+				tt.edit(claims)
+			}
+			mock.setClaims(claims)
+			code := tt.code
+			if code == "" {
+				code = "good-code"
+			}
+
+			identity, err := mock.provider().Authenticate(context.Background(), code, "verifier", mockRedirectURI, nonce)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Authenticate = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := OIDCIdentity{
+				Provider:      "mock",
+				Subject:       "mock-subject",
+				Email:         "jane@example.org",
+				EmailVerified: true,
+				GivenName:     "Jane",
+				FamilyName:    "Doe",
+			}
+			if *identity != want {
+				t.Errorf("identity = %+v, want %+v", *identity, want)
+			}
+		})
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	mock := newMockOIDCServer(t)
+	provider := mock.provider()
+	const nonce = "n"
+	authenticate := func() error {
+		mock.setClaims(mock.validClaims(nonce))
+		_, err := provider.Authenticate(context.Background(), "good-code", "verifier", mockRedirectURI, nonce)
+		return err
+	}
+
+	if err := authenticate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := authenticate(); err != nil {
+		t.Fatal(err)
+	}
+	if mock.keyFetches() != 1 {
+		t.Fatalf("JWKS fetched %d times, want the keys cached after the first", mock.keyFetches())
+	}
+
+	// Right after a fetch, an unknown kid is refused without asking the
+	// provider again.
+	retired := mock.keys["key-1"]
+	mock.rotate(t, "key-2")
+	if err := authenticate(); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("Authenticate with a fresh kid inside the refresh gap = %v", err)
+	}
+	if mock.keyFetches() != 1 {
+		t.Fatalf("JWKS fetched %d times inside the refresh gap", mock.keyFetches())
+	}
+
+	// Once the gap has passed, the new key is picked up.
+	provider.mu.Lock()
+	provider.keysFetched = time.Now().Add(-oidcKeyRefreshGap)
+	provider.mu.Unlock()
+	if err := authenticate(); err != nil {
+		t.Fatalf("Authenticate after rotation: %v", err)
+	}
+	if mock.keyFetches() != 2 {
+		t.Errorf("JWKS fetched %d times, want a refresh after rotation", mock.keyFetches())
+	}
+
+	// Tokens signed with the retired key no longer verify.
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mock.validClaims(nonce))
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(retired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.verifyIDToken(context.Background(), signed, nonce); err == nil {
+		t.Error("verifyIDToken accepted a token signed with a retired key")
+	}
+}
+
+func TestOIDCRejectsUnsignedAlgorithms(t *testing.T) {
+	mock := newMockOIDCServer(t)
+	provider := mock.provider()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, mock.validClaims("n"))
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString([]byte(mockClientSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.verifyIDToken(context.Background(), signed, "n"); err == nil {
+		t.Error("verifyIDToken accepted an HS256 token")
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+const (
+	defaultPasswordMinLength = 10
+	// passwordMaxLength bounds the work argon2id and the estimator do per
+	// request.
+	passwordMaxLength        = 128
+	defaultPasswordMinScore  = PasswordScoreSafelyUnguessable
+	defaultPasswordHistory   = 5
+	minUserInputLengthToFlag = 3
+)
+
+// PasswordPolicyError lists every rule a password broke, so the user can fix
+// them all at once.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return strings.Join(e.Violations, "; ")
+}
+
+// PasswordPolicy decides whether a new password is acceptable.
+type PasswordPolicy struct {
+	MinLength   int
+	MaxLength   int
+	MinScore    int
+	HistorySize int
+	// breached is nil when no corpus is configured.
+	breached *BreachedPasswords
+}
+
+// LoadPasswordPolicy reads the policy from the environment:
+// PASSWORD_MIN_LENGTH, PASSWORD_MIN_SCORE (0-4), PASSWORD_HISTORY (how many
+// previous passwords may not be reused) and PASSWORD_BREACH_CORPUS (path to
+// the offline breached-password corpus).
+func LoadPasswordPolicy() *PasswordPolicy {
+	policy := &PasswordPolicy{
+		MinLength:   envInt("PASSWORD_MIN_LENGTH", defaultPasswordMinLength),
+		MaxLength:   passwordMaxLength,
+		MinScore:    envInt("PASSWORD_MIN_SCORE", defaultPasswordMinScore),
+		HistorySize: envInt("PASSWORD_HISTORY", defaultPasswordHistory),
+	}
+	policy.MinScore = min(max(policy.MinScore, PasswordScoreTooGuessable), PasswordScoreVeryUnguessable)
+
+	if path := os.Getenv("PASSWORD_BREACH_CORPUS"); path != "" {
+		breached, err := NewBreachedPasswords(path)
+		if err != nil {
+			log.Printf("Breached password check disabled: %v", err)
+		} else {
+			policy.breached = breached
+		}
+	}
+
+	return policy
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// BreachCheckEnabled reports whether passwords are checked against the
+// offline breach corpus.
+func (p *PasswordPolicy) BreachCheckEnabled() bool {
+	return p.breached != nil
+}
+
+// Validate checks password against the policy for user. previousHashes are
+// the user's most recent password hashes, newest first; only the first
+// HistorySize are compared.
+func (p *PasswordPolicy) Validate(password string, user *models.User, previousHashes []string) error {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters", p.MinLength))
+	}
+	if length > p.MaxLength {
+		return &PasswordPolicyError{Violations: []string{fmt.Sprintf("password must be at most %d characters", p.MaxLength)}}
+	}
+
+	var userInputs []string
+	if user != nil {
+		userInputs = []string{user.Email, user.FirstName, user.LastName, user.Name}
+		if containsUserInput(password, user) {
+			violations = append(violations, "password must not contain your email address or name")
+		}
+	}
+
+	if strength := EstimatePasswordStrength(password, userInputs...); strength.Score < p.MinScore {
+		violations = append(violations, "password is too easy to guess, try a longer passphrase or fewer common words")
+	}
+
+	if p.breached != nil {
+		count, err := p.breached.Count(password)
+		if err != nil {
+			log.Printf("Failed to check breached password corpus: %v", err)
+		} else if count > 0 {
+			violations = append(violations, "password has appeared in a data breach, choose a different one")
+		}
+	}
+
+	if len(violations) == 0 {
+		for i, hash := range previousHashes {
+			if i >= p.HistorySize {
+				break
+			}
+			if match, _ := commons.ComparePassword(hash, password); match {
+				violations = append(violations, fmt.Sprintf("password must not match any of your last %d passwords", p.HistorySize))
+				break
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsUserInput reports whether the password includes the email's local
+// part or any name of three or more characters.
+func containsUserInput(password string, user *models.User) bool {
+	lower := strings.ToLower(password)
+
+	localPart, _, _ := strings.Cut(strings.ToLower(user.Email), "@")
+	candidates := []string{localPart}
+	for _, name := range []string{user.FirstName, user.LastName} {
+		candidates = append(candidates, strings.Fields(strings.ToLower(name))...)
+	}
+
+	for _, candidate := range candidates {
+		if utf8.RuneCountInString(candidate) >= minUserInputLengthToFlag && strings.Contains(lower, candidate) {
+			return true
+		}
+	}
+	return false
+}
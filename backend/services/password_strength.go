@@ -0,0 +1,350 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// The strength estimator follows the approach of zxcvbn: find the patterns
+// an attacker would try first (common passwords, the user's own details,
+// keyboard walks, sequences, repeats, years), price each in guesses, and
+// take the cheapest way to cover the whole password. The score uses the
+// same guess thresholds as zxcvbn.
+
+const (
+	PasswordScoreTooGuessable = iota
+	PasswordScoreVeryGuessable
+	PasswordScoreSomewhatGuessable
+	PasswordScoreSafelyUnguessable
+	PasswordScoreVeryUnguessable
+)
+
+var scoreThresholds = []float64{1e3, 1e6, 1e8, 1e10}
+
+// commonPasswords are ranked by popularity; a match costs its rank in
+// guesses.
+var commonPasswords = rankedWords(`
+password 123456 123456789 12345678 12345 qwerty 1234567 111111 1234567890
+123123 abc123 1234 password1 iloveyou 1q2w3e4r 000000 qwerty123 zaq12wsx
+dragon sunshine princess letmein 654321 monkey 27653 1qaz2wsx 123321
+qwertyuiop superman asdfghjkl football baseball welcome login admin
+master hello freedom whatever qazwsx trustno1 starwars passw0rd shadow
+michael jennifer hunter ranger buster soccer harley batman andrew tigger
+charlie robert thomas hockey killer george summer winter spring autumn
+secret access flower pepper ginger cookie orange banana computer internet
+mustang maggie jordan daniel chelsea arsenal liverpool manchester london
+changeme default uganda kampala entebbe jinja gulu mbarara africa
+opendata open data kenya nairobi
+`)
+
+// commonWords are everyday words an attacker's dictionary would include.
+var commonWords = rankedWords(`
+love life home family money happy lucky angel baby blessed god jesus
+music dance peace power sweet dream heart smile friend forever beautiful
+monday friday january july december red blue green black white yellow
+cat dog lion eagle tiger bear horse fish bird star moon sun sky water
+fire earth wind rain king queen boss prince lady girl boy man woman
+`)
+
+var keyboardRows = []string{
+	"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+	"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik,9ol.0p;/",
+}
+
+var sequenceAlphabets = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"0123456789",
+}
+
+var leetSubstitutions = strings.NewReplacer(
+	"4", "a", "@", "a", "8", "b", "(", "c", "3", "e", "6", "g", "1", "i",
+	"!", "i", "|", "l", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t",
+	"2", "z",
+)
+
+func rankedWords(list string) map[string]int {
+	words := strings.Fields(list)
+	ranks := make(map[string]int, len(words))
+	for i, word := range words {
+		if _, exists := ranks[word]; !exists {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}
+
+// PasswordStrength is the estimator's verdict for one password.
+type PasswordStrength struct {
+	Score   int     `json:"score"`
+	Guesses float64 `json:"guesses"`
+}
+
+type strengthMatch struct {
+	start, end int // inclusive indices into the password runes
+	guesses    float64
+}
+
+// EstimatePasswordStrength scores password from 0 (too guessable) to 4
+// (very unguessable). userInputs are words specific to the account, such
+// as the email address and name, which an attacker would try first.
+func EstimatePasswordStrength(password string, userInputs ...string) PasswordStrength {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return PasswordStrength{}
+	}
+
+	matches := findStrengthMatches(runes, userDictionary(userInputs))
+	cardinality := float64(bruteforceCardinality(runes))
+
+	// best[i] is the fewest guesses to cover runes[:i].
+	best := make([]float64, len(runes)+1)
+	best[0] = 1
+	for i := 1; i <= len(runes); i++ {
+		best[i] = best[i-1] * cardinality
+		for _, match := range matches {
+			if match.end != i-1 {
+				continue
+			}
+			if candidate := best[match.start] * match.guesses; candidate < best[i] {
+				best[i] = candidate
+			}
+		}
+	}
+
+	guesses := best[len(runes)]
+	score := 0
+	for _, threshold := range scoreThresholds {
+		if guesses >= threshold {
+			score++
+		}
+	}
+	return PasswordStrength{Score: score, Guesses: guesses}
+}
+
+// userDictionary turns account details into dictionary words, splitting
+// emails and names into their parts.
+func userDictionary(inputs []string) map[string]int {
+	words := make(map[string]int)
+	for _, input := range inputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if input == "" {
+			continue
+		}
+		words[input] = 1
+		for _, part := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len([]rune(part)) >= 3 {
+				words[part] = 1
+			}
+		}
+	}
+	return words
+}
+
+func findStrengthMatches(runes []rune, userWords map[string]int) []strengthMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+	var matches []strengthMatch
+
+	dictionaries := []map[string]int{commonPasswords, commonWords, userWords}
+	for i := range lower {
+		for j := i + 2; j < len(lower); j++ {
+			token := string(lower[i : j+1])
+			unleeted := leetSubstitutions.Replace(token)
+			reversed := reverseString(token)
+
+			for _, dictionary := range dictionaries {
+				rank, ok := dictionary[token]
+				extra := 1.0
+				if !ok {
+					if rank, ok = dictionary[unleeted]; ok {
+						extra = 4
+					} else if rank, ok = dictionary[reversed]; ok {
+						extra = 2
+					}
+				}
+				if !ok {
+					continue
+				}
+				guesses := float64(rank) * extra * uppercaseVariations(runes[i:j+1])
+				matches = append(matches, strengthMatch{start: i, end: j, guesses: math.Max(guesses, 10)})
+			}
+		}
+	}
+
+	matches = append(matches, repeatMatches(lower)...)
+	matches = append(matches, sequenceMatches(lower)...)
+	matches = append(matches, keyboardMatches(lower)...)
+	matches = append(matches, yearMatches(lower)...)
+	return matches
+}
+
+// uppercaseVariations is the extra work of guessing where the capitals go.
+// All-lowercase, all-uppercase and a leading capital are tried first.
+func uppercaseVariations(word []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 || lower == 0 || (upper == 1 && unicode.IsUpper(word[0])) {
+		if upper == 0 {
+			return 1
+		}
+		return 2
+	}
+
+	variations := 0.0
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return variations
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+func repeatMatches(runes []rune) []strengthMatch {
+	var matches []strengthMatch
+	for i := 0; i < len(runes); {
+		j := i
+		for j+1 < len(runes) && runes[j+1] == runes[i] {
+			j++
+		}
+		if j-i >= 2 {
+			matches = append(matches, strengthMatch{start: i, end: j, guesses: float64(bruteforceCardinality(runes[i:i+1])) * float64(j-i+1)})
+		}
+		i = j + 1
+	}
+	return matches
+}
+
+func sequenceMatches(runes []rune) []strengthMatch {
+	var matches []strengthMatch
+	for _, alphabet := range sequenceAlphabets {
+		matches = append(matches, walkMatches(runes, alphabet, 4)...)
+	}
+	return matches
+}
+
+func keyboardMatches(runes []rune) []strengthMatch {
+	var matches []strengthMatch
+	for _, row := range keyboardRows {
+		matches = append(matches, walkMatches(runes, row, 8)...)
+	}
+	return matches
+}
+
+// walkMatches finds runs of three or more characters that step through
+// alphabet one position at a time in either direction. baseGuesses
+// reflects how many starting points and directions an attacker tries.
+func walkMatches(runes []rune, alphabet string, baseGuesses float64) []strengthMatch {
+	position := make(map[rune]int, len(alphabet))
+	for i, r := range alphabet {
+		position[r] = i
+	}
+
+	var matches []strengthMatch
+	for i := 0; i < len(runes); {
+		start, ok := position[runes[i]]
+		if !ok || i+1 >= len(runes) {
+			i++
+			continue
+		}
+		next, ok := position[runes[i+1]]
+		direction := next - start
+		if !ok || (direction != 1 && direction != -1) {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j+1 < len(runes) {
+			following, ok := position[runes[j+1]]
+			if !ok || following-position[runes[j]] != direction {
+				break
+			}
+			j++
+		}
+		if j-i >= 2 {
+			matches = append(matches, strengthMatch{start: i, end: j, guesses: baseGuesses * float64(j-i+1)})
+		}
+		i = j
+	}
+	return matches
+}
+
+// yearMatches treats recent years as a small search space.
+func yearMatches(runes []rune) []strengthMatch {
+	var matches []strengthMatch
+	for i := 0; i+4 <= len(runes); i++ {
+		token := string(runes[i : i+4])
+		if (strings.HasPrefix(token, "19") || strings.HasPrefix(token, "20")) && isDigits(token) {
+			matches = append(matches, strengthMatch{start: i, end: i + 3, guesses: 120})
+		}
+	}
+	return matches
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func bruteforceCardinality(runes []rune) int {
+	var lower, upper, digits, symbols, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digits = true
+		case r < 128:
+			symbols = true
+		default:
+			other = true
+		}
+	}
+
+	cardinality := 0
+	if lower {
+		cardinality += 26
+	}
+	if upper {
+		cardinality += 26
+	}
+	if digits {
+		cardinality += 10
+	}
+	if symbols {
+		cardinality += 33
+	}
+	if other {
+		cardinality += 100
+	}
+	return max(cardinality, 10)
+}
+
+func reverseString(value string) string {
+	runes := []rune(value)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -0,0 +1,539 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"opendataug.org/models"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// ErrUnknownQualityOption is returned when a run names a level or rule that
+// does not exist.
+var ErrUnknownQualityOption = errors.New("unknown quality option")
+
+// QualityIssue is one problem found by a quality rule. Related lists the
+// other units involved, such as the rest of a group of duplicates.
+type QualityIssue struct {
+	Rule         string   `json:"rule"`
+	Severity     string   `json:"severity"`
+	Level        string   `json:"level"`
+	Number       string   `json:"number"`
+	Name         string   `json:"name"`
+	ParentNumber string   `json:"parent_number,omitempty"`
+	Related      []string `json:"related,omitempty"`
+	Message      string   `json:"message"`
+}
+
+type QualitySummary struct {
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+	ByRule   map[string]int `json:"by_rule"`
+}
+
+// QualityReport is the result of one validation run.
+type QualityReport struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Levels      []string       `json:"levels"`
+	Rules       []string       `json:"rules"`
+	Scanned     map[string]int `json:"scanned"`
+	Summary     QualitySummary `json:"summary"`
+	Issues      []QualityIssue `json:"issues"`
+}
+
+// HasIssues reports whether the report contains an issue at severity or
+// worse.
+func (r *QualityReport) HasIssues(severity string) bool {
+	if severity == SeverityWarning {
+		return r.Summary.Errors+r.Summary.Warnings > 0
+	}
+	return r.Summary.Errors > 0
+}
+
+// QualityRule checks the live units of one level. The scan gives access to
+// every level, including soft-deleted rows, for rules that look at parents.
+type QualityRule struct {
+	Name        string `json:"name"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	check       func(scan *qualityScan, level string, emit func(unit qualityUnit, message string, related ...string))
+}
+
+var qualityRules = []QualityRule{
+	{
+		Name:        "empty_name",
+		Severity:    SeverityError,
+		Description: "Name is empty or only whitespace",
+		check:       checkEmptyName,
+	},
+	{
+		Name:        "duplicate_name",
+		Severity:    SeverityError,
+		Description: "Another unit under the same parent has the same name, ignoring case",
+		check:       checkDuplicateNames,
+	},
+	{
+		Name:        "near_duplicate_name",
+		Severity:    SeverityWarning,
+		Description: "Another unit under the same parent has a name that differs only in punctuation or by one letter",
+		check:       checkNearDuplicateNames,
+	},
+	{
+		Name:        "orphan",
+		Severity:    SeverityError,
+		Description: "Parent is missing or does not exist",
+		check:       checkOrphans,
+	},
+	{
+		Name:        "deleted_parent",
+		Severity:    SeverityError,
+		Description: "Parent has been deleted but the unit is still live",
+		check:       checkDeletedParents,
+	},
+	{
+		Name:        "casing",
+		Severity:    SeverityWarning,
+		Description: "Name is all upper case, all lower case or starts with a lower-case letter",
+		check:       checkCasing,
+	},
+	{
+		Name:        "whitespace",
+		Severity:    SeverityWarning,
+		Description: "Name has leading, trailing or repeated whitespace",
+		check:       checkWhitespace,
+	},
+	{
+		Name:        "suspicious_characters",
+		Severity:    SeverityWarning,
+		Description: "Name contains control characters, symbols or no letters at all",
+		check:       checkSuspiciousCharacters,
+	},
+}
+
+// QualityRules returns the rules the validator knows, in the order they run.
+func QualityRules() []QualityRule {
+	return qualityRules
+}
+
+// QualityOptions limits a run to some levels and rules. Empty means all.
+type QualityOptions struct {
+	Levels []string
+	Rules  []string
+}
+
+type qualityUnit struct {
+	Number       string
+	Name         string
+	ParentNumber string
+	DeletedAt    gorm.DeletedAt
+}
+
+type qualityScan struct {
+	units map[string][]qualityUnit
+	index map[string]map[string]*qualityUnit
+}
+
+// live returns the units of level that have not been deleted.
+func (s *qualityScan) live(level string) []qualityUnit {
+	var live []qualityUnit
+	for _, unit := range s.units[level] {
+		if !unit.DeletedAt.Valid {
+			live = append(live, unit)
+		}
+	}
+	return live
+}
+
+// siblings groups the live units of level by parent.
+func (s *qualityScan) siblings(level string) [][]qualityUnit {
+	groups := map[string][]qualityUnit{}
+	var parents []string
+	for _, unit := range s.live(level) {
+		if _, ok := groups[unit.ParentNumber]; !ok {
+			parents = append(parents, unit.ParentNumber)
+		}
+		groups[unit.ParentNumber] = append(groups[unit.ParentNumber], unit)
+	}
+
+	result := make([][]qualityUnit, 0, len(parents))
+	for _, parent := range parents {
+		result = append(result, groups[parent])
+	}
+	return result
+}
+
+// QualityValidator checks the administrative hierarchy against the quality
+// rules.
+type QualityValidator struct {
+	db *gorm.DB
+}
+
+func NewQualityValidator(db *gorm.DB) *QualityValidator {
+	return &QualityValidator{db: db}
+}
+
+func (v *QualityValidator) load(levels []string) (*qualityScan, error) {
+	scan := &qualityScan{
+		units: map[string][]qualityUnit{},
+		index: map[string]map[string]*qualityUnit{},
+	}
+
+	// Parents are loaded too so orphan checks can see them.
+	needed := map[string]bool{}
+	for _, level := range levels {
+		for l := level; l != ""; l = models.Levels[l].ParentLevel {
+			needed[l] = true
+		}
+	}
+
+	for _, level := range models.LevelOrder {
+		if !needed[level] {
+			continue
+		}
+		meta := models.Levels[level]
+
+		columns := "number, name, deleted_at"
+		if meta.ParentColumn != "" {
+			columns += ", " + meta.ParentColumn + " AS parent_number"
+		}
+
+		var units []qualityUnit
+		if err := v.db.Table(meta.Table).Select(columns).Order("number").Scan(&units).Error; err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", meta.Table, err)
+		}
+
+		index := make(map[string]*qualityUnit, len(units))
+		for i := range units {
+			index[units[i].Number] = &units[i]
+		}
+		scan.units[level] = units
+		scan.index[level] = index
+	}
+
+	return scan, nil
+}
+
+// Validate runs the selected rules over the selected levels.
+func (v *QualityValidator) Validate(options QualityOptions) (*QualityReport, error) {
+	levels, err := selectLevels(options.Levels)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := selectRules(options.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	scan, err := v.load(levels)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &QualityReport{
+		GeneratedAt: time.Now().UTC(),
+		Levels:      levels,
+		Scanned:     map[string]int{},
+		Summary:     QualitySummary{ByRule: map[string]int{}},
+		Issues:      []QualityIssue{},
+	}
+	for _, rule := range rules {
+		report.Rules = append(report.Rules, rule.Name)
+	}
+
+	for _, level := range levels {
+		report.Scanned[level] = len(scan.live(level))
+
+		for _, rule := range rules {
+			rule.check(scan, level, func(unit qualityUnit, message string, related ...string) {
+				report.Issues = append(report.Issues, QualityIssue{
+					Rule:         rule.Name,
+					Severity:     rule.Severity,
+					Level:        level,
+					Number:       unit.Number,
+					Name:         unit.Name,
+					ParentNumber: unit.ParentNumber,
+					Related:      related,
+					Message:      message,
+				})
+				report.Summary.ByRule[rule.Name]++
+				if rule.Severity == SeverityError {
+					report.Summary.Errors++
+				} else {
+					report.Summary.Warnings++
+				}
+			})
+		}
+	}
+
+	return report, nil
+}
+
+func selectLevels(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return models.LevelOrder, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		if _, ok := models.Levels[name]; !ok {
+			return nil, fmt.Errorf("%w: level %q", ErrUnknownQualityOption, name)
+		}
+		wanted[name] = true
+	}
+
+	var levels []string
+	for _, level := range models.LevelOrder {
+		if wanted[level] {
+			levels = append(levels, level)
+		}
+	}
+	return levels, nil
+}
+
+func selectRules(names []string) ([]QualityRule, error) {
+	if len(names) == 0 {
+		return qualityRules, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var rules []QualityRule
+	for _, rule := range qualityRules {
+		if wanted[rule.Name] {
+			rules = append(rules, rule)
+			delete(wanted, rule.Name)
+		}
+	}
+	for name := range wanted {
+		return nil, fmt.Errorf("%w: rule %q", ErrUnknownQualityOption, name)
+	}
+	return rules, nil
+}
+
+func checkEmptyName(scan *qualityScan, level string, emit func(qualityUnit, string, ...string)) {
+	for _, unit := range scan.live(level) {
+		if strings.TrimSpace(unit.Name) == "" {
+			emit(unit, "name is empty")
+		}
+	}
+}
+
+func checkDuplicateNames(scan *qualityScan, level string, emit func(qualityUnit, string, ...string)) {
+	for _, group := range scan.siblings(level) {
+		byName := map[string][]qualityUnit{}
+		var names []string
+		for _, unit := range group {
+			key := strings.ToLower(strings.Join(strings.Fields(unit.Name), " "))
+			if key == "" {
+				continue
+			}
+			if _, ok := byName[key]; !ok {
+				names = append(names, key)
+			}
+			byName[key] = append(byName[key], unit)
+		}
+
+		for _, name := range names {
+			units := byName[name]
+			if len(units) < 2 {
+				continue
+			}
+			related := make([]string, 0, len(units)-1)
+			for _, other := range units[1:] {
+				related = append(related, other.Number)
+			}
+			emit(units[0], fmt.Sprintf("%d units under the same parent are named %q", len(units), units[0].Name), related...)
+		}
+	}
+}
+
+// normalizeName reduces a name to its lower-case letters and digits, so
+// names that differ only in spacing or punctuation compare equal.
+func normalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// numberedSiblings reports whether a and b only differ in a short final
+// word, as in "Kasana A" and "Kasana B" or "Bukoto I" and "Bukoto II".
+// Such names are deliberate and not near-duplicates.
+func numberedSiblings(a, b string) bool {
+	fa, fb := strings.Fields(strings.ToLower(a)), strings.Fields(strings.ToLower(b))
+	if len(fa) < 2 || len(fb) < 2 {
+		return false
+	}
+	lastA, lastB := fa[len(fa)-1], fb[len(fb)-1]
+	return len(lastA) <= 3 && len(lastB) <= 3 &&
+		strings.Join(fa[:len(fa)-1], " ") == strings.Join(fb[:len(fb)-1], " ")
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func checkNearDuplicateNames(scan *qualityScan, level string, emit func(qualityUnit, string, ...string)) {
+	for _, group := range scan.siblings(level) {
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				a, b := group[i], group[j]
+				if strings.EqualFold(strings.Join(strings.Fields(a.Name), " "), strings.Join(strings.Fields(b.Name), " ")) {
+					continue // reported by duplicate_name
+				}
+
+				na, nb := normalizeName(a.Name), normalizeName(b.Name)
+				if na == "" || nb == "" {
+					continue
+				}
+				if na == nb {
+					emit(a, fmt.Sprintf("name differs from %q only in spacing or punctuation", b.Name), b.Number)
+					continue
+				}
+				if len(na) >= 6 && len(nb) >= 6 && editDistance(na, nb) == 1 && !numberedSiblings(a.Name, b.Name) {
+					emit(a, fmt.Sprintf("name is one letter away from %q", b.Name), b.Number)
+				}
+			}
+		}
+	}
+}
+
+func checkOrphans(scan *qualityScan, level string, emit func(qualityUnit, string, ...string)) {
+	parentLevel := models.Levels[level].ParentLevel
+	if parentLevel == "" {
+		return
+	}
+	for _, unit := range scan.live(level) {
+		if unit.ParentNumber == "" {
+			emit(unit, "has no "+parentLevel)
+			continue
+		}
+		if _, ok := scan.index[parentLevel][unit.ParentNumber]; !ok {
+			emit(unit, fmt.Sprintf("%s %s does not exist", parentLevel, unit.ParentNumber))
+		}
+	}
+}
+
+func checkDeletedParents(scan *qualityScan, level string, emit func(qualityUnit, string, ...string)) {
+	parentLevel := models.Levels[level].ParentLevel
+	if parentLevel == "" {
+		return
+	}
+	for _, unit := range scan.live(level) {
+		parent, ok := scan.index[parentLevel][unit.ParentNumber]
+		if ok && parent.DeletedAt.Valid {
+			emit(unit, fmt.Sprintf("%s %q was deleted on %s", parentLevel, parent.Name, parent.DeletedAt.Time.Format("2006-01-02")))
+		}
+	}
+}
+
+func checkCasing(scan *qualityScan, level string, emit func(qualityUnit, string, ...string)) {
+	for _, unit := range scan.live(level) {
+		var letters, upper, lower int
+		var first rune
+		for _, r := range unit.Name {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			if first == 0 {
+				first = r
+			}
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			} else if unicode.IsLower(r) {
+				lower++
+			}
+		}
+
+		switch {
+		case letters < 4:
+			// Too short to judge; abbreviations and numerals are common.
+		case upper == letters:
+			emit(unit, "name is all upper case")
+		case lower == letters:
+			emit(unit, "name is all lower case")
+		case unicode.IsLower(first):
+			emit(unit, "name starts with a lower-case letter")
+		}
+	}
+}
+
+func checkWhitespace(scan *qualityScan, level string, emit func(qualityUnit, string, ...string)) {
+	for _, unit := range scan.live(level) {
+		if strings.TrimSpace(unit.Name) == "" {
+			continue // reported by empty_name
+		}
+		if unit.Name != strings.Join(strings.Fields(unit.Name), " ") {
+			emit(unit, "name has leading, trailing or repeated whitespace")
+		}
+	}
+}
+
+// allowedPunctuation is the punctuation that legitimately appears in unit
+// names, such as "St. Mary's" or "Kampala (Central)".
+const allowedPunctuation = "'’-.,()/&"
+
+func checkSuspiciousCharacters(scan *qualityScan, level string, emit func(qualityUnit, string, ...string)) {
+	for _, unit := range scan.live(level) {
+		if strings.TrimSpace(unit.Name) == "" {
+			continue // reported by empty_name
+		}
+
+		var suspicious []string
+		seen := map[rune]bool{}
+		hasLetter := false
+		for _, r := range unit.Name {
+			switch {
+			case unicode.IsLetter(r):
+				hasLetter = true
+			case unicode.IsDigit(r), r == ' ', strings.ContainsRune(allowedPunctuation, r):
+			default:
+				if !seen[r] {
+					seen[r] = true
+					suspicious = append(suspicious, fmt.Sprintf("%q", r))
+				}
+			}
+		}
+
+		if len(suspicious) > 0 {
+			sort.Strings(suspicious)
+			emit(unit, "name contains "+strings.Join(suspicious, ", "))
+		} else if !hasLetter {
+			emit(unit, "name has no letters")
+		}
+	}
+}
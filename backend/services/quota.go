@@ -0,0 +1,95 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/models"
+)
+
+// QuotaRefreshInterval bounds how long an in-memory counter is trusted
+// before it is reconciled with the recorded usage.
+const QuotaRefreshInterval = time.Minute
+
+type quotaCounter struct {
+	month    time.Time
+	count    int64
+	loadedAt time.Time
+}
+
+// QuotaTracker enforces monthly request quotas for organizations. Counts are
+// seeded from api_key_usage and incremented in memory between refreshes, so
+// requests still buffered in the UsageRecorder are not missed.
+type QuotaTracker struct {
+	db       *gorm.DB
+	mu       sync.Mutex
+	counters map[string]*quotaCounter
+}
+
+func NewQuotaTracker(db *gorm.DB) *QuotaTracker {
+	return &QuotaTracker{
+		db:       db,
+		counters: make(map[string]*quotaCounter),
+	}
+}
+
+// Allow counts one request against the organization and reports whether it
+// is within limit. A limit of zero or less means unlimited.
+func (q *QuotaTracker) Allow(organizationNumber string, limit int64) (bool, error) {
+	if limit <= 0 {
+		return true, nil
+	}
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counter, ok := q.counters[organizationNumber]
+	if !ok || !counter.month.Equal(month) {
+		counter = &quotaCounter{month: month}
+		q.counters[organizationNumber] = counter
+	}
+
+	if counter.loadedAt.IsZero() || now.Sub(counter.loadedAt) > QuotaRefreshInterval {
+		var recorded int64
+		if err := q.db.Model(&models.APIKeyUsage{}).
+			Where("organization_number = ? AND requested_at >= ?", organizationNumber, month).
+			Count(&recorded).Error; err != nil {
+			return false, err
+		}
+		counter.count = max(counter.count, recorded)
+		counter.loadedAt = now
+	}
+
+	if counter.count >= limit {
+		return false, nil
+	}
+
+	counter.count++
+	return true, nil
+}
+
+// Usage returns the number of requests counted for the organization in the
+// current month.
+func (q *QuotaTracker) Usage(organizationNumber string) (int64, error) {
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var recorded int64
+	if err := q.db.Model(&models.APIKeyUsage{}).
+		Where("organization_number = ? AND requested_at >= ?", organizationNumber, month).
+		Count(&recorded).Error; err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if counter, ok := q.counters[organizationNumber]; ok && counter.month.Equal(month) {
+		recorded = max(recorded, counter.count)
+	}
+
+	return recorded, nil
+}
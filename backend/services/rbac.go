@@ -0,0 +1,230 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"opendataug.org/models"
+)
+
+type Permission string
+
+var ErrUnitNotFound = errors.New("unit not found")
+
+const (
+	PermUnitsCreate   Permission = "units:create"
+	PermUnitsUpdate   Permission = "units:update"
+	PermUnitsDelete   Permission = "units:delete"
+	PermChangesReview Permission = "changes:review"
+	PermRolesManage   Permission = "roles:manage"
+	PermOrgsManage    Permission = "organizations:manage"
+	PermUsersManage   Permission = "users:manage"
+	PermAuditRead     Permission = "audit:read"
+	PermReleasesCut   Permission = "releases:cut"
+	PermUnitsPurge    Permission = "units:purge"
+)
+
+var rolePermissions = map[models.UserRole][]Permission{
+	models.RoleAdmin: {
+		PermUnitsCreate,
+		PermUnitsUpdate,
+		PermUnitsDelete,
+		PermChangesReview,
+		PermRolesManage,
+		PermOrgsManage,
+		PermUsersManage,
+		PermAuditRead,
+		PermReleasesCut,
+		PermUnitsPurge,
+	},
+	models.RoleDataEditor: {
+		PermUnitsCreate,
+		PermUnitsUpdate,
+		PermUnitsDelete,
+	},
+	models.RoleReviewer: {
+		PermChangesReview,
+	},
+	models.RoleDistrictSteward: {
+		PermUnitsCreate,
+		PermUnitsUpdate,
+		PermUnitsDelete,
+		PermChangesReview,
+	},
+	models.RoleUser: {},
+}
+
+// mfaPermissions are the administrative permissions. Holding any of them,
+// account-wide or through a role assignment, makes two-factor sign-in
+// mandatory.
+var mfaPermissions = []Permission{
+	PermRolesManage,
+	PermOrgsManage,
+	PermUsersManage,
+	PermAuditRead,
+	PermReleasesCut,
+	PermUnitsPurge,
+}
+
+// RoleRequiresMFA reports whether role grants an administrative permission.
+func RoleRequiresMFA(role models.UserRole) bool {
+	for _, permission := range mfaPermissions {
+		if RoleHasPermission(role, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+func RoleHasPermission(role models.UserRole, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func RolePermissions() map[models.UserRole][]Permission {
+	return rolePermissions
+}
+
+// Authorizer decides whether a user holds a permission, either account-wide
+// through User.Role or through a role assignment covering the target units.
+type Authorizer struct {
+	db *gorm.DB
+}
+
+func NewAuthorizer(db *gorm.DB) *Authorizer {
+	return &Authorizer{db: db}
+}
+
+// Lineage returns the unit followed by each of its ancestors up to the region.
+func (a *Authorizer) Lineage(level, number string) ([]models.UnitRef, error) {
+	var lineage []models.UnitRef
+
+	for level != "" {
+		meta, ok := models.Levels[level]
+		if !ok {
+			return nil, fmt.Errorf("unknown level %q", level)
+		}
+
+		lineage = append(lineage, models.UnitRef{Level: level, Number: number})
+		if meta.ParentLevel == "" {
+			break
+		}
+
+		var parents []string
+		if err := a.db.Table(meta.Table).
+			Where("number = ? AND deleted_at IS NULL", number).
+			Pluck(meta.ParentColumn, &parents).Error; err != nil {
+			return nil, err
+		}
+		if len(parents) == 0 {
+			return nil, ErrUnitNotFound
+		}
+
+		level, number = meta.ParentLevel, parents[0]
+	}
+
+	return lineage, nil
+}
+
+// Can reports whether user holds permission. Account-wide grants are checked
+// first; only when the user relies on scoped assignments are the target
+// lineages resolved, and every one of them must fall inside a granted scope.
+func (a *Authorizer) Can(user *models.User, permission Permission, targets func() ([][]models.UnitRef, error)) (bool, error) {
+	if RoleHasPermission(user.Role, permission) {
+		return true, nil
+	}
+
+	var assignments []models.RoleAssignment
+	if err := a.db.Where("user_number = ?", user.Number).Find(&assignments).Error; err != nil {
+		return false, err
+	}
+
+	var scoped []models.RoleAssignment
+	for _, assignment := range assignments {
+		if !RoleHasPermission(assignment.Role, permission) {
+			continue
+		}
+		if !assignment.IsScoped() {
+			return true, nil
+		}
+		scoped = append(scoped, assignment)
+	}
+
+	if len(scoped) == 0 || targets == nil {
+		return false, nil
+	}
+
+	lineages, err := targets()
+	if err != nil {
+		return false, err
+	}
+	if len(lineages) == 0 {
+		return false, nil
+	}
+
+	for _, lineage := range lineages {
+		if !coveredBy(lineage, scoped) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// CanAnywhere reports whether user holds permission for at least part of
+// the hierarchy, account-wide or through any scoped assignment.
+func (a *Authorizer) CanAnywhere(user *models.User, permission Permission) (bool, error) {
+	if RoleHasPermission(user.Role, permission) {
+		return true, nil
+	}
+
+	var assignments []models.RoleAssignment
+	if err := a.db.Where("user_number = ?", user.Number).Find(&assignments).Error; err != nil {
+		return false, err
+	}
+	for _, assignment := range assignments {
+		if RoleHasPermission(assignment.Role, permission) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequiresMFA reports whether user must sign in with two factors, which is
+// the case when User.Role or any role assignment, scoped or not, grants an
+// administrative permission.
+func (a *Authorizer) RequiresMFA(user *models.User) (bool, error) {
+	if RoleRequiresMFA(user.Role) {
+		return true, nil
+	}
+
+	var roles []models.UserRole
+	if err := a.db.Model(&models.RoleAssignment{}).
+		Where("user_number = ?", user.Number).
+		Distinct().
+		Pluck("role", &roles).Error; err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if RoleRequiresMFA(role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func coveredBy(lineage []models.UnitRef, assignments []models.RoleAssignment) bool {
+	for _, unit := range lineage {
+		for _, assignment := range assignments {
+			if assignment.ScopeLevel == unit.Level && assignment.ScopeNumber == unit.Number {
+				return true
+			}
+		}
+	}
+	return false
+}
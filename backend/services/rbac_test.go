@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+
+	"opendataug.org/commons"
+	"opendataug.org/database/dbtest"
+	"opendataug.org/models"
+)
+
+func TestRoleRequiresMFA(t *testing.T) {
+	tests := []struct {
+		role models.UserRole
+		want bool
+	}{
+		{models.RoleAdmin, true},
+		{models.RoleDataEditor, false},
+		{models.RoleReviewer, false},
+		{models.RoleDistrictSteward, false},
+		{models.RoleUser, false},
+		{models.UserRole("UNKNOWN"), false},
+	}
+
+	for _, tt := range tests {
+		if got := RoleRequiresMFA(tt.role); got != tt.want {
+			t.Errorf("RoleRequiresMFA(%s) = %v, want %v", tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizerRequiresMFA(t *testing.T) {
+	db := dbtest.Open(t)
+	authorizer := NewAuthorizer(db.DB)
+
+	newUser := func(email string, role models.UserRole, assigned ...models.RoleAssignment) *models.User {
+		user := &models.User{Number: commons.UUIDGenerator(), Email: email, FirstName: "Test", Role: role, Status: models.UserStatusActive}
+		if err := db.DB.Create(user).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, assignment := range assigned {
+			assignment.Number = commons.UUIDGenerator()
+			assignment.UserNumber = user.Number
+			if err := db.DB.Create(&assignment).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		return user
+	}
+
+	tests := []struct {
+		name string
+		user *models.User
+		want bool
+	}{
+		{"plain user", newUser("plain@example.org", models.RoleUser), false},
+		{"admin role", newUser("admin@example.org", models.RoleAdmin), true},
+		{"granted admin", newUser("granted@example.org", models.RoleUser,
+			models.RoleAssignment{Role: models.RoleAdmin}), true},
+		{"scoped admin", newUser("scoped@example.org", models.RoleUser,
+			models.RoleAssignment{Role: models.RoleAdmin, ScopeLevel: models.LevelDistrict, ScopeNumber: commons.UUIDGenerator()}), true},
+		{"steward", newUser("steward@example.org", models.RoleUser,
+			models.RoleAssignment{Role: models.RoleDistrictSteward, ScopeLevel: models.LevelDistrict, ScopeNumber: commons.UUIDGenerator()},
+			models.RoleAssignment{Role: models.RoleReviewer}), false},
+	}
+
+	for _, tt := range tests {
+		got, err := authorizer.RequiresMFA(tt.user)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: RequiresMFA = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"opendataug.org/models"
+)
+
+const (
+	UsageBufferSize    = 10000
+	UsageBatchSize     = 500
+	UsageFlushInterval = 5 * time.Second
+)
+
+// UsageRecorder collects API key usage entries off the request path and
+// writes them to the database in batches.
+type UsageRecorder struct {
+	db      *gorm.DB
+	entries chan models.APIKeyUsage
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+func NewUsageRecorder(db *gorm.DB) *UsageRecorder {
+	r := &UsageRecorder{
+		db:      db,
+		entries: make(chan models.APIKeyUsage, UsageBufferSize),
+		done:    make(chan struct{}),
+	}
+
+	r.wg.Add(1)
+	go r.run()
+
+	return r
+}
+
+// Record queues an entry without blocking. Entries are dropped when the
+// buffer is full so a slow database never holds up API responses.
+func (r *UsageRecorder) Record(entry models.APIKeyUsage) {
+	select {
+	case r.entries <- entry:
+	default:
+		log.Printf("usage recorder: buffer full, dropping entry for key %s", entry.APIKeyNumber)
+	}
+}
+
+// Stop flushes any buffered entries and stops the background writer.
+func (r *UsageRecorder) Stop() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+	r.wg.Wait()
+}
+
+func (r *UsageRecorder) run() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(UsageFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.APIKeyUsage, 0, UsageBatchSize)
+
+	for {
+		select {
+		case entry := <-r.entries:
+			batch = append(batch, entry)
+			if len(batch) >= UsageBatchSize {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-r.done:
+			for {
+				select {
+				case entry := <-r.entries:
+					batch = append(batch, entry)
+				default:
+					if len(batch) > 0 {
+						r.flush(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *UsageRecorder) flush(batch []models.APIKeyUsage) {
+	type keyTotals struct {
+		count    int64
+		lastUsed time.Time
+	}
+
+	totals := make(map[string]*keyTotals)
+	for _, entry := range batch {
+		t, ok := totals[entry.APIKeyNumber]
+		if !ok {
+			t = &keyTotals{}
+			totals[entry.APIKeyNumber] = t
+		}
+		t.count++
+		if entry.RequestedAt.After(t.lastUsed) {
+			t.lastUsed = entry.RequestedAt
+		}
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(batch, UsageBatchSize).Error; err != nil {
+			return err
+		}
+
+		for keyNumber, t := range totals {
+			if err := SkipAudit(tx).Model(&models.APIKey{}).
+				Where("number = ?", keyNumber).
+				Updates(map[string]interface{}{
+					"last_used_at": t.lastUsed,
+					"usage_count":  gorm.Expr("usage_count + ?", t.count),
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Printf("usage recorder: failed to write %d entries: %v", len(batch), err)
+	}
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"opendataug.org/commons"
+	"opendataug.org/models"
+)
+
+const (
+	webhookPollInterval  = 5 * time.Second
+	webhookSendTimeout   = 10 * time.Second
+	webhookBaseDelay     = 15 * time.Second
+	webhookMaxDelay      = time.Hour
+	webhookDispatchBatch = 500
+	webhookCursorName    = "audit_log"
+	// webhookClaimLease is how long a claimed delivery is left alone before
+	// it is taken to be abandoned. It comfortably outlasts one send.
+	webhookClaimLease = 3 * webhookSendTimeout
+	// webhookFlushWorkers is how many deliveries are sent at once.
+	webhookFlushWorkers = 4
+	// webhookErrorBodyLimit bounds how much of a failed response is kept
+	// in the delivery log.
+	webhookErrorBodyLimit = 512
+	// WebhookMaxAttempts is how many deliveries are tried, about seven
+	// hours of retries with the backoff above, before one is marked failed.
+	WebhookMaxAttempts = 12
+
+	WebhookSignatureHeader = "X-OpenDataUG-Signature"
+	WebhookEventHeader     = "X-OpenDataUG-Event"
+	WebhookDeliveryHeader  = "X-OpenDataUG-Delivery"
+)
+
+var errWebhookPrivateAddress = errors.New("webhook endpoints on private or loopback addresses are not allowed")
+
+// SignWebhookPayload returns the signature header for body sent at
+// timestamp: "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+// Receivers recompute the HMAC with their secret and should reject
+// timestamps more than a few minutes old.
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewWebhookPayload encodes an event for delivery under a new event ID.
+func NewWebhookPayload(event string, sequence int64, data models.WebhookEventData) (string, string, error) {
+	payload := models.WebhookPayload{
+		ID:        commons.UUIDGenerator(),
+		Event:     event,
+		Sequence:  sequence,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", "", err
+	}
+	return payload.ID, string(encoded), nil
+}
+
+// webhookEvents names the events an audit log entry raises. One update can
+// raise several, such as a rename and a move made together.
+func webhookEvents(entry *models.AuditLog, levels map[string]string) []string {
+	if entry.EntityType == "releases" {
+		if entry.Action == models.AuditActionCreate {
+			return []string{models.WebhookEventReleasePublished}
+		}
+		return nil
+	}
+
+	level, ok := levels[entry.EntityType]
+	if !ok {
+		return nil
+	}
+
+	var before, after map[string]json.RawMessage
+	if entry.Before != nil {
+		_ = json.Unmarshal([]byte(*entry.Before), &before)
+	}
+	if entry.After != nil {
+		_ = json.Unmarshal([]byte(*entry.After), &after)
+	}
+
+	switch entry.Action {
+	case models.AuditActionCreate:
+		return []string{level + "." + models.WebhookActionCreated}
+	case models.AuditActionDelete:
+		// Deleting a row that was already soft-deleted purges it.
+		if deletedAt, ok := before["deleted_at"]; ok && string(deletedAt) != "null" {
+			return []string{level + "." + models.WebhookActionPurged}
+		}
+		return []string{level + "." + models.WebhookActionDeleted}
+	}
+
+	var events []string
+	if _, ok := before["name"]; ok {
+		events = append(events, level+"."+models.WebhookActionRenamed)
+	}
+	if column := models.Levels[level].ParentColumn; column != "" {
+		if _, ok := before[column]; ok {
+			events = append(events, level+"."+models.WebhookActionMoved)
+		}
+	}
+	if _, ok := before["deleted_at"]; ok {
+		if string(after["deleted_at"]) == "null" {
+			events = append(events, level+"."+models.WebhookActionRestored)
+		} else {
+			events = append(events, level+"."+models.WebhookActionDeleted)
+		}
+	}
+	if len(events) == 0 {
+		events = append(events, level+"."+models.WebhookActionUpdated)
+	}
+	return events
+}
+
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookBaseDelay
+	for i := 1; i < attempts && delay < webhookMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxDelay)
+}
+
+// WebhookDispatcher turns audited changes into webhook deliveries and sends
+// them. Events are read from the audit log, whose sequence follows commit
+// order, so every committed change is dispatched exactly once however it
+// was made.
+type WebhookDispatcher struct {
+	db     *gorm.DB
+	client *http.Client
+}
+
+// NewWebhookDispatcher builds a dispatcher. Unless allowPrivateNetworks is
+// set, connections to loopback, private and link-local addresses are
+// refused, so webhooks cannot be used to probe the internal network.
+func NewWebhookDispatcher(db *gorm.DB, allowPrivateNetworks bool) *WebhookDispatcher {
+	dialer := &net.Dialer{Timeout: webhookSendTimeout}
+	if !allowPrivateNetworks {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			return checkWebhookAddress(address)
+		}
+	}
+
+	return &WebhookDispatcher{
+		db: db,
+		client: &http.Client{
+			Timeout:   webhookSendTimeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			// A redirect counts as a failed delivery rather than sending
+			// the payload somewhere the owner did not register.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// checkWebhookAddress refuses a resolved host:port that is not publicly
+// routable. It runs at dial time, after DNS, so a public name pointing at a
+// private address is caught too.
+func checkWebhookAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return errWebhookPrivateAddress
+	}
+	return nil
+}
+
+// LoadWebhookDispatcher builds the dispatcher from the environment. Set
+// WEBHOOK_ALLOW_PRIVATE_NETWORKS=true to deliver to receivers on localhost
+// or the local network, for example during development.
+func LoadWebhookDispatcher(db *gorm.DB) (*WebhookDispatcher, error) {
+	allowPrivate := false
+	if value := os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_ALLOW_PRIVATE_NETWORKS: %w", err)
+		}
+		allowPrivate = parsed
+	}
+	return NewWebhookDispatcher(db, allowPrivate), nil
+}
+
+// Dispatch queues deliveries for every audit log entry written since the
+// last call and returns how many it queued. On first use it starts from
+// the end of the log, so history is not replayed to new installs.
+func (d *WebhookDispatcher) Dispatch() (int, error) {
+	queued := 0
+	for {
+		n, more, err := d.dispatchBatch()
+		queued += n
+		if err != nil || !more {
+			return queued, err
+		}
+	}
+}
+
+// dispatchBatch handles one batch of entries. The cursor row is locked for
+// the whole batch, so instances running side by side take turns.
+func (d *WebhookDispatcher) dispatchBatch() (int, bool, error) {
+	levels := make(map[string]string, len(models.Levels))
+	tables := []string{"releases"}
+	for level, meta := range models.Levels {
+		levels[meta.Table] = level
+		tables = append(tables, meta.Table)
+	}
+
+	queued, more := 0, false
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		var cursor models.WebhookCursor
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("name = ?", webhookCursorName).
+			First(&cursor).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			cursor.Name = webhookCursorName
+			if err := tx.Model(&models.AuditLog{}).Select("COALESCE(MAX(sequence), 0)").Scan(&cursor.Sequence).Error; err != nil {
+				return err
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&cursor).Error
+		}
+
+		var entries []models.AuditLog
+		if err := tx.Where("sequence > ? AND entity_type IN ?", cursor.Sequence, tables).
+			Order("sequence").
+			Limit(webhookDispatchBatch + 1).
+			Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) > webhookDispatchBatch {
+			entries = entries[:webhookDispatchBatch]
+			more = true
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		var webhooks []models.Webhook
+		if err := tx.Where("active = ?", true).Find(&webhooks).Error; err != nil {
+			return err
+		}
+
+		var deliveries []models.WebhookDelivery
+		now := time.Now()
+		for i := range entries {
+			entry := &entries[i]
+			data := models.WebhookEventData{
+				Level:  levels[entry.EntityType],
+				Number: entry.EntityNumber,
+			}
+			if entry.Before != nil {
+				data.Before = json.RawMessage(*entry.Before)
+			}
+			if entry.After != nil {
+				data.After = json.RawMessage(*entry.After)
+			}
+
+			for _, event := range webhookEvents(entry, levels) {
+				var eventID, payload string
+				for _, webhook := range webhooks {
+					if !webhook.Wants(event) {
+						continue
+					}
+					if payload == "" {
+						var err error
+						if eventID, payload, err = NewWebhookPayload(event, entry.Sequence, data); err != nil {
+							return err
+						}
+					}
+					deliveries = append(deliveries, models.WebhookDelivery{
+						Number:        commons.UUIDGenerator(),
+						WebhookNumber: webhook.Number,
+						EventID:       eventID,
+						Event:         event,
+						Payload:       payload,
+						Status:        models.WebhookDeliveryPending,
+						NextAttemptAt: now,
+					})
+				}
+			}
+		}
+
+		if len(deliveries) > 0 {
+			if err := tx.CreateInBatches(deliveries, 100).Error; err != nil {
+				return err
+			}
+		}
+		queued = len(deliveries)
+
+		return tx.Model(&cursor).Update("sequence", entries[len(entries)-1].Sequence).Error
+	})
+	return queued, more, err
+}
+
+// send posts one delivery and returns the response status, if any.
+func (d *WebhookDispatcher) send(webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookSendTimeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "OpenDataUG-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.Number)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhookErrorBodyLimit))
+	return resp.StatusCode, nil
+}
+
+// deliverNext sends the oldest due delivery, if any. It reports whether a
+// delivery was found.
+//
+// The delivery is claimed with SKIP LOCKED like the email outbox, but the
+// claim only pushes next_attempt_at out by a lease and commits, so no lock
+// is held while the receiver is slow to answer. If the process dies before
+// the result is recorded, the delivery comes due again once the lease runs
+// out.
+func (d *WebhookDispatcher) deliverNext(now time.Time) (bool, error) {
+	delivery, webhook, err := d.claim(now)
+	if err != nil || delivery == nil {
+		return delivery != nil, err
+	}
+	if webhook == nil {
+		return true, nil
+	}
+
+	status, sendErr := d.send(webhook, delivery)
+	updates := map[string]interface{}{"response_status": status}
+	switch {
+	case sendErr == nil:
+		deliveredAt := time.Now()
+		updates["status"] = models.WebhookDeliveryDelivered
+		updates["delivered_at"] = &deliveredAt
+		updates["last_error"] = ""
+	case delivery.Attempts >= WebhookMaxAttempts:
+		log.Printf("webhooks: giving up on %s to %s after %d attempts: %v", delivery.Number, webhook.URL, delivery.Attempts, sendErr)
+		updates["status"] = models.WebhookDeliveryFailed
+		updates["last_error"] = sendErr.Error()
+	default:
+		updates["next_attempt_at"] = time.Now().Add(webhookRetryDelay(delivery.Attempts))
+		updates["last_error"] = sendErr.Error()
+	}
+
+	// A send that outlived its lease may have been picked up again; the
+	// attempt count tells the two apart and the later attempt wins.
+	return true, d.db.Model(&models.WebhookDelivery{}).
+		Where("number = ? AND attempts = ?", delivery.Number, delivery.Attempts).
+		Updates(updates).Error
+}
+
+// claim takes the oldest due delivery and counts the attempt. It returns a
+// nil delivery when none is due, and a nil webhook when the delivery was
+// failed instead because its webhook is gone or disabled.
+func (d *WebhookDispatcher) claim(now time.Time) (*models.WebhookDelivery, *models.Webhook, error) {
+	var claimed *models.WebhookDelivery
+	var target *models.Webhook
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		var delivery models.WebhookDelivery
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+			Order("next_attempt_at").
+			First(&delivery).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		claimed = &delivery
+
+		delivery.Attempts++
+		updates := map[string]interface{}{"attempts": delivery.Attempts}
+
+		var webhook models.Webhook
+		if err := tx.Where("number = ?", delivery.WebhookNumber).First(&webhook).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			updates["status"] = models.WebhookDeliveryFailed
+			updates["last_error"] = "webhook was deleted"
+			return tx.Model(&delivery).Updates(updates).Error
+		}
+		if !webhook.Active {
+			updates["status"] = models.WebhookDeliveryFailed
+			updates["last_error"] = "webhook is disabled"
+			return tx.Model(&delivery).Updates(updates).Error
+		}
+
+		target = &webhook
+		updates["next_attempt_at"] = now.Add(webhookClaimLease)
+		return tx.Model(&delivery).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return claimed, target, nil
+}
+
+// Flush sends every delivery that is due at now and returns how many it
+// attempted. Deliveries go out on several workers, so one slow receiver
+// does not hold up the rest.
+func (d *WebhookDispatcher) Flush(now time.Time) (int, error) {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		attempted int
+		firstErr  error
+	)
+	for range webhookFlushWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				found, err := d.deliverNext(now)
+				mu.Lock()
+				if found {
+					attempted++
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				stop := err != nil || !found || firstErr != nil
+				mu.Unlock()
+				if stop {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return attempted, firstErr
+}
+
+// Run polls the audit log for new events and sends due deliveries until
+// stop is closed. A round already under way finishes first; the cursor and
+// pending deliveries are stored, so nothing is lost across a restart.
+func (d *WebhookDispatcher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		if _, err := d.Dispatch(); err != nil {
+			log.Printf("webhooks: %v", err)
+		}
+		if _, err := d.Flush(time.Now()); err != nil {
+			log.Printf("webhooks: %v", err)
+		}
+	}
+}
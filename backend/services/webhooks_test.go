@@ -0,0 +1,403 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"opendataug.org/commons"
+	"opendataug.org/database/dbtest"
+	"opendataug.org/models"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func TestSignWebhookPayload(t *testing.T) {
+	at := time.Date(2026, 10, 7, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"event":"district.renamed"}`)
+
+	tests := []struct {
+		name   string
+		secret string
+		at     time.Time
+		body   []byte
+		want   string
+	}{
+		{
+			name:   "known signature",
+			secret: testWebhookSecret,
+			at:     at,
+			body:   body,
+			want:   "t=1791374400,v1=556c750584e07151deca879800b4777218d929a1dae69889f5250d781c01e800",
+		},
+		{
+			name:   "sub-second time is dropped",
+			secret: testWebhookSecret,
+			at:     at.Add(999 * time.Millisecond),
+			body:   body,
+			want:   "t=1791374400,v1=556c750584e07151deca879800b4777218d929a1dae69889f5250d781c01e800",
+		},
+		{
+			name:   "empty secret and body",
+			secret: "",
+			at:     at,
+			body:   nil,
+			want:   "t=1791374400,v1=ba12e6ecfc6e04cc2a8cf481caeb04e2228a9cd6a90370ff8857533cce2030bd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhookPayload(tt.secret, tt.at, tt.body); got != tt.want {
+				t.Errorf("SignWebhookPayload = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	signature := SignWebhookPayload(testWebhookSecret, at, body)
+	changes := map[string]string{
+		"secret":    SignWebhookPayload("whsec_other", at, body),
+		"timestamp": SignWebhookPayload(testWebhookSecret, at.Add(time.Second), body),
+		"body":      SignWebhookPayload(testWebhookSecret, at, []byte(`{"event":"district.moved"}`)),
+	}
+	for name, changed := range changes {
+		if changed == signature {
+			t.Errorf("changing the %s does not change the signature", name)
+		}
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, webhookBaseDelay},
+		{1, webhookBaseDelay},
+		{2, 30 * time.Second},
+		{3, time.Minute},
+		{8, 32 * time.Minute},
+		{9, webhookMaxDelay},
+		{WebhookMaxAttempts, webhookMaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestCheckWebhookAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"203.0.113.10:443", true},
+		{"[2001:db8::1]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"10.0.0.5:443", false},
+		{"172.16.0.1:443", false},
+		{"192.168.1.1:443", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:443", false},
+		{"[fd00::1]:443", false},
+		{"0.0.0.0:80", false},
+		{"not-an-address", false},
+	}
+
+	for _, tt := range tests {
+		err := checkWebhookAddress(tt.address)
+		if (err == nil) != tt.allowed {
+			t.Errorf("checkWebhookAddress(%s) = %v, want allowed %v", tt.address, err, tt.allowed)
+		}
+	}
+}
+
+// verifyWebhookSignature checks a delivery the way receivers are told to:
+// recompute the HMAC over "<t>.<body>" and reject stale timestamps.
+func verifyWebhookSignature(secret, header string, body []byte, now time.Time) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("bad timestamp")
+	}
+	if now.Sub(time.Unix(seconds, 0)).Abs() > 5*time.Minute {
+		return errors.New("stale timestamp")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, mac.Sum(nil)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// webhookReceiver records deliveries and answers with the next scripted
+// status, then 200 once the script runs out.
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	received []*http.Request
+	bodies   [][]byte
+	errors   []error
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+
+	r := &webhookReceiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.received = append(r.received, req)
+		r.bodies = append(r.bodies, body)
+		r.errors = append(r.errors, verifyWebhookSignature(testWebhookSecret, req.Header.Get(WebhookSignatureHeader), body, time.Now()))
+
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		if status >= 300 && status < 400 {
+			w.Header().Set("Location", r.URL+"/elsewhere")
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, "receiver says "+strconv.Itoa(status))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.received)
+}
+
+func TestWebhookSend(t *testing.T) {
+	dispatcher := NewWebhookDispatcher(nil, true)
+	payload := `{"event":"district.renamed","data":{"number":"d1"}}`
+
+	tests := []struct {
+		name       string
+		status     int
+		wantErr    string
+		wantStatus int
+	}{
+		{name: "delivered", status: http.StatusOK, wantStatus: http.StatusOK},
+		{name: "accepted", status: http.StatusAccepted, wantStatus: http.StatusAccepted},
+		{name: "server error", status: http.StatusServiceUnavailable, wantErr: "endpoint responded 503: receiver says 503", wantStatus: http.StatusServiceUnavailable},
+		{name: "client error", status: http.StatusGone, wantErr: "endpoint responded 410", wantStatus: http.StatusGone},
+		{name: "redirect is not followed", status: http.StatusFound, wantErr: "endpoint responded 302", wantStatus: http.StatusFound},
+		{name: "permanent redirect is not followed", status: http.StatusPermanentRedirect, wantErr: "endpoint responded 308", wantStatus: http.StatusPermanentRedirect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := newWebhookReceiver(t, tt.status)
+			webhook := &models.Webhook{URL: receiver.URL + "/hook", Secret: testWebhookSecret}
+			delivery := &models.WebhookDelivery{Number: "delivery-1", Event: "district.renamed", Payload: payload}
+
+			status, err := dispatcher.send(webhook, delivery)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("send = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("send = %v, want error containing %q", err, tt.wantErr)
+			}
+
+			if receiver.count() != 1 {
+				t.Fatalf("receiver got %d requests, want 1 and no redirect follow-up", receiver.count())
+			}
+			req, body := receiver.received[0], receiver.bodies[0]
+			if req.URL.Path != "/hook" || string(body) != payload {
+				t.Errorf("received %s %q", req.URL.Path, body)
+			}
+			if err := receiver.errors[0]; err != nil {
+				t.Errorf("receiver rejected the signature: %v", err)
+			}
+			if req.Header.Get(WebhookEventHeader) != "district.renamed" || req.Header.Get(WebhookDeliveryHeader) != "delivery-1" {
+				t.Errorf("headers = %v", req.Header)
+			}
+		})
+	}
+}
+
+func TestWebhookSignatureRejectedWithWrongSecret(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	webhook := &models.Webhook{URL: receiver.URL, Secret: "whsec_rotated"}
+	if _, err := NewWebhookDispatcher(nil, true).send(webhook, &models.WebhookDelivery{Number: "d", Event: "ping", Payload: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+	if receiver.errors[0] == nil {
+		t.Error("a payload signed with another secret verified")
+	}
+}
+
+func TestWebhookPrivateAddressBlocked(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	webhook := &models.Webhook{URL: receiver.URL, Secret: testWebhookSecret}
+	delivery := &models.WebhookDelivery{Number: "d", Event: "ping", Payload: "{}"}
+
+	_, err := NewWebhookDispatcher(nil, false).send(webhook, delivery)
+	if !errors.Is(err, errWebhookPrivateAddress) {
+		t.Errorf("send to %s = %v, want the private address error", receiver.URL, err)
+	}
+	if receiver.count() != 0 {
+		t.Error("the request reached a loopback receiver")
+	}
+
+	// A name resolving to loopback is caught at dial time too.
+	webhook.URL = strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)
+	if _, err := NewWebhookDispatcher(nil, false).send(webhook, delivery); !errors.Is(err, errWebhookPrivateAddress) {
+		t.Errorf("send to %s = %v, want the private address error", webhook.URL, err)
+	}
+
+	if _, err := NewWebhookDispatcher(nil, true).send(webhook, delivery); err != nil {
+		t.Errorf("send with private networks allowed = %v", err)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	db := dbtest.Open(t)
+	receiver := newWebhookReceiver(t, http.StatusServiceUnavailable, http.StatusInternalServerError)
+	dispatcher := NewWebhookDispatcher(db.DB, true)
+
+	webhook := models.Webhook{Number: commons.UUIDGenerator(), UserNumber: commons.UUIDGenerator(), URL: receiver.URL, Events: "*", Secret: testWebhookSecret, Active: true}
+	if err := db.DB.Create(&webhook).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	delivery := models.WebhookDelivery{Number: commons.UUIDGenerator(), WebhookNumber: webhook.Number, EventID: commons.UUIDGenerator(), Event: "district.renamed", Payload: "{}", Status: models.WebhookDeliveryPending, NextAttemptAt: now}
+	if err := db.DB.Create(&delivery).Error; err != nil {
+		t.Fatal(err)
+	}
+	load := func() models.WebhookDelivery {
+		var stored models.WebhookDelivery
+		if err := db.DB.Where("number = ?", delivery.Number).First(&stored).Error; err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+	flush := func(at time.Time) int {
+		attempted, err := dispatcher.Flush(at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return attempted
+	}
+
+	if flush(now) != 1 {
+		t.Fatal("due delivery not attempted")
+	}
+	first := load()
+	if first.Status != models.WebhookDeliveryPending || first.Attempts != 1 || first.ResponseStatus != http.StatusServiceUnavailable {
+		t.Fatalf("after a 503: %+v", first)
+	}
+	if wait := first.NextAttemptAt.Sub(now); wait < webhookRetryDelay(1) || wait > webhookRetryDelay(1)+time.Minute {
+		t.Errorf("first retry in %s, want about %s", wait, webhookRetryDelay(1))
+	}
+
+	// Nothing is due until the backoff has passed.
+	if flush(now) != 0 {
+		t.Error("delivery retried before its backoff")
+	}
+
+	if flush(first.NextAttemptAt) != 1 {
+		t.Fatal("retry not attempted once due")
+	}
+	second := load()
+	if second.Attempts != 2 || second.NextAttemptAt.Sub(first.NextAttemptAt) < webhookRetryDelay(2) {
+		t.Errorf("second retry = %+v, want the backoff doubled", second)
+	}
+
+	flush(second.NextAttemptAt)
+	if done := load(); done.Status != models.WebhookDeliveryDelivered || done.Attempts != 3 || done.DeliveredAt == nil || done.LastError != "" {
+		t.Errorf("after recovering: %+v", done)
+	}
+
+	// The last allowed attempt marks the delivery failed.
+	failing := newWebhookReceiver(t, http.StatusInternalServerError)
+	if err := db.DB.Model(&webhook).Update("url", failing.URL).Error; err != nil {
+		t.Fatal(err)
+	}
+	last := models.WebhookDelivery{Number: commons.UUIDGenerator(), WebhookNumber: webhook.Number, EventID: commons.UUIDGenerator(), Event: "district.renamed", Payload: "{}", Status: models.WebhookDeliveryPending, Attempts: WebhookMaxAttempts - 1, NextAttemptAt: now}
+	if err := db.DB.Create(&last).Error; err != nil {
+		t.Fatal(err)
+	}
+	flush(now.Add(time.Hour))
+	var failed models.WebhookDelivery
+	if err := db.DB.Where("number = ?", last.Number).First(&failed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if failed.Status != models.WebhookDeliveryFailed || failed.Attempts != WebhookMaxAttempts {
+		t.Errorf("after the last attempt: %+v", failed)
+	}
+}
+
+func TestWebhookClaimLease(t *testing.T) {
+	db := dbtest.Open(t)
+	dispatcher := NewWebhookDispatcher(db.DB, true)
+
+	webhook := models.Webhook{Number: commons.UUIDGenerator(), UserNumber: commons.UUIDGenerator(), URL: "http://127.0.0.1:1", Events: "*", Secret: testWebhookSecret, Active: true}
+	if err := db.DB.Create(&webhook).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	delivery := models.WebhookDelivery{Number: commons.UUIDGenerator(), WebhookNumber: webhook.Number, EventID: commons.UUIDGenerator(), Event: "district.renamed", Payload: "{}", Status: models.WebhookDeliveryPending, NextAttemptAt: now}
+	if err := db.DB.Create(&delivery).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, target, err := dispatcher.claim(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed == nil || target == nil || claimed.Number != delivery.Number || claimed.Attempts != 1 {
+		t.Fatalf("claim = %+v, %+v", claimed, target)
+	}
+
+	// A claimed delivery is left alone while it is being sent...
+	if again, _, err := dispatcher.claim(now.Add(webhookClaimLease - time.Second)); err != nil || again != nil {
+		t.Fatalf("claimed twice within the lease: %+v, %v", again, err)
+	}
+
+	// ...and comes due again if the sender never reports back.
+	again, _, err := dispatcher.claim(now.Add(webhookClaimLease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == nil || again.Number != delivery.Number || again.Attempts != 2 {
+		t.Errorf("claim after the lease = %+v, want the abandoned delivery on its second attempt", again)
+	}
+}